@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stuck/consensus"
+)
+
+var (
+	ErrNoReplicas   = errors.New("client has no replica")
+	ErrNotAccepted  = errors.New("no replica accepted the transaction")
+	errNotCommitted = errors.New("not committed")
+)
+
+// How long a single commit request waits on a replica
+const pollWait = time.Second
+
+// Client submits transactions to a cluster of n replicas. Up to f of them
+// may lie, so a transaction goes to f+1 replicas and a commit is trusted
+// once f+1 replicas acknowledge the same one
+type Client struct {
+	urls []string // base URL of every replica, like http://127.0.0.1:8000
+	f    int
+	http *http.Client
+	mu   sync.Mutex // rand is not safe for concurrent use
+	rand *rand.Rand
+}
+
+// Create client of the replicas at urls
+func NewClient(urls []string) (*Client, error) {
+	if len(urls) == 0 {
+		return nil, ErrNoReplicas
+	}
+	return &Client{
+		urls: urls,
+		f:    consensus.MaxFaulty(len(urls)),
+		http: &http.Client{},
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Close idle connections to the replicas
+func (c *Client) Close() {
+	c.http.CloseIdleConnections()
+}
+
+// Submit tx to f+1 random replicas and wait until f+1 replicas acknowledge
+// the same commit, or ctx is done
+func (c *Client) Submit(ctx context.Context, tx []byte) (Commit, error) {
+	if len(c.urls) == 0 {
+		return Commit{}, ErrNoReplicas
+	}
+	d := sha256.Sum256(tx)
+	hash := hex.EncodeToString(d[:])
+
+	c.mu.Lock()
+	targets := c.rand.Perm(len(c.urls))[:c.f+1]
+	c.mu.Unlock()
+	accepted := 0
+	var lastErr error
+	for _, i := range targets {
+		if err := c.post(ctx, c.urls[i], tx); err != nil {
+			lastErr = err
+			continue
+		}
+		accepted++
+	}
+	if accepted == 0 {
+		return Commit{}, fmt.Errorf("%w: %s", ErrNotAccepted, lastErr)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	acks := make(chan Commit, len(c.urls))
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	for _, url := range c.urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			if commit, ok := c.waitCommit(ctx, url, hash); ok {
+				acks <- commit
+			}
+		}(url)
+	}
+
+	votes := make(map[Commit]int)
+	for range c.urls {
+		select {
+		case commit := <-acks:
+			votes[commit]++
+			if votes[commit] >= c.f+1 {
+				return commit, nil
+			}
+		case <-ctx.Done():
+			return Commit{}, ctx.Err()
+		}
+	}
+	return Commit{}, fmt.Errorf("replicas disagree on the commit of %s", hash)
+}
+
+// Poll one replica until it reports the commit, false once ctx is done
+func (c *Client) waitCommit(ctx context.Context, url string, hash string) (Commit, bool) {
+	for {
+		commit, err := c.get(ctx, url, hash)
+		if err == nil {
+			return commit, true
+		}
+		select {
+		case <-ctx.Done():
+			return Commit{}, false
+		default:
+		}
+		if !errors.Is(err, errNotCommitted) {
+			// unreachable replica, try again later
+			select {
+			case <-time.After(pollWait / 10):
+			case <-ctx.Done():
+				return Commit{}, false
+			}
+		}
+	}
+}
+
+func (c *Client) post(ctx context.Context, url string, tx []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url+"/tx", bytes.NewReader(tx))
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, url string, hash string) (Commit, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/tx/%s?wait=%s", url, hash, pollWait), nil)
+	if err != nil {
+		return Commit{}, err
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return Commit{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return Commit{}, errNotCommitted
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Commit{}, responseError(resp)
+	}
+	var commit Commit
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		return Commit{}, err
+	}
+	if commit.Hash != hash {
+		return Commit{}, fmt.Errorf("commit of %s instead of %s", commit.Hash, hash)
+	}
+	return commit, nil
+}
+
+func responseError(resp *http.Response) error {
+	var e errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+		return fmt.Errorf("replica answered %s", resp.Status)
+	}
+	return fmt.Errorf("replica answered %s: %s", resp.Status, e.Error)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stuck/mempool"
+)
+
+const (
+	maxTxBytes = 1 << 20
+	maxWait    = 30 * time.Second
+	maxRecords = 1 << 16 // commits remembered for late subscribers
+)
+
+var ErrBadHash = errors.New("hash must be the hex sha256 of a transaction")
+
+// Where a transaction was committed, replicas acknowledge the same commit
+type Commit struct {
+	Hash     string `json:"hash"`     // hex sha256 of the transaction
+	Height   uint64 `json:"height"`   // epoch or sequence number of its batch
+	Position int    `json:"position"` // index in the batch
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type submitResponse struct {
+	Hash string `json:"hash"`
+}
+
+// Server is the client endpoint of one replica.
+//
+//	POST /tx             submit the request body as a transaction
+//	GET  /tx/<hash>      commit of a transaction, ?wait=2s blocks until it
+//	                     commits or the duration passes
+//
+// The replica reports committed batches with Committed
+type Server struct {
+	submit   func(tx []byte) error
+	mu       sync.Mutex
+	commits  map[string]Commit
+	order    []string // commits oldest first, for eviction
+	waiters  map[string][]chan Commit
+	srv      *http.Server
+	listener net.Listener
+	doneCh   chan struct{}
+}
+
+// Create server handing transactions to submit, usually the Submit of a
+// replica or the Add of its mempool
+func NewServer(submit func(tx []byte) error) *Server {
+	s := &Server{submit: submit}
+	s.commits = make(map[string]Commit)
+	s.waiters = make(map[string][]chan Commit)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tx", s.handleSubmit)
+	mux.HandleFunc("/tx/", s.handleCommit)
+	s.srv = &http.Server{Handler: mux}
+	return s
+}
+
+// Listen on addr and serve in the background
+func (s *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.doneCh = make(chan struct{})
+	go func() {
+		defer close(s.doneCh)
+		s.srv.Serve(listener)
+	}()
+	return nil
+}
+
+// Address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close the listener and all connections, waiting subscribers return
+func (s *Server) Stop() {
+	s.srv.Close()
+	if s.doneCh != nil {
+		<-s.doneCh
+	}
+}
+
+// Record a committed batch and notify subscribers of its transactions
+func (s *Server) Committed(height uint64, txs [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, tx := range txs {
+		d := sha256.Sum256(tx)
+		c := Commit{Hash: hex.EncodeToString(d[:]), Height: height, Position: i}
+		if _, ok := s.commits[c.Hash]; ok {
+			continue
+		}
+		s.commits[c.Hash] = c
+		s.order = append(s.order, c.Hash)
+		for _, ch := range s.waiters[c.Hash] {
+			ch <- c
+		}
+		delete(s.waiters, c.Hash)
+	}
+	for len(s.order) > maxRecords {
+		delete(s.commits, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use POST"})
+		return
+	}
+	tx, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxTxBytes))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: err.Error()})
+		return
+	}
+	if len(tx) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "empty transaction"})
+		return
+	}
+	if err := s.submit(tx); err != nil {
+		status := http.StatusServiceUnavailable
+		switch {
+		case errors.Is(err, mempool.ErrFull):
+			// the replica is busy, the client retries later
+			status = http.StatusTooManyRequests
+			w.Header().Set("Retry-After", "1")
+		case errors.Is(err, mempool.ErrTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+	d := sha256.Sum256(tx)
+	writeJSON(w, http.StatusAccepted, submitResponse{Hash: hex.EncodeToString(d[:])})
+}
+
+func (s *Server) handleCommit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use GET"})
+		return
+	}
+	hash := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/tx/"))
+	if d, err := hex.DecodeString(hash); err != nil || len(d) != sha256.Size {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: ErrBadHash.Error()})
+		return
+	}
+	var wait time.Duration
+	if v := r.URL.Query().Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "wait must be a duration"})
+			return
+		}
+		wait = d
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+
+	s.mu.Lock()
+	c, ok := s.commits[hash]
+	if ok || wait == 0 {
+		s.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "not committed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+		return
+	}
+	ch := make(chan Commit, 1)
+	s.waiters[hash] = append(s.waiters[hash], ch)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case c := <-ch:
+		writeJSON(w, http.StatusOK, c)
+	case <-timer.C:
+		s.unsubscribe(hash, ch)
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not committed"})
+	case <-r.Context().Done():
+		s.unsubscribe(hash, ch)
+	}
+}
+
+func (s *Server) unsubscribe(hash string, ch chan Commit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	waiters := s.waiters[hash]
+	for i, w := range waiters {
+		if w == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(s.waiters, hash)
+	} else {
+		s.waiters[hash] = waiters
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
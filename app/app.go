@@ -0,0 +1,101 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	ErrUnknownPath = errors.New("unknown query path")
+	ErrNoSnapshots = errors.New("application cannot restore snapshots")
+	ErrAppHash     = errors.New("snapshot does not match the app hash")
+)
+
+// Result codes, anything but CodeOK means the transaction had no effect
+const (
+	CodeOK uint32 = iota
+	CodeInvalid
+)
+
+// Ordered batch of transactions, Height counts the batches executed so far
+type Block struct {
+	Height uint64
+	Txs    [][]byte
+}
+
+// Outcome of one transaction, part of the replicated state so it must be
+// deterministic
+type Result struct {
+	Code uint32
+	Data []byte
+}
+
+// Application is the replicated state machine that consensus output is
+// delivered to. Every replica executes the same blocks in the same order,
+// so a deterministic application reaches the same app hash everywhere
+type Application interface {
+	CheckTx(tx []byte) error                        // stateless check before a transaction is proposed
+	ExecuteBlock(block Block) []Result              // execute on pending state, one result per transaction
+	Commit() []byte                                 // make pending state durable and return its app hash
+	Query(path string, data []byte) ([]byte, error) // read committed state
+}
+
+// Applications that can hand over their committed state, so that a
+// lagging replica restores it instead of executing every block
+type Snapshotter interface {
+	Snapshot() []byte              // committed state
+	Restore(snapshot []byte) error // replace committed state, pending state is dropped
+}
+
+// StateMachine feeds the ordered batches of a consensus node to an
+// application, one block per batch, committing after each of them
+type StateMachine struct {
+	app     Application
+	height  uint64
+	appHash []byte
+}
+
+// Create state machine on top of app, nothing executed yet
+func NewStateMachine(app Application) *StateMachine {
+	return &StateMachine{app: app}
+}
+
+// Execute and commit the next batch, return its results and the app hash
+func (sm *StateMachine) Apply(txs [][]byte) ([]Result, []byte) {
+	sm.height++
+	results := sm.app.ExecuteBlock(Block{Height: sm.height, Txs: txs})
+	sm.appHash = sm.app.Commit()
+	return results, sm.appHash
+}
+
+// Replace the state by a snapshot taken at height, the app hash must match
+// or the previous state is put back
+func (sm *StateMachine) Restore(height uint64, snapshot []byte, appHash []byte) error {
+	snapshotter, ok := sm.app.(Snapshotter)
+	if !ok {
+		return ErrNoSnapshots
+	}
+	previous := snapshotter.Snapshot()
+	if err := snapshotter.Restore(snapshot); err != nil {
+		return err
+	}
+	if hash := sm.app.Commit(); !bytes.Equal(hash, appHash) {
+		if err := snapshotter.Restore(previous); err != nil {
+			return err
+		}
+		return ErrAppHash
+	}
+	sm.height = height
+	sm.appHash = appHash
+	return nil
+}
+
+// Number of batches applied
+func (sm *StateMachine) Height() uint64 {
+	return sm.height
+}
+
+// App hash after the last batch, nil before the first
+func (sm *StateMachine) AppHash() []byte {
+	return sm.appHash
+}
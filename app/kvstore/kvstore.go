@@ -0,0 +1,155 @@
+package kvstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/stuck/app"
+)
+
+var (
+	ErrMalformedTx = errors.New("transaction is not key=value")
+	ErrNotFound    = errors.New("key not found")
+	ErrBadSnapshot = errors.New("malformed snapshot")
+)
+
+// Query path reading the value of the key in data
+const PathKey = "/key"
+
+// KVStore is the in-memory reference application. A transaction
+// "key=value" sets key, the app hash covers every committed pair
+type KVStore struct {
+	mu        sync.RWMutex // Query may run beside consensus
+	committed map[string][]byte
+	pending   map[string][]byte
+}
+
+// Create empty store
+func NewKVStore() *KVStore {
+	return &KVStore{
+		committed: make(map[string][]byte),
+		pending:   make(map[string][]byte),
+	}
+}
+
+// Reject transactions without a key
+func (kv *KVStore) CheckTx(tx []byte) error {
+	_, _, err := parse(tx)
+	return err
+}
+
+// Set the pairs of the block on pending state, malformed transactions are
+// skipped
+func (kv *KVStore) ExecuteBlock(block app.Block) []app.Result {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	results := make([]app.Result, len(block.Txs))
+	for i, tx := range block.Txs {
+		key, value, err := parse(tx)
+		if err != nil {
+			results[i] = app.Result{Code: app.CodeInvalid, Data: []byte(err.Error())}
+			continue
+		}
+		kv.pending[string(key)] = value
+		results[i] = app.Result{Code: app.CodeOK}
+	}
+	return results
+}
+
+// Apply pending pairs and hash the whole store, keys in order
+func (kv *KVStore) Commit() []byte {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	for key, value := range kv.pending {
+		kv.committed[key] = value
+	}
+	kv.pending = make(map[string][]byte)
+	h := sha256.Sum256(kv.encode())
+	return h[:]
+}
+
+// Committed pairs, keys in order, hashing the snapshot gives the app hash
+func (kv *KVStore) Snapshot() []byte {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.encode()
+}
+
+// Replace the whole store by a snapshot, pending pairs are dropped
+func (kv *KVStore) Restore(snapshot []byte) error {
+	committed := make(map[string][]byte)
+	for len(snapshot) > 0 {
+		key, rest, err := readField(snapshot)
+		if err != nil {
+			return err
+		}
+		value, rest, err := readField(rest)
+		if err != nil {
+			return err
+		}
+		committed[string(key)] = value
+		snapshot = rest
+	}
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.committed = committed
+	kv.pending = make(map[string][]byte)
+	return nil
+}
+
+// Length prefixed keys and values, keys in order
+func (kv *KVStore) encode() []byte {
+	keys := make([]string, 0, len(kv.committed))
+	for key := range kv.committed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	var size [8]byte
+	for _, key := range keys {
+		binary.BigEndian.PutUint64(size[:], uint64(len(key)))
+		buf.Write(size[:])
+		buf.WriteString(key)
+		binary.BigEndian.PutUint64(size[:], uint64(len(kv.committed[key])))
+		buf.Write(size[:])
+		buf.Write(kv.committed[key])
+	}
+	return buf.Bytes()
+}
+
+// Committed value of a key under PathKey
+func (kv *KVStore) Query(path string, data []byte) ([]byte, error) {
+	if path != PathKey {
+		return nil, app.ErrUnknownPath
+	}
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	value, ok := kv.committed[string(data)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+func parse(tx []byte) ([]byte, []byte, error) {
+	i := bytes.IndexByte(tx, '=')
+	if i <= 0 {
+		return nil, nil, ErrMalformedTx
+	}
+	return tx[:i], tx[i+1:], nil
+}
+
+func readField(b []byte) ([]byte, []byte, error) {
+	if len(b) < 8 {
+		return nil, nil, ErrBadSnapshot
+	}
+	size := binary.BigEndian.Uint64(b[:8])
+	if size > uint64(len(b)-8) {
+		return nil, nil, ErrBadSnapshot
+	}
+	return b[8 : 8+size], b[8+size:], nil
+}
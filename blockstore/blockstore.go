@@ -0,0 +1,213 @@
+package blockstore
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrNotFound           = errors.New("block not found")
+	ErrInvalidCertificate = errors.New("invalid block certificate")
+	ErrHeight             = errors.New("block does not follow the last one")
+)
+
+const maxRange = 1024 // blocks returned by one range read
+
+// Committed batch and the threshold signature certifying it
+type Block struct {
+	Height      uint64
+	Txs         [][]byte
+	Certificate []byte
+}
+
+// Position of a committed transaction
+type Location struct {
+	Height   uint64
+	Position int
+}
+
+// Store keeps committed blocks in a write-ahead log, one record per block
+// starting at height 1, so that block h is record h. Transactions are
+// indexed by their sha256, the first commit of a transaction wins.
+// Opening a store verifies the certificate of every block against the
+// group public key of the crypto provider, a Certifier produces the
+// certificates of the blocks the protocols commit
+type Store struct {
+	mu     sync.RWMutex
+	log    *wal.WAL
+	cp     crypto.CryptoProvider
+	height uint64
+	txs    map[[sha256.Size]byte]Location
+}
+
+// Open the store in dir and check every stored block
+func Open(dir string, cp crypto.CryptoProvider, opts wal.Options) (*Store, error) {
+	log, err := wal.Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{log: log, cp: cp, txs: make(map[[sha256.Size]byte]Location)}
+	err = log.Replay(func(index uint64, data []byte) error {
+		b, err := decode(data)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", index, err)
+		}
+		if b.Height != index {
+			return fmt.Errorf("%w: record %d holds height %d", wal.ErrCorrupt, index, b.Height)
+		}
+		if !s.verify(b) {
+			return fmt.Errorf("%w at height %d", ErrInvalidCertificate, b.Height)
+		}
+		s.index(b)
+		return nil
+	})
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append the next block once its certificate is checked
+func (s *Store) Append(b Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b.Height != s.height+1 {
+		return fmt.Errorf("%w, height %d after %d", ErrHeight, b.Height, s.height)
+	}
+	if !s.verify(b) {
+		return fmt.Errorf("%w at height %d", ErrInvalidCertificate, b.Height)
+	}
+	data, err := proto.Marshal(&pb.StoredBlock{Height: b.Height, Txs: b.Txs, Certificate: b.Certificate})
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Append(data); err != nil {
+		return err
+	}
+	s.index(b)
+	return nil
+}
+
+// Height of the last block, 0 if the store is empty
+func (s *Store) Height() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.height
+}
+
+// Block at height
+func (s *Store) Block(height uint64) (Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.read(height)
+}
+
+// Blocks from height from to height to, both included, at most maxRange
+// of them. The range ends at the last block
+func (s *Store) Range(from, to uint64) ([]Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if from == 0 || from > s.height || to < from {
+		return nil, ErrNotFound
+	}
+	if to > s.height {
+		to = s.height
+	}
+	if to-from >= maxRange {
+		to = from + maxRange - 1
+	}
+	blocks := make([]Block, 0, to-from+1)
+	for h := from; h <= to; h++ {
+		b, err := s.read(h)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// Where the transaction with this sha256 was committed
+func (s *Store) Tx(hash []byte) (Location, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var key [sha256.Size]byte
+	if len(hash) != len(key) {
+		return Location{}, ErrNotFound
+	}
+	copy(key[:], hash)
+	loc, ok := s.txs[key]
+	if !ok {
+		return Location{}, ErrNotFound
+	}
+	return loc, nil
+}
+
+// Close the underlying log
+func (s *Store) Close() error {
+	return s.log.Close()
+}
+
+func (s *Store) read(height uint64) (Block, error) {
+	if height == 0 || height > s.height {
+		return Block{}, ErrNotFound
+	}
+	data, err := s.log.Read(height)
+	if err != nil {
+		return Block{}, err
+	}
+	return decode(data)
+}
+
+func (s *Store) index(b Block) {
+	s.height = b.Height
+	for i, tx := range b.Txs {
+		key := sha256.Sum256(tx)
+		if _, ok := s.txs[key]; !ok {
+			s.txs[key] = Location{Height: b.Height, Position: i}
+		}
+	}
+}
+
+func (s *Store) verify(b Block) bool {
+	return s.cp.VerifySignature(CertificateData(b.Height, b.Txs), b.Certificate)
+}
+
+func decode(data []byte) (Block, error) {
+	stored := &pb.StoredBlock{}
+	if err := proto.Unmarshal(data, stored); err != nil {
+		return Block{}, err
+	}
+	return Block{Height: stored.Height, Txs: stored.Txs, Certificate: stored.Certificate}, nil
+}
+
+// Data a block certificate signs, the height and the digest of the
+// transactions
+func CertificateData(height uint64, txs [][]byte) []byte {
+	return certificateData(height, Digest(txs))
+}
+
+func certificateData(height uint64, digest []byte) []byte {
+	return []byte(fmt.Sprintf("block|%d|%x", height, digest))
+}
+
+// Digest of the length prefixed transactions of a block
+func Digest(txs [][]byte) []byte {
+	h := sha256.New()
+	var size [8]byte
+	for _, tx := range txs {
+		binary.BigEndian.PutUint64(size[:], uint64(len(tx)))
+		h.Write(size[:])
+		h.Write(tx)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,220 @@
+package blockstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+)
+
+var (
+	ErrInvalidSender = errors.New("invalid sender")
+	ErrInvalidShare  = errors.New("invalid block share")
+)
+
+const (
+	maxPending   = 4 * maxRange // committed blocks waiting for a certificate
+	retryCommits = 16           // commits before asking for missing blocks again
+)
+
+// Certifier runs the share round that certifies committed blocks and
+// appends them to the store in height order. Each node signs the height
+// and digest of every block it commits and broadcasts the share, f+1
+// shares on the same digest include a correct node and combine into the
+// certificate. The protocols commit the same blocks everywhere, so every
+// node certifies on its own and no certificate is gossiped.
+//
+// A node that skips heights, restoring a snapshot or committing too far
+// ahead of its store, asks its peers for the certified blocks it misses
+// instead, their certificates need no agreement. Like the protocol
+// instances it runs on the goroutine of its owner
+type Certifier struct {
+	n         int
+	f         int
+	id        int
+	net       consensus.Network
+	cp        crypto.CryptoProvider
+	store     *Store
+	pending   map[uint64][][]byte                  // height -> txs committed here
+	signed    map[uint64]map[int]bool              // height -> senders
+	shares    map[uint64]map[string]map[int][]byte // height -> digest -> sender -> share
+	asked     bool
+	requested uint64 // store height we last asked at
+	askedAt   uint64 // commit we last asked at
+}
+
+// Create certifier appending to store
+func NewCertifier(n, id int, net consensus.Network, cp crypto.CryptoProvider, store *Store) *Certifier {
+	return &Certifier{
+		n:       n,
+		f:       consensus.MaxFaulty(n),
+		id:      id,
+		net:     net,
+		cp:      cp,
+		store:   store,
+		pending: make(map[uint64][][]byte),
+		signed:  make(map[uint64]map[int]bool),
+		shares:  make(map[uint64]map[string]map[int][]byte),
+	}
+}
+
+// Sign a committed block, heights must follow each other unless the node
+// skipped some
+func (c *Certifier) Commit(height uint64, txs [][]byte) error {
+	if height <= c.store.Height() {
+		return nil
+	}
+	if height > c.store.Height()+maxPending {
+		c.request(height)
+		return nil
+	}
+	c.pending[height] = txs
+	digest := Digest(txs)
+	c.net.Broadcast(&pb.BlockMsg{
+		Type:   pb.BlockType_BlockShare,
+		Sender: int64(c.id),
+		Height: height,
+		Digest: digest,
+		Share:  c.cp.ComputeShare(certificateData(height, digest)),
+	})
+	if _, ok := c.pending[c.store.Height()+1]; !ok {
+		c.request(height)
+	}
+	// the shares of the peers may have come first
+	return c.advance()
+}
+
+func (c *Certifier) HandleMessage(msg *pb.BlockMsg) error {
+	sender := int(msg.Sender)
+	if sender < 1 || sender > c.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	switch msg.Type {
+	case pb.BlockType_BlockShare:
+		return c.handleShare(sender, msg)
+	case pb.BlockType_BlockRequest:
+		c.handleRequest(sender, msg)
+		return nil
+	case pb.BlockType_BlockRange:
+		return c.handleRange(sender, msg)
+	}
+	return fmt.Errorf("unknown block message type %d", msg.Type)
+}
+
+// Shares too far ahead are dropped, the block is fetched once certified
+func (c *Certifier) handleShare(sender int, msg *pb.BlockMsg) error {
+	if msg.Height <= c.store.Height() || msg.Height > c.store.Height()+maxPending {
+		return nil
+	}
+	if c.signed[msg.Height][sender] {
+		return nil
+	}
+	if !c.cp.VerifyShare(certificateData(msg.Height, msg.Digest), msg.Share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	if c.signed[msg.Height] == nil {
+		c.signed[msg.Height] = make(map[int]bool)
+		c.shares[msg.Height] = make(map[string]map[int][]byte)
+	}
+	c.signed[msg.Height][sender] = true
+	digest := string(msg.Digest)
+	if c.shares[msg.Height][digest] == nil {
+		c.shares[msg.Height][digest] = make(map[int][]byte)
+	}
+	c.shares[msg.Height][digest][sender] = msg.Share
+	return c.advance()
+}
+
+// Send the certified blocks after the requested height
+func (c *Certifier) handleRequest(sender int, msg *pb.BlockMsg) {
+	if sender == c.id {
+		return
+	}
+	blocks, err := c.store.Range(msg.Height+1, msg.Height+maxRange)
+	if err != nil {
+		return
+	}
+	resp := &pb.BlockMsg{Type: pb.BlockType_BlockRange, Sender: int64(c.id), Height: msg.Height}
+	for _, b := range blocks {
+		resp.Blocks = append(resp.Blocks, &pb.StoredBlock{Height: b.Height, Txs: b.Txs, Certificate: b.Certificate})
+	}
+	c.net.SendToPeer(sender, resp)
+}
+
+// Append the fetched blocks that follow the store, the store checks
+// their certificates
+func (c *Certifier) handleRange(sender int, msg *pb.BlockMsg) error {
+	if sender == c.id {
+		return nil
+	}
+	for _, b := range msg.Blocks {
+		if b.Height <= c.store.Height() {
+			continue
+		}
+		if err := c.store.Append(Block{Height: b.Height, Txs: b.Txs, Certificate: b.Certificate}); err != nil {
+			c.collect()
+			return fmt.Errorf("%w from %d", err, sender)
+		}
+	}
+	c.collect()
+	if len(msg.Blocks) == maxRange {
+		c.request(c.askedAt)
+	}
+	return c.advance()
+}
+
+// Append the next blocks as long as their shares combine
+func (c *Certifier) advance() error {
+	for {
+		height := c.store.Height() + 1
+		txs, ok := c.pending[height]
+		if !ok {
+			return nil
+		}
+		digest := Digest(txs)
+		if len(c.shares[height][string(digest)]) < c.f+1 {
+			return nil
+		}
+		shares := make([][]byte, 0, len(c.shares[height][string(digest)]))
+		for _, share := range c.shares[height][string(digest)] {
+			shares = append(shares, share)
+		}
+		// f+1 shares include a correct node, the provider may need more
+		certificate := c.cp.Combine(certificateData(height, digest), shares)
+		if certificate == nil {
+			return nil
+		}
+		if err := c.store.Append(Block{Height: height, Txs: txs, Certificate: certificate}); err != nil {
+			return err
+		}
+		c.collect()
+	}
+}
+
+// Ask peers for the certified blocks after the store, again once the
+// store moved or retryCommits blocks were committed meanwhile
+func (c *Certifier) request(height uint64) {
+	if c.asked && c.requested == c.store.Height() && height < c.askedAt+retryCommits {
+		return
+	}
+	c.asked, c.requested, c.askedAt = true, c.store.Height(), height
+	c.net.Broadcast(&pb.BlockMsg{Type: pb.BlockType_BlockRequest, Sender: int64(c.id), Height: c.store.Height()})
+}
+
+// Forget what the store holds already
+func (c *Certifier) collect() {
+	stored := c.store.Height()
+	for height := range c.pending {
+		if height <= stored {
+			delete(c.pending, height)
+		}
+	}
+	for height := range c.signed {
+		if height <= stored {
+			delete(c.signed, height)
+			delete(c.shares, height)
+		}
+	}
+}
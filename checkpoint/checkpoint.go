@@ -0,0 +1,255 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+// Intervals above the latest certificate shares are collected for, a node
+// further behind learns the certificates that are gossiped
+const shareWindow = 4
+
+var (
+	ErrInvalidSender      = errors.New("invalid sender")
+	ErrInvalidShare       = errors.New("invalid checkpoint share")
+	ErrInvalidCertificate = errors.New("invalid checkpoint certificate")
+)
+
+// State hash at a height and the threshold signature certifying it
+type Checkpoint struct {
+	Height      uint64 `json:"height"`
+	StateHash   []byte `json:"state_hash"`
+	Certificate []byte `json:"certificate"`
+}
+
+// Data a checkpoint certificate signs
+func Data(height uint64, stateHash []byte) []byte {
+	return []byte(fmt.Sprintf("checkpoint|%d|%x", height, stateHash))
+}
+
+// Check a checkpoint against the group public key of cp
+func Verify(cp crypto.CryptoProvider, c Checkpoint) bool {
+	return cp.VerifySignature(Data(c.Height, c.StateHash), c.Certificate)
+}
+
+// Checkpointer certifies the state every interval heights. Each node
+// signs (height, state hash) and broadcasts the share, whoever collects
+// f+1 shares on the same hash combines them into the certificate and
+// gossips it, and a node sending shares for heights below the latest
+// certificate gets the certificate back. Certificates are stored in a
+// log if there is one, the latest is loaded again on restart.
+//
+// A node does not need to reach a height to learn its certificate, so a
+// lagging node finds out how far behind it is. Like the protocol
+// instances it runs on the goroutine of its owner
+type Checkpointer struct {
+	n        int
+	f        int
+	id       int
+	interval uint64
+	net      consensus.Network
+	cp       crypto.CryptoProvider
+	log      *wal.WAL
+	latest   *Checkpoint
+	signed   map[uint64]map[int]bool              // height -> senders
+	shares   map[uint64]map[string]map[int][]byte // height -> state hash -> sender -> share
+}
+
+// Create checkpointer storing certificates in log, nil keeps nothing
+func NewCheckpointer(n, id int, interval uint64, net consensus.Network, cp crypto.CryptoProvider, log *wal.WAL) *Checkpointer {
+	if interval == 0 {
+		interval = 16
+	}
+	c := &Checkpointer{
+		n:        n,
+		f:        consensus.MaxFaulty(n),
+		id:       id,
+		interval: interval,
+		net:      net,
+		cp:       cp,
+		log:      log,
+		signed:   make(map[uint64]map[int]bool),
+		shares:   make(map[uint64]map[string]map[int][]byte),
+	}
+	if err := c.recover(); err != nil {
+		fmt.Printf("[Node:%d] checkpoint recover error due to : %s.\n", id, err)
+	}
+	return c
+}
+
+// Load the latest stored certificate, every one is verified again
+func (c *Checkpointer) recover() error {
+	if c.log == nil {
+		return nil
+	}
+	return c.log.Replay(func(index uint64, data []byte) error {
+		msg := &pb.CheckpointMsg{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return fmt.Errorf("checkpoint %d: %w", index, err)
+		}
+		stored := Checkpoint{Height: msg.Height, StateHash: msg.StateHash, Certificate: msg.Certificate}
+		if !Verify(c.cp, stored) {
+			return fmt.Errorf("%w at height %d", ErrInvalidCertificate, stored.Height)
+		}
+		if c.latest == nil || stored.Height > c.latest.Height {
+			c.latest = &stored
+		}
+		return nil
+	})
+}
+
+// Heights between checkpoints
+func (c *Checkpointer) Interval() uint64 {
+	return c.interval
+}
+
+// Latest certified checkpoint, nil if there is none yet
+func (c *Checkpointer) Latest() *Checkpoint {
+	return c.latest
+}
+
+// Sign the state reached at height if a checkpoint is due
+func (c *Checkpointer) Record(height uint64, stateHash []byte) {
+	if height == 0 || height%c.interval != 0 || (c.latest != nil && height <= c.latest.Height) {
+		return
+	}
+	c.net.Broadcast(&pb.CheckpointMsg{
+		Type:      pb.CheckpointType_CheckpointShare,
+		Sender:    int64(c.id),
+		Height:    height,
+		StateHash: stateHash,
+		Share:     c.cp.ComputeShare(Data(height, stateHash)),
+	})
+}
+
+func (c *Checkpointer) HandleMessage(msg *pb.CheckpointMsg) error {
+	sender := int(msg.Sender)
+	if sender < 1 || sender > c.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	switch msg.Type {
+	case pb.CheckpointType_CheckpointShare:
+		return c.handleShare(sender, msg)
+	case pb.CheckpointType_CheckpointCert:
+		err := c.Adopt(Checkpoint{Height: msg.Height, StateHash: msg.StateHash, Certificate: msg.Certificate})
+		if err != nil {
+			return fmt.Errorf("%w from %d", err, sender)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown checkpoint message type %d", msg.Type)
+}
+
+func (c *Checkpointer) handleShare(sender int, msg *pb.CheckpointMsg) error {
+	if msg.Height == 0 || msg.Height%c.interval != 0 {
+		return fmt.Errorf("%w from %d, height %d", ErrInvalidShare, sender, msg.Height)
+	}
+	if c.latest != nil && msg.Height <= c.latest.Height {
+		// the sender is behind, tell it what is certified
+		if sender != c.id && msg.Height < c.latest.Height {
+			c.net.SendToPeer(sender, c.certMsg())
+		}
+		return nil
+	}
+	if msg.Height > c.stable()+shareWindow*c.interval {
+		return nil
+	}
+	if c.signed[msg.Height][sender] {
+		return nil
+	}
+	if !c.cp.VerifyShare(Data(msg.Height, msg.StateHash), msg.Share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	if c.signed[msg.Height] == nil {
+		c.signed[msg.Height] = make(map[int]bool)
+		c.shares[msg.Height] = make(map[string]map[int][]byte)
+	}
+	c.signed[msg.Height][sender] = true
+	hash := string(msg.StateHash)
+	if c.shares[msg.Height][hash] == nil {
+		c.shares[msg.Height][hash] = make(map[int][]byte)
+	}
+	c.shares[msg.Height][hash][sender] = msg.Share
+
+	// f+1 shares include a correct node, the provider may need more. Every
+	// sender is counted once above, so a failed combine is only tried
+	// again with a new share
+	if len(c.shares[msg.Height][hash]) < c.f+1 {
+		return nil
+	}
+	shares := make([][]byte, 0, len(c.shares[msg.Height][hash]))
+	for _, share := range c.shares[msg.Height][hash] {
+		shares = append(shares, share)
+	}
+	certificate := c.cp.Combine(Data(msg.Height, msg.StateHash), shares)
+	if certificate == nil {
+		return nil
+	}
+	if err := c.store(&Checkpoint{Height: msg.Height, StateHash: msg.StateHash, Certificate: certificate}); err != nil {
+		return err
+	}
+	c.net.Broadcast(c.certMsg())
+	return nil
+}
+
+// Keep a certificate learned elsewhere if it verifies and is newer than
+// the latest one
+func (c *Checkpointer) Adopt(received Checkpoint) error {
+	if c.latest != nil && received.Height <= c.latest.Height {
+		return nil
+	}
+	if received.Height == 0 || received.Height%c.interval != 0 || !Verify(c.cp, received) {
+		return ErrInvalidCertificate
+	}
+	return c.store(&received)
+}
+
+// Store a newer certified checkpoint and forget the shares below it
+func (c *Checkpointer) store(checkpoint *Checkpoint) error {
+	if c.log != nil {
+		data, err := proto.Marshal(&pb.CheckpointMsg{
+			Type:        pb.CheckpointType_CheckpointCert,
+			Height:      checkpoint.Height,
+			StateHash:   checkpoint.StateHash,
+			Certificate: checkpoint.Certificate,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := c.log.Append(data); err != nil {
+			return err
+		}
+	}
+	c.latest = checkpoint
+	for height := range c.signed {
+		if height <= checkpoint.Height {
+			delete(c.signed, height)
+			delete(c.shares, height)
+		}
+	}
+	return nil
+}
+
+// Height of the latest certificate, 0 if there is none yet
+func (c *Checkpointer) stable() uint64 {
+	if c.latest == nil {
+		return 0
+	}
+	return c.latest.Height
+}
+
+func (c *Checkpointer) certMsg() *pb.CheckpointMsg {
+	return &pb.CheckpointMsg{
+		Type:        pb.CheckpointType_CheckpointCert,
+		Sender:      int64(c.id),
+		Height:      c.latest.Height,
+		StateHash:   c.latest.StateHash,
+		Certificate: c.latest.Certificate,
+	}
+}
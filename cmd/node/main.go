@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/stuck/node"
+)
+
+// Config of the node from a node file, or from a cluster file and an id
+func loadConfig(configPath, clusterPath string, id int) (node.Config, error) {
+	if clusterPath == "" {
+		return node.LoadConfig(configPath)
+	}
+	cluster, err := node.LoadCluster(clusterPath)
+	if err != nil {
+		return node.Config{}, err
+	}
+	return cluster.Config(id)
+}
+
+func main() {
+	configPath := flag.String("config", "node.json", "config file of the node")
+	clusterPath := flag.String("cluster", "", "cluster file, used instead of -config together with -id")
+	id := flag.Int("id", 0, "id of the node in the cluster file")
+	check := flag.Bool("check", false, "validate the config and exit")
+	flag.Parse()
+	log.SetFlags(log.LstdFlags)
+
+	cfg, err := loadConfig(*configPath, *clusterPath, *id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if *check {
+		log.Printf("config of node %d is valid", cfg.ID)
+		return
+	}
+	n, err := node.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("node %d/%d running %s, peers on %s", cfg.ID, cfg.N, cfg.Protocol, n.Addr())
+	if addr := n.APIAddr(); addr != "" {
+		log.Printf("client endpoint on %s", addr)
+	}
+
+	// a second signal exits without waiting for the shutdown
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	log.Printf("received %s, shutting down", sig)
+	go func() {
+		<-signals
+		log.Fatal("forced exit")
+	}()
+	n.Stop()
+	log.Printf("stopped at height %d", n.Height())
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/stuck/node"
+)
+
+func main() {
+	n := flag.Int("n", 4, "total node number")
+	t := flag.Int("t", 0, "threshold of tbls, default is the one the protocol needs")
+	protocol := flag.String("protocol", node.ProtocolHoneyBadger, "ordering protocol: hbbft, pbft or hotstuff")
+	host := flag.String("host", "127.0.0.1", "host of every node")
+	basePort := flag.Int("base-port", 7000, "node i listens on base-port+i-1")
+	apiPort := flag.Int("api-port", 8000, "client endpoint of node i on api-port+i-1, 0 disables it")
+	batchSize := flag.Int("batch", 0, "batch size, default is the one of the protocol")
+	timeout := flag.String("view-timeout", "", "view timeout of pbft and hotstuff, e.g. 2s")
+	out := flag.String("o", "testnet", "directory to write the testnet to")
+	force := flag.Bool("force", false, "overwrite an existing testnet")
+	flag.Parse()
+	log.SetFlags(0)
+
+	opts := node.TestnetOptions{
+		N:           *n,
+		T:           *t,
+		Protocol:    *protocol,
+		Host:        *host,
+		BasePort:    *basePort,
+		APIBasePort: *apiPort,
+		BatchSize:   *batchSize,
+		ViewTimeout: *timeout,
+	}
+	if err := node.GenerateTestnet(*out, opts, *force); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("wrote %d node %s testnet to %s, start node i with\n", *n, *protocol, *out)
+	fmt.Printf("\tnode -config %s\n", filepath.Join(*out, "node<i>", node.TestnetConfigFile))
+}
@@ -0,0 +1,425 @@
+package aba
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+)
+
+var (
+	ErrAlreadyProposed = errors.New("already proposed")
+	ErrInvalidSender   = errors.New("invalid sender")
+	ErrWrongInstance   = errors.New("message belongs to another instance")
+	ErrInvalidValue    = errors.New("invalid value")
+	ErrInvalidShare    = errors.New("invalid coin share")
+)
+
+// Bits as sets, CONF carries such a mask
+const (
+	maskZero uint32 = 1 << 0
+	maskOne  uint32 = 1 << 1
+)
+
+const (
+	roundWindow   = 8 // rounds above the current one messages are kept for
+	roundMessages = 5 // BVAL of both bits, AUX, CONF and COIN of a sender per round
+)
+
+// ABA is one instance of Mostefaoui-Moumen-Raynal binary agreement with a
+// CONF phase, the common coin of every round is a threshold signature.
+// All honest nodes decide the same bit, which was proposed by an honest
+// node, and decide with probability 1. A node announces its decision with
+// TERM, f+1 of them make the others decide and n-f let it halt.
+//
+// Messages of rounds more than roundWindow above the current one are
+// dropped and a sender only has the messages of roundWindow rounds kept
+// for future rounds, TERM lets a node that falls behind decide anyway.
+// Rounds more than roundWindow below the current one are dropped, and
+// every round once the instance halts
+type ABA struct {
+	tag      string // domain of the coins, protocols running ABA apart use their own
+	n        int
+	f        int
+	id       int
+	epoch    uint64
+	instance int
+	net      consensus.Network
+	cp       crypto.CryptoProvider
+	proposed bool
+	round    uint32
+	est      uint32
+	rounds   map[uint32]*round
+	guard    *consensus.EpochGuard // rounds as epochs
+	decided  bool
+	decision uint32
+	termRecv map[int]uint32 // sender -> decided bit
+	halted   bool
+}
+
+// State of one round, messages of future rounds are kept until we get there
+type round struct {
+	bvalSent   [2]bool
+	bvalRecv   [2]map[int]bool
+	binValues  uint32 // mask
+	auxSent    bool
+	auxRecv    map[int]uint32
+	confSent   bool
+	confRecv   map[int]uint32
+	vals       uint32 // mask, fixed once n-f CONF are accepted
+	coinSent   bool
+	coinShares map[int][]byte
+	coinTried  int // shares of the last failed combine
+	coin       int // -1 until the coin is known
+}
+
+func newRound() *round {
+	return &round{
+		bvalRecv:   [2]map[int]bool{make(map[int]bool), make(map[int]bool)},
+		auxRecv:    make(map[int]uint32),
+		confRecv:   make(map[int]uint32),
+		coinShares: make(map[int][]byte),
+		coin:       -1,
+	}
+}
+
+// Create ABA instance (epoch, instance) of node id
+func NewABA(n, id int, epoch uint64, instance int, net consensus.Network, cp crypto.CryptoProvider) *ABA {
+	return NewTaggedABA("aba", n, id, epoch, instance, net, cp)
+}
+
+// Create ABA instance whose coins are signed under tag, so coin shares of
+// another protocol with the same (epoch, instance) cannot be replayed
+func NewTaggedABA(tag string, n, id int, epoch uint64, instance int, net consensus.Network, cp crypto.CryptoProvider) *ABA {
+	return &ABA{
+		tag:      tag,
+		n:        n,
+		f:        consensus.MaxFaulty(n),
+		id:       id,
+		epoch:    epoch,
+		instance: instance,
+		net:      net,
+		cp:       cp,
+		rounds:   make(map[uint32]*round),
+		guard:    consensus.NewEpochGuard(consensus.EpochLimits{Window: roundWindow, PerSender: roundMessages * roundWindow}),
+		termRecv: make(map[int]uint32),
+	}
+}
+
+// Propose our initial estimate
+func (a *ABA) Propose(bit bool) error {
+	if a.proposed {
+		return ErrAlreadyProposed
+	}
+	a.proposed = true
+	if a.halted {
+		return nil
+	}
+	a.est = toValue(bit)
+	a.sendBval(a.round, a.est)
+	a.progress()
+	return nil
+}
+
+// Whether Propose was called
+func (a *ABA) Proposed() bool {
+	return a.proposed
+}
+
+// Decided bit, if any
+func (a *ABA) Output() (bool, bool) {
+	return a.decision == 1, a.decided
+}
+
+// Halted instances no longer send messages, n-f nodes announced their
+// decision so every honest node decides without us
+func (a *ABA) Halted() bool {
+	return a.halted
+}
+
+// Current round
+func (a *ABA) Round() uint32 {
+	return a.round
+}
+
+// Handle BVAL, AUX, CONF, COIN and TERM messages of this instance
+func (a *ABA) HandleMessage(msg *pb.ABAMsg) error {
+	sender := int(msg.Sender)
+	if sender < 1 || sender > a.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	if msg.Epoch != a.epoch || int(msg.Instance) != a.instance {
+		return ErrWrongInstance
+	}
+	if a.halted {
+		return nil
+	}
+	if msg.Type == pb.ABAType_ABATerm {
+		if msg.Value > 1 {
+			return fmt.Errorf("%w %d in TERM", ErrInvalidValue, msg.Value)
+		}
+		a.handleTerm(sender, msg.Value)
+		a.progress()
+		return nil
+	}
+
+	if !a.guard.Admit(uint64(msg.Round), sender, msg) {
+		return nil
+	}
+	r := a.getRound(msg.Round)
+	switch msg.Type {
+	case pb.ABAType_ABABval:
+		if msg.Value > 1 {
+			return fmt.Errorf("%w %d in BVAL", ErrInvalidValue, msg.Value)
+		}
+		a.handleBval(msg.Round, r, sender, msg.Value)
+	case pb.ABAType_ABAAux:
+		if msg.Value > 1 {
+			return fmt.Errorf("%w %d in AUX", ErrInvalidValue, msg.Value)
+		}
+		if _, ok := r.auxRecv[sender]; !ok {
+			r.auxRecv[sender] = msg.Value
+		}
+	case pb.ABAType_ABAConf:
+		if msg.Value == 0 || msg.Value > (maskZero|maskOne) {
+			return fmt.Errorf("%w %d in CONF", ErrInvalidValue, msg.Value)
+		}
+		if _, ok := r.confRecv[sender]; !ok {
+			r.confRecv[sender] = msg.Value
+		}
+	case pb.ABAType_ABACoin:
+		if err := a.handleCoin(msg.Round, r, sender, msg.Share); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown aba message type %d", msg.Type)
+	}
+
+	a.progress()
+	return nil
+}
+
+// Relay a bit after f+1 BVAL, accept it after 2f+1
+func (a *ABA) handleBval(rn uint32, r *round, sender int, value uint32) {
+	if r.bvalRecv[value][sender] {
+		return
+	}
+	r.bvalRecv[value][sender] = true
+	count := len(r.bvalRecv[value])
+
+	if count >= a.f+1 {
+		a.sendBval(rn, value)
+	}
+	if count >= 2*a.f+1 {
+		r.binValues |= toMask(value)
+	}
+}
+
+// Decide after f+1 TERM, one of them is honest, and halt after n-f, f+1
+// honest nodes then told every node
+func (a *ABA) handleTerm(sender int, value uint32) {
+	if _, ok := a.termRecv[sender]; ok {
+		return
+	}
+	a.termRecv[sender] = value
+	count := 0
+	for _, v := range a.termRecv {
+		if v == value {
+			count++
+		}
+	}
+	if count >= a.f+1 {
+		a.decide(value)
+	}
+	if count >= a.n-a.f {
+		a.halted = true
+		a.rounds = make(map[uint32]*round)
+	}
+}
+
+// Keep valid coin shares, one per sender
+func (a *ABA) handleCoin(rn uint32, r *round, sender int, share []byte) error {
+	if r.coin >= 0 {
+		return nil
+	}
+	if _, ok := r.coinShares[sender]; ok {
+		return nil
+	}
+	if !a.cp.VerifyShare(a.coinData(rn), share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	r.coinShares[sender] = share
+	return nil
+}
+
+// Run the current round as far as received messages allow
+func (a *ABA) progress() {
+	for a.proposed && !a.halted {
+		rn := a.round
+		r := a.getRound(rn)
+
+		// AUX carries the first accepted bit
+		if !r.auxSent {
+			if r.binValues == 0 {
+				return
+			}
+			r.auxSent = true
+			a.net.Broadcast(a.newMsg(pb.ABAType_ABAAux, rn, firstValue(r.binValues)))
+		}
+
+		// CONF after n-f AUX with accepted bits
+		if !r.confSent {
+			count := 0
+			for _, v := range r.auxRecv {
+				if r.binValues&toMask(v) != 0 {
+					count++
+				}
+			}
+			if count < a.n-a.f {
+				return
+			}
+			r.confSent = true
+			a.net.Broadcast(a.newMsg(pb.ABAType_ABAConf, rn, r.binValues))
+		}
+
+		// Release coin share after n-f CONF with accepted sets
+		if !r.coinSent {
+			count := 0
+			var vals uint32
+			for _, mask := range r.confRecv {
+				if mask&^r.binValues == 0 {
+					count++
+					vals |= mask
+				}
+			}
+			if count < a.n-a.f {
+				return
+			}
+			r.vals = vals
+			r.coinSent = true
+			share := a.cp.ComputeShare(a.coinData(rn))
+			msg := a.newMsg(pb.ABAType_ABACoin, rn, 0)
+			msg.Share = share
+			a.net.Broadcast(msg)
+		}
+
+		if !a.tossCoin(rn, r) {
+			return
+		}
+		a.nextRound(rn, r)
+	}
+}
+
+// Combine coin shares once enough are collected, again only after a new
+// share arrived
+func (a *ABA) tossCoin(rn uint32, r *round) bool {
+	if r.coin >= 0 {
+		return true
+	}
+	if len(r.coinShares) < a.f+1 || len(r.coinShares) == r.coinTried {
+		return false
+	}
+	r.coinTried = len(r.coinShares)
+	shares := make([][]byte, 0, len(r.coinShares))
+	for _, share := range r.coinShares {
+		shares = append(shares, share)
+	}
+	signature := a.cp.Combine(a.coinData(rn), shares)
+	if signature == nil {
+		// the threshold is higher than f+1, wait for more shares
+		return false
+	}
+	digest := sha256.Sum256(signature)
+	r.coin = int(digest[0] & 1)
+	return true
+}
+
+// Decide if the only candidate equals the coin, otherwise adopt a new estimate
+func (a *ABA) nextRound(rn uint32, r *round) {
+	coin := uint32(r.coin)
+	if r.vals == maskZero || r.vals == maskOne {
+		a.est = firstValue(r.vals)
+		if a.est == coin {
+			a.decide(a.est)
+		}
+	} else {
+		a.est = coin
+	}
+
+	a.round = rn + 1
+	a.guard.Advance(uint64(a.round))
+	if a.round > roundWindow && a.guard.Stabilize(uint64(a.round-roundWindow)) {
+		for old := range a.rounds {
+			if old < a.round-roundWindow {
+				delete(a.rounds, old)
+			}
+		}
+	}
+	a.sendBval(a.round, a.est)
+}
+
+// Decide value and announce it, rounds go on until the instance halts
+func (a *ABA) decide(value uint32) {
+	if a.decided {
+		return
+	}
+	a.decided = true
+	a.decision = value
+	a.net.Broadcast(a.newMsg(pb.ABAType_ABATerm, a.round, value))
+}
+
+func (a *ABA) sendBval(rn uint32, value uint32) {
+	r := a.getRound(rn)
+	if r.bvalSent[value] {
+		return
+	}
+	r.bvalSent[value] = true
+	a.net.Broadcast(a.newMsg(pb.ABAType_ABABval, rn, value))
+}
+
+func (a *ABA) getRound(rn uint32) *round {
+	r, ok := a.rounds[rn]
+	if !ok {
+		r = newRound()
+		a.rounds[rn] = r
+	}
+	return r
+}
+
+// Data signed for the common coin of a round
+func (a *ABA) coinData(rn uint32) []byte {
+	return []byte(fmt.Sprintf("%s-coin|%d|%d|%d", a.tag, a.epoch, a.instance, rn))
+}
+
+func (a *ABA) newMsg(typ pb.ABAType, rn uint32, value uint32) *pb.ABAMsg {
+	return &pb.ABAMsg{
+		Type:     typ,
+		Sender:   int64(a.id),
+		Epoch:    a.epoch,
+		Instance: int64(a.instance),
+		Round:    rn,
+		Value:    value,
+	}
+}
+
+func toValue(bit bool) uint32 {
+	if bit {
+		return 1
+	}
+	return 0
+}
+
+func toMask(value uint32) uint32 {
+	return 1 << value
+}
+
+// Smallest bit in a non-empty mask
+func firstValue(mask uint32) uint32 {
+	if mask&maskZero != 0 {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,333 @@
+package aba
+
+import (
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+)
+
+// Decided bit of instance (Epoch, Instance)
+type Decision struct {
+	Epoch    uint64
+	Instance int
+	Value    bool
+}
+
+type instanceID struct {
+	epoch    uint64
+	instance int
+}
+
+type proposal struct {
+	epoch    uint64
+	instance int
+	value    bool
+}
+
+const window = 16 // epochs above the current one messages are kept for
+
+// Agreement runs the ABA instances of one node over a transport,
+// all instances are driven by a single goroutine. The current epoch is
+// the latest one we proposed or decided in, messages are kept for at most
+// window epochs above it and a sender can only have the messages of
+// window epochs kept ahead, so a byzantine node cannot make us hold
+// instances nobody runs. Instances below the epoch passed to Stable are
+// dropped.
+//
+// With a write-ahead log the instances send through an EpochLog, our
+// proposals and the messages accepted for undecided instances are
+// recorded, and every decision is recorded before it is delivered. A
+// restarted agreement delivers its decisions once more and handles the
+// records of the other instances again, so it re-enters them with the
+// same votes. Proposals for those instances are ignored, the recorded one
+// stands. The log is truncated below the epoch passed to Stable, those
+// instances are neither delivered nor re-entered after a restart
+type Agreement struct {
+	n         int
+	id        int
+	t         transport.Transport
+	cp        crypto.CryptoProvider
+	net       *consensus.TransportNetwork
+	log       *consensus.EpochLog
+	resumed   map[instanceID]bool // proposed in or decided before a restart
+	outbox    []Decision
+	instances map[instanceID]*ABA
+	guard     *consensus.EpochGuard
+	proposeCh chan proposal
+	stableCh  chan uint64
+	decideCh  chan Decision
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+}
+
+// Create agreement of node id and start consuming the transport
+func NewAgreement(n, id int, t transport.Transport, cp crypto.CryptoProvider) *Agreement {
+	return NewLoggedAgreement(n, id, t, cp, nil)
+}
+
+// Create agreement recovering from w and recording in it, w is left to
+// the caller
+func NewLoggedAgreement(n, id int, t transport.Transport, cp crypto.CryptoProvider, w *wal.WAL) *Agreement {
+	ag := &Agreement{n: n, id: id, t: t, cp: cp}
+	ag.net = consensus.NewTransportNetwork(id, t)
+	ag.log = consensus.NewEpochLog(id, w, ag.net)
+	ag.instances = make(map[instanceID]*ABA)
+	ag.resumed = make(map[instanceID]bool)
+	// a few rounds of every instance
+	ag.guard = consensus.NewEpochGuard(consensus.EpochLimits{Window: window, PerSender: 32 * n * window})
+	ag.proposeCh = make(chan proposal, n)
+	ag.stableCh = make(chan uint64, 1)
+	ag.decideCh = make(chan Decision, n*n)
+	ag.stopCh = make(chan struct{})
+	ag.doneCh = make(chan struct{})
+	if err := ag.recover(); err != nil {
+		fmt.Printf("[Node:%d] aba recover error due to : %s.\n", id, err)
+	}
+	go ag.run()
+	return ag
+}
+
+// Replay the write-ahead log, decisions are delivered again and the
+// proposals and messages of the undecided instances are handled again in
+// order
+func (ag *Agreement) recover() error {
+	type event struct {
+		id  instanceID
+		msg *pb.ABAMsg // nil for a proposal of bit
+		bit bool
+	}
+	var pending []event
+	err := ag.log.Replay(func(rec *pb.WALRecord) error {
+		switch {
+		case rec.Type == pb.WALType_WALInput && len(rec.Digest) == 1:
+			id := instanceID{epoch: rec.Seq, instance: int(rec.View)}
+			pending = append(pending, event{id: id, bit: rec.Digest[0] == 1})
+		case rec.Type == pb.WALType_WALMessage:
+			msg, err := message.Decode(rec.Digest)
+			if err != nil {
+				return fmt.Errorf("%w, epoch %d: %s", wal.ErrCorrupt, rec.Seq, err)
+			}
+			if abaMsg, ok := msg.(*pb.ABAMsg); ok {
+				id := instanceID{epoch: abaMsg.Epoch, instance: int(abaMsg.Instance)}
+				pending = append(pending, event{id: id, msg: abaMsg})
+			}
+		case rec.Type == pb.WALType_WALCheckpoint:
+			// the caller was done with the epochs below
+			ag.guard.Stabilize(rec.Seq)
+			outbox := ag.outbox[:0]
+			for _, d := range ag.outbox {
+				if d.Epoch >= rec.Seq {
+					outbox = append(outbox, d)
+				}
+			}
+			ag.outbox = outbox
+			kept := pending[:0]
+			for _, e := range pending {
+				if e.id.epoch >= rec.Seq {
+					kept = append(kept, e)
+				}
+			}
+			pending = kept
+		case rec.Type == pb.WALType_WALDecide && len(rec.Digest) == 1:
+			id := instanceID{epoch: rec.Seq, instance: int(rec.View)}
+			ag.outbox = append(ag.outbox, Decision{Epoch: id.epoch, Instance: id.instance, Value: rec.Digest[0] == 1})
+			ag.resumed[id] = true
+			ag.guard.Advance(id.epoch)
+			kept := pending[:0]
+			for _, e := range pending {
+				if e.id != id {
+					kept = append(kept, e)
+				}
+			}
+			pending = kept
+		}
+		return nil
+	})
+	for id := range ag.resumed {
+		if id.epoch < ag.guard.Stable() {
+			delete(ag.resumed, id)
+		}
+	}
+
+	for _, e := range pending {
+		a := ag.instance(e.id.epoch, e.id.instance)
+		_, decided := a.Output()
+		if e.msg == nil {
+			ag.resumed[e.id] = true
+			ag.guard.Advance(e.id.epoch)
+			if err := a.Propose(e.bit); err != nil {
+				fmt.Printf("[Node:%d] aba propose error due to : %s.\n", ag.id, err)
+			}
+		} else if err := a.HandleMessage(e.msg); err != nil {
+			fmt.Printf("[Node:%d] aba handle msg error due to : %s.\n", ag.id, err)
+		}
+		if d, ok := ag.decided(a, decided); ok {
+			ag.outbox = append(ag.outbox, d)
+		}
+	}
+	return err
+}
+
+// Decision of instance a, recorded, false if there is none or it was
+// delivered before
+func (ag *Agreement) decided(a *ABA, decided bool) (Decision, bool) {
+	value, ok := a.Output()
+	if !ok || decided {
+		return Decision{}, false
+	}
+	bit := byte(0)
+	if value {
+		bit = 1
+	}
+	err := ag.log.Append(&pb.WALRecord{Type: pb.WALType_WALDecide, Seq: a.epoch, View: uint64(a.instance), Digest: []byte{bit}})
+	if err != nil {
+		fmt.Printf("[Node:%d] aba wal error due to : %s.\n", ag.id, err)
+		return Decision{}, false
+	}
+	ag.guard.Advance(a.epoch)
+	return Decision{Epoch: a.epoch, Instance: a.instance, Value: value}, true
+}
+
+// Propose bit in instance (epoch, instance)
+func (ag *Agreement) Propose(epoch uint64, instance int, bit bool) {
+	select {
+	case ag.proposeCh <- proposal{epoch: epoch, instance: instance, value: bit}:
+	case <-ag.stopCh:
+	}
+}
+
+// Drop the instances below epoch, the caller is done with them
+func (ag *Agreement) Stable(epoch uint64) {
+	select {
+	case ag.stableCh <- epoch:
+	case <-ag.stopCh:
+	}
+}
+
+// Messages kept per epoch
+func (ag *Agreement) Usage() []consensus.EpochUsage {
+	return ag.guard.Usage()
+}
+
+// Return a "read-only" channel of decisions
+func (ag *Agreement) Decide() <-chan Decision {
+	return ag.decideCh
+}
+
+// Stop agreement, the transport is left to the caller
+func (ag *Agreement) Stop() {
+	close(ag.stopCh)
+	<-ag.doneCh
+	ag.net.Stop()
+}
+
+func (ag *Agreement) run() {
+	defer close(ag.doneCh)
+	// decisions replayed from the write-ahead log
+	for _, d := range ag.outbox {
+		select {
+		case ag.decideCh <- d:
+		case <-ag.stopCh:
+			return
+		}
+	}
+	ag.outbox = nil
+	for {
+		var a *ABA
+		var decided bool
+
+		select {
+		case <-ag.stopCh:
+			return
+
+		case epoch := <-ag.stableCh:
+			if ag.guard.Stabilize(epoch) {
+				for id := range ag.instances {
+					if id.epoch < epoch {
+						delete(ag.instances, id)
+					}
+				}
+				for id := range ag.resumed {
+					if id.epoch < epoch {
+						delete(ag.resumed, id)
+					}
+				}
+				if err := ag.log.Stable(epoch); err != nil {
+					fmt.Printf("[Node:%d] aba wal error due to : %s.\n", ag.id, err)
+				}
+			}
+			continue
+
+		case p := <-ag.proposeCh:
+			id := instanceID{epoch: p.epoch, instance: p.instance}
+			if p.epoch < ag.guard.Stable() || ag.resumed[id] {
+				continue
+			}
+			bit := byte(0)
+			if p.value {
+				bit = 1
+			}
+			if err := ag.log.Input(&pb.WALRecord{Seq: p.epoch, View: uint64(p.instance), Digest: []byte{bit}}); err != nil {
+				fmt.Printf("[Node:%d] aba wal error due to : %s.\n", ag.id, err)
+				continue
+			}
+			ag.guard.Advance(p.epoch)
+			a = ag.instance(p.epoch, p.instance)
+			_, decided = a.Output()
+			if err := a.Propose(p.value); err != nil {
+				fmt.Printf("[Node:%d] aba propose error due to : %s.\n", ag.id, err)
+			}
+
+		case v := <-ag.t.Consume():
+			msg, err := message.Decode(v)
+			if err != nil {
+				continue
+			}
+			abaMsg, ok := msg.(*pb.ABAMsg)
+			if !ok {
+				continue
+			}
+			id, sender := instanceID{epoch: abaMsg.Epoch, instance: int(abaMsg.Instance)}, int(abaMsg.Sender)
+			if id.instance < 1 || id.instance > ag.n || sender < 1 || sender > ag.n {
+				continue
+			}
+			if ag.resumed[id] && ag.instances[id] == nil || !ag.guard.Admit(id.epoch, sender, abaMsg) {
+				continue
+			}
+			a = ag.instance(id.epoch, id.instance)
+			if _, decided = a.Output(); !decided {
+				if err := ag.log.Receive(id.epoch, abaMsg); err != nil {
+					fmt.Printf("[Node:%d] aba wal error due to : %s.\n", ag.id, err)
+					continue
+				}
+			}
+			if err := a.HandleMessage(abaMsg); err != nil {
+				fmt.Printf("[Node:%d] aba handle msg error due to : %s.\n", ag.id, err)
+				continue
+			}
+		}
+
+		if d, ok := ag.decided(a, decided); ok {
+			select {
+			case ag.decideCh <- d:
+			case <-ag.stopCh:
+				return
+			}
+		}
+	}
+}
+
+func (ag *Agreement) instance(epoch uint64, instance int) *ABA {
+	id := instanceID{epoch: epoch, instance: instance}
+	a, ok := ag.instances[id]
+	if !ok {
+		a = NewABA(ag.n, ag.id, epoch, instance, ag.log, ag.cp)
+		ag.instances[id] = a
+	}
+	return a
+}
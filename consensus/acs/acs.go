@@ -0,0 +1,147 @@
+package acs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/consensus/aba"
+	"github.com/stuck/consensus/avid"
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrWrongEpoch     = errors.New("message belongs to another epoch")
+	ErrUnknownMessage = errors.New("unknown acs message")
+)
+
+// ACS is one epoch of asynchronous common subset: every node reliably
+// broadcasts its proposal and one binary agreement per node decides
+// whether that proposal is part of the output. All honest nodes output the
+// same set of at least n-f proposals
+type ACS struct {
+	n          int
+	f          int
+	id         int
+	epoch      uint64
+	broadcasts map[int]*avid.AVID // proposer -> broadcast
+	agreements map[int]*aba.ABA   // proposer -> agreement on its proposal
+	done       bool
+	output     map[int][]byte
+}
+
+// Create epoch of node id, proposers and agreements are numbered 1..n
+func NewACS(n, id int, epoch uint64, net consensus.Network, cp crypto.CryptoProvider) *ACS {
+	a := &ACS{
+		n:          n,
+		f:          consensus.MaxFaulty(n),
+		id:         id,
+		epoch:      epoch,
+		broadcasts: make(map[int]*avid.AVID, n),
+		agreements: make(map[int]*aba.ABA, n),
+	}
+	for j := 1; j <= n; j++ {
+		a.broadcasts[j] = avid.NewAVID(n, id, epoch, j, net)
+		a.agreements[j] = aba.NewABA(n, id, epoch, j, net, cp)
+	}
+	return a
+}
+
+// Broadcast our proposal
+func (a *ACS) Input(value []byte) error {
+	if err := a.broadcasts[a.id].Input(value); err != nil {
+		return err
+	}
+	a.progress()
+	return nil
+}
+
+// Route AVID and ABA messages of this epoch to their instance
+func (a *ACS) HandleMessage(msg proto.Message) error {
+	var err error
+	switch m := msg.(type) {
+	case *pb.AVIDMsg:
+		if m.Epoch != a.epoch {
+			return ErrWrongEpoch
+		}
+		b, ok := a.broadcasts[int(m.Proposer)]
+		if !ok {
+			return fmt.Errorf("unknown proposer %d", m.Proposer)
+		}
+		err = b.HandleMessage(m)
+	case *pb.ABAMsg:
+		if m.Epoch != a.epoch {
+			return ErrWrongEpoch
+		}
+		ag, ok := a.agreements[int(m.Instance)]
+		if !ok {
+			return fmt.Errorf("unknown agreement %d", m.Instance)
+		}
+		err = ag.HandleMessage(m)
+	default:
+		return ErrUnknownMessage
+	}
+
+	a.progress()
+	return err
+}
+
+// Agreed proposals by proposer, available once every agreement decided and
+// every accepted proposal was delivered
+func (a *ACS) Output() (map[int][]byte, bool) {
+	return a.output, a.done
+}
+
+// Epoch of this instance
+func (a *ACS) Epoch() uint64 {
+	return a.epoch
+}
+
+func (a *ACS) progress() {
+	if a.done {
+		return
+	}
+
+	// Vote 1 for every delivered proposal
+	for j := 1; j <= a.n; j++ {
+		if _, delivered := a.broadcasts[j].Output(); delivered && !a.agreements[j].Proposed() {
+			a.agreements[j].Propose(true)
+		}
+	}
+
+	// Vote 0 for the rest once n-f proposals are accepted
+	ones := 0
+	for j := 1; j <= a.n; j++ {
+		if value, decided := a.agreements[j].Output(); decided && value {
+			ones++
+		}
+	}
+	if ones >= a.n-a.f {
+		for j := 1; j <= a.n; j++ {
+			if !a.agreements[j].Proposed() {
+				a.agreements[j].Propose(false)
+			}
+		}
+	}
+
+	// Output once all agreements decided and accepted proposals arrived
+	output := make(map[int][]byte, ones)
+	for j := 1; j <= a.n; j++ {
+		value, decided := a.agreements[j].Output()
+		if !decided {
+			return
+		}
+		if !value {
+			continue
+		}
+		proposal, delivered := a.broadcasts[j].Output()
+		if !delivered {
+			return
+		}
+		output[j] = proposal
+	}
+	a.done = true
+	a.output = output
+}
@@ -0,0 +1,344 @@
+package acs
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+// One epoch of a common subset protocol
+type Instance interface {
+	Input(value []byte) error
+	HandleMessage(msg proto.Message) error
+	Output() (map[int][]byte, bool)
+	Epoch() uint64
+}
+
+// Create the instance of an epoch, sending through net
+type Factory func(epoch uint64, net consensus.Network) Instance
+
+// Messages routed by epoch, every generated message with an epoch field
+type epochMessage interface {
+	GetEpoch() uint64
+	GetSender() int64
+}
+
+const window = 16 // epochs above the current one messages are kept for
+
+// Agreed proposals of an epoch by proposer
+type Result struct {
+	Epoch  uint64
+	Values map[int][]byte
+}
+
+type input struct {
+	epoch uint64
+	value []byte
+}
+
+// Subset runs the ACS epochs of one node over a single transport,
+// all broadcasts and agreements are driven by one goroutine. The current
+// epoch is the latest one we proposed in or the one after the latest
+// output, messages are kept for at most window epochs above it. Epochs
+// below the one passed to Stable are dropped.
+//
+// With a write-ahead log the instances send through an EpochLog, our
+// inputs and the messages accepted for epochs without output are recorded,
+// and every agreed subset is recorded before it is delivered. A restarted
+// subset delivers its subsets once more and handles the records of the
+// other epochs again, so it re-enters them with the same votes. Inputs
+// for those epochs are ignored, the recorded one stands. The log is
+// truncated below the epoch passed to Stable, those epochs are neither
+// delivered nor re-entered after a restart
+type Subset struct {
+	n        int
+	id       int
+	t        transport.Transport
+	factory  Factory
+	net      *consensus.TransportNetwork
+	log      *consensus.EpochLog
+	resumed  map[uint64]bool // epochs proposed in or delivered before a restart
+	outbox   []Result
+	epochs   map[uint64]Instance
+	guard    *consensus.EpochGuard
+	inputCh  chan input
+	stableCh chan uint64
+	outputCh chan Result
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// Create HoneyBadger style subset of node id and start consuming the transport
+func NewSubset(n, id int, t transport.Transport, cp crypto.CryptoProvider) *Subset {
+	return NewSubsetWith(n, id, t, func(epoch uint64, net consensus.Network) Instance {
+		return NewACS(n, id, epoch, net, cp)
+	})
+}
+
+// Create subset running the instances made by factory
+func NewSubsetWith(n, id int, t transport.Transport, factory Factory) *Subset {
+	return NewLoggedSubset(n, id, t, factory, nil)
+}
+
+// Create subset running the instances made by factory, recovering from w
+// and recording in it, w is left to the caller
+func NewLoggedSubset(n, id int, t transport.Transport, factory Factory, w *wal.WAL) *Subset {
+	s := &Subset{n: n, id: id, t: t, factory: factory}
+	s.net = consensus.NewTransportNetwork(id, t)
+	s.log = consensus.NewEpochLog(id, w, s.net)
+	s.epochs = make(map[uint64]Instance)
+	s.resumed = make(map[uint64]bool)
+	s.guard = consensus.NewEpochGuard(consensus.EpochLimits{Window: window, PerSender: 32 * n * window})
+	s.inputCh = make(chan input, n)
+	s.stableCh = make(chan uint64, 1)
+	s.outputCh = make(chan Result, n)
+	s.stopCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	if err := s.recover(); err != nil {
+		fmt.Printf("[Node:%d] acs recover error due to : %s.\n", id, err)
+	}
+	go s.run()
+	return s
+}
+
+// Replay the write-ahead log, subsets are delivered again and the inputs
+// and messages of the epochs without output are handled again in order
+func (s *Subset) recover() error {
+	var pending []*pb.WALRecord
+	err := s.log.Replay(func(rec *pb.WALRecord) error {
+		if rec.Type == pb.WALType_WALMessage || rec.Type == pb.WALType_WALInput {
+			pending = append(pending, rec)
+			return nil
+		}
+		if rec.Type == pb.WALType_WALCheckpoint {
+			// the caller was done with the epochs below
+			s.guard.Stabilize(rec.Seq)
+			outbox := s.outbox[:0]
+			for _, r := range s.outbox {
+				if r.Epoch >= rec.Seq {
+					outbox = append(outbox, r)
+				}
+			}
+			s.outbox = outbox
+			kept := pending[:0]
+			for _, p := range pending {
+				if p.Seq >= rec.Seq {
+					kept = append(kept, p)
+				}
+			}
+			pending = kept
+			return nil
+		}
+		if rec.Type != pb.WALType_WALDecide || len(rec.Proposers) != len(rec.Txs) {
+			return nil
+		}
+		values := make(map[int][]byte, len(rec.Txs))
+		for i, value := range rec.Txs {
+			values[int(rec.Proposers[i])] = value
+		}
+		s.outbox = append(s.outbox, Result{Epoch: rec.Seq, Values: values})
+		s.resumed[rec.Seq] = true
+		s.guard.Advance(rec.Seq + 1)
+		kept := pending[:0]
+		for _, p := range pending {
+			if p.Seq != rec.Seq {
+				kept = append(kept, p)
+			}
+		}
+		pending = kept
+		return nil
+	})
+	for e := range s.resumed {
+		if e < s.guard.Stable() {
+			delete(s.resumed, e)
+		}
+	}
+
+	for _, rec := range pending {
+		a := s.epoch(rec.Seq)
+		_, done := a.Output()
+		if rec.Type == pb.WALType_WALInput {
+			s.resumed[rec.Seq] = true
+			s.guard.Advance(rec.Seq)
+			if err := a.Input(rec.Digest); err != nil {
+				fmt.Printf("[Node:%d] acs input error due to : %s.\n", s.id, err)
+			}
+		} else {
+			msg, err := message.Decode(rec.Digest)
+			if err != nil {
+				return fmt.Errorf("%w, epoch %d: %s", wal.ErrCorrupt, rec.Seq, err)
+			}
+			if err := a.HandleMessage(msg); err != nil {
+				fmt.Printf("[Node:%d] acs handle msg error due to : %s.\n", s.id, err)
+			}
+		}
+		if r, ok := s.agreed(a, done); ok {
+			s.outbox = append(s.outbox, r)
+		}
+	}
+	return err
+}
+
+// Record an agreed subset, proposers in order
+func (s *Subset) record(epoch uint64, values map[int][]byte) error {
+	rec := &pb.WALRecord{Type: pb.WALType_WALDecide, Seq: epoch}
+	for j := range values {
+		rec.Proposers = append(rec.Proposers, int64(j))
+	}
+	sort.Slice(rec.Proposers, func(i, j int) bool { return rec.Proposers[i] < rec.Proposers[j] })
+	for _, j := range rec.Proposers {
+		rec.Txs = append(rec.Txs, values[int(j)])
+	}
+	return s.log.Append(rec)
+}
+
+// Subset instance a agreed on, recorded, false if there is none or it
+// was delivered before
+func (s *Subset) agreed(a Instance, done bool) (Result, bool) {
+	values, ok := a.Output()
+	if !ok || done {
+		return Result{}, false
+	}
+	if err := s.record(a.Epoch(), values); err != nil {
+		fmt.Printf("[Node:%d] acs wal error due to : %s.\n", s.id, err)
+		return Result{}, false
+	}
+	s.guard.Advance(a.Epoch() + 1)
+	return Result{Epoch: a.Epoch(), Values: values}, true
+}
+
+// Propose value in epoch
+func (s *Subset) Propose(epoch uint64, value []byte) {
+	select {
+	case s.inputCh <- input{epoch: epoch, value: value}:
+	case <-s.stopCh:
+	}
+}
+
+// Drop the epochs below epoch, the caller is done with them
+func (s *Subset) Stable(epoch uint64) {
+	select {
+	case s.stableCh <- epoch:
+	case <-s.stopCh:
+	}
+}
+
+// Messages kept per epoch
+func (s *Subset) Usage() []consensus.EpochUsage {
+	return s.guard.Usage()
+}
+
+// Return a "read-only" channel of agreed subsets
+func (s *Subset) Output() <-chan Result {
+	return s.outputCh
+}
+
+// Stop subset, the transport is left to the caller
+func (s *Subset) Stop() {
+	close(s.stopCh)
+	<-s.doneCh
+	s.net.Stop()
+}
+
+func (s *Subset) run() {
+	defer close(s.doneCh)
+	// subsets replayed from the write-ahead log
+	for _, r := range s.outbox {
+		select {
+		case s.outputCh <- r:
+		case <-s.stopCh:
+			return
+		}
+	}
+	s.outbox = nil
+	for {
+		var a Instance
+		var done bool
+
+		select {
+		case <-s.stopCh:
+			return
+
+		case epoch := <-s.stableCh:
+			if s.guard.Stabilize(epoch) {
+				for e := range s.epochs {
+					if e < epoch {
+						delete(s.epochs, e)
+					}
+				}
+				for e := range s.resumed {
+					if e < epoch {
+						delete(s.resumed, e)
+					}
+				}
+				if err := s.log.Stable(epoch); err != nil {
+					fmt.Printf("[Node:%d] acs wal error due to : %s.\n", s.id, err)
+				}
+			}
+			continue
+
+		case in := <-s.inputCh:
+			if in.epoch < s.guard.Stable() || s.resumed[in.epoch] {
+				continue
+			}
+			if err := s.log.Input(&pb.WALRecord{Seq: in.epoch, Digest: in.value}); err != nil {
+				fmt.Printf("[Node:%d] acs wal error due to : %s.\n", s.id, err)
+				continue
+			}
+			s.guard.Advance(in.epoch)
+			a = s.epoch(in.epoch)
+			_, done = a.Output()
+			if err := a.Input(in.value); err != nil {
+				fmt.Printf("[Node:%d] acs input error due to : %s.\n", s.id, err)
+			}
+
+		case v := <-s.t.Consume():
+			msg, err := message.Decode(v)
+			if err != nil {
+				continue
+			}
+			m, ok := msg.(epochMessage)
+			if !ok || !s.guard.Admit(m.GetEpoch(), int(m.GetSender()), msg) {
+				continue
+			}
+			if s.resumed[m.GetEpoch()] && s.epochs[m.GetEpoch()] == nil {
+				// delivered before the restart
+				continue
+			}
+			a = s.epoch(m.GetEpoch())
+			if _, done = a.Output(); !done {
+				if err := s.log.Receive(m.GetEpoch(), msg); err != nil {
+					fmt.Printf("[Node:%d] acs wal error due to : %s.\n", s.id, err)
+					continue
+				}
+			}
+			if err := a.HandleMessage(msg); err != nil {
+				fmt.Printf("[Node:%d] acs handle msg error due to : %s.\n", s.id, err)
+			}
+		}
+
+		if r, ok := s.agreed(a, done); ok {
+			select {
+			case s.outputCh <- r:
+			case <-s.stopCh:
+				return
+			}
+		}
+	}
+}
+
+func (s *Subset) epoch(epoch uint64) Instance {
+	a, ok := s.epochs[epoch]
+	if !ok {
+		a = s.factory(epoch, s.log)
+		s.epochs[epoch] = a
+	}
+	return a
+}
@@ -0,0 +1,216 @@
+package avid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto/merkle"
+	"github.com/stuck/erasure"
+	pb "github.com/stuck/message/messagepb"
+)
+
+var (
+	ErrNotProposer   = errors.New("only the proposer can input a value")
+	ErrInvalidSender = errors.New("invalid sender")
+	ErrWrongInstance = errors.New("message belongs to another instance")
+	ErrInvalidProof  = errors.New("fragment does not match merkle root")
+)
+
+// AVID is one instance of erasure-coded reliable broadcast. The proposer
+// sends each node one Reed-Solomon fragment with a Merkle proof instead of
+// the whole value, any n-2f fragments reconstruct it.
+// Honest nodes deliver the same value, or all deliver nil if the proposer
+// committed to fragments that are not a valid encoding
+type AVID struct {
+	n          int
+	f          int
+	id         int
+	epoch      uint64
+	proposer   int
+	net        consensus.Network
+	enc        *erasure.Encoder
+	valRecv    bool // VAL from proposer already handled
+	readySent  bool
+	echoes     map[int]bool // senders of ECHO
+	readies    map[int]bool // senders of READY
+	fragments  map[[sha256.Size]byte][][]byte
+	echoCount  map[[sha256.Size]byte]int
+	readyCount map[[sha256.Size]byte]int
+	delivered  bool
+	output     []byte
+}
+
+// Create AVID instance (epoch, proposer) of node id
+func NewAVID(n, id int, epoch uint64, proposer int, net consensus.Network) *AVID {
+	f := consensus.MaxFaulty(n)
+	enc, err := erasure.NewEncoder(n-2*f, n)
+	if err != nil {
+		// n-2f >= 1 for every n >= 1
+		panic(err)
+	}
+	return &AVID{
+		n:          n,
+		f:          f,
+		id:         id,
+		epoch:      epoch,
+		proposer:   proposer,
+		net:        net,
+		enc:        enc,
+		echoes:     make(map[int]bool),
+		readies:    make(map[int]bool),
+		fragments:  make(map[[sha256.Size]byte][][]byte),
+		echoCount:  make(map[[sha256.Size]byte]int),
+		readyCount: make(map[[sha256.Size]byte]int),
+	}
+}
+
+// Proposer encodes value and sends every node its fragment
+func (a *AVID) Input(value []byte) error {
+	if a.id != a.proposer {
+		return ErrNotProposer
+	}
+	fragments := a.enc.Encode(value)
+	tree := merkle.NewTree(fragments)
+	for i, fragment := range fragments {
+		msg := a.newMsg(pb.AVIDType_AVIDVal, tree.Root())
+		msg.Fragment = fragment
+		msg.Proof = tree.Proof(i)
+		a.net.SendToPeer(i+1, msg)
+	}
+	return nil
+}
+
+// Handle VAL, ECHO and READY messages of this instance
+func (a *AVID) HandleMessage(msg *pb.AVIDMsg) error {
+	sender := int(msg.Sender)
+	if sender < 1 || sender > a.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	if msg.Epoch != a.epoch || int(msg.Proposer) != a.proposer {
+		return ErrWrongInstance
+	}
+	if len(msg.Root) != sha256.Size {
+		return fmt.Errorf("invalid merkle root size %d", len(msg.Root))
+	}
+
+	switch msg.Type {
+	case pb.AVIDType_AVIDVal:
+		return a.handleVal(sender, msg)
+	case pb.AVIDType_AVIDEcho:
+		return a.handleEcho(sender, msg)
+	case pb.AVIDType_AVIDReady:
+		a.handleReady(sender, msg.Root)
+	default:
+		return fmt.Errorf("unknown avid message type %d", msg.Type)
+	}
+	return nil
+}
+
+// Delivered value, if any. A nil value means the proposer was byzantine
+func (a *AVID) Output() ([]byte, bool) {
+	return a.output, a.delivered
+}
+
+// Echo our own fragment to everyone
+func (a *AVID) handleVal(sender int, msg *pb.AVIDMsg) error {
+	if sender != a.proposer {
+		return fmt.Errorf("%w %d, VAL must come from proposer %d", ErrInvalidSender, sender, a.proposer)
+	}
+	if a.valRecv {
+		return nil
+	}
+	if !merkle.Verify(msg.Root, msg.Fragment, a.id-1, msg.Proof) {
+		return ErrInvalidProof
+	}
+	a.valRecv = true
+
+	echo := a.newMsg(pb.AVIDType_AVIDEcho, msg.Root)
+	echo.Fragment = msg.Fragment
+	echo.Proof = msg.Proof
+	a.net.Broadcast(echo)
+	return nil
+}
+
+// Collect fragments, send READY after n-f ECHO with the same root
+func (a *AVID) handleEcho(sender int, msg *pb.AVIDMsg) error {
+	if a.echoes[sender] {
+		return nil
+	}
+	if !merkle.Verify(msg.Root, msg.Fragment, sender-1, msg.Proof) {
+		return ErrInvalidProof
+	}
+	a.echoes[sender] = true
+
+	root := toDigest(msg.Root)
+	if a.fragments[root] == nil {
+		a.fragments[root] = make([][]byte, a.n)
+	}
+	a.fragments[root][sender-1] = msg.Fragment
+	a.echoCount[root]++
+
+	if a.echoCount[root] >= a.n-a.f {
+		a.sendReady(msg.Root)
+	}
+	a.tryDeliver(root)
+	return nil
+}
+
+// Amplify READY after f+1 matching READY
+func (a *AVID) handleReady(sender int, rootBytes []byte) {
+	if a.readies[sender] {
+		return
+	}
+	a.readies[sender] = true
+	root := toDigest(rootBytes)
+	a.readyCount[root]++
+
+	if a.readyCount[root] >= a.f+1 {
+		a.sendReady(rootBytes)
+	}
+	a.tryDeliver(root)
+}
+
+// Deliver after 2f+1 READY and n-2f fragments, the value is re-encoded to
+// make sure every subset of fragments decodes to the same value
+func (a *AVID) tryDeliver(root [sha256.Size]byte) {
+	if a.delivered || a.readyCount[root] < 2*a.f+1 || a.echoCount[root] < a.enc.DataShards() {
+		return
+	}
+	a.delivered = true
+
+	value, err := a.enc.Decode(a.fragments[root])
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(merkle.NewTree(a.enc.Encode(value)).Root(), root[:]) {
+		return
+	}
+	a.output = value
+}
+
+func (a *AVID) sendReady(root []byte) {
+	if a.readySent {
+		return
+	}
+	a.readySent = true
+	a.net.Broadcast(a.newMsg(pb.AVIDType_AVIDReady, root))
+}
+
+func (a *AVID) newMsg(typ pb.AVIDType, root []byte) *pb.AVIDMsg {
+	return &pb.AVIDMsg{
+		Type:     typ,
+		Sender:   int64(a.id),
+		Epoch:    a.epoch,
+		Proposer: int64(a.proposer),
+		Root:     root,
+	}
+}
+
+func toDigest(b []byte) [sha256.Size]byte {
+	var d [sha256.Size]byte
+	copy(d[:], b)
+	return d
+}
@@ -0,0 +1,183 @@
+package cbc
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+)
+
+var (
+	ErrNotProposer        = errors.New("only the proposer can input a value")
+	ErrInvalidSender      = errors.New("invalid sender")
+	ErrWrongInstance      = errors.New("message belongs to another instance")
+	ErrInvalidShare       = errors.New("invalid signature share")
+	ErrInvalidCertificate = errors.New("invalid certificate")
+)
+
+// CBC is one instance of consistent broadcast with threshold signatures.
+// Receivers sign the first value of the proposer, the proposer combines n-f
+// shares into a certificate and sends it along with the value in FINAL.
+// With threshold n-f honest nodes never deliver different values, but
+// unlike RBC a byzantine proposer can make only some of them deliver.
+// The certificate lets any node check a delivered value with Verify
+type CBC struct {
+	n           int
+	f           int
+	id          int
+	epoch       uint64
+	proposer    int
+	net         consensus.Network
+	cp          crypto.CryptoProvider
+	sendRecv    bool // SEND from proposer already handled
+	proposed    bool
+	value       []byte         // our input, proposer only
+	shares      map[int][]byte // sender -> share, proposer only
+	finalSent   bool
+	delivered   bool
+	output      []byte
+	certificate []byte
+}
+
+// Create CBC instance (epoch, proposer) of node id
+func NewCBC(n, id int, epoch uint64, proposer int, net consensus.Network, cp crypto.CryptoProvider) *CBC {
+	return &CBC{
+		n:        n,
+		f:        consensus.MaxFaulty(n),
+		id:       id,
+		epoch:    epoch,
+		proposer: proposer,
+		net:      net,
+		cp:       cp,
+		shares:   make(map[int][]byte),
+	}
+}
+
+// Proposer broadcasts its value
+func (c *CBC) Input(value []byte) error {
+	if c.id != c.proposer {
+		return ErrNotProposer
+	}
+	c.proposed = true
+	c.value = value
+	msg := c.newMsg(pb.CBCType_CBCSend)
+	msg.Value = value
+	c.net.Broadcast(msg)
+	return nil
+}
+
+// Handle SEND, SHARE and FINAL messages of this instance
+func (c *CBC) HandleMessage(msg *pb.CBCMsg) error {
+	sender := int(msg.Sender)
+	if sender < 1 || sender > c.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	if msg.Epoch != c.epoch || int(msg.Proposer) != c.proposer {
+		return ErrWrongInstance
+	}
+
+	switch msg.Type {
+	case pb.CBCType_CBCSend:
+		return c.handleSend(sender, msg.Value)
+	case pb.CBCType_CBCShare:
+		return c.handleShare(sender, msg.Share)
+	case pb.CBCType_CBCFinal:
+		return c.handleFinal(msg.Value, msg.Certificate)
+	}
+	return fmt.Errorf("unknown cbc message type %d", msg.Type)
+}
+
+// Delivered value, if any
+func (c *CBC) Output() ([]byte, bool) {
+	return c.output, c.delivered
+}
+
+// Certificate of the delivered value
+func (c *CBC) Certificate() ([]byte, bool) {
+	return c.certificate, c.delivered
+}
+
+// Sign the first value of the proposer
+func (c *CBC) handleSend(sender int, value []byte) error {
+	if sender != c.proposer {
+		return fmt.Errorf("%w %d sending for %d", ErrInvalidSender, sender, c.proposer)
+	}
+	if c.sendRecv {
+		return nil
+	}
+	c.sendRecv = true
+	msg := c.newMsg(pb.CBCType_CBCShare)
+	msg.Share = c.cp.ComputeShare(data(c.epoch, c.proposer, value))
+	c.net.SendToPeer(c.proposer, msg)
+	return nil
+}
+
+// Combine n-f shares on our value and send FINAL
+func (c *CBC) handleShare(sender int, share []byte) error {
+	if !c.proposed || c.finalSent {
+		return nil
+	}
+	if _, ok := c.shares[sender]; ok {
+		return nil
+	}
+	signed := data(c.epoch, c.proposer, c.value)
+	if !c.cp.VerifyShare(signed, share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	c.shares[sender] = share
+	if len(c.shares) < c.n-c.f {
+		return nil
+	}
+
+	shares := make([][]byte, 0, len(c.shares))
+	for _, s := range c.shares {
+		shares = append(shares, s)
+	}
+	certificate := c.cp.Combine(signed, shares)
+	if certificate == nil {
+		// the threshold is higher than n-f, wait for more shares
+		return nil
+	}
+	c.finalSent = true
+	msg := c.newMsg(pb.CBCType_CBCFinal)
+	msg.Value = c.value
+	msg.Certificate = certificate
+	c.net.Broadcast(msg)
+	return nil
+}
+
+// Deliver a certified value, FINAL may be relayed by any node
+func (c *CBC) handleFinal(value []byte, certificate []byte) error {
+	if c.delivered {
+		return nil
+	}
+	if !Verify(c.cp, c.epoch, c.proposer, value, certificate) {
+		return ErrInvalidCertificate
+	}
+	c.delivered = true
+	c.output = value
+	c.certificate = certificate
+	return nil
+}
+
+func (c *CBC) newMsg(typ pb.CBCType) *pb.CBCMsg {
+	return &pb.CBCMsg{
+		Type:     typ,
+		Sender:   int64(c.id),
+		Epoch:    c.epoch,
+		Proposer: int64(c.proposer),
+	}
+}
+
+// Verify certificate of value in instance (epoch, proposer)
+func Verify(cp crypto.CryptoProvider, epoch uint64, proposer int, value []byte, certificate []byte) bool {
+	return cp.VerifySignature(data(epoch, proposer, value), certificate)
+}
+
+// Data signed by receivers
+func data(epoch uint64, proposer int, value []byte) []byte {
+	return []byte(fmt.Sprintf("cbc|%d|%d|%x", epoch, proposer, sha256.Sum256(value)))
+}
@@ -0,0 +1,114 @@
+package consensus
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"google.golang.org/protobuf/proto"
+)
+
+// Network is how protocol instances talk to their peers, sending never blocks
+type Network interface {
+	SendToPeer(peerId int, msg proto.Message)
+	Broadcast(msg proto.Message) // including the sender itself
+}
+
+// External validity predicate, must return the same result on every node
+type Validator func(value []byte) bool
+
+// Maximum number of byzantine nodes tolerated by n nodes
+func MaxFaulty(n int) int {
+	return (n - 1) / 3
+}
+
+// TransportNetwork sends protocol messages over a transport.Transport.
+// Messages are queued and sent by a separate goroutine, so a protocol loop
+// never waits for a peer that is itself busy and can keep consuming
+type TransportNetwork struct {
+	id     int
+	t      transport.Transport
+	mu     sync.Mutex
+	queue  []outgoing
+	notify chan struct{}
+	stopCh chan struct{}
+}
+
+type outgoing struct {
+	broadcast bool
+	peerId    int
+	op        *pb.Operation
+}
+
+// Create network of node id
+func NewTransportNetwork(id int, t transport.Transport) *TransportNetwork {
+	tn := &TransportNetwork{id: id, t: t}
+	tn.notify = make(chan struct{}, 1)
+	tn.stopCh = make(chan struct{})
+	go tn.run()
+	return tn
+}
+
+// Send message to one peer
+func (tn *TransportNetwork) SendToPeer(peerId int, msg proto.Message) {
+	tn.enqueue(false, peerId, msg)
+}
+
+// Broadcast message to all peers
+func (tn *TransportNetwork) Broadcast(msg proto.Message) {
+	tn.enqueue(true, 0, msg)
+}
+
+// Stop sending, queued messages are dropped. A send in progress may still
+// wait for the transport, it returns once the transport stops
+func (tn *TransportNetwork) Stop() {
+	close(tn.stopCh)
+}
+
+func (tn *TransportNetwork) enqueue(broadcast bool, peerId int, msg proto.Message) {
+	op, err := message.NewConsensusOperation(msg)
+	if err != nil {
+		fmt.Printf("[Node:%d] wrap msg error due to : %s.\n", tn.id, err)
+		return
+	}
+
+	tn.mu.Lock()
+	tn.queue = append(tn.queue, outgoing{broadcast: broadcast, peerId: peerId, op: op})
+	tn.mu.Unlock()
+
+	select {
+	case tn.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Drain queue in order, transport errors are reported by the transport itself
+func (tn *TransportNetwork) run() {
+	for {
+		select {
+		case <-tn.stopCh:
+			return
+		case <-tn.notify:
+		}
+
+		tn.mu.Lock()
+		batch := tn.queue
+		tn.queue = nil
+		tn.mu.Unlock()
+
+		for _, out := range batch {
+			select {
+			case <-tn.stopCh:
+				return
+			default:
+			}
+			if out.broadcast {
+				tn.t.Broadcast(out.op)
+			} else {
+				tn.t.SendToPeer(out.peerId, out.op)
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package dumbo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/consensus/avid"
+	"github.com/stuck/consensus/mvba"
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrWrongEpoch     = errors.New("message belongs to another epoch")
+	ErrInvalidSender  = errors.New("invalid sender")
+	ErrInvalidShare   = errors.New("invalid done share")
+	ErrUnknownMessage = errors.New("unknown dumbo message")
+)
+
+// ACS is one epoch of Dumbo2 common subset: every node reliably broadcasts
+// its proposal and signs the broadcasts it delivered, n-f such shares hold
+// f+1 honest ones and prove that every honest node will deliver. Instead
+// of n binary agreements a single MVBA then picks one vector of n-f
+// proofs, and the proposals it names are the output.
+//
+// The MVBA needs a crypto provider with threshold n-f
+type ACS struct {
+	n          int
+	f          int
+	id         int
+	epoch      uint64
+	net        consensus.Network
+	cp         crypto.CryptoProvider
+	broadcasts map[int]*avid.AVID     // proposer -> broadcast
+	doneSent   map[int]bool           // proposers whose delivery we signed
+	doneShares map[int]map[int][]byte // proposer -> sender -> share
+	proofs     map[int][]byte         // proposer -> proof of delivery
+	mvba       *mvba.MVBA
+	selected   []int // proposers picked by the MVBA, nil until it decides
+	done       bool
+	output     map[int][]byte
+}
+
+// Create epoch of node id, proposers are numbered 1..n
+func NewACS(n, id int, epoch uint64, net consensus.Network, cp crypto.CryptoProvider) *ACS {
+	a := &ACS{
+		n:          n,
+		f:          consensus.MaxFaulty(n),
+		id:         id,
+		epoch:      epoch,
+		net:        net,
+		cp:         cp,
+		broadcasts: make(map[int]*avid.AVID, n),
+		doneSent:   make(map[int]bool, n),
+		doneShares: make(map[int]map[int][]byte, n),
+		proofs:     make(map[int][]byte, n),
+	}
+	for j := 1; j <= n; j++ {
+		a.broadcasts[j] = avid.NewAVID(n, id, epoch, j, net)
+		a.doneShares[j] = make(map[int][]byte)
+	}
+	a.mvba = mvba.NewMVBA(n, id, epoch, net, cp, a.validate)
+	return a
+}
+
+// Broadcast our proposal
+func (a *ACS) Input(value []byte) error {
+	if err := a.broadcasts[a.id].Input(value); err != nil {
+		return err
+	}
+	a.progress()
+	return nil
+}
+
+// Route AVID, done, MVBA, PB and ABA messages of this epoch
+func (a *ACS) HandleMessage(msg proto.Message) error {
+	var err error
+	switch m := msg.(type) {
+	case *pb.AVIDMsg:
+		if m.Epoch != a.epoch {
+			return ErrWrongEpoch
+		}
+		b, ok := a.broadcasts[int(m.Proposer)]
+		if !ok {
+			return fmt.Errorf("unknown proposer %d", m.Proposer)
+		}
+		err = b.HandleMessage(m)
+	case *pb.DumboDone:
+		if m.Epoch != a.epoch {
+			return ErrWrongEpoch
+		}
+		err = a.handleDone(m)
+	case *pb.MVBAMsg, *pb.PBMsg, *pb.ABAMsg:
+		err = a.mvba.HandleMessage(msg)
+	default:
+		return ErrUnknownMessage
+	}
+
+	a.progress()
+	return err
+}
+
+// Agreed proposals by proposer, available once the MVBA decided and every
+// picked proposal was delivered
+func (a *ACS) Output() (map[int][]byte, bool) {
+	return a.output, a.done
+}
+
+// Epoch of this instance
+func (a *ACS) Epoch() uint64 {
+	return a.epoch
+}
+
+// Keep valid shares on the delivery of a broadcast, one per sender
+func (a *ACS) handleDone(msg *pb.DumboDone) error {
+	sender, proposer := int(msg.Sender), int(msg.Proposer)
+	if sender < 1 || sender > a.n || proposer < 1 || proposer > a.n {
+		return fmt.Errorf("%w %d for %d", ErrInvalidSender, sender, proposer)
+	}
+	if _, ok := a.proofs[proposer]; ok {
+		return nil
+	}
+	if _, ok := a.doneShares[proposer][sender]; ok {
+		return nil
+	}
+	if !a.cp.VerifyShare(a.doneData(proposer), msg.Share) {
+		return fmt.Errorf("%w from %d for %d", ErrInvalidShare, sender, proposer)
+	}
+	a.doneShares[proposer][sender] = msg.Share
+	return nil
+}
+
+func (a *ACS) progress() {
+	if a.done {
+		return
+	}
+
+	for j := 1; j <= a.n; j++ {
+		// Sign every delivered broadcast
+		if _, delivered := a.broadcasts[j].Output(); delivered && !a.doneSent[j] {
+			a.doneSent[j] = true
+			a.net.Broadcast(&pb.DumboDone{
+				Sender:   int64(a.id),
+				Epoch:    a.epoch,
+				Proposer: int64(j),
+				Share:    a.cp.ComputeShare(a.doneData(j)),
+			})
+		}
+
+		// n-f shares, f+1 of them honest, prove that every honest node delivers
+		if _, ok := a.proofs[j]; !ok && len(a.doneShares[j]) >= a.n-a.f {
+			shares := make([][]byte, 0, len(a.doneShares[j]))
+			for _, share := range a.doneShares[j] {
+				shares = append(shares, share)
+			}
+			if proof := a.cp.Combine(a.doneData(j), shares); proof != nil {
+				a.proofs[j] = proof
+			}
+		}
+	}
+
+	// Propose the first n-f proofs
+	if !a.mvba.Proposed() && len(a.proofs) >= a.n-a.f {
+		a.proposeProofs()
+	}
+
+	if a.selected == nil {
+		value, decided := a.mvba.Output()
+		if !decided {
+			return
+		}
+		// the MVBA only outputs values that passed validate
+		proofs := &pb.DumboProofs{}
+		if err := proto.Unmarshal(value, proofs); err != nil {
+			return
+		}
+		a.selected = make([]int, 0, len(proofs.Proofs))
+		for _, p := range proofs.Proofs {
+			a.selected = append(a.selected, int(p.Proposer))
+		}
+	}
+
+	// Output once every picked proposal arrived, totality guarantees it
+	output := make(map[int][]byte, len(a.selected))
+	for _, j := range a.selected {
+		proposal, delivered := a.broadcasts[j].Output()
+		if !delivered {
+			return
+		}
+		output[j] = proposal
+	}
+	a.done = true
+	a.output = output
+}
+
+func (a *ACS) proposeProofs() {
+	proposers := make([]int, 0, len(a.proofs))
+	for j := range a.proofs {
+		proposers = append(proposers, j)
+	}
+	sort.Ints(proposers)
+
+	proofs := &pb.DumboProofs{}
+	for _, j := range proposers[:a.n-a.f] {
+		proofs.Proofs = append(proofs.Proofs, &pb.DumboProof{Proposer: int64(j), Signature: a.proofs[j]})
+	}
+	value, err := proto.Marshal(proofs)
+	if err != nil {
+		fmt.Printf("[Node:%d] dumbo marshal proofs error due to : %s.\n", a.id, err)
+		return
+	}
+	if err := a.mvba.Input(value); err != nil {
+		fmt.Printf("[Node:%d] dumbo mvba input error due to : %s.\n", a.id, err)
+	}
+}
+
+// External validity of MVBA values, n-f valid proofs for distinct proposers
+func (a *ACS) validate(value []byte) bool {
+	proofs := &pb.DumboProofs{}
+	if err := proto.Unmarshal(value, proofs); err != nil {
+		return false
+	}
+	if len(proofs.Proofs) < a.n-a.f {
+		return false
+	}
+	seen := make(map[int]bool, len(proofs.Proofs))
+	for _, p := range proofs.Proofs {
+		j := int(p.Proposer)
+		if j < 1 || j > a.n || seen[j] {
+			return false
+		}
+		seen[j] = true
+		if !a.cp.VerifySignature(a.doneData(j), p.Signature) {
+			return false
+		}
+	}
+	return true
+}
+
+// Data signed once the broadcast of proposer is delivered
+func (a *ACS) doneData(proposer int) []byte {
+	return []byte(fmt.Sprintf("dumbo-done|%d|%d", a.epoch, proposer))
+}
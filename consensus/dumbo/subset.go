@@ -0,0 +1,15 @@
+package dumbo
+
+import (
+	"github.com/stuck/consensus"
+	"github.com/stuck/consensus/acs"
+	"github.com/stuck/crypto"
+	"github.com/stuck/transport"
+)
+
+// Create Dumbo2 subset of node id and start consuming the transport
+func NewSubset(n, id int, t transport.Transport, cp crypto.CryptoProvider) *acs.Subset {
+	return acs.NewSubsetWith(n, id, t, func(epoch uint64, net consensus.Network) acs.Instance {
+		return NewACS(n, id, epoch, net, cp)
+	})
+}
@@ -0,0 +1,163 @@
+package consensus
+
+import (
+	"fmt"
+
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+// EpochLog is the network of a runner that keeps its epochs in a
+// write-ahead log. The runner records every message it accepts and every
+// input it gets, in the order it handles them, and hands the records of
+// the epochs it has not finished to its instances again after a restart.
+// The instances are deterministic, so they send the same messages once
+// more and never different ones. The records are synced before a message
+// is sent, so nothing we sent depends on a record lost in a crash, a
+// message that cannot be synced is dropped. Without a log messages are
+// passed on as they are.
+//
+// Once the runner is done with the epochs below some epoch, Stable records
+// a checkpoint and the log is truncated before the first record of a later
+// epoch, records of earlier epochs may still be replayed before the
+// checkpoint and the runner skips them
+type EpochLog struct {
+	id    int
+	wal   *wal.WAL
+	net   Network
+	first map[uint64]uint64 // epoch -> index of its first record in the log
+}
+
+// Create epoch log of node id sending through net, w may be nil
+func NewEpochLog(id int, w *wal.WAL, net Network) *EpochLog {
+	return &EpochLog{id: id, wal: w, net: net, first: make(map[uint64]uint64)}
+}
+
+func (l *EpochLog) SendToPeer(peerId int, msg proto.Message) {
+	if l.sync() {
+		l.net.SendToPeer(peerId, msg)
+	}
+}
+
+func (l *EpochLog) Broadcast(msg proto.Message) {
+	if l.sync() {
+		l.net.Broadcast(msg)
+	}
+}
+
+// Append a record of the runner and wait for the disk, usually a decision
+func (l *EpochLog) Append(rec *pb.WALRecord) error {
+	if l.wal == nil {
+		return nil
+	}
+	data, err := proto.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	index, err := l.wal.Append(data)
+	if err != nil {
+		return err
+	}
+	l.mark(rec.Seq, index)
+	return nil
+}
+
+// Record an input of the runner, it is synced before the next message
+func (l *EpochLog) Input(rec *pb.WALRecord) error {
+	rec.Type = pb.WALType_WALInput
+	return l.write(rec)
+}
+
+// Record a message accepted in epoch before it is handled, it is synced
+// before the next message
+func (l *EpochLog) Receive(epoch uint64, msg proto.Message) error {
+	if l.wal == nil {
+		return nil
+	}
+	op, err := message.NewConsensusOperation(msg)
+	if err != nil {
+		return err
+	}
+	encoded, err := proto.Marshal(op)
+	if err != nil {
+		return err
+	}
+	return l.write(&pb.WALRecord{Type: pb.WALType_WALMessage, Seq: epoch, Digest: encoded})
+}
+
+// Write a record without waiting for the disk
+func (l *EpochLog) write(rec *pb.WALRecord) error {
+	if l.wal == nil {
+		return nil
+	}
+	data, err := proto.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	index, err := l.wal.Write(data)
+	if err != nil {
+		return err
+	}
+	l.mark(rec.Seq, index)
+	return nil
+}
+
+// Record that the epochs below epoch are done and drop the log before the
+// first record of a later one, the log is truncated in whole segments
+func (l *EpochLog) Stable(epoch uint64) error {
+	if l.wal == nil {
+		return nil
+	}
+	if err := l.Append(&pb.WALRecord{Type: pb.WALType_WALCheckpoint, Seq: epoch}); err != nil {
+		return err
+	}
+	index := l.wal.NextIndex()
+	for e, i := range l.first {
+		if e < epoch {
+			delete(l.first, e)
+		} else if i < index {
+			index = i
+		}
+	}
+	return l.wal.TruncateBefore(index)
+}
+
+// Remember the record at index if it is the first one of epoch
+func (l *EpochLog) mark(epoch, index uint64) {
+	if _, ok := l.first[epoch]; !ok {
+		l.first[epoch] = index
+	}
+}
+
+// Replay the log, every record is passed to fn in order, the message of
+// a received one is decoded with message.Decode(rec.Digest)
+func (l *EpochLog) Replay(fn func(rec *pb.WALRecord) error) error {
+	if l.wal == nil {
+		return nil
+	}
+	return l.wal.Replay(func(index uint64, data []byte) error {
+		rec := &pb.WALRecord{}
+		if err := proto.Unmarshal(data, rec); err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		l.mark(rec.Seq, index)
+		if err := fn(rec); err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		return nil
+	})
+}
+
+// Sync the records, false if the message must be dropped
+func (l *EpochLog) sync() bool {
+	if l.wal == nil {
+		return true
+	}
+	if err := l.wal.Sync(); err != nil {
+		fmt.Printf("[Node:%d] epoch log error due to : %s.\n", l.id, err)
+		return false
+	}
+	return true
+}
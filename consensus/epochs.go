@@ -0,0 +1,141 @@
+package consensus
+
+import (
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Bounds on the epochs a runner keeps state for, zero is unlimited
+type EpochLimits struct {
+	Window    uint64 // epochs above the current one messages are accepted for
+	PerSender int    // messages a sender may have kept for epochs above the current one
+}
+
+// Messages kept for one epoch
+type EpochUsage struct {
+	Epoch    uint64
+	Messages int
+	Bytes    int // encoded size of the messages
+}
+
+type epochUsage struct {
+	EpochUsage
+	ahead map[int]int // sender -> messages counted while the epoch was in the future
+}
+
+// EpochGuard decides which epoch messages a runner keeps. Messages of
+// epochs below the stable one are garbage, the others are accepted up to
+// Window epochs above the current one, and a sender can only have
+// PerSender messages kept for future epochs, so a byzantine node cannot
+// make us hold state for epochs nobody reached. Accepted messages are
+// accounted per epoch until the epoch becomes garbage
+type EpochGuard struct {
+	limits  EpochLimits
+	mu      sync.Mutex // Usage may be called beside the runner
+	current uint64
+	stable  uint64
+	usage   map[uint64]*epochUsage
+	ahead   map[int]int // sender -> messages kept for future epochs
+}
+
+// Create guard at epoch 0, nothing stable yet
+func NewEpochGuard(limits EpochLimits) *EpochGuard {
+	return &EpochGuard{
+		limits: limits,
+		usage:  make(map[uint64]*epochUsage),
+		ahead:  make(map[int]int),
+	}
+}
+
+// Account a message of sender for epoch, false if it must be dropped
+func (g *EpochGuard) Admit(epoch uint64, sender int, msg proto.Message) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if epoch < g.stable {
+		return false
+	}
+	future := epoch > g.current
+	if future && g.limits.Window > 0 && epoch > g.current+g.limits.Window {
+		return false
+	}
+	if future && g.limits.PerSender > 0 && g.ahead[sender] >= g.limits.PerSender {
+		return false
+	}
+
+	u, ok := g.usage[epoch]
+	if !ok {
+		u = &epochUsage{EpochUsage: EpochUsage{Epoch: epoch}, ahead: make(map[int]int)}
+		g.usage[epoch] = u
+	}
+	u.Messages++
+	u.Bytes += proto.Size(msg)
+	if future {
+		u.ahead[sender]++
+		g.ahead[sender]++
+	}
+	return true
+}
+
+// The runner works on epoch now, messages kept for it and the epochs
+// before no longer count as future ones
+func (g *EpochGuard) Advance(epoch uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if epoch <= g.current {
+		return
+	}
+	g.current = epoch
+	for e, u := range g.usage {
+		if e <= epoch {
+			g.release(u)
+		}
+	}
+}
+
+// Epochs below epoch are garbage, false if they already were
+func (g *EpochGuard) Stabilize(epoch uint64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if epoch <= g.stable {
+		return false
+	}
+	g.stable = epoch
+	for e, u := range g.usage {
+		if e < epoch {
+			g.release(u)
+			delete(g.usage, e)
+		}
+	}
+	return true
+}
+
+// Lowest epoch that is not garbage
+func (g *EpochGuard) Stable() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stable
+}
+
+// Messages kept per epoch, in epoch order
+func (g *EpochGuard) Usage() []EpochUsage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	usage := make([]EpochUsage, 0, len(g.usage))
+	for _, u := range g.usage {
+		usage = append(usage, u.EpochUsage)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Epoch < usage[j].Epoch })
+	return usage
+}
+
+func (g *EpochGuard) release(u *epochUsage) {
+	for sender, count := range u.ahead {
+		g.ahead[sender] -= count
+		if g.ahead[sender] == 0 {
+			delete(g.ahead, sender)
+		}
+	}
+	u.ahead = make(map[int]int)
+}
@@ -0,0 +1,610 @@
+package hbbft
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/consensus/acs"
+	"github.com/stuck/crypto"
+	"github.com/stuck/mempool"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+// Crypto used by HoneyBadgerBFT, threshold signatures for the common coins
+// and threshold encryption for proposals
+type Crypto interface {
+	crypto.CryptoProvider
+	crypto.ThresholdEncrypter
+}
+
+type Config struct {
+	N         int                   // total nodes
+	ID        int                   // current node id, 1..N
+	BatchSize int                   // B, each node proposes B/N of its first B transactions
+	Mempool   mempool.Config        // limits of the transactions waiting to be proposed
+	Limits    consensus.EpochLimits // epochs kept ahead, zero fields take defaults
+	Committed int                   // blocks whose transactions are not committed again, zero takes the default
+	WAL       *wal.WAL              // epochs taken part in and committed, nil keeps nothing, left to the caller
+}
+
+const (
+	defaultWindow    = 16
+	defaultCommitted = 256
+)
+
+// Messages routed by epoch
+type epochMessage interface {
+	GetEpoch() uint64
+	GetSender() int64
+}
+
+// Transactions committed in one epoch, in the same order on every node
+type Block struct {
+	Epoch uint64
+	Txs   [][]byte
+}
+
+// HoneyBadger is a HoneyBadgerBFT replica. Every epoch each node encrypts a
+// random sample of its buffered transactions and proposes it through ACS,
+// the agreed ciphertexts are then jointly decrypted and committed in order.
+// A proposal is encrypted for its epoch and proposer, a ciphertext another
+// node proposes as its own is not decrypted, so nobody learns a proposal
+// before it is agreed by copying it.
+//
+// The transactions of the last Config.Committed blocks are never added to
+// the mempool again nor committed again, whoever proposed them.
+//
+// Committed epochs are kept so that slower nodes can still finish them,
+// until Stable says which epochs are garbage. Messages are only accepted
+// up to Limits.Window epochs ahead and up to Limits.PerSender of them per
+// sender for future epochs, a node that falls further behind needs state
+// transfer
+//
+// With a write-ahead log the ACS instances of the epochs, with their RBC
+// and ABA instances, send through an EpochLog. Our proposals and the
+// messages we accept for epochs not committed yet are recorded, and every
+// block is recorded before it is committed. A restarted replica commits
+// its blocks once more and handles the records of the later epochs again,
+// so it re-enters the epochs it took part in with the same proposal and
+// the same votes. Once an epoch is stable the log is truncated below the
+// last Config.Committed blocks before it, which a restart still commits
+// again
+type HoneyBadger struct {
+	n         int
+	f         int
+	id        int
+	batchSize int
+	t         transport.Transport
+	cp        Crypto
+	net       *consensus.TransportNetwork
+	log       *consensus.EpochLog // net, recording the epochs not committed
+	decided   uint64              // epochs below are logged as committed
+	outbox    []Block
+	pool      *mempool.Mempool
+	committed *mempool.Committed // transactions of the last blocks
+	kept      uint64             // blocks committed remembers
+	epoch     uint64             // next epoch to commit
+	epochs    map[uint64]*epochState
+	guard     *consensus.EpochGuard
+	controlMu sync.Mutex
+	jumpTo    uint64   // epoch to continue from, set by state transfer
+	jumpTxs   [][]byte // transactions committed in the skipped epochs
+	stableTo  uint64   // epochs below are garbage
+	controlCh chan struct{}
+	submitCh  chan []byte
+	commitCh  chan Block
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+}
+
+// Progress of one epoch
+type epochState struct {
+	acs         *acs.ACS
+	proposed    bool
+	ciphertexts map[int][]byte         // agreed proposals, proposer -> ciphertext
+	shares      map[int]map[int][]byte // proposer -> sender -> decryption share
+	plaintexts  map[int][]byte
+	invalid     map[int]bool // agreed proposals that cannot be decrypted
+}
+
+// Create replica and start consuming the transport
+func NewHoneyBadger(cfg Config, t transport.Transport, cp Crypto) *HoneyBadger {
+	hb := &HoneyBadger{
+		n:         cfg.N,
+		f:         consensus.MaxFaulty(cfg.N),
+		id:        cfg.ID,
+		batchSize: cfg.BatchSize,
+		t:         t,
+		cp:        cp,
+	}
+	if hb.batchSize < hb.n {
+		hb.batchSize = hb.n
+	}
+	hb.net = consensus.NewTransportNetwork(hb.id, t)
+	hb.log = consensus.NewEpochLog(hb.id, cfg.WAL, hb.net)
+	hb.pool = mempool.NewMempool(cfg.Mempool)
+	if cfg.Committed == 0 {
+		cfg.Committed = defaultCommitted
+	}
+	hb.committed = mempool.NewCommitted(cfg.Committed)
+	hb.kept = uint64(cfg.Committed)
+	limits := cfg.Limits
+	if limits.Window == 0 {
+		limits.Window = defaultWindow
+	}
+	if limits.PerSender == 0 {
+		// a few ABA rounds take about 32 messages per sender and instance
+		limits.PerSender = 32 * hb.n * int(limits.Window)
+	}
+	hb.guard = consensus.NewEpochGuard(limits)
+	hb.epochs = make(map[uint64]*epochState)
+	hb.controlCh = make(chan struct{}, 1)
+	hb.submitCh = make(chan []byte, hb.batchSize)
+	hb.commitCh = make(chan Block, hb.n)
+	hb.stopCh = make(chan struct{})
+	hb.doneCh = make(chan struct{})
+	if err := hb.recover(); err != nil {
+		fmt.Printf("[Node:%d] hbbft recover error due to : %s.\n", hb.id, err)
+	}
+	go hb.run()
+	return hb
+}
+
+// Replay the write-ahead log, blocks committed before the restart are
+// delivered again and the proposals and messages of the later epochs are
+// handled again in the order they were recorded
+func (hb *HoneyBadger) recover() error {
+	var pending []*pb.WALRecord // of epochs not committed yet
+	err := hb.log.Replay(func(rec *pb.WALRecord) error {
+		switch rec.Type {
+		case pb.WALType_WALDecide:
+			if rec.Seq < hb.epoch {
+				return fmt.Errorf("%w, committed epoch %d after %d", wal.ErrCorrupt, rec.Seq, hb.epoch-1)
+			}
+			// epochs skipped by state transfer have no block
+			hb.outbox = append(hb.outbox, Block{Epoch: rec.Seq, Txs: rec.Txs})
+			hb.pool.Remove(rec.Txs)
+			hb.committed.Add(rec.Txs)
+			hb.epoch = rec.Seq + 1
+		case pb.WALType_WALCheckpoint:
+			// skipped epochs, or the log is truncated below
+			if len(rec.Txs) > 0 {
+				hb.committed.Add(rec.Txs)
+			}
+			if rec.Seq > hb.epoch {
+				hb.epoch = rec.Seq
+			}
+		case pb.WALType_WALMessage, pb.WALType_WALInput:
+			pending = append(pending, rec)
+		default:
+			return nil
+		}
+		kept := pending[:0]
+		for _, rec := range pending {
+			if rec.Seq >= hb.epoch {
+				kept = append(kept, rec)
+			}
+		}
+		pending = kept
+		return nil
+	})
+	hb.decided = hb.epoch
+	hb.guard.Advance(hb.epoch)
+
+	for _, rec := range pending {
+		if rec.Type == pb.WALType_WALInput {
+			st := hb.state(rec.Seq)
+			st.proposed = true
+			if err := st.acs.Input(rec.Digest); err != nil {
+				fmt.Printf("[Node:%d] hbbft acs input error due to : %s.\n", hb.id, err)
+			}
+			continue
+		}
+		msg, err := message.Decode(rec.Digest)
+		if err != nil {
+			return fmt.Errorf("%w, epoch %d: %s", wal.ErrCorrupt, rec.Seq, err)
+		}
+		if err := hb.route(msg); err != nil {
+			fmt.Printf("[Node:%d] hbbft handle msg error due to : %s.\n", hb.id, err)
+		}
+	}
+	return err
+}
+
+// Add transaction to the mempool, ErrFull or ErrTooLarge of the mempool
+// if it does not fit
+func (hb *HoneyBadger) Submit(tx []byte) error {
+	if err := hb.pool.Fits(tx); err != nil {
+		return err
+	}
+	select {
+	case hb.submitCh <- tx:
+	case <-hb.stopCh:
+	}
+	return nil
+}
+
+// Return a "read-only" channel of committed blocks, the totally ordered log
+func (hb *HoneyBadger) Commit() <-chan Block {
+	return hb.commitCh
+}
+
+// Continue from epoch, the epochs before it were committed elsewhere and
+// their transactions txs are transferred to the application. Never blocks,
+// blocks of skipped epochs may still come out of Commit
+func (hb *HoneyBadger) Jump(epoch uint64, txs [][]byte) {
+	hb.controlMu.Lock()
+	if epoch > hb.jumpTo {
+		hb.jumpTo = epoch
+	}
+	hb.jumpTxs = append(hb.jumpTxs, txs...)
+	hb.controlMu.Unlock()
+	hb.notify()
+}
+
+// Epochs below epoch are committed by enough nodes, usually certified by
+// a checkpoint, their state and messages are dropped once we committed
+// them too. Never blocks
+func (hb *HoneyBadger) Stable(epoch uint64) {
+	hb.controlMu.Lock()
+	if epoch > hb.stableTo {
+		hb.stableTo = epoch
+	}
+	hb.controlMu.Unlock()
+	hb.notify()
+}
+
+// Messages kept per epoch
+func (hb *HoneyBadger) Usage() []consensus.EpochUsage {
+	return hb.guard.Usage()
+}
+
+// Stop replica, the transport is left to the caller
+func (hb *HoneyBadger) Stop() {
+	close(hb.stopCh)
+	<-hb.doneCh
+	hb.net.Stop()
+}
+
+func (hb *HoneyBadger) run() {
+	defer close(hb.doneCh)
+	// blocks replayed from the write-ahead log, then the resumed epochs
+	if !hb.flush() || !hb.advance() {
+		return
+	}
+	for {
+		select {
+		case <-hb.stopCh:
+			return
+
+		case <-hb.controlCh:
+			hb.control()
+
+		case tx := <-hb.submitCh:
+			if hb.committed.Has(tx) {
+				// resubmitted after its commit
+				continue
+			}
+			if err := hb.pool.Add(tx); err != nil && !errors.Is(err, mempool.ErrDuplicate) {
+				fmt.Printf("[Node:%d] hbbft mempool error due to : %s.\n", hb.id, err)
+			}
+
+		case v := <-hb.t.Consume():
+			msg, err := message.Decode(v)
+			if err != nil {
+				continue
+			}
+			if err := hb.handleMessage(msg); err != nil {
+				fmt.Printf("[Node:%d] hbbft handle msg error due to : %s.\n", hb.id, err)
+			}
+		}
+
+		if !hb.advance() {
+			return
+		}
+	}
+}
+
+func (hb *HoneyBadger) handleMessage(msg proto.Message) error {
+	m, ok := msg.(epochMessage)
+	if !ok || !hb.guard.Admit(m.GetEpoch(), int(m.GetSender()), msg) {
+		return nil
+	}
+	if epoch := m.GetEpoch(); epoch >= hb.decided && (epoch >= hb.epoch || hb.epochs[epoch] != nil) {
+		// handled again after a restart, a message we cannot record is lost
+		if err := hb.log.Receive(epoch, msg); err != nil {
+			return err
+		}
+	}
+	return hb.route(msg)
+}
+
+func (hb *HoneyBadger) route(msg proto.Message) error {
+	switch m := msg.(type) {
+	case *pb.AVIDMsg:
+		return hb.handleSubset(m.Epoch, m)
+	case *pb.ABAMsg:
+		return hb.handleSubset(m.Epoch, m)
+	case *pb.HBDecShare:
+		return hb.handleDecShare(m)
+	}
+	return nil
+}
+
+// Route ACS messages, once ACS outputs release our decryption shares
+func (hb *HoneyBadger) handleSubset(epoch uint64, msg proto.Message) error {
+	if epoch < hb.epoch && hb.epochs[epoch] == nil {
+		return nil
+	}
+	st := hb.state(epoch)
+	_, done := st.acs.Output()
+	err := st.acs.HandleMessage(msg)
+
+	if values, ok := st.acs.Output(); ok && !done {
+		st.ciphertexts = values
+		for j, ciphertext := range values {
+			share, err := hb.cp.DecryptShare(ciphertext, label(epoch, j))
+			if err != nil {
+				// nil proposal or invalid ciphertext, the same on every node
+				st.invalid[j] = true
+				continue
+			}
+			hb.log.Broadcast(&pb.HBDecShare{Sender: int64(hb.id), Epoch: epoch, Proposer: int64(j), Share: share})
+			hb.tryDecrypt(st, j)
+		}
+	}
+	return err
+}
+
+// Keep decryption shares, they are verified once the ciphertext is agreed
+func (hb *HoneyBadger) handleDecShare(msg *pb.HBDecShare) error {
+	sender, proposer := int(msg.Sender), int(msg.Proposer)
+	if sender < 1 || sender > hb.n || proposer < 1 || proposer > hb.n {
+		return fmt.Errorf("invalid decryption share from %d for %d", sender, proposer)
+	}
+	if msg.Epoch < hb.epoch && hb.epochs[msg.Epoch] == nil {
+		return nil
+	}
+
+	st := hb.state(msg.Epoch)
+	if st.shares[proposer] == nil {
+		st.shares[proposer] = make(map[int][]byte)
+	}
+	if _, ok := st.shares[proposer][sender]; ok {
+		return nil
+	}
+	if ciphertext, ok := st.ciphertexts[proposer]; ok && !hb.cp.VerifyDecryptShare(ciphertext, label(msg.Epoch, proposer), msg.Share) {
+		return fmt.Errorf("invalid decryption share from %d for %d", sender, proposer)
+	}
+	st.shares[proposer][sender] = msg.Share
+	hb.tryDecrypt(st, proposer)
+	return nil
+}
+
+// Decrypt proposal j once f+1 decryption shares are collected
+func (hb *HoneyBadger) tryDecrypt(st *epochState, j int) {
+	ciphertext, agreed := st.ciphertexts[j]
+	if _, done := st.plaintexts[j]; !agreed || done || st.invalid[j] || len(st.shares[j]) < hb.f+1 {
+		return
+	}
+
+	shares := make([][]byte, 0, len(st.shares[j]))
+	for _, share := range st.shares[j] {
+		shares = append(shares, share)
+	}
+	plaintext, err := hb.cp.CombineDecrypt(ciphertext, label(st.acs.Epoch(), j), shares)
+	if err != nil {
+		if err != crypto.ErrNotEnoughShares {
+			// the key is unique, so every node fails to open the ciphertext
+			st.invalid[j] = true
+		}
+		return
+	}
+	st.plaintexts[j] = plaintext
+}
+
+// Propose and commit epochs in order, false if stopped while committing
+func (hb *HoneyBadger) advance() bool {
+	for {
+		// stay idle until there is something to order
+		if _, started := hb.epochs[hb.epoch]; !started && hb.pool.Len() == 0 {
+			return true
+		}
+		st := hb.state(hb.epoch)
+		if !st.proposed {
+			hb.propose(st)
+		}
+
+		block, ok := hb.decrypted(st)
+		if !ok {
+			return true
+		}
+		if hb.decided <= block.Epoch {
+			err := hb.log.Append(&pb.WALRecord{Type: pb.WALType_WALDecide, Seq: block.Epoch, Txs: block.Txs})
+			if err != nil {
+				// committing without the record could skip it in the replay
+				fmt.Printf("[Node:%d] hbbft wal error due to : %s.\n", hb.id, err)
+				return true
+			}
+			hb.decided = block.Epoch + 1
+		}
+		select {
+		case hb.commitCh <- block:
+		case <-hb.controlCh:
+			hb.control()
+			continue
+		case <-hb.stopCh:
+			return false
+		}
+		hb.pool.Remove(block.Txs)
+		hb.committed.Add(block.Txs)
+		hb.epoch++
+		hb.guard.Advance(hb.epoch)
+		hb.collect()
+	}
+}
+
+// Send replayed blocks, false if stopped meanwhile
+func (hb *HoneyBadger) flush() bool {
+	for len(hb.outbox) > 0 {
+		select {
+		case hb.commitCh <- hb.outbox[0]:
+			hb.outbox = hb.outbox[1:]
+		case <-hb.stopCh:
+			return false
+		}
+	}
+	return true
+}
+
+func (hb *HoneyBadger) notify() {
+	select {
+	case hb.controlCh <- struct{}{}:
+	default:
+	}
+}
+
+// Jump to the epoch state transfer reached and collect garbage
+func (hb *HoneyBadger) control() {
+	hb.controlMu.Lock()
+	epoch, txs := hb.jumpTo, hb.jumpTxs
+	hb.jumpTxs = nil
+	hb.controlMu.Unlock()
+
+	if len(txs) > 0 || epoch > hb.epoch {
+		// the replay must not resume the skipped epochs
+		err := hb.log.Append(&pb.WALRecord{Type: pb.WALType_WALCheckpoint, Seq: epoch, Txs: txs})
+		if err != nil {
+			fmt.Printf("[Node:%d] hbbft wal error due to : %s.\n", hb.id, err)
+		}
+	}
+	hb.pool.Remove(txs)
+	if len(txs) > 0 {
+		hb.committed.Add(txs)
+	}
+	if epoch > hb.epoch {
+		for e := range hb.epochs {
+			if e < epoch {
+				delete(hb.epochs, e)
+			}
+		}
+		hb.epoch = epoch
+		hb.guard.Advance(epoch)
+	}
+	hb.collect()
+}
+
+// Drop the stable epochs we committed
+func (hb *HoneyBadger) collect() {
+	hb.controlMu.Lock()
+	stable := hb.stableTo
+	hb.controlMu.Unlock()
+	if stable > hb.epoch {
+		stable = hb.epoch
+	}
+	if !hb.guard.Stabilize(stable) {
+		return
+	}
+	for e := range hb.epochs {
+		if e < stable {
+			delete(hb.epochs, e)
+		}
+	}
+	// the blocks committed remembers stay for the replay
+	if stable > hb.kept {
+		if err := hb.log.Stable(stable - hb.kept); err != nil {
+			fmt.Printf("[Node:%d] hbbft wal error due to : %s.\n", hb.id, err)
+		}
+	}
+}
+
+// Encrypt a random B/N sample of the first B buffered transactions, it is
+// recorded so that a restart proposes the same ciphertext
+func (hb *HoneyBadger) propose(st *epochState) {
+	st.proposed = true
+	hb.pool.Evict()
+	batch := &pb.TxBatch{Txs: hb.pool.Sample(hb.batchSize, hb.batchSize/hb.n)}
+	plaintext, err := proto.Marshal(batch)
+	if err != nil {
+		fmt.Printf("[Node:%d] hbbft marshal batch error due to : %s.\n", hb.id, err)
+		return
+	}
+	ciphertext, err := hb.cp.Encrypt(plaintext, label(st.acs.Epoch(), hb.id))
+	if err != nil {
+		fmt.Printf("[Node:%d] hbbft encrypt batch error due to : %s.\n", hb.id, err)
+		return
+	}
+	if err := hb.log.Input(&pb.WALRecord{Seq: st.acs.Epoch(), Digest: ciphertext}); err != nil {
+		fmt.Printf("[Node:%d] hbbft wal error due to : %s.\n", hb.id, err)
+		return
+	}
+	if err := st.acs.Input(ciphertext); err != nil {
+		fmt.Printf("[Node:%d] hbbft acs input error due to : %s.\n", hb.id, err)
+	}
+}
+
+// Label binding a proposal to its epoch and proposer, a byzantine node
+// proposing the ciphertext of another one gets nothing decrypted
+func label(epoch uint64, proposer int) []byte {
+	return []byte(fmt.Sprintf("hbbft|%d|%d", epoch, proposer))
+}
+
+// Block of an epoch whose agreed proposals are all decrypted or invalid,
+// transactions are ordered by proposer and deduplicated, within the block
+// and against the last blocks
+func (hb *HoneyBadger) decrypted(st *epochState) (Block, bool) {
+	if _, ok := st.acs.Output(); !ok {
+		return Block{}, false
+	}
+	proposers := make([]int, 0, len(st.ciphertexts))
+	for j := range st.ciphertexts {
+		if _, done := st.plaintexts[j]; !done && !st.invalid[j] {
+			return Block{}, false
+		}
+		proposers = append(proposers, j)
+	}
+	sort.Ints(proposers)
+
+	block := Block{Epoch: st.acs.Epoch(), Txs: make([][]byte, 0)}
+	seen := make(map[[sha256.Size]byte]bool)
+	for _, j := range proposers {
+		if st.invalid[j] {
+			continue
+		}
+		batch := &pb.TxBatch{}
+		if err := proto.Unmarshal(st.plaintexts[j], batch); err != nil {
+			continue
+		}
+		for _, tx := range batch.Txs {
+			digest := sha256.Sum256(tx)
+			if !seen[digest] && !hb.committed.Has(tx) {
+				seen[digest] = true
+				block.Txs = append(block.Txs, tx)
+			}
+		}
+	}
+	return block, true
+}
+
+func (hb *HoneyBadger) state(epoch uint64) *epochState {
+	st, ok := hb.epochs[epoch]
+	if !ok {
+		st = &epochState{
+			acs:         acs.NewACS(hb.n, hb.id, epoch, hb.log, hb.cp),
+			ciphertexts: make(map[int][]byte),
+			shares:      make(map[int]map[int][]byte),
+			plaintexts:  make(map[int][]byte),
+			invalid:     make(map[int]bool),
+		}
+		hb.epochs[epoch] = st
+	}
+	return st
+}
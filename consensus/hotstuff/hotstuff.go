@@ -0,0 +1,857 @@
+package hotstuff
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	viewWindow             = 16   // views above the current one proposals and votes are kept for
+	defaultCompactInterval = 1024 // committed blocks between log compactions
+)
+
+var (
+	ErrInvalidSender   = errors.New("invalid sender")
+	ErrInvalidProposal = errors.New("invalid proposal")
+	ErrInvalidQC       = errors.New("invalid quorum certificate")
+	ErrInvalidShare    = errors.New("invalid signature share")
+)
+
+type Config struct {
+	N               int           // total nodes
+	ID              int           // current node id, 1..N
+	BatchSize       int           // maximum transactions per block
+	Timeout         time.Duration // view timeout
+	CompactInterval uint64        // committed blocks between log compactions, zero takes the default
+	WAL             *wal.WAL      // votes and commits to recover from, nil keeps nothing, left to the caller
+}
+
+// Committed block, in the same order on every node
+type Block struct {
+	Height uint64
+	View   uint64
+	Txs    [][]byte
+}
+
+type digest = [sha256.Size]byte
+
+type voteKey struct {
+	view  uint64
+	block digest
+}
+
+// HotStuff is a chained HotStuff replica, the partially synchronous
+// baseline for the asynchronous protocols. The leader of view v is node
+// v mod n + 1, votes are threshold signature shares and n-f of them form a
+// quorum certificate. A block is committed once it heads a chain of three
+// certified blocks with consecutive views. Replicas that time out broadcast
+// a share on the view and n-f shares form a timeout certificate, f+1 make
+// the others time out as well, which keeps views synchronized. Timeouts
+// carry the last vote of their sender, so the QC a crashed leader should
+// have formed is still formed by everyone.
+//
+// Proposals and votes of views more than viewWindow above the current one
+// are dropped, their QCs are learned anyway, and a sender has one vote
+// kept per view. Of the timeouts of future views only the highest of each
+// sender is kept, f+1 of them still bring a replica that fell behind to
+// the view the others are in.
+//
+// With a write-ahead log every vote together with our locked view, every
+// timeout and every committed block is recorded before it is sent or
+// delivered. A restarted replica delivers the blocks it committed before
+// once more and neither votes again in a view it voted or timed out in
+// nor below its lock. Every Config.CompactInterval committed blocks the
+// last one, the lock, the last vote and timeout are recorded again and the
+// log before them is dropped in whole segments, only the blocks left in it
+// are delivered again.
+//
+// QCs must be unique per view, so the crypto provider has to use threshold
+// n-f
+type HotStuff struct {
+	n            int
+	f            int
+	id           int
+	batchSize    int
+	timeout      time.Duration
+	interval     uint64 // committed blocks between log compactions
+	t            transport.Transport
+	cp           crypto.CryptoProvider
+	net          *consensus.TransportNetwork
+	wal          *wal.WAL
+	buffer       [][]byte
+	buffered     map[digest]bool
+	genesis      digest
+	blocks       map[digest]*pb.HSBlock
+	committed    map[digest]bool
+	height       uint64
+	view         uint64
+	highQC       *pb.HSQC
+	lastTC       *pb.HSQC
+	lockedView   uint64 // we only vote for blocks justified from here on
+	lastVoted    uint64
+	lastVote     *pb.HSMsg
+	proposedView uint64
+	timeoutView  uint64 // highest view we timed out in
+	compacted    uint64 // height the log was last compacted at
+	votes        map[voteKey]map[int][]byte
+	voters       map[uint64]map[int]bool // view -> senders whose vote is kept
+	timeouts     map[uint64]map[int][]byte
+	timedOut     map[int]uint64         // sender -> future view its timeout is kept for
+	pending      map[uint64]*pb.HSBlock // proposals of future views
+	incomplete   []*pb.HSQC             // QCs whose chain misses blocks
+	fetching     map[digest]bool
+	outbox       []Block
+	timer        *time.Timer
+	submitCh     chan []byte
+	commitCh     chan Block
+	stopCh       chan struct{}
+	doneCh       chan struct{}
+}
+
+// Create replica and start consuming the transport
+func NewHotStuff(cfg Config, t transport.Transport, cp crypto.CryptoProvider) *HotStuff {
+	hs := &HotStuff{
+		n:         cfg.N,
+		f:         consensus.MaxFaulty(cfg.N),
+		id:        cfg.ID,
+		batchSize: cfg.BatchSize,
+		timeout:   cfg.Timeout,
+		interval:  cfg.CompactInterval,
+		t:         t,
+		cp:        cp,
+		wal:       cfg.WAL,
+	}
+	if hs.batchSize <= 0 {
+		hs.batchSize = 1
+	}
+	if hs.timeout <= 0 {
+		hs.timeout = time.Second
+	}
+	if hs.interval == 0 {
+		hs.interval = defaultCompactInterval
+	}
+	genesis := &pb.HSBlock{}
+	hs.genesis = blockDigest(genesis)
+	hs.blocks = map[digest]*pb.HSBlock{hs.genesis: genesis}
+	hs.committed = map[digest]bool{hs.genesis: true}
+	hs.highQC = &pb.HSQC{Block: hs.genesis[:]}
+	hs.net = consensus.NewTransportNetwork(hs.id, t)
+	hs.buffered = make(map[digest]bool)
+	hs.votes = make(map[voteKey]map[int][]byte)
+	hs.voters = make(map[uint64]map[int]bool)
+	hs.timeouts = make(map[uint64]map[int][]byte)
+	hs.timedOut = make(map[int]uint64)
+	hs.pending = make(map[uint64]*pb.HSBlock)
+	hs.fetching = make(map[digest]bool)
+	hs.submitCh = make(chan []byte, hs.batchSize)
+	hs.commitCh = make(chan Block, hs.n)
+	hs.stopCh = make(chan struct{})
+	hs.doneCh = make(chan struct{})
+	hs.timer = time.NewTimer(hs.timeout)
+	if err := hs.recover(); err != nil {
+		fmt.Printf("[Node:%d] hotstuff recover error due to : %s.\n", hs.id, err)
+	}
+	go hs.run()
+	return hs
+}
+
+// Replay the write-ahead log, blocks committed before the restart are
+// delivered again
+func (hs *HotStuff) recover() error {
+	if hs.wal == nil {
+		return nil
+	}
+	var last *pb.WALRecord
+	start := uint64(0) // index of the first record, above 1 once compacted
+	err := hs.wal.Replay(func(index uint64, data []byte) error {
+		if start == 0 {
+			start = index
+		}
+		rec := &pb.WALRecord{}
+		if err := proto.Unmarshal(data, rec); err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		switch rec.Type {
+		case pb.WALType_WALVote:
+			if rec.View > hs.lastVoted {
+				hs.lastVoted = rec.View
+				last = rec
+			}
+			if rec.Locked > hs.lockedView {
+				hs.lockedView = rec.Locked
+			}
+		case pb.WALType_WALViewChange:
+			// left view - 1 for view
+			if rec.View-1 > hs.timeoutView {
+				hs.timeoutView = rec.View - 1
+			}
+			if hs.timeoutView > hs.lastVoted {
+				hs.lastVoted = hs.timeoutView
+			}
+		case pb.WALType_WALCheckpoint:
+			if rec.Locked > hs.lockedView {
+				hs.lockedView = rec.Locked
+			}
+			if rec.Seq <= hs.height || len(rec.Digest) != sha256.Size {
+				return nil
+			}
+			hs.committed[toDigest(rec.Digest)] = true
+			hs.height = rec.Seq
+			hs.compacted = rec.Seq
+		case pb.WALType_WALDecide:
+			if rec.Seq <= hs.height {
+				return nil
+			}
+			if start > 1 && hs.height == 0 {
+				// the chain starts at the first block left in the log
+				hs.height = rec.Seq - 1
+			}
+			if rec.Seq != hs.height+1 || len(rec.Digest) != sha256.Size {
+				return fmt.Errorf("record %d: %w, committed height %d after %d", index, wal.ErrCorrupt, rec.Seq, hs.height)
+			}
+			hs.committed[toDigest(rec.Digest)] = true
+			hs.height++
+			hs.outbox = append(hs.outbox, Block{Height: hs.height, View: rec.View, Txs: rec.Txs})
+		}
+		return nil
+	})
+	// the vote is repeated in our timeouts, the share is deterministic
+	if last != nil {
+		hs.lastVote = &pb.HSMsg{
+			Type:   pb.HSType_HSVote,
+			Sender: int64(hs.id),
+			View:   last.View,
+			Digest: last.Digest,
+			Share:  hs.cp.ComputeShare(voteData(last.View, last.Digest)),
+		}
+	}
+	return err
+}
+
+// Add transaction to the local buffer
+func (hs *HotStuff) Submit(tx []byte) {
+	select {
+	case hs.submitCh <- tx:
+	case <-hs.stopCh:
+	}
+}
+
+// Return a "read-only" channel of committed blocks
+func (hs *HotStuff) Commit() <-chan Block {
+	return hs.commitCh
+}
+
+// Stop replica, the transport is left to the caller
+func (hs *HotStuff) Stop() {
+	close(hs.stopCh)
+	<-hs.doneCh
+	hs.net.Stop()
+}
+
+func (hs *HotStuff) run() {
+	defer close(hs.doneCh)
+	defer func() { hs.timer.Stop() }()
+	// blocks replayed from the write-ahead log
+	if !hs.flush() {
+		return
+	}
+	// a restarted replica waits in the last view it acted in
+	if hs.lastVoted > 1 {
+		hs.enterView(hs.lastVoted)
+	} else {
+		hs.enterView(1)
+	}
+	for {
+		select {
+		case <-hs.stopCh:
+			return
+
+		case tx := <-hs.submitCh:
+			d := sha256.Sum256(tx)
+			if !hs.buffered[d] {
+				hs.buffered[d] = true
+				hs.buffer = append(hs.buffer, tx)
+			}
+			hs.tryPropose()
+
+		case <-hs.timer.C:
+			hs.sendTimeout(hs.view)
+
+		case v := <-hs.t.Consume():
+			msg, err := message.Decode(v)
+			if err != nil {
+				continue
+			}
+			hsMsg, ok := msg.(*pb.HSMsg)
+			if !ok {
+				continue
+			}
+			if err := hs.handleMessage(hsMsg); err != nil {
+				fmt.Printf("[Node:%d] hotstuff handle msg error due to : %s.\n", hs.id, err)
+			}
+		}
+
+		if !hs.flush() {
+			return
+		}
+	}
+}
+
+func (hs *HotStuff) handleMessage(msg *pb.HSMsg) error {
+	sender := int(msg.Sender)
+	if sender < 1 || sender > hs.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	switch msg.Type {
+	case pb.HSType_HSProposal:
+		return hs.handleProposal(sender, msg)
+	case pb.HSType_HSVote:
+		if hs.leader(msg.View+1) != hs.id {
+			return nil
+		}
+		return hs.handleVote(sender, msg)
+	case pb.HSType_HSTimeout:
+		return hs.handleTimeout(sender, msg)
+	case pb.HSType_HSFetch:
+		if b, ok := hs.blocks[toDigest(msg.Digest)]; ok && len(msg.Digest) == sha256.Size {
+			hs.net.SendToPeer(sender, &pb.HSMsg{Type: pb.HSType_HSBlockResp, Sender: int64(hs.id), Block: b})
+		}
+		return nil
+	case pb.HSType_HSBlockResp:
+		if msg.Block == nil || !hs.fetching[blockDigest(msg.Block)] {
+			return nil
+		}
+		if !hs.validQC(msg.Block.Justify) {
+			return ErrInvalidQC
+		}
+		hs.storeBlock(msg.Block)
+		hs.tryPropose()
+		return nil
+	}
+	return fmt.Errorf("unknown hotstuff message type %d", msg.Type)
+}
+
+// Check a proposal of the view leader, learn its QC and vote if safe
+func (hs *HotStuff) handleProposal(sender int, msg *pb.HSMsg) error {
+	b, v := msg.Block, msg.View
+	if b == nil || sender != hs.leader(v) || int(b.Proposer) != sender || b.View != v {
+		return fmt.Errorf("%w from %d", ErrInvalidProposal, sender)
+	}
+	if b.Justify == nil || b.Justify.View >= v {
+		return fmt.Errorf("%w from %d", ErrInvalidProposal, sender)
+	}
+	if b.Justify.View+1 != v && (msg.Tc == nil || msg.Tc.View+1 != v) {
+		return fmt.Errorf("%w from %d, view %d is not justified", ErrInvalidProposal, sender, v)
+	}
+	if !hs.validQC(b.Justify) {
+		return ErrInvalidQC
+	}
+	if msg.Tc != nil && !hs.validTC(msg.Tc) {
+		return ErrInvalidQC
+	}
+
+	hs.storeBlock(b)
+	hs.processQC(b.Justify)
+	if msg.Tc != nil {
+		hs.processTC(msg.Tc)
+	}
+	if v > hs.view+viewWindow {
+		return nil
+	}
+	if v > hs.view {
+		hs.pending[v] = b
+		return nil
+	}
+	hs.vote(b)
+	return nil
+}
+
+// Vote for a block of the current view, at most once per view and only if
+// it is justified from our locked view on
+func (hs *HotStuff) vote(b *pb.HSBlock) {
+	v := b.View
+	if v != hs.view || v <= hs.lastVoted || b.Justify.View < hs.lockedView {
+		return
+	}
+	d := blockDigest(b)
+	if err := hs.persist(&pb.WALRecord{Type: pb.WALType_WALVote, View: v, Digest: d[:], Locked: hs.lockedView}); err != nil {
+		fmt.Printf("[Node:%d] hotstuff wal error due to : %s.\n", hs.id, err)
+		return
+	}
+	hs.lastVoted = v
+	hs.lastVote = &pb.HSMsg{
+		Type:   pb.HSType_HSVote,
+		Sender: int64(hs.id),
+		View:   v,
+		Digest: d[:],
+		Share:  hs.cp.ComputeShare(voteData(v, d[:])),
+	}
+	hs.net.SendToPeer(hs.leader(v+1), hs.lastVote)
+}
+
+// The next leader combines n-f votes into a QC, so does anyone collecting
+// them from timeouts
+func (hs *HotStuff) handleVote(sender int, msg *pb.HSMsg) error {
+	v := msg.View
+	if v <= hs.highQC.View || v > hs.view+viewWindow || len(msg.Digest) != sha256.Size {
+		return nil
+	}
+	if hs.voters[v][sender] {
+		return nil
+	}
+	if !hs.cp.VerifyShare(voteData(v, msg.Digest), msg.Share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	key := voteKey{view: v, block: toDigest(msg.Digest)}
+	if hs.votes[key] == nil {
+		hs.votes[key] = make(map[int][]byte)
+	}
+	if hs.voters[v] == nil {
+		hs.voters[v] = make(map[int]bool)
+	}
+	hs.voters[v][sender] = true
+	hs.votes[key][sender] = msg.Share
+	if len(hs.votes[key]) < hs.n-hs.f {
+		return nil
+	}
+
+	shares := make([][]byte, 0, len(hs.votes[key]))
+	for _, share := range hs.votes[key] {
+		shares = append(shares, share)
+	}
+	signature := hs.cp.Combine(voteData(v, msg.Digest), shares)
+	if signature == nil {
+		return nil
+	}
+	hs.processQC(&pb.HSQC{View: v, Block: msg.Digest, Signature: signature})
+	return nil
+}
+
+// Learn the sender's QC, join a timeout seen by f+1 nodes and move on once
+// n-f nodes timed out
+func (hs *HotStuff) handleTimeout(sender int, msg *pb.HSMsg) error {
+	if msg.Qc != nil {
+		if !hs.validQC(msg.Qc) {
+			return ErrInvalidQC
+		}
+		hs.processQC(msg.Qc)
+	}
+	if msg.Vote != nil && int(msg.Vote.Sender) == sender {
+		if err := hs.handleVote(sender, msg.Vote); err != nil {
+			return err
+		}
+	}
+	v := msg.View
+	if v < hs.view {
+		return nil
+	}
+	if _, ok := hs.timeouts[v][sender]; ok {
+		return nil
+	}
+	kept, ok := hs.timedOut[sender]
+	if v > hs.view && ok && kept > v {
+		return nil
+	}
+	if !hs.cp.VerifyShare(timeoutData(v), msg.Share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	if v > hs.view {
+		if ok {
+			delete(hs.timeouts[kept], sender)
+		}
+		hs.timedOut[sender] = v
+	}
+	if hs.timeouts[v] == nil {
+		hs.timeouts[v] = make(map[int][]byte)
+	}
+	hs.timeouts[v][sender] = msg.Share
+
+	if len(hs.timeouts[v]) >= hs.f+1 {
+		hs.sendTimeout(v)
+	}
+	if len(hs.timeouts[v]) < hs.n-hs.f {
+		return nil
+	}
+	shares := make([][]byte, 0, len(hs.timeouts[v]))
+	for _, share := range hs.timeouts[v] {
+		shares = append(shares, share)
+	}
+	signature := hs.cp.Combine(timeoutData(v), shares)
+	if signature == nil {
+		return nil
+	}
+	hs.processTC(&pb.HSQC{View: v, Signature: signature})
+	return nil
+}
+
+// Give up on view v, we no longer vote in it
+func (hs *HotStuff) sendTimeout(v uint64) {
+	if v <= hs.timeoutView {
+		return
+	}
+	if err := hs.persist(&pb.WALRecord{Type: pb.WALType_WALViewChange, View: v + 1}); err != nil {
+		fmt.Printf("[Node:%d] hotstuff wal error due to : %s.\n", hs.id, err)
+		return
+	}
+	hs.timeoutView = v
+	if hs.lastVoted < v {
+		hs.lastVoted = v
+	}
+	msg := &pb.HSMsg{
+		Type:   pb.HSType_HSTimeout,
+		Sender: int64(hs.id),
+		View:   v,
+		Qc:     hs.highQC,
+		Share:  hs.cp.ComputeShare(timeoutData(v)),
+	}
+	if hs.lastVote != nil && hs.lastVote.View > hs.highQC.View {
+		msg.Vote = hs.lastVote
+	}
+	hs.net.Broadcast(msg)
+}
+
+func (hs *HotStuff) processQC(qc *pb.HSQC) {
+	if qc.View > hs.highQC.View {
+		hs.highQC = qc
+	}
+	if !hs.updateChain(qc) {
+		hs.incomplete = append(hs.incomplete, qc)
+	}
+	if qc.View >= hs.view {
+		hs.enterView(qc.View + 1)
+	}
+}
+
+func (hs *HotStuff) processTC(tc *pb.HSQC) {
+	if tc.View >= hs.view {
+		hs.lastTC = tc
+		hs.enterView(tc.View + 1)
+	}
+}
+
+// Lock on the parent of a certified block and commit its grandparent if
+// the three views are consecutive, false if a block is still missing
+func (hs *HotStuff) updateChain(qc *pb.HSQC) bool {
+	b2, ok := hs.block(qc.Block)
+	if !ok {
+		return false
+	}
+	if b2.Justify == nil {
+		return true
+	}
+	b1, ok := hs.block(b2.Justify.Block)
+	if !ok {
+		return false
+	}
+	if b1.View > hs.lockedView {
+		hs.lockedView = b1.View
+	}
+	if b1.Justify == nil || b2.View != b1.View+1 {
+		return true
+	}
+	b0, ok := hs.block(b1.Justify.Block)
+	if !ok {
+		return false
+	}
+	if b1.View != b0.View+1 {
+		return true
+	}
+	return hs.commit(b0)
+}
+
+// Commit b and its uncommitted ancestors, oldest first
+func (hs *HotStuff) commit(b *pb.HSBlock) bool {
+	chain := make([]*pb.HSBlock, 0)
+	for cur := b; !hs.committed[blockDigest(cur)]; {
+		chain = append(chain, cur)
+		parent, ok := hs.block(cur.Justify.Block)
+		if !ok {
+			return false
+		}
+		cur = parent
+	}
+
+	var d digest
+	for i := len(chain) - 1; i >= 0; i-- {
+		d = blockDigest(chain[i])
+		err := hs.persist(&pb.WALRecord{Type: pb.WALType_WALDecide, View: chain[i].View, Seq: hs.height + 1, Digest: d[:], Txs: chain[i].Txs})
+		if err != nil {
+			// delivering without the record could fork the replayed chain
+			fmt.Printf("[Node:%d] hotstuff wal error due to : %s.\n", hs.id, err)
+			return false
+		}
+		hs.committed[d] = true
+		hs.height++
+		hs.outbox = append(hs.outbox, Block{Height: hs.height, View: chain[i].View, Txs: chain[i].Txs})
+		hs.removeCommitted(chain[i].Txs)
+	}
+	if len(chain) > 0 && hs.height >= hs.compacted+hs.interval {
+		if err := hs.compact(d); err != nil {
+			fmt.Printf("[Node:%d] hotstuff wal error due to : %s.\n", hs.id, err)
+		}
+	}
+	return true
+}
+
+// Log again what a replay needs, the height and digest of the last
+// committed block, our lock, last vote and timeout, then drop the log
+// before it
+func (hs *HotStuff) compact(last digest) error {
+	if hs.wal == nil {
+		return nil
+	}
+	index := hs.wal.NextIndex()
+	records := []*pb.WALRecord{{Type: pb.WALType_WALCheckpoint, Seq: hs.height, Digest: last[:], Locked: hs.lockedView}}
+	if hs.lastVote != nil {
+		records = append(records, &pb.WALRecord{Type: pb.WALType_WALVote, View: hs.lastVote.View, Digest: hs.lastVote.Digest, Locked: hs.lockedView})
+	}
+	if hs.timeoutView > 0 {
+		records = append(records, &pb.WALRecord{Type: pb.WALType_WALViewChange, View: hs.timeoutView + 1})
+	}
+	for _, rec := range records {
+		if err := hs.persist(rec); err != nil {
+			return err
+		}
+	}
+	hs.compacted = hs.height
+	return hs.wal.TruncateBefore(index)
+}
+
+func (hs *HotStuff) enterView(v uint64) {
+	if v <= hs.view {
+		return
+	}
+	hs.view = v
+	hs.timer.Stop()
+	hs.timer = time.NewTimer(hs.timeout)
+
+	for view := range hs.pending {
+		if view < v {
+			delete(hs.pending, view)
+		}
+	}
+	for key := range hs.votes {
+		if key.view+1 < v {
+			delete(hs.votes, key)
+		}
+	}
+	for view := range hs.voters {
+		if view+1 < v {
+			delete(hs.voters, view)
+		}
+	}
+	for view := range hs.timeouts {
+		if view < v {
+			delete(hs.timeouts, view)
+		}
+	}
+	// timeouts of the views from v on are no longer of future views
+	for sender, view := range hs.timedOut {
+		if view <= v {
+			delete(hs.timedOut, sender)
+		}
+	}
+
+	hs.tryPropose()
+	if b, ok := hs.pending[v]; ok {
+		delete(hs.pending, v)
+		hs.vote(b)
+	}
+}
+
+// Leader extends the highest QC, it stays idle while there is nothing to
+// order and no recent transactions left to commit
+func (hs *HotStuff) tryPropose() {
+	v := hs.view
+	if hs.leader(v) != hs.id || hs.proposedView >= v {
+		return
+	}
+	// a restarted replica waits in its last view without a certificate for it
+	if hs.highQC.View+1 != v && (hs.lastTC == nil || hs.lastTC.View+1 != v) {
+		return
+	}
+	parent, ok := hs.block(hs.highQC.Block)
+	if !ok {
+		return
+	}
+
+	// transactions of uncommitted ancestors, and of the last three blocks
+	// which still need descendants to commit or to be seen committed
+	ordered := make(map[digest]bool)
+	unfinished := false
+	depth := 0
+	for cur := parent; cur.Justify != nil; depth++ {
+		if hs.committed[blockDigest(cur)] && depth >= 3 {
+			break
+		}
+		if len(cur.Txs) > 0 {
+			unfinished = true
+		}
+		for _, tx := range cur.Txs {
+			ordered[sha256.Sum256(tx)] = true
+		}
+		next, ok := hs.blocks[toDigest(cur.Justify.Block)]
+		if !ok {
+			break
+		}
+		cur = next
+	}
+
+	batch := make([][]byte, 0, hs.batchSize)
+	for _, tx := range hs.buffer {
+		if len(batch) == hs.batchSize {
+			break
+		}
+		if !ordered[sha256.Sum256(tx)] {
+			batch = append(batch, tx)
+		}
+	}
+	if len(batch) == 0 && !unfinished {
+		return
+	}
+
+	hs.proposedView = v
+	msg := &pb.HSMsg{
+		Type:   pb.HSType_HSProposal,
+		Sender: int64(hs.id),
+		View:   v,
+		Block:  &pb.HSBlock{View: v, Justify: hs.highQC, Proposer: int64(hs.id), Txs: batch},
+	}
+	if hs.highQC.View+1 != v {
+		msg.Tc = hs.lastTC
+	}
+	hs.net.Broadcast(msg)
+}
+
+func (hs *HotStuff) storeBlock(b *pb.HSBlock) {
+	d := blockDigest(b)
+	if _, ok := hs.blocks[d]; ok {
+		return
+	}
+	hs.blocks[d] = b
+	delete(hs.fetching, d)
+
+	incomplete := hs.incomplete
+	hs.incomplete = nil
+	for _, qc := range incomplete {
+		if !hs.updateChain(qc) {
+			hs.incomplete = append(hs.incomplete, qc)
+		}
+	}
+}
+
+// Look up a block, fetching it from the others if it is missing
+func (hs *HotStuff) block(d []byte) (*pb.HSBlock, bool) {
+	if len(d) != sha256.Size {
+		return nil, false
+	}
+	key := toDigest(d)
+	if b, ok := hs.blocks[key]; ok {
+		return b, true
+	}
+	if !hs.fetching[key] {
+		hs.fetching[key] = true
+		hs.net.Broadcast(&pb.HSMsg{Type: pb.HSType_HSFetch, Sender: int64(hs.id), Digest: d})
+	}
+	return nil, false
+}
+
+func (hs *HotStuff) validQC(qc *pb.HSQC) bool {
+	if qc == nil {
+		return false
+	}
+	if qc.View == 0 {
+		return bytes.Equal(qc.Block, hs.genesis[:])
+	}
+	return len(qc.Block) == sha256.Size && hs.cp.VerifySignature(voteData(qc.View, qc.Block), qc.Signature)
+}
+
+func (hs *HotStuff) validTC(tc *pb.HSQC) bool {
+	return hs.cp.VerifySignature(timeoutData(tc.View), tc.Signature)
+}
+
+// Append a record to the write-ahead log, if there is one
+func (hs *HotStuff) persist(rec *pb.WALRecord) error {
+	if hs.wal == nil {
+		return nil
+	}
+	data, err := proto.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = hs.wal.Append(data)
+	return err
+}
+
+// Send committed blocks, false if stopped meanwhile
+func (hs *HotStuff) flush() bool {
+	for len(hs.outbox) > 0 {
+		select {
+		case hs.commitCh <- hs.outbox[0]:
+			hs.outbox = hs.outbox[1:]
+		case <-hs.stopCh:
+			return false
+		}
+	}
+	return true
+}
+
+func (hs *HotStuff) removeCommitted(txs [][]byte) {
+	if len(txs) == 0 {
+		return
+	}
+	committed := make(map[digest]bool, len(txs))
+	for _, tx := range txs {
+		committed[sha256.Sum256(tx)] = true
+	}
+	remaining := hs.buffer[:0]
+	for _, tx := range hs.buffer {
+		d := sha256.Sum256(tx)
+		if committed[d] {
+			delete(hs.buffered, d)
+			continue
+		}
+		remaining = append(remaining, tx)
+	}
+	hs.buffer = remaining
+}
+
+func (hs *HotStuff) leader(v uint64) int {
+	return int(v%uint64(hs.n)) + 1
+}
+
+func blockDigest(b *pb.HSBlock) digest {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(b)
+	if err != nil {
+		// blocks only hold scalars and bytes
+		panic(err)
+	}
+	return sha256.Sum256(data)
+}
+
+func toDigest(d []byte) digest {
+	var key digest
+	copy(key[:], d)
+	return key
+}
+
+// Data signed by votes
+func voteData(v uint64, block []byte) []byte {
+	return []byte(fmt.Sprintf("hs-vote|%d|%x", v, block))
+}
+
+// Data signed by timeouts
+func timeoutData(v uint64) []byte {
+	return []byte(fmt.Sprintf("hs-timeout|%d", v))
+}
@@ -0,0 +1,173 @@
+package mvba
+
+import (
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+)
+
+// Agreed value of an epoch
+type Decision struct {
+	Epoch uint64
+	Value []byte
+}
+
+type proposal struct {
+	epoch uint64
+	value []byte
+}
+
+const window = 16 // epochs above the current one messages are kept for
+
+// Agreement runs the MVBA instances of one node over a transport,
+// all instances are driven by a single goroutine. The current epoch is
+// the latest one we proposed or decided in, messages are kept for at most
+// window epochs above it and a sender can only have the messages of a
+// view in each of them kept ahead, so a byzantine node cannot make us
+// hold instances nobody runs. Instances below the epoch passed to Stable
+// are dropped
+type Agreement struct {
+	n         int
+	id        int
+	t         transport.Transport
+	cp        crypto.CryptoProvider
+	validate  consensus.Validator
+	net       *consensus.TransportNetwork
+	instances map[uint64]*MVBA
+	guard     *consensus.EpochGuard
+	proposeCh chan proposal
+	stableCh  chan uint64
+	decideCh  chan Decision
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+}
+
+// Create agreement of node id and start consuming the transport
+func NewAgreement(n, id int, t transport.Transport, cp crypto.CryptoProvider, validate consensus.Validator) *Agreement {
+	ag := &Agreement{n: n, id: id, t: t, cp: cp, validate: validate}
+	ag.net = consensus.NewTransportNetwork(id, t)
+	ag.instances = make(map[uint64]*MVBA)
+	ag.guard = consensus.NewEpochGuard(consensus.EpochLimits{Window: window, PerSender: viewMessages * window})
+	ag.proposeCh = make(chan proposal, n)
+	ag.stableCh = make(chan uint64, 1)
+	ag.decideCh = make(chan Decision, n)
+	ag.stopCh = make(chan struct{})
+	ag.doneCh = make(chan struct{})
+	go ag.run()
+	return ag
+}
+
+// Propose value in epoch
+func (ag *Agreement) Propose(epoch uint64, value []byte) {
+	select {
+	case ag.proposeCh <- proposal{epoch: epoch, value: value}:
+	case <-ag.stopCh:
+	}
+}
+
+// Drop the instances below epoch, the caller is done with them
+func (ag *Agreement) Stable(epoch uint64) {
+	select {
+	case ag.stableCh <- epoch:
+	case <-ag.stopCh:
+	}
+}
+
+// Messages kept per epoch
+func (ag *Agreement) Usage() []consensus.EpochUsage {
+	return ag.guard.Usage()
+}
+
+// Return a "read-only" channel of decisions
+func (ag *Agreement) Decide() <-chan Decision {
+	return ag.decideCh
+}
+
+// Stop agreement, the transport is left to the caller
+func (ag *Agreement) Stop() {
+	close(ag.stopCh)
+	<-ag.doneCh
+	ag.net.Stop()
+}
+
+func (ag *Agreement) run() {
+	defer close(ag.doneCh)
+	for {
+		var m *MVBA
+		var decided bool
+
+		select {
+		case <-ag.stopCh:
+			return
+
+		case epoch := <-ag.stableCh:
+			if ag.guard.Stabilize(epoch) {
+				for e := range ag.instances {
+					if e < epoch {
+						delete(ag.instances, e)
+					}
+				}
+			}
+			continue
+
+		case p := <-ag.proposeCh:
+			if p.epoch < ag.guard.Stable() {
+				continue
+			}
+			ag.guard.Advance(p.epoch)
+			m = ag.instance(p.epoch)
+			_, decided = m.Output()
+			if err := m.Input(p.value); err != nil {
+				fmt.Printf("[Node:%d] mvba input error due to : %s.\n", ag.id, err)
+			}
+
+		case v := <-ag.t.Consume():
+			msg, err := message.Decode(v)
+			if err != nil {
+				continue
+			}
+			var epoch uint64
+			var sender int64
+			switch msg := msg.(type) {
+			case *pb.MVBAMsg:
+				epoch, sender = msg.Epoch, msg.Sender
+			case *pb.PBMsg:
+				epoch, sender = msg.Epoch, msg.Sender
+			case *pb.ABAMsg:
+				epoch, sender = msg.Epoch, msg.Sender
+			default:
+				continue
+			}
+			if sender < 1 || int(sender) > ag.n || !ag.guard.Admit(epoch, int(sender), msg) {
+				continue
+			}
+			m = ag.instance(epoch)
+			_, decided = m.Output()
+			if err := m.HandleMessage(msg); err != nil {
+				fmt.Printf("[Node:%d] mvba handle msg error due to : %s.\n", ag.id, err)
+			}
+		}
+
+		if value, ok := m.Output(); ok && !decided {
+			ag.guard.Advance(m.Epoch())
+			select {
+			case ag.decideCh <- Decision{Epoch: m.Epoch(), Value: value}:
+			case <-ag.stopCh:
+				return
+			}
+		}
+	}
+}
+
+func (ag *Agreement) instance(epoch uint64) *MVBA {
+	m, ok := ag.instances[epoch]
+	if !ok {
+		m = NewMVBA(ag.n, ag.id, epoch, ag.net, ag.cp, ag.validate)
+		ag.instances[epoch] = m
+	}
+	return m
+}
@@ -0,0 +1,402 @@
+package mvba
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/consensus/aba"
+	"github.com/stuck/consensus/provable"
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	viewWindow   = 4  // views above the current one messages are kept for
+	viewMessages = 64 // PB and MVBA messages of a sender per view, with its ABA rounds
+)
+
+var (
+	ErrAlreadyProposed = errors.New("already proposed")
+	ErrInvalidSender   = errors.New("invalid sender")
+	ErrWrongInstance   = errors.New("message belongs to another instance")
+	ErrInvalidValue    = errors.New("value is not externally valid")
+	ErrInvalidShare    = errors.New("invalid coin share")
+	ErrInvalidProof    = errors.New("invalid proof of delivery")
+	ErrUnknownMessage  = errors.New("unknown mvba message")
+)
+
+// MVBA is one instance of Dumbo-style multi-valued validated agreement.
+// In every view each node provably broadcasts its value: nodes that find
+// the value valid sign it, and n-f signature shares form a proof of
+// delivery. Once n-f broadcasts finished a common coin elects a leader,
+// nodes exchange what they know about its value and one ABA decides whether
+// to output it or to move to the next view.
+//
+// Messages of views more than viewWindow above the current one are
+// dropped and a sender only has the messages of viewWindow views kept for
+// future views. Views more than viewWindow below the current one are
+// dropped.
+//
+// Proofs must be unique per (view, proposer), so the crypto provider has to
+// use threshold n-f, the coins then wait for n-f shares as well
+type MVBA struct {
+	n        int
+	f        int
+	id       int
+	epoch    uint64
+	net      consensus.Network
+	cp       crypto.CryptoProvider
+	validate consensus.Validator
+	input    []byte
+	proposed bool
+	view     uint32
+	views    map[uint32]*view
+	guard    *consensus.EpochGuard // views as epochs
+	done     bool
+	output   []byte
+}
+
+// State of one view, messages of future views are kept until we get there
+type view struct {
+	broadcasts map[int]*provable.PB // proposer -> provable broadcast
+	finishSent bool
+	values     map[int][]byte // proposer -> value with a verified proof
+	proofs     map[int][]byte // proposer -> proof of delivery
+	finished   map[int]bool   // FINISH senders
+	coinSent   bool
+	coinShares map[int][]byte
+	leader     int // 0 until elected
+	voteSent   bool
+	votes      map[int]int // sender -> leader it voted about
+	aba        *aba.ABA
+	valueSent  bool
+}
+
+// Create MVBA instance epoch of node id, values failing validate are never
+// signed nor output
+func NewMVBA(n, id int, epoch uint64, net consensus.Network, cp crypto.CryptoProvider, validate consensus.Validator) *MVBA {
+	return &MVBA{
+		n:        n,
+		f:        consensus.MaxFaulty(n),
+		id:       id,
+		epoch:    epoch,
+		net:      net,
+		cp:       cp,
+		validate: validate,
+		views:    make(map[uint32]*view),
+		guard:    consensus.NewEpochGuard(consensus.EpochLimits{Window: viewWindow, PerSender: viewMessages * viewWindow}),
+	}
+}
+
+// Propose our value, which must be externally valid
+func (m *MVBA) Input(value []byte) error {
+	if m.proposed {
+		return ErrAlreadyProposed
+	}
+	if !m.validate(value) {
+		return ErrInvalidValue
+	}
+	m.proposed = true
+	m.input = value
+	if err := m.startView(); err != nil {
+		return err
+	}
+	m.progress()
+	return nil
+}
+
+// Whether Input was called
+func (m *MVBA) Proposed() bool {
+	return m.proposed
+}
+
+// Agreed value, if any
+func (m *MVBA) Output() ([]byte, bool) {
+	return m.output, m.done
+}
+
+// Epoch of this instance
+func (m *MVBA) Epoch() uint64 {
+	return m.epoch
+}
+
+// Current view
+func (m *MVBA) View() uint32 {
+	return m.view
+}
+
+// Handle MVBA messages, and the PB and ABA messages of every view
+func (m *MVBA) HandleMessage(msg proto.Message) error {
+	var err error
+	switch msg := msg.(type) {
+	case *pb.MVBAMsg:
+		err = m.handleMVBA(msg)
+	case *pb.PBMsg:
+		if msg.Epoch != m.epoch {
+			return ErrWrongInstance
+		}
+		if admitted, err := m.admit(msg.Round, msg.Sender, msg); !admitted {
+			return err
+		}
+		b, ok := m.getView(msg.Round).broadcasts[int(msg.Proposer)]
+		if !ok {
+			return fmt.Errorf("%w proposer %d", ErrInvalidSender, msg.Proposer)
+		}
+		err = b.HandleMessage(msg)
+	case *pb.ABAMsg:
+		if msg.Epoch != m.epoch {
+			return ErrWrongInstance
+		}
+		if admitted, err := m.admit(uint32(msg.Instance), msg.Sender, msg); !admitted {
+			return err
+		}
+		err = m.getView(uint32(msg.Instance)).aba.HandleMessage(msg)
+	default:
+		return ErrUnknownMessage
+	}
+
+	m.progress()
+	return err
+}
+
+func (m *MVBA) handleMVBA(msg *pb.MVBAMsg) error {
+	sender, proposer := int(msg.Sender), int(msg.Proposer)
+	if sender < 1 || sender > m.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	if proposer < 1 || proposer > m.n {
+		return fmt.Errorf("%w proposer %d", ErrInvalidSender, proposer)
+	}
+	if msg.Epoch != m.epoch {
+		return ErrWrongInstance
+	}
+	if !m.guard.Admit(uint64(msg.View), sender, msg) {
+		return nil
+	}
+
+	v := m.getView(msg.View)
+	switch msg.Type {
+	case pb.MVBAType_MVBAFinish:
+		if sender != proposer {
+			return fmt.Errorf("%w %d finishing broadcast of %d", ErrInvalidSender, sender, proposer)
+		}
+		if err := m.addProof(msg.View, v, proposer, msg.Value, msg.Proof); err != nil {
+			return err
+		}
+		v.finished[sender] = true
+	case pb.MVBAType_MVBACoin:
+		return m.handleCoin(msg.View, v, sender, msg.Share)
+	case pb.MVBAType_MVBAVote:
+		if _, ok := v.votes[sender]; ok {
+			return nil
+		}
+		if len(msg.Proof) != 0 {
+			if err := m.addProof(msg.View, v, proposer, msg.Value, msg.Proof); err != nil {
+				return err
+			}
+		}
+		v.votes[sender] = proposer
+	case pb.MVBAType_MVBAValue:
+		return m.addProof(msg.View, v, proposer, msg.Value, msg.Proof)
+	default:
+		return fmt.Errorf("unknown mvba message type %d", msg.Type)
+	}
+	return nil
+}
+
+// Account a PB or ABA message of view vn, false with no error if it is
+// dropped
+func (m *MVBA) admit(vn uint32, sender int64, msg proto.Message) (bool, error) {
+	if sender < 1 || int(sender) > m.n {
+		return false, fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	return m.guard.Admit(uint64(vn), int(sender), msg), nil
+}
+
+// Keep valid coin shares, one per sender
+func (m *MVBA) handleCoin(vn uint32, v *view, sender int, share []byte) error {
+	if v.leader != 0 {
+		return nil
+	}
+	if _, ok := v.coinShares[sender]; ok {
+		return nil
+	}
+	if !m.cp.VerifyShare(m.coinData(vn), share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	v.coinShares[sender] = share
+	return nil
+}
+
+// Remember a proven value of proposer
+func (m *MVBA) addProof(vn uint32, v *view, proposer int, value []byte, proof []byte) error {
+	if _, ok := v.proofs[proposer]; ok {
+		return nil
+	}
+	if !provable.Verify(m.cp, m.epoch, vn, proposer, value, proof) {
+		return fmt.Errorf("%w of %d", ErrInvalidProof, proposer)
+	}
+	v.values[proposer] = value
+	v.proofs[proposer] = proof
+	return nil
+}
+
+// Run the current view as far as received messages allow
+func (m *MVBA) progress() {
+	for m.proposed && !m.done {
+		vn := m.view
+		v := m.getView(vn)
+
+		// Everyone learns the certificate of our value
+		if !v.finishSent {
+			if proof, ok := v.broadcasts[m.id].Certificate(); ok {
+				v.finishSent = true
+				msg := m.newMsg(pb.MVBAType_MVBAFinish, vn, m.id)
+				msg.Value = m.input
+				msg.Proof = proof
+				m.net.Broadcast(msg)
+			}
+		}
+
+		// Release coin share after n-f finished broadcasts
+		if !v.coinSent {
+			if len(v.finished) < m.n-m.f {
+				return
+			}
+			v.coinSent = true
+			msg := m.newMsg(pb.MVBAType_MVBACoin, vn, m.id)
+			msg.Share = m.cp.ComputeShare(m.coinData(vn))
+			m.net.Broadcast(msg)
+		}
+
+		if !m.elect(vn, v) {
+			return
+		}
+
+		// Tell everyone what we know about the leader's value
+		if !v.voteSent {
+			v.voteSent = true
+			msg := m.newMsg(pb.MVBAType_MVBAVote, vn, v.leader)
+			if proof, ok := v.proofs[v.leader]; ok {
+				msg.Value = v.values[v.leader]
+				msg.Proof = proof
+			}
+			m.net.Broadcast(msg)
+		}
+
+		// Vote 1 in ABA if any of n-f votes proved the leader's value
+		if !v.aba.Proposed() {
+			count := 0
+			for _, leader := range v.votes {
+				if leader == v.leader {
+					count++
+				}
+			}
+			if count < m.n-m.f {
+				return
+			}
+			_, proven := v.proofs[v.leader]
+			v.aba.Propose(proven)
+		}
+
+		decision, decided := v.aba.Output()
+		if !decided {
+			return
+		}
+		if !decision {
+			m.view = vn + 1
+			m.guard.Advance(uint64(m.view))
+			if m.view > viewWindow && m.guard.Stabilize(uint64(m.view-viewWindow)) {
+				for old := range m.views {
+					if old < m.view-viewWindow {
+						delete(m.views, old)
+					}
+				}
+			}
+			if err := m.startView(); err != nil {
+				return
+			}
+			continue
+		}
+
+		// Some honest node holds the proven value and forwards it
+		proof, ok := v.proofs[v.leader]
+		if !ok {
+			return
+		}
+		if !v.valueSent {
+			v.valueSent = true
+			msg := m.newMsg(pb.MVBAType_MVBAValue, vn, v.leader)
+			msg.Value = v.values[v.leader]
+			msg.Proof = proof
+			m.net.Broadcast(msg)
+		}
+		m.done = true
+		m.output = v.values[v.leader]
+	}
+}
+
+// Combine coin shares into the leader of a view
+func (m *MVBA) elect(vn uint32, v *view) bool {
+	if v.leader != 0 {
+		return true
+	}
+	if len(v.coinShares) < m.f+1 {
+		return false
+	}
+	shares := make([][]byte, 0, len(v.coinShares))
+	for _, share := range v.coinShares {
+		shares = append(shares, share)
+	}
+	signature := m.cp.Combine(m.coinData(vn), shares)
+	if signature == nil {
+		return false
+	}
+	digest := sha256.Sum256(signature)
+	v.leader = int(binary.BigEndian.Uint64(digest[:8])%uint64(m.n)) + 1
+	return true
+}
+
+// Provably broadcast our value in the current view
+func (m *MVBA) startView() error {
+	return m.getView(m.view).broadcasts[m.id].Input(m.input)
+}
+
+func (m *MVBA) getView(vn uint32) *view {
+	v, ok := m.views[vn]
+	if !ok {
+		v = &view{
+			broadcasts: make(map[int]*provable.PB, m.n),
+			values:     make(map[int][]byte),
+			proofs:     make(map[int][]byte),
+			finished:   make(map[int]bool),
+			coinShares: make(map[int][]byte),
+			votes:      make(map[int]int),
+			aba:        aba.NewTaggedABA("mvba-aba", m.n, m.id, m.epoch, int(vn), m.net, m.cp),
+		}
+		for j := 1; j <= m.n; j++ {
+			v.broadcasts[j] = provable.NewPB(m.n, m.id, m.epoch, vn, j, m.net, m.cp, m.validate)
+		}
+		m.views[vn] = v
+	}
+	return v
+}
+
+// Data signed for the leader election of a view
+func (m *MVBA) coinData(vn uint32) []byte {
+	return []byte(fmt.Sprintf("mvba-coin|%d|%d", m.epoch, vn))
+}
+
+func (m *MVBA) newMsg(typ pb.MVBAType, vn uint32, proposer int) *pb.MVBAMsg {
+	return &pb.MVBAMsg{
+		Type:     typ,
+		Sender:   int64(m.id),
+		Epoch:    m.epoch,
+		View:     vn,
+		Proposer: int64(proposer),
+	}
+}
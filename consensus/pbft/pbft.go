@@ -0,0 +1,1292 @@
+package pbft
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	"github.com/stuck/crypto/executor"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+const viewWindow = 4 // views above ours normal case messages are kept for
+
+var (
+	ErrInvalidSender     = errors.New("invalid sender")
+	ErrInvalidPrePrepare = errors.New("invalid pre-prepare")
+	ErrInvalidShare      = errors.New("invalid signature share")
+	ErrInvalidViewChange = errors.New("invalid view change")
+	ErrInvalidNewView    = errors.New("invalid new view")
+	ErrEquivocation      = errors.New("already voted for another batch")
+)
+
+// Shares of a view change sign it for its sender, so the provider has to
+// tell which node computed a share
+type Crypto interface {
+	crypto.CryptoProvider
+	crypto.ShareIndexer
+}
+
+type Config struct {
+	N                  int                // total nodes
+	ID                 int                // current node id, 1..N
+	BatchSize          int                // maximum transactions per batch
+	Timeout            time.Duration      // view change timeout
+	CheckpointInterval uint64             // executed batches between checkpoints
+	WAL                *wal.WAL           // protocol state to recover from, nil keeps nothing, left to the caller
+	Executor           *executor.Executor // verifies the certificates of view changes in parallel, nil verifies inline, left to the caller
+}
+
+// Executed batch, in the same order on every node. Batches ordered during
+// a view change to fill gaps have no transactions
+type Block struct {
+	Seq  uint64
+	View uint64
+	Txs  [][]byte
+}
+
+type digest = [sha256.Size]byte
+
+type vote struct {
+	digest []byte
+	share  []byte
+}
+
+type commitKey struct {
+	view   uint64
+	digest string
+}
+
+type voteKey struct {
+	view uint64
+	seq  uint64
+}
+
+type decision struct {
+	view   uint64
+	digest []byte
+	txs    [][]byte
+}
+
+// Agreement on one sequence number. Pre-prepare and prepare only count in
+// the current view, batches and commits are kept across views so that a
+// node which moved on alone still commits what the others commit
+type slot struct {
+	view        uint64
+	digest      []byte
+	txs         [][]byte
+	prePrepared bool
+	prepares    map[int]vote
+	prepared    bool
+	batches     map[string][][]byte // digest -> transactions
+	commits     map[commitKey]map[int]bool
+	decision    *decision
+}
+
+// A share or signature a message carries, verified together with the
+// others of the message
+type check struct {
+	share bool
+	data  []byte
+	proof []byte
+}
+
+type checkpointKey struct {
+	seq   uint64
+	state string
+}
+
+// PBFT is a classic PBFT replica, the partially synchronous baseline next
+// to HotStuff. The primary of view v is node v mod n + 1, it assigns
+// sequence numbers to batches in PRE-PREPARE, PREPARE messages carry
+// threshold signature shares and n-f of them form the prepared certificate
+// that later proves the batch in a view change. A batch commits once n-f
+// nodes prepared it and batches execute in sequence order.
+//
+// Every CheckpointInterval batches the nodes sign the state digest, n-f
+// shares make the checkpoint stable, older slots are dropped and the
+// window of acceptable sequence numbers moves on. Replicas with pending
+// work that see no progress before the timeout move to the next view, the
+// new primary gathers n-f VIEW-CHANGE messages and sends them in NEW-VIEW
+// together with the pre-prepares every replica recomputes from them.
+//
+// Normal case messages of views not installed yet or of the next window
+// are kept up to viewWindow views above ours, and a sender only has the
+// messages of the two windows in these views kept. Of the view changes
+// only the highest view of each sender is kept.
+//
+// Certificates must be unique per view, so the crypto provider has to use
+// threshold n-f. Replicas lagging behind a stable checkpoint need state
+// transfer, which is not part of the baseline.
+//
+// With a write-ahead log every pre-prepare or prepare, prepared
+// certificate, view change, stable checkpoint and decided batch is
+// recorded before it is sent or applied. A restarted replica replays the
+// log, delivers the batches it executed before once more, returns to its
+// view and never votes for a different batch at a (view, seq) it voted on.
+// Each stable checkpoint is followed by what a replay needs beyond it and
+// the log before it is dropped, batches up to the checkpoint are then not
+// delivered again and the application needs state transfer
+type PBFT struct {
+	n           int
+	f           int
+	id          int
+	batchSize   int
+	timeout     time.Duration
+	interval    uint64
+	t           transport.Transport
+	cp          Crypto
+	net         *consensus.TransportNetwork
+	wal         *wal.WAL
+	executor    *executor.Executor
+	buffer      [][]byte
+	buffered    map[digest]bool
+	view        uint64
+	active      bool // false while changing view
+	changes     uint // views moved to since the last one installed
+	nextSeq     uint64
+	voted       map[voteKey][]byte // digest we pre-prepared or prepared
+	lastExec    uint64
+	state       []byte
+	slots       map[uint64]*slot
+	prepared    map[uint64]*pb.PBFTPrepared // our highest prepared certificate per seq
+	stable      *pb.PBFTCheckpointCert
+	states      map[uint64][]byte // state after executing checkpoint seqs
+	checkpoints map[checkpointKey]map[int][]byte
+	viewChanges map[uint64]map[int]*pb.PBFTViewChangeInfo
+	changedTo   map[int]uint64 // sender -> view of its view change we keep
+	newViewSent uint64
+	future      []*pb.PBFTMsg // normal case messages of views not installed yet
+	ahead       []*pb.PBFTMsg // normal case messages of the next window
+	held        map[int]int   // sender -> messages in future and ahead
+	outbox      []Block
+	timer       *time.Timer
+	submitCh    chan []byte
+	commitCh    chan Block
+	stopCh      chan struct{}
+	doneCh      chan struct{}
+}
+
+// Create replica and start consuming the transport
+func NewPBFT(cfg Config, t transport.Transport, cp Crypto) *PBFT {
+	p := &PBFT{
+		n:         cfg.N,
+		f:         consensus.MaxFaulty(cfg.N),
+		id:        cfg.ID,
+		batchSize: cfg.BatchSize,
+		timeout:   cfg.Timeout,
+		interval:  cfg.CheckpointInterval,
+		t:         t,
+		cp:        cp,
+		wal:       cfg.WAL,
+		executor:  cfg.Executor,
+		active:    true,
+	}
+	if p.batchSize <= 0 {
+		p.batchSize = 1
+	}
+	if p.timeout <= 0 {
+		p.timeout = time.Second
+	}
+	if p.interval == 0 {
+		p.interval = 16
+	}
+	p.net = consensus.NewTransportNetwork(p.id, t)
+	p.buffered = make(map[digest]bool)
+	p.slots = make(map[uint64]*slot)
+	p.voted = make(map[voteKey][]byte)
+	p.prepared = make(map[uint64]*pb.PBFTPrepared)
+	p.stable = &pb.PBFTCheckpointCert{}
+	p.states = make(map[uint64][]byte)
+	p.checkpoints = make(map[checkpointKey]map[int][]byte)
+	p.viewChanges = make(map[uint64]map[int]*pb.PBFTViewChangeInfo)
+	p.changedTo = make(map[int]uint64)
+	p.held = make(map[int]int)
+	p.submitCh = make(chan []byte, p.batchSize)
+	p.commitCh = make(chan Block, p.n)
+	p.stopCh = make(chan struct{})
+	p.doneCh = make(chan struct{})
+	if err := p.recover(); err != nil {
+		fmt.Printf("[Node:%d] pbft recover error due to : %s.\n", p.id, err)
+	}
+	p.timer = time.NewTimer(p.timeout)
+	go p.run()
+	return p
+}
+
+// Add transaction to the local buffer
+func (p *PBFT) Submit(tx []byte) {
+	select {
+	case p.submitCh <- tx:
+	case <-p.stopCh:
+	}
+}
+
+// Return a "read-only" channel of executed batches
+func (p *PBFT) Commit() <-chan Block {
+	return p.commitCh
+}
+
+// Stop replica, the transport is left to the caller
+func (p *PBFT) Stop() {
+	close(p.stopCh)
+	<-p.doneCh
+	p.net.Stop()
+}
+
+// Replay the write-ahead log, batches executed before the restart are
+// delivered again and the replica resumes in the view it last moved to
+func (p *PBFT) recover() error {
+	if p.wal == nil {
+		return nil
+	}
+	if err := p.recoverBase(); err != nil {
+		return err
+	}
+	err := p.wal.Replay(func(index uint64, data []byte) error {
+		rec := &pb.WALRecord{}
+		if err := proto.Unmarshal(data, rec); err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		switch rec.Type {
+		case pb.WALType_WALVote:
+			if rec.Seq > p.stable.Seq {
+				p.voted[voteKey{view: rec.View, seq: rec.Seq}] = rec.Digest
+			}
+		case pb.WALType_WALPrepared:
+			if c, ok := p.prepared[rec.Seq]; rec.Seq > p.stable.Seq && (!ok || rec.View > c.View) {
+				p.prepared[rec.Seq] = &pb.PBFTPrepared{View: rec.View, Seq: rec.Seq, Digest: rec.Digest, Txs: rec.Txs, Certificate: rec.Certificate}
+			}
+		case pb.WALType_WALViewChange:
+			p.view = rec.View
+			p.active = false
+		case pb.WALType_WALNewView:
+			p.view = rec.View
+			p.active = true
+		case pb.WALType_WALCheckpoint:
+			if rec.Seq > p.stable.Seq {
+				p.collect(&pb.PBFTCheckpointCert{Seq: rec.Seq, State: rec.Digest, Certificate: rec.Certificate})
+			}
+		case pb.WALType_WALDecide:
+			if rec.Seq <= p.lastExec {
+				// covered by the checkpoint we start from, or logged again
+				// after a checkpoint
+				return nil
+			}
+			if rec.Seq != p.lastExec+1 {
+				return fmt.Errorf("record %d: %w, decided seq %d after %d", index, wal.ErrCorrupt, rec.Seq, p.lastExec)
+			}
+			p.apply(&decision{view: rec.View, digest: rec.Digest, txs: rec.Txs})
+		}
+		return nil
+	})
+
+	for key := range p.voted {
+		if key.view < p.view {
+			delete(p.voted, key)
+		} else if key.seq > p.nextSeq {
+			p.nextSeq = key.seq
+		}
+	}
+	if p.nextSeq < p.lastExec {
+		p.nextSeq = p.lastExec
+	}
+	return err
+}
+
+// A truncated log no longer starts with the first batch, the replay then
+// starts from the latest stable checkpoint in it
+func (p *PBFT) recoverBase() error {
+	var base *pb.WALRecord
+	first := uint64(0)
+	err := p.wal.Replay(func(index uint64, data []byte) error {
+		rec := &pb.WALRecord{}
+		if err := proto.Unmarshal(data, rec); err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		switch rec.Type {
+		case pb.WALType_WALDecide:
+			if first == 0 {
+				first = rec.Seq
+			}
+		case pb.WALType_WALCheckpoint:
+			if base == nil || rec.Seq > base.Seq {
+				base = rec
+			}
+		}
+		return nil
+	})
+	if err != nil || base == nil || first == 1 {
+		return err
+	}
+	p.collect(&pb.PBFTCheckpointCert{Seq: base.Seq, State: base.Digest, Certificate: base.Certificate})
+	p.lastExec = base.Seq
+	p.state = base.Digest
+	p.states[base.Seq] = base.Digest
+	return nil
+}
+
+func (p *PBFT) run() {
+	defer close(p.doneCh)
+	defer func() { p.timer.Stop() }()
+	// batches replayed from the write-ahead log
+	if !p.flush() {
+		return
+	}
+	for {
+		stable := p.stable.Seq
+		select {
+		case <-p.stopCh:
+			return
+
+		case tx := <-p.submitCh:
+			d := sha256.Sum256(tx)
+			if !p.buffered[d] {
+				p.buffered[d] = true
+				p.buffer = append(p.buffer, tx)
+			}
+			p.tryPropose()
+
+		case <-p.timer.C:
+			if !p.active || p.hasPending() {
+				p.sendViewChange(p.view + 1)
+			} else {
+				p.resetTimer()
+			}
+
+		case v := <-p.t.Consume():
+			msg, err := message.Decode(v)
+			if err != nil {
+				continue
+			}
+			pbftMsg, ok := msg.(*pb.PBFTMsg)
+			if !ok {
+				continue
+			}
+			if err := p.handleMessage(pbftMsg); err != nil {
+				fmt.Printf("[Node:%d] pbft handle msg error due to : %s.\n", p.id, err)
+			}
+		}
+
+		for stable != p.stable.Seq {
+			stable = p.stable.Seq
+			p.replayAhead()
+		}
+		if !p.flush() {
+			return
+		}
+	}
+}
+
+// Keep a normal case message for later, up to viewWindow views above ours
+// and, per sender, pre-prepare, prepare and commit of every seq of two
+// windows in each of these views
+func (p *PBFT) hold(list *[]*pb.PBFTMsg, sender int, msg *pb.PBFTMsg) {
+	quota := 3 * 4 * p.interval * (viewWindow + 1)
+	if msg.View > p.view+viewWindow || uint64(p.held[sender]) >= quota {
+		return
+	}
+	p.held[sender]++
+	*list = append(*list, msg)
+}
+
+// Take kept messages out of the quotas of their senders
+func (p *PBFT) release(msgs []*pb.PBFTMsg) {
+	for _, msg := range msgs {
+		sender := int(msg.Sender)
+		p.held[sender]--
+		if p.held[sender] == 0 {
+			delete(p.held, sender)
+		}
+	}
+}
+
+// Handle messages kept for the window we just moved to
+func (p *PBFT) replayAhead() {
+	ahead := p.ahead
+	p.ahead = nil
+	p.release(ahead)
+	for _, msg := range ahead {
+		if err := p.handleMessage(msg); err != nil {
+			fmt.Printf("[Node:%d] pbft handle msg error due to : %s.\n", p.id, err)
+		}
+	}
+}
+
+func (p *PBFT) handleMessage(msg *pb.PBFTMsg) error {
+	sender := int(msg.Sender)
+	if sender < 1 || sender > p.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	switch msg.Type {
+	case pb.PBFTType_PBFTPrePrepare, pb.PBFTType_PBFTPrepare, pb.PBFTType_PBFTCommit:
+		if msg.Seq <= p.stable.Seq {
+			return nil
+		}
+		if msg.Seq > p.stable.Seq+2*p.interval {
+			// the others may already have moved the window
+			if msg.Seq <= p.stable.Seq+4*p.interval {
+				p.hold(&p.ahead, sender, msg)
+			}
+			return nil
+		}
+		if msg.View < p.view {
+			switch msg.Type {
+			case pb.PBFTType_PBFTPrePrepare:
+				return p.learnBatch(sender, msg)
+			case pb.PBFTType_PBFTCommit:
+				return p.handleCommit(sender, msg)
+			}
+			return nil
+		}
+		if msg.View > p.view || !p.active {
+			p.hold(&p.future, sender, msg)
+			return nil
+		}
+		switch msg.Type {
+		case pb.PBFTType_PBFTPrePrepare:
+			return p.handlePrePrepare(sender, msg)
+		case pb.PBFTType_PBFTPrepare:
+			return p.handlePrepare(sender, msg)
+		default:
+			return p.handleCommit(sender, msg)
+		}
+	case pb.PBFTType_PBFTCheckpoint:
+		return p.handleCheckpoint(sender, msg)
+	case pb.PBFTType_PBFTViewChange:
+		return p.handleViewChange(sender, msg.ViewChange)
+	case pb.PBFTType_PBFTNewView:
+		return p.handleNewView(sender, msg)
+	}
+	return fmt.Errorf("unknown pbft message type %d", msg.Type)
+}
+
+// Accept the first batch the primary assigns to a sequence number in this
+// view and prepare it
+func (p *PBFT) handlePrePrepare(sender int, msg *pb.PBFTMsg) error {
+	if sender != p.primary(msg.View) {
+		return fmt.Errorf("%w from %d", ErrInvalidPrePrepare, sender)
+	}
+	d := batchDigest(msg.Txs)
+	if !bytes.Equal(d[:], msg.Digest) {
+		return fmt.Errorf("%w from %d, digest mismatch", ErrInvalidPrePrepare, sender)
+	}
+	s := p.slot(msg.Seq)
+	if s.prePrepared {
+		if !bytes.Equal(s.digest, msg.Digest) {
+			return fmt.Errorf("%w from %d, seq %d already assigned", ErrInvalidPrePrepare, sender, msg.Seq)
+		}
+		return nil
+	}
+	if s.decision != nil && !bytes.Equal(s.decision.digest, msg.Digest) {
+		return fmt.Errorf("%w from %d, seq %d committed another batch", ErrInvalidPrePrepare, sender, msg.Seq)
+	}
+	if err := p.vote(msg.Seq, msg.Digest); err != nil {
+		return fmt.Errorf("prepare seq %d: %w", msg.Seq, err)
+	}
+	s.prePrepared = true
+	s.digest = msg.Digest
+	s.txs = msg.Txs
+	s.batches[string(msg.Digest)] = msg.Txs
+	if msg.Seq > p.nextSeq {
+		p.nextSeq = msg.Seq
+	}
+
+	p.net.Broadcast(&pb.PBFTMsg{
+		Type:   pb.PBFTType_PBFTPrepare,
+		Sender: int64(p.id),
+		View:   p.view,
+		Seq:    msg.Seq,
+		Digest: msg.Digest,
+		Share:  p.cp.ComputeShare(prepareData(p.view, msg.Seq, msg.Digest)),
+	})
+	return p.update(msg.Seq)
+}
+
+// Remember the batch of a pre-prepare from an older view, commits of that
+// view may still arrive
+func (p *PBFT) learnBatch(sender int, msg *pb.PBFTMsg) error {
+	if sender != p.primary(msg.View) {
+		return fmt.Errorf("%w from %d", ErrInvalidPrePrepare, sender)
+	}
+	d := batchDigest(msg.Txs)
+	if !bytes.Equal(d[:], msg.Digest) {
+		return fmt.Errorf("%w from %d, digest mismatch", ErrInvalidPrePrepare, sender)
+	}
+	s := p.slot(msg.Seq)
+	s.batches[string(msg.Digest)] = msg.Txs
+	p.tryCommit(msg.Seq)
+	return nil
+}
+
+func (p *PBFT) handlePrepare(sender int, msg *pb.PBFTMsg) error {
+	s := p.slot(msg.Seq)
+	if _, ok := s.prepares[sender]; ok {
+		return nil
+	}
+	if !p.cp.VerifyShare(prepareData(msg.View, msg.Seq, msg.Digest), msg.Share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	s.prepares[sender] = vote{digest: msg.Digest, share: msg.Share}
+	return p.update(msg.Seq)
+}
+
+func (p *PBFT) handleCommit(sender int, msg *pb.PBFTMsg) error {
+	s := p.slot(msg.Seq)
+	key := commitKey{view: msg.View, digest: string(msg.Digest)}
+	if s.commits[key] == nil {
+		s.commits[key] = make(map[int]bool)
+	}
+	if s.commits[key][sender] {
+		return nil
+	}
+	s.commits[key][sender] = true
+	return p.update(msg.Seq)
+}
+
+// Prepare with n-f matching shares, commit with n-f matching COMMITs
+func (p *PBFT) update(seq uint64) error {
+	p.tryPrepare(seq)
+	p.tryCommit(seq)
+	return nil
+}
+
+// Combine n-f prepares on the pre-prepared batch into its certificate
+func (p *PBFT) tryPrepare(seq uint64) {
+	s := p.slot(seq)
+	if !s.prePrepared || s.prepared {
+		return
+	}
+	shares := make([][]byte, 0, len(s.prepares))
+	for _, v := range s.prepares {
+		if bytes.Equal(v.digest, s.digest) {
+			shares = append(shares, v.share)
+		}
+	}
+	if len(shares) < p.n-p.f {
+		return
+	}
+	certificate := p.cp.Combine(prepareData(s.view, seq, s.digest), shares)
+	if certificate == nil {
+		return
+	}
+	err := p.persist(&pb.WALRecord{Type: pb.WALType_WALPrepared, View: s.view, Seq: seq, Digest: s.digest, Txs: s.txs, Certificate: certificate})
+	if err != nil {
+		fmt.Printf("[Node:%d] pbft wal error due to : %s.\n", p.id, err)
+		return
+	}
+	s.prepared = true
+	p.prepared[seq] = &pb.PBFTPrepared{View: s.view, Seq: seq, Digest: s.digest, Txs: s.txs, Certificate: certificate}
+	p.net.Broadcast(&pb.PBFTMsg{
+		Type:   pb.PBFTType_PBFTCommit,
+		Sender: int64(p.id),
+		View:   s.view,
+		Seq:    seq,
+		Digest: s.digest,
+	})
+}
+
+// Commit a batch with n-f COMMITs of any view, f+1 correct nodes prepared
+// it in that view so every later view keeps it at this seq
+func (p *PBFT) tryCommit(seq uint64) {
+	s := p.slots[seq]
+	if s == nil || s.decision != nil {
+		return
+	}
+	for key, senders := range s.commits {
+		if len(senders) < p.n-p.f {
+			continue
+		}
+		if txs, ok := s.batches[key.digest]; ok {
+			s.decision = &decision{view: key.view, digest: []byte(key.digest), txs: txs}
+			p.execute()
+			return
+		}
+	}
+}
+
+// Execute committed batches in sequence order
+func (p *PBFT) execute() {
+	for {
+		s, ok := p.slots[p.lastExec+1]
+		if !ok || s.decision == nil {
+			return
+		}
+		d := s.decision
+		err := p.persist(&pb.WALRecord{Type: pb.WALType_WALDecide, View: d.view, Seq: p.lastExec + 1, Digest: d.digest, Txs: d.txs})
+		if err != nil {
+			// executing without the record could fork the replayed state
+			fmt.Printf("[Node:%d] pbft wal error due to : %s.\n", p.id, err)
+			return
+		}
+		p.apply(d)
+		p.removeExecuted(d.txs)
+		p.resetTimer()
+
+		if p.lastExec%p.interval == 0 {
+			p.net.Broadcast(&pb.PBFTMsg{
+				Type:   pb.PBFTType_PBFTCheckpoint,
+				Sender: int64(p.id),
+				Seq:    p.lastExec,
+				Digest: p.state,
+				Share:  p.cp.ComputeShare(checkpointData(p.lastExec, p.state)),
+			})
+			p.stabilize(p.lastExec)
+		}
+		p.tryPropose()
+	}
+}
+
+// Execute the next batch, the state is the hash chain of executed digests
+func (p *PBFT) apply(d *decision) {
+	p.lastExec++
+	state := sha256.Sum256(append(append([]byte{}, p.state...), d.digest...))
+	p.state = state[:]
+	p.outbox = append(p.outbox, Block{Seq: p.lastExec, View: d.view, Txs: d.txs})
+	if p.lastExec%p.interval == 0 {
+		p.states[p.lastExec] = p.state
+	}
+}
+
+func (p *PBFT) handleCheckpoint(sender int, msg *pb.PBFTMsg) error {
+	if msg.Seq <= p.stable.Seq || msg.Seq%p.interval != 0 {
+		return nil
+	}
+	key := checkpointKey{seq: msg.Seq, state: string(msg.Digest)}
+	if p.checkpoints[key] == nil {
+		p.checkpoints[key] = make(map[int][]byte)
+	}
+	if _, ok := p.checkpoints[key][sender]; ok {
+		return nil
+	}
+	if !p.cp.VerifyShare(checkpointData(msg.Seq, msg.Digest), msg.Share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	p.checkpoints[key][sender] = msg.Share
+	p.stabilize(msg.Seq)
+	return nil
+}
+
+// Make the checkpoint at seq stable once n-f nodes signed our state, then
+// forget everything up to it
+func (p *PBFT) stabilize(seq uint64) {
+	state, ok := p.states[seq]
+	if !ok || seq <= p.stable.Seq {
+		return
+	}
+	votes := p.checkpoints[checkpointKey{seq: seq, state: string(state)}]
+	if len(votes) < p.n-p.f {
+		return
+	}
+	shares := make([][]byte, 0, len(votes))
+	for _, share := range votes {
+		shares = append(shares, share)
+	}
+	certificate := p.cp.Combine(checkpointData(seq, state), shares)
+	if certificate == nil {
+		return
+	}
+	p.adoptCheckpoint(&pb.PBFTCheckpointCert{Seq: seq, State: state, Certificate: certificate})
+}
+
+func (p *PBFT) adoptCheckpoint(cp *pb.PBFTCheckpointCert) {
+	var index uint64
+	if p.wal != nil {
+		index = p.wal.NextIndex()
+	}
+	err := p.persist(&pb.WALRecord{Type: pb.WALType_WALCheckpoint, Seq: cp.Seq, Digest: cp.State, Certificate: cp.Certificate})
+	if err != nil {
+		// the checkpoint is certified, losing it only costs the window
+		fmt.Printf("[Node:%d] pbft wal error due to : %s.\n", p.id, err)
+	}
+	p.collect(cp)
+	if err == nil {
+		if err := p.compact(index); err != nil {
+			fmt.Printf("[Node:%d] pbft wal error due to : %s.\n", p.id, err)
+		}
+	}
+	p.tryPropose()
+}
+
+// Log again what a replay needs beyond the stable checkpoint recorded at
+// index, the batches executed after it, our view, votes and prepared
+// certificates, then drop the log before the checkpoint
+func (p *PBFT) compact(index uint64) error {
+	if p.wal == nil || p.stable.Seq > p.lastExec {
+		// lagging behind the checkpoint, the log still has to get there
+		return nil
+	}
+	records := make([]*pb.WALRecord, 0)
+	for seq := p.stable.Seq + 1; seq <= p.lastExec; seq++ {
+		s, ok := p.slots[seq]
+		if !ok || s.decision == nil {
+			// replayed before a restart, the next checkpoint compacts
+			return nil
+		}
+		d := s.decision
+		records = append(records, &pb.WALRecord{Type: pb.WALType_WALDecide, View: d.view, Seq: seq, Digest: d.digest, Txs: d.txs})
+	}
+	if p.active {
+		records = append(records, &pb.WALRecord{Type: pb.WALType_WALNewView, View: p.view})
+	} else {
+		records = append(records, &pb.WALRecord{Type: pb.WALType_WALViewChange, View: p.view})
+	}
+	for key, d := range p.voted {
+		records = append(records, &pb.WALRecord{Type: pb.WALType_WALVote, View: key.view, Seq: key.seq, Digest: d})
+	}
+	for _, c := range p.prepared {
+		records = append(records, &pb.WALRecord{Type: pb.WALType_WALPrepared, View: c.View, Seq: c.Seq, Digest: c.Digest, Txs: c.Txs, Certificate: c.Certificate})
+	}
+	for _, rec := range records {
+		if err := p.persist(rec); err != nil {
+			return err
+		}
+	}
+	return p.wal.TruncateBefore(index)
+}
+
+// Move the window above a stable checkpoint and drop what is below
+func (p *PBFT) collect(cp *pb.PBFTCheckpointCert) {
+	p.stable = cp
+	for seq := range p.slots {
+		if seq <= cp.Seq {
+			delete(p.slots, seq)
+		}
+	}
+	for seq := range p.prepared {
+		if seq <= cp.Seq {
+			delete(p.prepared, seq)
+		}
+	}
+	for seq := range p.states {
+		if seq < cp.Seq {
+			delete(p.states, seq)
+		}
+	}
+	for key := range p.checkpoints {
+		if key.seq <= cp.Seq {
+			delete(p.checkpoints, key)
+		}
+	}
+	for key := range p.voted {
+		if key.seq <= cp.Seq {
+			delete(p.voted, key)
+		}
+	}
+	if p.nextSeq < cp.Seq {
+		p.nextSeq = cp.Seq
+	}
+}
+
+// Stop working in the current view and ask to move to view v
+func (p *PBFT) sendViewChange(v uint64) {
+	if v < p.view || (v == p.view && !p.active) {
+		return
+	}
+	if err := p.persist(&pb.WALRecord{Type: pb.WALType_WALViewChange, View: v}); err != nil {
+		fmt.Printf("[Node:%d] pbft wal error due to : %s.\n", p.id, err)
+		return
+	}
+	p.view = v
+	p.active = false
+	if p.changes < 8 {
+		p.changes++
+	}
+	p.resetTimer()
+
+	vc := &pb.PBFTViewChangeInfo{View: v, Sender: int64(p.id), Checkpoint: p.stable}
+	seqs := make([]uint64, 0, len(p.prepared))
+	for seq := range p.prepared {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	for _, seq := range seqs {
+		vc.Prepared = append(vc.Prepared, p.prepared[seq])
+	}
+	vc.Signature = p.cp.ComputeShare(viewChangeData(vc))
+	p.net.Broadcast(&pb.PBFTMsg{Type: pb.PBFTType_PBFTViewChange, Sender: int64(p.id), View: v, ViewChange: vc})
+}
+
+// Join a view change f+1 nodes asked for, the new primary sends NEW-VIEW
+// once n-f nodes asked for its view
+func (p *PBFT) handleViewChange(sender int, vc *pb.PBFTViewChangeInfo) error {
+	if vc == nil || int(vc.Sender) != sender {
+		return fmt.Errorf("%w from %d", ErrInvalidViewChange, sender)
+	}
+	if vc.View < p.view || (vc.View == p.view && p.active) {
+		return nil
+	}
+	kept, ok := p.changedTo[sender]
+	if ok && kept >= vc.View {
+		return nil
+	}
+	if err := p.verifyViewChange(vc); err != nil {
+		return fmt.Errorf("%w from %d", err, sender)
+	}
+	if ok {
+		delete(p.viewChanges[kept], sender)
+	}
+	p.changedTo[sender] = vc.View
+	if p.viewChanges[vc.View] == nil {
+		p.viewChanges[vc.View] = make(map[int]*pb.PBFTViewChangeInfo)
+	}
+	p.viewChanges[vc.View][sender] = vc
+
+	// the smallest view above ours that f+1 nodes moved to
+	senders := make(map[int]bool)
+	var target uint64
+	for v, vcs := range p.viewChanges {
+		if v <= p.view {
+			continue
+		}
+		for s := range vcs {
+			senders[s] = true
+		}
+		if target == 0 || v < target {
+			target = v
+		}
+	}
+	if len(senders) >= p.f+1 {
+		p.sendViewChange(target)
+	}
+
+	v := p.view
+	if p.primary(v) != p.id || p.active || p.newViewSent >= v || len(p.viewChanges[v]) < p.n-p.f {
+		return nil
+	}
+	ids := make([]int, 0, len(p.viewChanges[v]))
+	for id := range p.viewChanges[v] {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	proof := make([]*pb.PBFTViewChangeInfo, 0, p.n-p.f)
+	for _, id := range ids[:p.n-p.f] {
+		proof = append(proof, p.viewChanges[v][id])
+	}
+	_, prePrepares := p.newViewBatches(v, proof)
+	p.newViewSent = v
+	p.net.Broadcast(&pb.PBFTMsg{
+		Type:        pb.PBFTType_PBFTNewView,
+		Sender:      int64(p.id),
+		View:        v,
+		ViewChanges: proof,
+		PrePrepares: prePrepares,
+	})
+	return nil
+}
+
+// Check the proof of the new primary and install the view
+func (p *PBFT) handleNewView(sender int, msg *pb.PBFTMsg) error {
+	v := msg.View
+	if v < p.view || (v == p.view && p.active) {
+		return nil
+	}
+	if sender != p.primary(v) {
+		return fmt.Errorf("%w from %d", ErrInvalidNewView, sender)
+	}
+	senders := make(map[int]bool)
+	var checks []check
+	for _, vc := range msg.ViewChanges {
+		if vc == nil || vc.View != v || senders[int(vc.Sender)] {
+			return fmt.Errorf("%w from %d", ErrInvalidNewView, sender)
+		}
+		vcChecks, err := p.viewChangeChecks(vc)
+		if err != nil {
+			return fmt.Errorf("%w from %d, %s", ErrInvalidNewView, sender, err)
+		}
+		checks = append(checks, vcChecks...)
+		senders[int(vc.Sender)] = true
+	}
+	if len(senders) < p.n-p.f {
+		return fmt.Errorf("%w from %d, only %d view changes", ErrInvalidNewView, sender, len(senders))
+	}
+	if !p.verifyAll(checks) {
+		return fmt.Errorf("%w from %d, bad signature in view changes", ErrInvalidNewView, sender)
+	}
+	checkpoint, prePrepares := p.newViewBatches(v, msg.ViewChanges)
+	if len(prePrepares) != len(msg.PrePrepares) {
+		return fmt.Errorf("%w from %d, wrong pre-prepares", ErrInvalidNewView, sender)
+	}
+	for i, pp := range prePrepares {
+		if pp.Seq != msg.PrePrepares[i].Seq || !bytes.Equal(pp.Digest, msg.PrePrepares[i].Digest) {
+			return fmt.Errorf("%w from %d, wrong pre-prepares", ErrInvalidNewView, sender)
+		}
+	}
+	if err := p.persist(&pb.WALRecord{Type: pb.WALType_WALNewView, View: v}); err != nil {
+		return fmt.Errorf("install view %d: %w", v, err)
+	}
+
+	p.view = v
+	p.active = true
+	p.changes = 0
+	p.resetTimer()
+	for view := range p.viewChanges {
+		if view <= v {
+			delete(p.viewChanges, view)
+		}
+	}
+	for sender, view := range p.changedTo {
+		if view <= v {
+			delete(p.changedTo, sender)
+		}
+	}
+	for key := range p.voted {
+		if key.view < v {
+			delete(p.voted, key)
+		}
+	}
+	if checkpoint.Seq > p.stable.Seq && checkpoint.Seq <= p.lastExec {
+		p.adoptCheckpoint(checkpoint)
+	}
+	// slots above the new view batches were never committed anywhere
+	maxSeq := checkpoint.Seq
+	if len(prePrepares) > 0 {
+		maxSeq = prePrepares[len(prePrepares)-1].Seq
+	}
+	for seq, s := range p.slots {
+		if seq > maxSeq && s.decision == nil {
+			delete(p.slots, seq)
+		}
+	}
+	p.nextSeq = maxSeq
+	if p.nextSeq < p.stable.Seq {
+		p.nextSeq = p.stable.Seq
+	}
+
+	for _, pp := range prePrepares {
+		if pp.Seq <= p.stable.Seq {
+			continue
+		}
+		if err := p.handlePrePrepare(sender, pp); err != nil {
+			return err
+		}
+	}
+	future := p.future
+	p.future = nil
+	p.release(future)
+	for _, m := range future {
+		if err := p.handleMessage(m); err != nil {
+			fmt.Printf("[Node:%d] pbft handle msg error due to : %s.\n", p.id, err)
+		}
+	}
+	p.tryPropose()
+	return nil
+}
+
+// Latest stable checkpoint of the view changes and the batches of view v
+// after it, the prepared batch of the highest view for every seq and
+// empty batches for the gaps
+func (p *PBFT) newViewBatches(v uint64, vcs []*pb.PBFTViewChangeInfo) (*pb.PBFTCheckpointCert, []*pb.PBFTMsg) {
+	checkpoint := vcs[0].Checkpoint
+	for _, vc := range vcs {
+		if vc.Checkpoint.Seq > checkpoint.Seq {
+			checkpoint = vc.Checkpoint
+		}
+	}
+	chosen := make(map[uint64]*pb.PBFTPrepared)
+	var maxSeq uint64
+	for _, vc := range vcs {
+		for _, prepared := range vc.Prepared {
+			if prepared.Seq <= checkpoint.Seq {
+				continue
+			}
+			if c, ok := chosen[prepared.Seq]; !ok || prepared.View > c.View {
+				chosen[prepared.Seq] = prepared
+			}
+			if prepared.Seq > maxSeq {
+				maxSeq = prepared.Seq
+			}
+		}
+	}
+
+	prePrepares := make([]*pb.PBFTMsg, 0)
+	for seq := checkpoint.Seq + 1; seq <= maxSeq; seq++ {
+		msg := &pb.PBFTMsg{Type: pb.PBFTType_PBFTPrePrepare, Sender: int64(p.primary(v)), View: v, Seq: seq}
+		if prepared, ok := chosen[seq]; ok {
+			msg.Digest = prepared.Digest
+			msg.Txs = prepared.Txs
+		} else {
+			d := batchDigest(nil)
+			msg.Digest = d[:]
+		}
+		prePrepares = append(prePrepares, msg)
+	}
+	return checkpoint, prePrepares
+}
+
+// Check the signature of a view change and every certificate it carries
+func (p *PBFT) verifyViewChange(vc *pb.PBFTViewChangeInfo) error {
+	checks, err := p.viewChangeChecks(vc)
+	if err != nil {
+		return err
+	}
+	if !p.verifyAll(checks) {
+		return fmt.Errorf("%w, bad signature or certificate", ErrInvalidViewChange)
+	}
+	return nil
+}
+
+// Check the structure of a view change and list the signature and the
+// certificates it carries
+func (p *PBFT) viewChangeChecks(vc *pb.PBFTViewChangeInfo) ([]check, error) {
+	index, ok := p.cp.ShareIndex(vc.Signature)
+	if !ok || index != int(vc.Sender)-1 {
+		return nil, ErrInvalidViewChange
+	}
+	checks := []check{{share: true, data: viewChangeData(vc), proof: vc.Signature}}
+	cp := vc.Checkpoint
+	if cp == nil {
+		return nil, fmt.Errorf("%w, missing checkpoint", ErrInvalidViewChange)
+	}
+	if cp.Seq > 0 {
+		checks = append(checks, check{data: checkpointData(cp.Seq, cp.State), proof: cp.Certificate})
+	}
+	for _, prepared := range vc.Prepared {
+		if prepared == nil || prepared.View >= vc.View {
+			return nil, fmt.Errorf("%w, bad prepared certificate", ErrInvalidViewChange)
+		}
+		if prepared.Seq <= cp.Seq || prepared.Seq > cp.Seq+2*p.interval {
+			return nil, fmt.Errorf("%w, prepared seq %d out of window", ErrInvalidViewChange, prepared.Seq)
+		}
+		d := batchDigest(prepared.Txs)
+		if !bytes.Equal(d[:], prepared.Digest) {
+			return nil, fmt.Errorf("%w, bad prepared certificate", ErrInvalidViewChange)
+		}
+		checks = append(checks, check{data: prepareData(prepared.View, prepared.Seq, prepared.Digest), proof: prepared.Certificate})
+	}
+	return checks, nil
+}
+
+// Verify every check, on the executor if there is one
+func (p *PBFT) verifyAll(checks []check) bool {
+	if p.executor == nil {
+		for _, c := range checks {
+			if c.share && !p.cp.VerifyShare(c.data, c.proof) || !c.share && !p.cp.VerifySignature(c.data, c.proof) {
+				return false
+			}
+		}
+		return true
+	}
+	futures := make([]*executor.Future, len(checks))
+	for i, c := range checks {
+		if c.share {
+			futures[i] = p.executor.VerifyShare(c.data, c.proof)
+		} else {
+			futures[i] = p.executor.VerifySignature(c.data, c.proof)
+		}
+	}
+	valid := true
+	for _, f := range futures {
+		if ok, err := f.Result(); !ok || err != nil {
+			valid = false
+		}
+	}
+	return valid
+}
+
+// Primary assigns the next sequence numbers to buffered transactions that
+// are not ordered yet, within the window above the stable checkpoint
+func (p *PBFT) tryPropose() {
+	if !p.active || p.primary(p.view) != p.id {
+		return
+	}
+	ordered := make(map[digest]bool)
+	for seq, s := range p.slots {
+		if seq <= p.lastExec || !s.prePrepared {
+			continue
+		}
+		for _, tx := range s.txs {
+			ordered[sha256.Sum256(tx)] = true
+		}
+	}
+
+	for p.nextSeq < p.stable.Seq+2*p.interval {
+		batch := make([][]byte, 0, p.batchSize)
+		for _, tx := range p.buffer {
+			if len(batch) == p.batchSize {
+				break
+			}
+			d := sha256.Sum256(tx)
+			if !ordered[d] {
+				ordered[d] = true
+				batch = append(batch, tx)
+			}
+		}
+		if len(batch) == 0 {
+			return
+		}
+		d := batchDigest(batch)
+		if err := p.vote(p.nextSeq+1, d[:]); err != nil {
+			fmt.Printf("[Node:%d] pbft pre-prepare seq %d error due to : %s.\n", p.id, p.nextSeq+1, err)
+			return
+		}
+		p.nextSeq++
+		p.net.Broadcast(&pb.PBFTMsg{
+			Type:   pb.PBFTType_PBFTPrePrepare,
+			Sender: int64(p.id),
+			View:   p.view,
+			Seq:    p.nextSeq,
+			Digest: d[:],
+			Txs:    batch,
+		})
+	}
+}
+
+// Slot of seq, the prepare phase of an older view starts over
+func (p *PBFT) slot(seq uint64) *slot {
+	s, ok := p.slots[seq]
+	if !ok {
+		s = &slot{
+			view:     p.view,
+			prepares: make(map[int]vote),
+			batches:  make(map[string][][]byte),
+			commits:  make(map[commitKey]map[int]bool),
+		}
+		p.slots[seq] = s
+	}
+	if s.view != p.view {
+		s.view = p.view
+		s.digest = nil
+		s.txs = nil
+		s.prePrepared = false
+		s.prepared = false
+		s.prepares = make(map[int]vote)
+	}
+	return s
+}
+
+// Work the primary should make progress on
+func (p *PBFT) hasPending() bool {
+	if len(p.buffer) > 0 {
+		return true
+	}
+	for seq, s := range p.slots {
+		if seq > p.lastExec && s.prePrepared {
+			return true
+		}
+	}
+	return false
+}
+
+// The timeout doubles with every view we move to without installing it,
+// so that correct nodes eventually stay long enough in the same view
+func (p *PBFT) resetTimer() {
+	timeout := p.timeout
+	if !p.active {
+		timeout <<= p.changes
+	}
+	p.timer.Stop()
+	p.timer = time.NewTimer(timeout)
+}
+
+// Record a pre-prepare or prepare before sending it, a replica votes for
+// one batch per view and seq even across restarts
+func (p *PBFT) vote(seq uint64, d []byte) error {
+	key := voteKey{view: p.view, seq: seq}
+	if voted, ok := p.voted[key]; ok {
+		if !bytes.Equal(voted, d) {
+			return ErrEquivocation
+		}
+		return nil
+	}
+	if err := p.persist(&pb.WALRecord{Type: pb.WALType_WALVote, View: p.view, Seq: seq, Digest: d}); err != nil {
+		return err
+	}
+	p.voted[key] = d
+	return nil
+}
+
+// Append a record to the write-ahead log, if there is one
+func (p *PBFT) persist(rec *pb.WALRecord) error {
+	if p.wal == nil {
+		return nil
+	}
+	data, err := proto.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = p.wal.Append(data)
+	return err
+}
+
+// Send executed batches, false if stopped meanwhile
+func (p *PBFT) flush() bool {
+	for len(p.outbox) > 0 {
+		select {
+		case p.commitCh <- p.outbox[0]:
+			p.outbox = p.outbox[1:]
+		case <-p.stopCh:
+			return false
+		}
+	}
+	return true
+}
+
+func (p *PBFT) removeExecuted(txs [][]byte) {
+	if len(txs) == 0 {
+		return
+	}
+	executed := make(map[digest]bool, len(txs))
+	for _, tx := range txs {
+		executed[sha256.Sum256(tx)] = true
+	}
+	remaining := p.buffer[:0]
+	for _, tx := range p.buffer {
+		d := sha256.Sum256(tx)
+		if executed[d] {
+			delete(p.buffered, d)
+			continue
+		}
+		remaining = append(remaining, tx)
+	}
+	p.buffer = remaining
+}
+
+func (p *PBFT) primary(v uint64) int {
+	return int(v%uint64(p.n)) + 1
+}
+
+// Digest of a batch, transactions are length prefixed
+func batchDigest(txs [][]byte) digest {
+	h := sha256.New()
+	var size [8]byte
+	for _, tx := range txs {
+		binary.BigEndian.PutUint64(size[:], uint64(len(tx)))
+		h.Write(size[:])
+		h.Write(tx)
+	}
+	var d digest
+	copy(d[:], h.Sum(nil))
+	return d
+}
+
+// Data signed by prepares
+func prepareData(v, seq uint64, d []byte) []byte {
+	return []byte(fmt.Sprintf("pbft-prepare|%d|%d|%x", v, seq, d))
+}
+
+// Data signed by checkpoints
+func checkpointData(seq uint64, state []byte) []byte {
+	return []byte(fmt.Sprintf("pbft-checkpoint|%d|%x", seq, state))
+}
+
+// Data signed by view changes, everything but the signature
+func viewChangeData(vc *pb.PBFTViewChangeInfo) []byte {
+	unsigned := &pb.PBFTViewChangeInfo{View: vc.View, Sender: vc.Sender, Checkpoint: vc.Checkpoint, Prepared: vc.Prepared}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(unsigned)
+	if err != nil {
+		// view changes only hold scalars, bytes and nested messages
+		panic(err)
+	}
+	return []byte(fmt.Sprintf("pbft-view-change|%x", sha256.Sum256(data)))
+}
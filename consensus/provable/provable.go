@@ -0,0 +1,172 @@
+package provable
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+)
+
+var (
+	ErrNotProposer   = errors.New("only the proposer can input a value")
+	ErrInvalidSender = errors.New("invalid sender")
+	ErrWrongInstance = errors.New("message belongs to another instance")
+	ErrInvalidValue  = errors.New("value is not externally valid")
+	ErrInvalidShare  = errors.New("invalid signature share")
+)
+
+// PB is one instance of provable broadcast. Receivers sign the first
+// externally valid value of the proposer, and the proposer combines n-f
+// signature shares into a certificate that any node checks with Verify.
+// With threshold n-f a certificate proves that f+1 honest nodes hold the
+// value and that no other value of this instance can be certified.
+//
+// PB has no broadcaster of its own, MVBA runs its instances within the
+// views it keeps messages for
+type PB struct {
+	n           int
+	f           int
+	id          int
+	epoch       uint64
+	round       uint32
+	proposer    int
+	net         consensus.Network
+	cp          crypto.CryptoProvider
+	validate    consensus.Validator
+	proposed    bool
+	value       []byte         // our input, proposer only
+	shares      map[int][]byte // sender -> share, proposer only
+	certificate []byte
+	delivered   bool
+	output      []byte
+}
+
+// Create PB instance (epoch, round, proposer) of node id, values failing
+// validate are never signed
+func NewPB(n, id int, epoch uint64, round uint32, proposer int, net consensus.Network, cp crypto.CryptoProvider, validate consensus.Validator) *PB {
+	return &PB{
+		n:        n,
+		f:        consensus.MaxFaulty(n),
+		id:       id,
+		epoch:    epoch,
+		round:    round,
+		proposer: proposer,
+		net:      net,
+		cp:       cp,
+		validate: validate,
+		shares:   make(map[int][]byte),
+	}
+}
+
+// Proposer broadcasts its value, which must be externally valid
+func (p *PB) Input(value []byte) error {
+	if p.id != p.proposer {
+		return ErrNotProposer
+	}
+	if !p.validate(value) {
+		return ErrInvalidValue
+	}
+	p.proposed = true
+	p.value = value
+	msg := p.newMsg(pb.PBType_PBSend)
+	msg.Value = value
+	p.net.Broadcast(msg)
+	return nil
+}
+
+// Handle SEND and SHARE messages of this instance
+func (p *PB) HandleMessage(msg *pb.PBMsg) error {
+	sender := int(msg.Sender)
+	if sender < 1 || sender > p.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	if msg.Epoch != p.epoch || msg.Round != p.round || int(msg.Proposer) != p.proposer {
+		return ErrWrongInstance
+	}
+
+	switch msg.Type {
+	case pb.PBType_PBSend:
+		return p.handleSend(sender, msg.Value)
+	case pb.PBType_PBShare:
+		return p.handleShare(sender, msg.Share)
+	}
+	return fmt.Errorf("unknown pb message type %d", msg.Type)
+}
+
+// Value signed by this node, if any
+func (p *PB) Output() ([]byte, bool) {
+	return p.output, p.delivered
+}
+
+// Certificate of our own value, proposer only
+func (p *PB) Certificate() ([]byte, bool) {
+	return p.certificate, p.certificate != nil
+}
+
+// Sign the first valid value of the proposer
+func (p *PB) handleSend(sender int, value []byte) error {
+	if sender != p.proposer {
+		return fmt.Errorf("%w %d sending for %d", ErrInvalidSender, sender, p.proposer)
+	}
+	if p.delivered {
+		return nil
+	}
+	if !p.validate(value) {
+		return fmt.Errorf("%w from %d", ErrInvalidValue, sender)
+	}
+	p.delivered = true
+	p.output = value
+	msg := p.newMsg(pb.PBType_PBShare)
+	msg.Share = p.cp.ComputeShare(data(p.epoch, p.round, p.proposer, value))
+	p.net.SendToPeer(p.proposer, msg)
+	return nil
+}
+
+// Combine n-f shares on our value into its certificate
+func (p *PB) handleShare(sender int, share []byte) error {
+	if !p.proposed || p.certificate != nil {
+		return nil
+	}
+	if _, ok := p.shares[sender]; ok {
+		return nil
+	}
+	signed := data(p.epoch, p.round, p.proposer, p.value)
+	if !p.cp.VerifyShare(signed, share) {
+		return fmt.Errorf("%w from %d", ErrInvalidShare, sender)
+	}
+	p.shares[sender] = share
+	if len(p.shares) < p.n-p.f {
+		return nil
+	}
+
+	shares := make([][]byte, 0, len(p.shares))
+	for _, s := range p.shares {
+		shares = append(shares, s)
+	}
+	// nil while the threshold is higher than the shares we have
+	p.certificate = p.cp.Combine(signed, shares)
+	return nil
+}
+
+func (p *PB) newMsg(typ pb.PBType) *pb.PBMsg {
+	return &pb.PBMsg{
+		Type:     typ,
+		Sender:   int64(p.id),
+		Epoch:    p.epoch,
+		Round:    p.round,
+		Proposer: int64(p.proposer),
+	}
+}
+
+// Verify certificate of value in instance (epoch, round, proposer)
+func Verify(cp crypto.CryptoProvider, epoch uint64, round uint32, proposer int, value []byte, certificate []byte) bool {
+	return cp.VerifySignature(data(epoch, round, proposer, value), certificate)
+}
+
+// Data signed by receivers
+func data(epoch uint64, round uint32, proposer int, value []byte) []byte {
+	return []byte(fmt.Sprintf("pb|%d|%d|%d|%x", epoch, round, proposer, sha256.Sum256(value)))
+}
@@ -0,0 +1,169 @@
+package rbc
+
+import (
+	"fmt"
+
+	"github.com/stuck/consensus"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+)
+
+// Delivered value of instance (Epoch, Proposer)
+type Delivery struct {
+	Epoch    uint64
+	Proposer int
+	Value    []byte
+}
+
+type instanceID struct {
+	epoch    uint64
+	proposer int
+}
+
+type input struct {
+	epoch uint64
+	value []byte
+}
+
+const window = 16 // epochs above the current one messages are kept for
+
+// Broadcaster runs the RBC instances of one node over a transport,
+// all instances are driven by a single goroutine. The current epoch is
+// the latest one we broadcast or delivered in, messages are kept for at
+// most window epochs above it and a sender can only have the messages of
+// window epochs kept ahead, so a byzantine node cannot make us hold
+// instances nobody runs. Instances below the epoch passed to Stable are
+// dropped
+type Broadcaster struct {
+	n         int
+	id        int
+	t         transport.Transport
+	net       *consensus.TransportNetwork
+	instances map[instanceID]*RBC
+	guard     *consensus.EpochGuard
+	inputCh   chan input
+	stableCh  chan uint64
+	deliverCh chan Delivery
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+}
+
+// Create broadcaster of node id and start consuming the transport
+func NewBroadcaster(n, id int, t transport.Transport) *Broadcaster {
+	b := &Broadcaster{n: n, id: id, t: t}
+	b.net = consensus.NewTransportNetwork(id, t)
+	b.instances = make(map[instanceID]*RBC)
+	// SEND, ECHO and READY of a sender for every proposer
+	b.guard = consensus.NewEpochGuard(consensus.EpochLimits{Window: window, PerSender: 3 * n * window})
+	b.inputCh = make(chan input, n)
+	b.stableCh = make(chan uint64, 1)
+	b.deliverCh = make(chan Delivery, n*n)
+	b.stopCh = make(chan struct{})
+	b.doneCh = make(chan struct{})
+	go b.run()
+	return b
+}
+
+// Reliably broadcast value in instance (epoch, id)
+func (b *Broadcaster) Broadcast(epoch uint64, value []byte) {
+	select {
+	case b.inputCh <- input{epoch: epoch, value: value}:
+	case <-b.stopCh:
+	}
+}
+
+// Drop the instances below epoch, the caller is done with them
+func (b *Broadcaster) Stable(epoch uint64) {
+	select {
+	case b.stableCh <- epoch:
+	case <-b.stopCh:
+	}
+}
+
+// Messages kept per epoch
+func (b *Broadcaster) Usage() []consensus.EpochUsage {
+	return b.guard.Usage()
+}
+
+// Return a "read-only" channel of delivered values
+func (b *Broadcaster) Deliver() <-chan Delivery {
+	return b.deliverCh
+}
+
+// Stop broadcaster, the transport is left to the caller
+func (b *Broadcaster) Stop() {
+	close(b.stopCh)
+	<-b.doneCh
+	b.net.Stop()
+}
+
+func (b *Broadcaster) run() {
+	defer close(b.doneCh)
+	for {
+		select {
+		case <-b.stopCh:
+			return
+
+		case epoch := <-b.stableCh:
+			if b.guard.Stabilize(epoch) {
+				for id := range b.instances {
+					if id.epoch < epoch {
+						delete(b.instances, id)
+					}
+				}
+			}
+
+		case in := <-b.inputCh:
+			if in.epoch < b.guard.Stable() {
+				continue
+			}
+			b.guard.Advance(in.epoch)
+			r := b.instance(in.epoch, b.id)
+			if err := r.Input(in.value); err != nil {
+				fmt.Printf("[Node:%d] rbc input error due to : %s.\n", b.id, err)
+			}
+
+		case v := <-b.t.Consume():
+			msg, err := message.Decode(v)
+			if err != nil {
+				continue
+			}
+			rbcMsg, ok := msg.(*pb.RBCMsg)
+			if !ok {
+				continue
+			}
+			proposer, sender := int(rbcMsg.Proposer), int(rbcMsg.Sender)
+			if proposer < 1 || proposer > b.n || sender < 1 || sender > b.n {
+				continue
+			}
+			if !b.guard.Admit(rbcMsg.Epoch, sender, rbcMsg) {
+				continue
+			}
+			r := b.instance(rbcMsg.Epoch, proposer)
+			_, delivered := r.Output()
+			if err := r.HandleMessage(rbcMsg); err != nil {
+				fmt.Printf("[Node:%d] rbc handle msg error due to : %s.\n", b.id, err)
+				continue
+			}
+			if value, ok := r.Output(); ok && !delivered {
+				b.guard.Advance(rbcMsg.Epoch)
+				select {
+				case b.deliverCh <- Delivery{Epoch: rbcMsg.Epoch, Proposer: proposer, Value: value}:
+				case <-b.stopCh:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *Broadcaster) instance(epoch uint64, proposer int) *RBC {
+	id := instanceID{epoch: epoch, proposer: proposer}
+	r, ok := b.instances[id]
+	if !ok {
+		r = NewRBC(b.n, b.id, epoch, proposer, b.net)
+		b.instances[id] = r
+	}
+	return r
+}
@@ -0,0 +1,152 @@
+package rbc
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/stuck/consensus"
+	pb "github.com/stuck/message/messagepb"
+)
+
+var (
+	ErrNotProposer   = errors.New("only the proposer can input a value")
+	ErrInvalidSender = errors.New("invalid sender")
+	ErrWrongInstance = errors.New("message belongs to another instance")
+)
+
+// RBC is one instance of Bracha's reliable broadcast. If an honest node
+// delivers v then every honest node eventually delivers v, even if up to
+// f = (n-1)/3 nodes, the proposer included, are byzantine
+type RBC struct {
+	n          int
+	f          int
+	id         int
+	epoch      uint64
+	proposer   int
+	net        consensus.Network
+	sendRecv   bool // SEND from proposer already handled
+	readySent  bool
+	echoes     map[int]bool // senders of ECHO
+	readies    map[int]bool // senders of READY
+	echoCount  map[[sha256.Size]byte]int
+	readyCount map[[sha256.Size]byte]int
+	delivered  bool
+	output     []byte
+}
+
+// Create RBC instance (epoch, proposer) of node id
+func NewRBC(n, id int, epoch uint64, proposer int, net consensus.Network) *RBC {
+	return &RBC{
+		n:          n,
+		f:          consensus.MaxFaulty(n),
+		id:         id,
+		epoch:      epoch,
+		proposer:   proposer,
+		net:        net,
+		echoes:     make(map[int]bool),
+		readies:    make(map[int]bool),
+		echoCount:  make(map[[sha256.Size]byte]int),
+		readyCount: make(map[[sha256.Size]byte]int),
+	}
+}
+
+// Proposer broadcasts its value
+func (r *RBC) Input(value []byte) error {
+	if r.id != r.proposer {
+		return ErrNotProposer
+	}
+	r.net.Broadcast(r.newMsg(pb.RBCType_RBCSend, value))
+	return nil
+}
+
+// Handle SEND, ECHO and READY messages of this instance
+func (r *RBC) HandleMessage(msg *pb.RBCMsg) error {
+	sender := int(msg.Sender)
+	if sender < 1 || sender > r.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	if msg.Epoch != r.epoch || int(msg.Proposer) != r.proposer {
+		return ErrWrongInstance
+	}
+
+	switch msg.Type {
+	case pb.RBCType_RBCSend:
+		return r.handleSend(sender, msg.Value)
+	case pb.RBCType_RBCEcho:
+		r.handleEcho(sender, msg.Value)
+	case pb.RBCType_RBCReady:
+		r.handleReady(sender, msg.Value)
+	default:
+		return fmt.Errorf("unknown rbc message type %d", msg.Type)
+	}
+	return nil
+}
+
+// Delivered value, if any
+func (r *RBC) Output() ([]byte, bool) {
+	return r.output, r.delivered
+}
+
+// Echo the first value sent by the proposer
+func (r *RBC) handleSend(sender int, value []byte) error {
+	if sender != r.proposer {
+		return fmt.Errorf("%w %d, SEND must come from proposer %d", ErrInvalidSender, sender, r.proposer)
+	}
+	if r.sendRecv {
+		return nil
+	}
+	r.sendRecv = true
+	r.net.Broadcast(r.newMsg(pb.RBCType_RBCEcho, value))
+	return nil
+}
+
+// Send READY after ceil((n+f+1)/2) matching ECHO
+func (r *RBC) handleEcho(sender int, value []byte) {
+	if r.echoes[sender] {
+		return
+	}
+	r.echoes[sender] = true
+	digest := sha256.Sum256(value)
+	r.echoCount[digest]++
+
+	if r.echoCount[digest] >= (r.n+r.f)/2+1 {
+		r.sendReady(value)
+	}
+}
+
+// Amplify READY after f+1 matching READY, deliver after 2f+1
+func (r *RBC) handleReady(sender int, value []byte) {
+	if r.readies[sender] {
+		return
+	}
+	r.readies[sender] = true
+	digest := sha256.Sum256(value)
+	r.readyCount[digest]++
+
+	if r.readyCount[digest] >= r.f+1 {
+		r.sendReady(value)
+	}
+	if r.readyCount[digest] >= 2*r.f+1 && !r.delivered {
+		r.delivered = true
+		r.output = value
+	}
+}
+
+func (r *RBC) sendReady(value []byte) {
+	if r.readySent {
+		return
+	}
+	r.readySent = true
+	r.net.Broadcast(r.newMsg(pb.RBCType_RBCReady, value))
+}
+
+func (r *RBC) newMsg(typ pb.RBCType, value []byte) *pb.RBCMsg {
+	return &pb.RBCMsg{
+		Type:     typ,
+		Sender:   int64(r.id),
+		Epoch:    r.epoch,
+		Proposer: int64(r.proposer),
+		Value:    value,
+	}
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+
+	"github.com/stuck/crypto"
+)
+
+// Wraps a CryptoProvider and memoizes verification outcomes, the same share
+// or signature is often verified many times as it gets forwarded
+type CachedCryptoProvider struct {
+	hits     uint64 // accessed atomically, keep 64-bit aligned
+	misses   uint64
+	cp       crypto.CryptoProvider
+	capacity int
+	mu       sync.Mutex
+	lru      *list.List // front is the most recently used
+	items    map[[sha256.Size]byte]*list.Element
+}
+
+type entry struct {
+	key   [sha256.Size]byte
+	valid bool
+}
+
+const (
+	kindShare     byte = 0
+	kindSignature byte = 1
+)
+
+// Cache at most capacity verification results
+func NewCachedCryptoProvider(cp crypto.CryptoProvider, capacity int) *CachedCryptoProvider {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &CachedCryptoProvider{
+		cp:       cp,
+		capacity: capacity,
+		lru:      list.New(),
+		items:    make(map[[sha256.Size]byte]*list.Element, capacity),
+	}
+}
+
+// Compute partial signature, never cached
+func (c *CachedCryptoProvider) ComputeShare(data []byte) []byte {
+	return c.cp.ComputeShare(data)
+}
+
+// Verify partial signature
+func (c *CachedCryptoProvider) VerifyShare(data []byte, share []byte) bool {
+	return c.verify(kindShare, data, share, c.cp.VerifyShare)
+}
+
+// Combine signature, never cached
+func (c *CachedCryptoProvider) Combine(data []byte, shares [][]byte) []byte {
+	return c.cp.Combine(data, shares)
+}
+
+// Verify signature
+func (c *CachedCryptoProvider) VerifySignature(data []byte, signature []byte) bool {
+	return c.verify(kindSignature, data, signature, c.cp.VerifySignature)
+}
+
+// Number of verifications answered from the cache
+func (c *CachedCryptoProvider) Hits() uint64 {
+	return atomic.LoadUint64(&c.hits)
+}
+
+// Number of verifications passed to the wrapped provider
+func (c *CachedCryptoProvider) Misses() uint64 {
+	return atomic.LoadUint64(&c.misses)
+}
+
+// Number of cached results
+func (c *CachedCryptoProvider) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *CachedCryptoProvider) verify(kind byte, data []byte, proof []byte, verify func([]byte, []byte) bool) bool {
+	key := cacheKey(kind, data, proof)
+
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		valid := elem.Value.(*entry).valid
+		c.mu.Unlock()
+		atomic.AddUint64(&c.hits, 1)
+		return valid
+	}
+	c.mu.Unlock()
+
+	// Verify without holding the lock, concurrent misses on the same key
+	// just verify twice
+	atomic.AddUint64(&c.misses, 1)
+	valid := verify(data, proof)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		return valid
+	}
+	c.items[key] = c.lru.PushFront(&entry{key: key, valid: valid})
+	if c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+	return valid
+}
+
+// hash(kind, len(data), data, proof), the length keeps data and proof apart
+func cacheKey(kind byte, data []byte, proof []byte) [sha256.Size]byte {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(data)))
+	h := sha256.New()
+	h.Write([]byte{kind})
+	h.Write(length[:])
+	h.Write(data)
+	h.Write(proof)
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
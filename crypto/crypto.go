@@ -1,5 +1,10 @@
 package crypto
 
+import "errors"
+
+// Returned by CombineDecrypt while fewer than t decryption shares are valid
+var ErrNotEnoughShares = errors.New("not enough valid decryption shares")
+
 // Cryptographic Interface
 type CryptoProvider interface {
 	ComputeShare(data []byte) []byte                    // compute partial share
@@ -7,3 +12,29 @@ type CryptoProvider interface {
 	Combine(data []byte, shares [][]byte) []byte        // combine signature
 	VerifySignature(data []byte, signature []byte) bool // verify signature
 }
+
+// Providers that can verify many shares of the same data at once
+type BatchVerifier interface {
+	BatchVerifyShares(data []byte, shares [][]byte) []int // positions of invalid shares
+}
+
+// Providers that can combine despite invalid shares and report them
+type OptimisticCombiner interface {
+	OptimisticCombine(data []byte, shares [][]byte) ([]byte, []int) // signature and positions of invalid shares
+}
+
+// Providers that can tell which key share computed a share, so that shares
+// double as signatures of individual nodes
+type ShareIndexer interface {
+	ShareIndex(share []byte) (int, bool) // index of the key share, 0-based like the provider index
+}
+
+// Threshold encryption, any t valid decryption shares open a ciphertext.
+// A ciphertext is only valid for the label it was encrypted for, no share
+// is computed for it under another label
+type ThresholdEncrypter interface {
+	Encrypt(plaintext, label []byte) ([]byte, error)                          // encrypt under the group key
+	DecryptShare(ciphertext, label []byte) ([]byte, error)                    // compute decryption share
+	VerifyDecryptShare(ciphertext, label []byte, share []byte) bool           // verify decryption share
+	CombineDecrypt(ciphertext, label []byte, shares [][]byte) ([]byte, error) // combine shares and decrypt
+}
@@ -0,0 +1,196 @@
+package executor
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/stuck/crypto"
+)
+
+var ErrStopped = errors.New("Executor is stopped")
+
+// Executor runs CPU-bound verification on a fixed number of workers, so
+// protocol goroutines can keep consuming messages while shares are verified.
+// At most capacity tasks wait for a worker, submitting more blocks the
+// caller until one is taken, so callbacks must not submit
+type Executor struct {
+	cp       crypto.CryptoProvider
+	workers  int
+	capacity int
+	mu       sync.Mutex
+	cond     *sync.Cond // signaled when a task is queued
+	space    *sync.Cond // signaled when a task leaves the queue
+	queue    []task
+	stopped  bool
+	wg       sync.WaitGroup
+}
+
+type taskKind int
+
+const (
+	verifyShare taskKind = iota
+	verifySignature
+)
+
+type task struct {
+	kind     taskKind
+	data     []byte
+	proof    []byte // share or signature
+	future   *Future
+	callback func(bool)
+}
+
+// Future holds the result of a verification that may not have finished yet
+type Future struct {
+	done   chan struct{}
+	result bool
+	err    error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) complete(result bool, err error) {
+	f.result = result
+	f.err = err
+	close(f.done)
+}
+
+// Closed once the result is available
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Block until the verification finishes, unfinished tasks are reported as
+// invalid with ErrStopped once the executor stops
+func (f *Future) Result() (bool, error) {
+	<-f.done
+	return f.result, f.err
+}
+
+// Create executor with workers goroutines and room for capacity waiting
+// tasks, 64 per worker if capacity is not positive
+func NewExecutor(cp crypto.CryptoProvider, workers, capacity int) *Executor {
+	if workers <= 0 {
+		workers = 1
+	}
+	if capacity <= 0 {
+		capacity = 64 * workers
+	}
+	e := &Executor{cp: cp, workers: workers, capacity: capacity}
+	e.cond = sync.NewCond(&e.mu)
+	e.space = sync.NewCond(&e.mu)
+	e.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go e.run()
+	}
+	return e
+}
+
+// Verify partial share in the background
+func (e *Executor) VerifyShare(data []byte, share []byte) *Future {
+	f := newFuture()
+	e.submit(task{kind: verifyShare, data: data, proof: share, future: f})
+	return f
+}
+
+// Verify signature in the background
+func (e *Executor) VerifySignature(data []byte, signature []byte) *Future {
+	f := newFuture()
+	e.submit(task{kind: verifySignature, data: data, proof: signature, future: f})
+	return f
+}
+
+// Verify partial share and call back from a worker goroutine,
+// the callback is not invoked if the executor stops first
+func (e *Executor) VerifyShareAsync(data []byte, share []byte, callback func(bool)) {
+	e.submit(task{kind: verifyShare, data: data, proof: share, callback: callback})
+}
+
+// Verify signature and call back from a worker goroutine,
+// the callback is not invoked if the executor stops first
+func (e *Executor) VerifySignatureAsync(data []byte, signature []byte, callback func(bool)) {
+	e.submit(task{kind: verifySignature, data: data, proof: signature, callback: callback})
+}
+
+// Number of tasks waiting for a worker
+func (e *Executor) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.queue)
+}
+
+// Stop workers and fail all pending futures, running tasks finish first
+func (e *Executor) Stop() {
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return
+	}
+	e.stopped = true
+	pending := e.queue
+	e.queue = nil
+	e.cond.Broadcast()
+	e.space.Broadcast()
+	e.mu.Unlock()
+
+	for _, t := range pending {
+		if t.future != nil {
+			t.future.complete(false, ErrStopped)
+		}
+	}
+	e.wg.Wait()
+}
+
+func (e *Executor) submit(t task) {
+	e.mu.Lock()
+	for len(e.queue) >= e.capacity && !e.stopped {
+		e.space.Wait()
+	}
+	if e.stopped {
+		e.mu.Unlock()
+		if t.future != nil {
+			t.future.complete(false, ErrStopped)
+		}
+		return
+	}
+	e.queue = append(e.queue, t)
+	e.cond.Signal()
+	e.mu.Unlock()
+}
+
+// Worker loop
+func (e *Executor) run() {
+	defer e.wg.Done()
+	for {
+		e.mu.Lock()
+		for len(e.queue) == 0 && !e.stopped {
+			e.cond.Wait()
+		}
+		if e.stopped {
+			e.mu.Unlock()
+			return
+		}
+		t := e.queue[0]
+		e.queue[0] = task{} // release references held by the backing array
+		e.queue = e.queue[1:]
+		e.space.Signal()
+		e.mu.Unlock()
+
+		var result bool
+		switch t.kind {
+		case verifyShare:
+			result = e.cp.VerifyShare(t.data, t.proof)
+		case verifySignature:
+			result = e.cp.VerifySignature(t.data, t.proof)
+		}
+
+		if t.future != nil {
+			t.future.complete(result, nil)
+		}
+		if t.callback != nil {
+			t.callback(result)
+		}
+	}
+}
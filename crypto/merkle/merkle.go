@@ -0,0 +1,96 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Domain separation between leaves and inner nodes
+const (
+	leafPrefix  byte = 0
+	innerPrefix byte = 1
+)
+
+// Merkle tree over sha256, leaves are padded to a power of two
+type Tree struct {
+	size   int        // number of real leaves
+	levels [][][]byte // levels[0] are leaf hashes, the last level is the root
+}
+
+// Build tree from leaf data
+func NewTree(leaves [][]byte) *Tree {
+	width := 1
+	for width < len(leaves) {
+		width <<= 1
+	}
+
+	level := make([][]byte, width)
+	for i := range level {
+		if i < len(leaves) {
+			level[i] = hashLeaf(leaves[i])
+		} else {
+			level[i] = make([]byte, sha256.Size)
+		}
+	}
+
+	levels := [][][]byte{level}
+	for len(level) > 1 {
+		next := make([][]byte, len(level)/2)
+		for i := range next {
+			next[i] = hashInner(level[2*i], level[2*i+1])
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return &Tree{size: len(leaves), levels: levels}
+}
+
+// Root commits to all leaves
+func (t *Tree) Root() []byte {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Sibling hashes from leaf i up to the root
+func (t *Tree) Proof(i int) [][]byte {
+	if i < 0 || i >= t.size {
+		return nil
+	}
+	proof := make([][]byte, 0, len(t.levels)-1)
+	for _, level := range t.levels[:len(t.levels)-1] {
+		proof = append(proof, level[i^1])
+		i >>= 1
+	}
+	return proof
+}
+
+// Check that leaf is the i-th leaf of the tree with root
+func Verify(root []byte, leaf []byte, i int, proof [][]byte) bool {
+	if i < 0 || i>>uint(len(proof)) != 0 {
+		return false
+	}
+	h := hashLeaf(leaf)
+	for _, sibling := range proof {
+		if i&1 == 0 {
+			h = hashInner(h, sibling)
+		} else {
+			h = hashInner(sibling, h)
+		}
+		i >>= 1
+	}
+	return bytes.Equal(h, root)
+}
+
+func hashLeaf(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hashInner(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{innerPrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
@@ -2,8 +2,10 @@ package decode
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"path/filepath"
 	"strconv"
 
 	"github.com/stuck/crypto/tbls/key"
@@ -11,6 +13,14 @@ import (
 	"go.dedis.ch/kyber/v3/share"
 )
 
+// Default root of key sets, relative to the package that reads them
+const DefaultKeyRoot = "../crypto/tbls/keys"
+
+const (
+	PriKeyFile = "private_key.conf"
+	PubKeyFile = "public_key.conf"
+)
+
 func getFilePrefix(n, t int) string {
 	f := (n - 1) / 3
 	if t == f+1 {
@@ -20,73 +30,129 @@ func getFilePrefix(n, t int) string {
 	}
 }
 
-func readFromFile(filePrefix string, n int, fileName string) ([]byte, error) {
-	plan, err := ioutil.ReadFile("../crypto/tbls/keys/" + filePrefix + "/" + strconv.Itoa(n) + fileName)
-	if err != nil {
-		return nil, err
-	}
-	return plan, nil
+// Directory of the (n, t) key set under root, e.g. root/f+1/4
+func KeyDir(root string, n, t int) string {
+	return filepath.Join(root, getFilePrefix(n, t), strconv.Itoa(n))
 }
 
-// Decode private share for a specific node
-func DecodePriShare(n, t, i int) *share.PriShare {
-	filePrefix := getFilePrefix(n, t)
-	plan, err := readFromFile(filePrefix, n, "/private_key.conf")
+// Read all private shares stored in dir
+func LoadPriShares(dir string) ([]key.PriShare, error) {
+	return ReadPriShares(filepath.Join(dir, PriKeyFile))
+}
+
+// Read the private shares of a key file, a node's own file may hold only
+// its share
+func ReadPriShares(path string) ([]key.PriShare, error) {
+	plan, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	var priShares []key.PriShare
 	err = json.Unmarshal(plan, &priShares)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	return priShares, nil
+}
 
-	// Unmarshal
-	suite := bn256.NewSuite()
-	scalar := suite.G2().Scalar()
-	err = scalar.UnmarshalBinary(priShares[i].PriBytes)
+// Read all public shares stored in dir
+func LoadPubShares(dir string) ([]key.PubShare, error) {
+	return ReadPubShares(filepath.Join(dir, PubKeyFile))
+}
+
+// Read the public shares of a key file
+func ReadPubShares(path string) ([]key.PubShare, error) {
+	plan, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return &share.PriShare{I: priShares[i].Index, V: scalar}
-}
-
-// Decode public key
-func DecodePubShare(n, t int) *share.PubPoly {
-	filePrefix := getFilePrefix(n, t)
-	plan, err := readFromFile(filePrefix, n, "//public_key.conf")
+	var pubShares []key.PubShare
+	err = json.Unmarshal(plan, &pubShares)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	return pubShares, nil
+}
 
-	var pubSharesBytes []key.PubShare
-	err = json.Unmarshal(plan, &pubSharesBytes)
+// Unmarshal a private share
+func ToPriShare(priShare key.PriShare) (*share.PriShare, error) {
+	suite := bn256.NewSuite()
+	scalar := suite.G2().Scalar()
+	err := scalar.UnmarshalBinary(priShare.PriBytes)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	return &share.PriShare{I: priShare.Index, V: scalar}, nil
+}
 
-	pubShares := make([]*share.PubShare, n)
+// Unmarshal public shares
+func ToPubShares(pubSharesBytes []key.PubShare) ([]*share.PubShare, error) {
+	pubShares := make([]*share.PubShare, len(pubSharesBytes))
 	suite := bn256.NewSuite()
 
-	// Unmarshal public shares
 	for i, b := range pubSharesBytes {
 		point := suite.G2().Point()
-		pubShares[i] = &share.PubShare{}
-		pubShares[i].I = b.Index
-		err = point.UnmarshalBinary(b.PubBytes)
+		err := point.UnmarshalBinary(b.PubBytes)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		pubShares[i].V = point
+		pubShares[i] = &share.PubShare{I: b.Index, V: point}
+	}
+	return pubShares, nil
+}
+
+// Recover public polynomial from public shares
+func ToPubPoly(pubSharesBytes []key.PubShare, t, n int) (*share.PubPoly, error) {
+	if len(pubSharesBytes) != n {
+		return nil, fmt.Errorf("expect %d public shares, got %d", n, len(pubSharesBytes))
+	}
+
+	pubShares, err := ToPubShares(pubSharesBytes)
+	if err != nil {
+		return nil, err
 	}
 
-	// Recovery public key
-	pubPoly, err := share.RecoverPubPoly(suite.G2(), pubShares, t, n)
+	suite := bn256.NewSuite()
+	return share.RecoverPubPoly(suite.G2(), pubShares, t, n)
+}
+
+// Load private share of node i from dir
+func LoadPriShare(dir string, i int) (*share.PriShare, error) {
+	priShares, err := LoadPriShares(dir)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(priShares) {
+		return nil, fmt.Errorf("private share %d out of range [0, %d)", i, len(priShares))
+	}
+	return ToPriShare(priShares[i])
+}
+
+// Load public polynomial from dir
+func LoadPubPoly(dir string, t, n int) (*share.PubPoly, error) {
+	pubShares, err := LoadPubShares(dir)
+	if err != nil {
+		return nil, err
+	}
+	return ToPubPoly(pubShares, t, n)
+}
+
+// Decode private share for a specific node
+func DecodePriShare(n, t, i int) *share.PriShare {
+	priShare, err := LoadPriShare(KeyDir(DefaultKeyRoot, n, t), i)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return priShare
+}
 
+// Decode public key
+func DecodePubShare(n, t int) *share.PubPoly {
+	pubPoly, err := LoadPubPoly(KeyDir(DefaultKeyRoot, n, t), t, n)
+	if err != nil {
+		log.Fatal(err)
+	}
 	return pubPoly
 }
@@ -8,8 +8,6 @@ import (
 	"strconv"
 
 	"github.com/stuck/crypto/tbls/key"
-	"go.dedis.ch/kyber/v3/pairing/bn256"
-	"go.dedis.ch/kyber/v3/share"
 )
 
 func writeToFile(filePrefix string, n int, fileName string, shareBytes []byte) {
@@ -26,22 +24,9 @@ func main() {
 	flag.Parse()
 	f := (*n - 1) / 3
 
-	suite := bn256.NewSuite()
-	priShares := make([]key.PriShare, *n) // Each node has its own private key
-	pubShares := make([]key.PubShare, *n) // A public key will be split into n shares
-
-	secret := suite.G1().Scalar().Pick(suite.RandomStream())
-	priPoly := share.NewPriPoly(suite.G2(), *t, secret, suite.RandomStream()) // Private key set
-	pubPoly := priPoly.Commit(suite.G2().Point().Base())                      // Public key
-
-	// Marshal private key
-	for i, x := range priPoly.Shares(*n) {
-		priBytes, err := x.V.MarshalBinary()
-		if err != nil {
-			log.Fatal(err)
-		}
-		ps := key.PriShare{Index: x.I, PriBytes: priBytes}
-		priShares[i] = ps
+	priShares, pubShares, err := key.Generate(*n, *t)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// Marshal private key set
@@ -59,16 +44,6 @@ func main() {
 	}
 	writeToFile(filePrefix, *n, "/private_key.conf", priSharesBytes)
 
-	// Marshal public key
-	for i, x := range pubPoly.Shares(*n) {
-		pubShareBytes, err := x.V.MarshalBinary()
-		if err != nil {
-			log.Fatal(err)
-		}
-		ps := key.PubShare{Index: x.I, PubBytes: pubShareBytes}
-		pubShares[i] = ps
-	}
-
 	// Marshal public key shares
 	pubSharesBytes, err := json.Marshal(pubShares)
 	if err != nil {
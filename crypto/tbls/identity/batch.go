@@ -0,0 +1,100 @@
+package identity
+
+import (
+	"sort"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"go.dedis.ch/kyber/v3/sign/tbls"
+)
+
+type hashablePoint interface {
+	Hash([]byte) kyber.Point
+}
+
+// Verify partial signatures of the same data with one randomized pairing check
+// e(sum r_i*S_i, G2) == e(H(m), sum r_i*X_i), and only when it fails check the
+// shares one by one. Returns the positions of invalid shares in shares.
+func (cp *TBLSCryproProvider) BatchVerifyShares(data []byte, shares [][]byte) []int {
+	invalid := make([]int, 0)
+	candidates := make([]int, 0, len(shares))
+	sigs := make([]kyber.Point, 0, len(shares))
+	pubs := make([]kyber.Point, 0, len(shares))
+
+	// Malformed shares never join the batch
+	for pos, share := range shares {
+		sig, pub, ok := cp.decodeShare(share)
+		if !ok {
+			invalid = append(invalid, pos)
+			continue
+		}
+		candidates = append(candidates, pos)
+		sigs = append(sigs, sig)
+		pubs = append(pubs, pub)
+	}
+
+	if len(candidates) == 0 || cp.aggregateCheck(data, sigs, pubs) {
+		return invalid
+	}
+
+	// Batch failed, find out who is bad
+	for i, pos := range candidates {
+		value := tbls.SigShare(shares[pos])
+		if bls.Verify(cp.suite, pubs[i], data, value.Value()) != nil {
+			invalid = append(invalid, pos)
+		}
+	}
+	sort.Ints(invalid)
+	return invalid
+}
+
+// Randomized aggregate check, random coefficients prevent invalid shares
+// from cancelling each other out
+func (cp *TBLSCryproProvider) aggregateCheck(data []byte, sigs, pubs []kyber.Point) bool {
+	hashable, ok := cp.suite.G1().Point().(hashablePoint)
+	if !ok {
+		return false
+	}
+
+	aggSig := cp.suite.G1().Point().Null()
+	aggPub := cp.suite.G2().Point().Null()
+	random := cp.suite.RandomStream()
+	for i := range sigs {
+		r := cp.suite.G1().Scalar().Pick(random)
+		aggSig.Add(aggSig, cp.suite.G1().Point().Mul(r, sigs[i]))
+		aggPub.Add(aggPub, cp.suite.G2().Point().Mul(r, pubs[i]))
+	}
+
+	left := cp.suite.Pair(aggSig, cp.suite.G2().Point().Base())
+	right := cp.suite.Pair(hashable.Hash(data), aggPub)
+	return left.Equal(right)
+}
+
+// Parse a share into its signature point and the signer's public key
+func (cp *TBLSCryproProvider) decodeShare(share []byte) (kyber.Point, kyber.Point, bool) {
+	s := tbls.SigShare(share)
+	i, err := s.Index()
+	if err != nil || i < 0 || i >= cp.n || len(share) <= 2 {
+		return nil, nil, false
+	}
+	sig := cp.suite.G1().Point()
+	if err := sig.UnmarshalBinary(s.Value()); err != nil {
+		return nil, nil, false
+	}
+	return sig, cp.pubKey(i), true
+}
+
+// Public key of share i, evaluated once and then cached
+func (cp *TBLSCryproProvider) pubKey(i int) kyber.Point {
+	cp.pubMu.Lock()
+	defer cp.pubMu.Unlock()
+	if cp.pubKeys == nil {
+		cp.pubKeys = make(map[int]kyber.Point)
+	}
+	pub, ok := cp.pubKeys[i]
+	if !ok {
+		pub = cp.pubPoly.Eval(i).V
+		cp.pubKeys[i] = pub
+	}
+	return pub
+}
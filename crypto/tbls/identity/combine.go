@@ -0,0 +1,94 @@
+package identity
+
+import (
+	"sort"
+
+	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"go.dedis.ch/kyber/v3/sign/tbls"
+)
+
+// Interpolate the first t shares without checking them and verify the result,
+// only when the signature is invalid the bad shares are identified, excluded
+// and the signature is recovered from the remaining shares.
+// Returns the signature (nil if not enough valid shares) and the positions of
+// invalid shares in shares.
+func (cp *TBLSCryproProvider) OptimisticCombine(data []byte, shares [][]byte) ([]byte, []int) {
+	invalid := make([]int, 0)
+	candidates := make([]int, 0, len(shares))
+	points := make(map[int]*share.PubShare, len(shares))
+
+	// Malformed shares are invalid without any pairing
+	for pos, s := range shares {
+		sig, _, ok := cp.decodeShare(s)
+		if !ok {
+			invalid = append(invalid, pos)
+			continue
+		}
+		index, _ := tbls.SigShare(s).Index()
+		candidates = append(candidates, pos)
+		points[pos] = &share.PubShare{I: index, V: sig}
+	}
+
+	// Optimistic path
+	if signature, ok := cp.interpolate(data, candidates, points); ok {
+		return signature, invalid
+	}
+
+	// Pessimistic path, identify and exclude bad shares
+	remaining := make([][]byte, len(candidates))
+	for i, pos := range candidates {
+		remaining[i] = shares[pos]
+	}
+	bad := make(map[int]bool)
+	for _, i := range cp.BatchVerifyShares(data, remaining) {
+		bad[candidates[i]] = true
+		invalid = append(invalid, candidates[i])
+	}
+	sort.Ints(invalid)
+
+	valid := make([]int, 0, len(candidates))
+	for _, pos := range candidates {
+		if !bad[pos] {
+			valid = append(valid, pos)
+		}
+	}
+	signature, ok := cp.interpolate(data, valid, points)
+	if !ok {
+		return nil, invalid
+	}
+	return signature, invalid
+}
+
+// Recover signature from the first t shares with distinct indexes and verify it
+func (cp *TBLSCryproProvider) interpolate(data []byte, positions []int, points map[int]*share.PubShare) ([]byte, bool) {
+	used := make(map[int]bool, cp.t)
+	pubShares := make([]*share.PubShare, 0, cp.t)
+	for _, pos := range positions {
+		ps := points[pos]
+		if used[ps.I] {
+			continue
+		}
+		used[ps.I] = true
+		pubShares = append(pubShares, ps)
+		if len(pubShares) == cp.t {
+			break
+		}
+	}
+	if len(pubShares) < cp.t {
+		return nil, false
+	}
+
+	commit, err := share.RecoverCommit(cp.suite.G1(), pubShares, cp.t, cp.n)
+	if err != nil {
+		return nil, false
+	}
+	signature, err := commit.MarshalBinary()
+	if err != nil {
+		return nil, false
+	}
+	if bls.Verify(cp.suite, cp.pubPoly.Commit(), data, signature) != nil {
+		return nil, false
+	}
+	return signature, true
+}
@@ -1,8 +1,11 @@
 package identity
 
 import (
+	"sync"
+
 	"github.com/stuck/crypto/tbls/decode"
 
+	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"go.dedis.ch/kyber/v3/share"
 	"go.dedis.ch/kyber/v3/sign/bls"
@@ -15,6 +18,8 @@ type TBLSCryproProvider struct {
 	suite   *bn256.Suite
 	priPoly *share.PriShare
 	pubPoly *share.PubPoly
+	pubMu   sync.Mutex
+	pubKeys map[int]kyber.Point // cached public key of each share
 }
 
 func NewTBLSCryproProvider(n, t, i int) *TBLSCryproProvider {
@@ -27,6 +32,17 @@ func NewTBLSCryproProvider(n, t, i int) *TBLSCryproProvider {
 	return cp
 }
 
+// Create provider from in-memory key material instead of the key files
+func NewTBLSCryproProviderFromShares(n, t int, priShare *share.PriShare, pubPoly *share.PubPoly) *TBLSCryproProvider {
+	cp := &TBLSCryproProvider{}
+	cp.n = n
+	cp.t = t
+	cp.suite = bn256.NewSuite()
+	cp.priPoly = priShare
+	cp.pubPoly = pubPoly
+	return cp
+}
+
 // Compute partial signature
 func (cp *TBLSCryproProvider) ComputeShare(data []byte) []byte {
 	share, err := tbls.Sign(cp.suite, cp.priPoly, data)
@@ -42,12 +58,9 @@ func (cp *TBLSCryproProvider) VerifyShare(data []byte, share []byte) bool {
 	return err == nil
 }
 
-// Combine signature
+// Combine signature, invalid shares are skipped as long as t valid shares remain
 func (cp *TBLSCryproProvider) Combine(data []byte, shares [][]byte) []byte {
-	signature, err := tbls.Recover(cp.suite, cp.pubPoly, data, shares, cp.t, cp.n)
-	if err != nil {
-		return nil
-	}
+	signature, _ := cp.OptimisticCombine(data, shares)
 	return signature
 }
 
@@ -56,3 +69,12 @@ func (cp *TBLSCryproProvider) VerifySignature(data []byte, signature []byte) boo
 	err := bls.Verify(cp.suite, cp.pubPoly.Commit(), data, signature)
 	return err == nil
 }
+
+// Index of the key share that computed share
+func (cp *TBLSCryproProvider) ShareIndex(share []byte) (int, bool) {
+	index, err := tbls.SigShare(share).Index()
+	if err != nil {
+		return 0, false
+	}
+	return index, true
+}
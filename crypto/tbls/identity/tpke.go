@@ -0,0 +1,215 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/stuck/crypto"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share"
+)
+
+var (
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+	ErrNotEnoughShares   = crypto.ErrNotEnoughShares
+)
+
+const nonceSize = 12
+
+// Threshold ElGamal with the tbls key shares, after the TDH scheme of Baek
+// and Zheng. A ciphertext is
+// U = r*G2 || W = r*H(U, nonce, c, label) || nonce || c = AES-GCM(H(r*X), plaintext),
+// where X is the group key and label names what the ciphertext is for.
+// Only whoever picked r can compute W for a label, so W proves the
+// ciphertext valid for its label with e(W, G2) == e(H, U), and shares are
+// only released for valid ciphertexts: a ciphertext copied under another
+// label, or altered, is never decrypted. The decryption shares x_i*U are
+// publicly verifiable as well: e(H, x_i*U) == e(W, X_i)
+
+// Encrypt under the group key for label
+func (cp *TBLSCryproProvider) Encrypt(plaintext, label []byte) ([]byte, error) {
+	r := cp.suite.G2().Scalar().Pick(cp.suite.RandomStream())
+	u := cp.suite.G2().Point().Mul(r, nil)
+	k := cp.suite.G2().Point().Mul(r, cp.pubPoly.Commit())
+
+	uBytes, err := u.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(append([]byte{}, nonce...), nonce, plaintext, uBytes)
+
+	h, err := cp.labelPoint(uBytes, sealed, label)
+	if err != nil {
+		return nil, err
+	}
+	wBytes, err := cp.suite.G1().Point().Mul(r, h).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(append(uBytes, wBytes...), sealed...), nil
+}
+
+// Decryption share i || x_i*U of a ciphertext valid for label
+func (cp *TBLSCryproProvider) DecryptShare(ciphertext, label []byte) ([]byte, error) {
+	u, _, _, err := cp.parseCiphertext(ciphertext, label)
+	if err != nil {
+		return nil, err
+	}
+	d := cp.suite.G2().Point().Mul(cp.priPoly.V, u)
+	dBytes, err := d.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.BigEndian, uint16(cp.priPoly.I)); err != nil {
+		return nil, err
+	}
+	buf.Write(dBytes)
+	return buf.Bytes(), nil
+}
+
+// Verify decryption share
+func (cp *TBLSCryproProvider) VerifyDecryptShare(ciphertext, label []byte, share []byte) bool {
+	_, w, h, err := cp.parseCiphertext(ciphertext, label)
+	if err != nil {
+		return false
+	}
+	_, ok := cp.decodeDecryptShare(h, w, share)
+	return ok
+}
+
+// Combine t valid decryption shares and decrypt, invalid shares are skipped
+func (cp *TBLSCryproProvider) CombineDecrypt(ciphertext, label []byte, shares [][]byte) ([]byte, error) {
+	_, w, h, err := cp.parseCiphertext(ciphertext, label)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[int]bool, cp.t)
+	pubShares := make([]*share.PubShare, 0, cp.t)
+	for _, s := range shares {
+		ps, ok := cp.decodeDecryptShare(h, w, s)
+		if !ok || used[ps.I] {
+			continue
+		}
+		used[ps.I] = true
+		pubShares = append(pubShares, ps)
+		if len(pubShares) == cp.t {
+			break
+		}
+	}
+	if len(pubShares) < cp.t {
+		return nil, ErrNotEnoughShares
+	}
+
+	k, err := share.RecoverCommit(cp.suite.G2(), pubShares, cp.t, cp.n)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+
+	uBytes := ciphertext[:cp.suite.G2().PointLen()]
+	nonce := ciphertext[cp.headerSize() : cp.headerSize()+nonceSize]
+	return aead.Open(nil, nonce, ciphertext[cp.headerSize()+nonceSize:], uBytes)
+}
+
+// Parse U and W and check that W is r*H for the r of U, H is returned too
+func (cp *TBLSCryproProvider) parseCiphertext(ciphertext, label []byte) (kyber.Point, kyber.Point, kyber.Point, error) {
+	uSize := cp.suite.G2().PointLen()
+	if len(ciphertext) < cp.headerSize()+nonceSize {
+		return nil, nil, nil, ErrInvalidCiphertext
+	}
+	u := cp.suite.G2().Point()
+	if err := u.UnmarshalBinary(ciphertext[:uSize]); err != nil {
+		return nil, nil, nil, ErrInvalidCiphertext
+	}
+	w := cp.suite.G1().Point()
+	if err := w.UnmarshalBinary(ciphertext[uSize:cp.headerSize()]); err != nil {
+		return nil, nil, nil, ErrInvalidCiphertext
+	}
+	h, err := cp.labelPoint(ciphertext[:uSize], ciphertext[cp.headerSize():], label)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	left := cp.suite.Pair(w, cp.suite.G2().Point().Base())
+	right := cp.suite.Pair(h, u)
+	if !left.Equal(right) {
+		return nil, nil, nil, ErrInvalidCiphertext
+	}
+	return u, w, h, nil
+}
+
+// H(U, nonce, c, label) in G1, the lengths keep the parts apart
+func (cp *TBLSCryproProvider) labelPoint(u, sealed, label []byte) (kyber.Point, error) {
+	hashable, ok := cp.suite.G1().Point().(hashablePoint)
+	if !ok {
+		return nil, ErrInvalidCiphertext
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("tpke")
+	for _, part := range [][]byte{u, sealed, label} {
+		if err := binary.Write(buf, binary.BigEndian, uint32(len(part))); err != nil {
+			return nil, err
+		}
+		buf.Write(part)
+	}
+	return hashable.Hash(buf.Bytes()), nil
+}
+
+// Parse and verify decryption share against the public key of its index
+func (cp *TBLSCryproProvider) decodeDecryptShare(h, w kyber.Point, s []byte) (*share.PubShare, bool) {
+	if len(s) != 2+cp.suite.G2().PointLen() {
+		return nil, false
+	}
+	i := int(binary.BigEndian.Uint16(s))
+	if i >= cp.n {
+		return nil, false
+	}
+	d := cp.suite.G2().Point()
+	if err := d.UnmarshalBinary(s[2:]); err != nil {
+		return nil, false
+	}
+
+	left := cp.suite.Pair(h, d)
+	right := cp.suite.Pair(w, cp.pubKey(i))
+	if !left.Equal(right) {
+		return nil, false
+	}
+	return &share.PubShare{I: i, V: d}, true
+}
+
+func (cp *TBLSCryproProvider) headerSize() int {
+	return cp.suite.G2().PointLen() + cp.suite.G1().PointLen()
+}
+
+// AES-256-GCM keyed by the hash of a group element
+func newAEAD(k kyber.Point) (cipher.AEAD, error) {
+	kBytes, err := k.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256(kBytes)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, nonceSize)
+}
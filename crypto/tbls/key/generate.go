@@ -0,0 +1,37 @@
+package key
+
+import (
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/share"
+)
+
+// Generate a fresh (n, t) key set, the i-th private share belongs to the i-th node
+func Generate(n, t int) ([]PriShare, []PubShare, error) {
+	suite := bn256.NewSuite()
+	priShares := make([]PriShare, n) // Each node has its own private key
+	pubShares := make([]PubShare, n) // A public key will be split into n shares
+
+	secret := suite.G1().Scalar().Pick(suite.RandomStream())
+	priPoly := share.NewPriPoly(suite.G2(), t, secret, suite.RandomStream()) // Private key set
+	pubPoly := priPoly.Commit(suite.G2().Point().Base())                     // Public key
+
+	// Marshal private key
+	for i, x := range priPoly.Shares(n) {
+		priBytes, err := x.V.MarshalBinary()
+		if err != nil {
+			return nil, nil, err
+		}
+		priShares[i] = PriShare{Index: x.I, PriBytes: priBytes}
+	}
+
+	// Marshal public key
+	for i, x := range pubPoly.Shares(n) {
+		pubBytes, err := x.V.MarshalBinary()
+		if err != nil {
+			return nil, nil, err
+		}
+		pubShares[i] = PubShare{Index: x.I, PubBytes: pubBytes}
+	}
+
+	return priShares, pubShares, nil
+}
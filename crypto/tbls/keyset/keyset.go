@@ -0,0 +1,335 @@
+package keyset
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stuck/crypto/tbls/decode"
+	"github.com/stuck/crypto/tbls/identity"
+	"github.com/stuck/crypto/tbls/key"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+)
+
+var (
+	ErrParams       = errors.New("invalid key set parameters")
+	ErrExists       = errors.New("key set already exists")
+	ErrInconsistent = errors.New("public shares are not on one polynomial")
+	ErrMismatch     = errors.New("private shares do not match their public shares")
+	ErrSignature    = errors.New("shares do not reconstruct a valid signature")
+	ErrNoKey        = errors.New("no key matches")
+)
+
+// KeySet is a tbls key set of n shares with threshold t stored in Dir
+type KeySet struct {
+	Dir string
+	N   int
+	T   int
+}
+
+// Key set of (n, t) in dir, or in the conventional directory below root
+// when dir is empty
+func New(root, dir string, n, t int) (KeySet, error) {
+	if n <= 0 {
+		return KeySet{}, fmt.Errorf("%w: n must be positive, got %d", ErrParams, n)
+	}
+	if t <= 0 || t > n {
+		return KeySet{}, fmt.Errorf("%w: t must be in [1, %d], got %d", ErrParams, n, t)
+	}
+	if dir == "" {
+		dir = decode.KeyDir(root, n, t)
+	}
+	return KeySet{Dir: dir, N: n, T: t}, nil
+}
+
+// Generate the key set and write it to disk, an existing one is only
+// replaced with force
+func (ks KeySet) Generate(w io.Writer, force bool) error {
+	if _, err := os.Stat(filepath.Join(ks.Dir, decode.PriKeyFile)); err == nil && !force {
+		return fmt.Errorf("%w in %s, use force to overwrite", ErrExists, ks.Dir)
+	}
+
+	priShares, pubShares, err := key.Generate(ks.N, ks.T)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(ks.Dir, 0755); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(ks.Dir, decode.PriKeyFile), priShares, 0600); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(ks.Dir, decode.PubKeyFile), pubShares, 0644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "wrote (n=%d, t=%d) key set to %s\n", ks.N, ks.T, ks.Dir)
+	return nil
+}
+
+// Print group public key and the public key of each share
+func (ks KeySet) Inspect(w io.Writer) error {
+	pubShares, err := decode.LoadPubShares(ks.Dir)
+	if err != nil {
+		return err
+	}
+	pubPoly, err := decode.ToPubPoly(pubShares, ks.T, ks.N)
+	if err != nil {
+		return err
+	}
+	groupKey, err := pubPoly.Commit().MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "key set:    %s\n", ks.Dir)
+	fmt.Fprintf(w, "n, t:       %d, %d\n", ks.N, ks.T)
+	fmt.Fprintf(w, "group key:  %s\n", hex.EncodeToString(groupKey))
+
+	// Every share must lie on the recovered polynomial
+	inconsistent := 0
+	for _, ps := range pubShares {
+		status := "ok"
+		expected, err := pubPoly.Eval(ps.Index).V.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(expected, ps.PubBytes) {
+			status = "NOT ON POLYNOMIAL"
+			inconsistent++
+		}
+		fmt.Fprintf(w, "share %-4d  %s  %s\n", ps.Index, hex.EncodeToString(ps.PubBytes), status)
+	}
+
+	if inconsistent > 0 {
+		return fmt.Errorf("%w, %d of them are not", ErrInconsistent, inconsistent)
+	}
+	return nil
+}
+
+// Check that private share index matches its public share, -1 checks all
+func (ks KeySet) Check(w io.Writer, index int) error {
+	priShares, err := decode.LoadPriShares(ks.Dir)
+	if err != nil {
+		return err
+	}
+	pubShares, err := decode.LoadPubShares(ks.Dir)
+	if err != nil {
+		return err
+	}
+	pubs, err := decode.ToPubShares(pubShares)
+	if err != nil {
+		return err
+	}
+
+	if index >= len(priShares) {
+		return fmt.Errorf("%w: share %d out of range [0, %d)", ErrParams, index, len(priShares))
+	}
+
+	suite := bn256.NewSuite()
+	mismatch := 0
+	for i, ps := range priShares {
+		if index >= 0 && i != index {
+			continue
+		}
+		pri, err := decode.ToPriShare(ps)
+		if err != nil {
+			return err
+		}
+		if i >= len(pubs) || pubs[i].I != pri.I {
+			fmt.Fprintf(w, "share %-4d  missing public share\n", pri.I)
+			mismatch++
+			continue
+		}
+		// x_i * G2 must equal the published public share
+		if suite.G2().Point().Mul(pri.V, nil).Equal(pubs[i].V) {
+			fmt.Fprintf(w, "share %-4d  ok\n", pri.I)
+		} else {
+			fmt.Fprintf(w, "share %-4d  MISMATCH\n", pri.I)
+			mismatch++
+		}
+	}
+
+	if mismatch > 0 {
+		return fmt.Errorf("%w, %d of them do not", ErrMismatch, mismatch)
+	}
+	return nil
+}
+
+// Check that the shares of signers reconstruct a signature on msg valid
+// under the group key, nil signers picks t random shares
+func (ks KeySet) Verify(w io.Writer, msg []byte, signers []int) error {
+	pubPoly, err := decode.LoadPubPoly(ks.Dir, ks.T, ks.N)
+	if err != nil {
+		return err
+	}
+
+	if signers == nil {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		signers = r.Perm(ks.N)[:ks.T]
+	}
+	if len(signers) < ks.T {
+		return fmt.Errorf("%w: need at least %d signers, got %d", ErrParams, ks.T, len(signers))
+	}
+
+	shares := make([][]byte, 0, len(signers))
+	var combiner *identity.TBLSCryproProvider
+	for _, i := range signers {
+		pri, err := decode.LoadPriShare(ks.Dir, i)
+		if err != nil {
+			return err
+		}
+		cp := identity.NewTBLSCryproProviderFromShares(ks.N, ks.T, pri, pubPoly)
+		share := cp.ComputeShare(msg)
+		if !cp.VerifyShare(msg, share) {
+			return fmt.Errorf("%w, share %d produced an invalid signature share", ErrSignature, i)
+		}
+		shares = append(shares, share)
+		combiner = cp
+	}
+
+	signature := combiner.Combine(msg, shares)
+	if signature == nil {
+		return fmt.Errorf("%w, failed to combine signature shares", ErrSignature)
+	}
+	if !combiner.VerifySignature(msg, signature) {
+		return fmt.Errorf("%w, combined signature is invalid under the group key", ErrSignature)
+	}
+
+	fmt.Fprintf(w, "signers:    %v\n", signers)
+	fmt.Fprintf(w, "signature:  %s\n", hex.EncodeToString(signature))
+	fmt.Fprintln(w, "ok")
+	return nil
+}
+
+// Export keys in hex, base64 or pem. Part is public, private or all and
+// index selects one share, -1 exports all
+func (ks KeySet) Export(w io.Writer, format, part string, index int) error {
+	encode, err := encoderFor(format)
+	if err != nil {
+		return err
+	}
+	if part != "public" && part != "private" && part != "all" {
+		return fmt.Errorf("%w: unknown part %q", ErrParams, part)
+	}
+
+	var blocks []*pem.Block
+
+	if part == "public" || part == "all" {
+		pubShares, err := decode.LoadPubShares(ks.Dir)
+		if err != nil {
+			return err
+		}
+		pubPoly, err := decode.ToPubPoly(pubShares, ks.T, ks.N)
+		if err != nil {
+			return err
+		}
+		groupKey, err := pubPoly.Commit().MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if index < 0 {
+			blocks = append(blocks, keyBlock("TBLS GROUP PUBLIC KEY", -1, ks.N, ks.T, groupKey))
+		}
+		for _, ps := range pubShares {
+			if index < 0 || ps.Index == index {
+				blocks = append(blocks, keyBlock("TBLS PUBLIC KEY SHARE", ps.Index, ks.N, ks.T, ps.PubBytes))
+			}
+		}
+	}
+
+	if part == "private" || part == "all" {
+		priShares, err := decode.LoadPriShares(ks.Dir)
+		if err != nil {
+			return err
+		}
+		for _, ps := range priShares {
+			if index < 0 || ps.Index == index {
+				blocks = append(blocks, keyBlock("TBLS PRIVATE KEY SHARE", ps.Index, ks.N, ks.T, ps.PriBytes))
+			}
+		}
+	}
+
+	if len(blocks) == 0 {
+		return fmt.Errorf("%w index %d", ErrNoKey, index)
+	}
+
+	for _, b := range blocks {
+		if err := encode(w, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Share indexes from a comma separated list, nil for an empty one
+func ParseSigners(list string) ([]int, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var indexes []int
+	for _, s := range strings.Split(list, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// Build a pem block, headers are also used by hex and base64 output
+func keyBlock(typ string, index, n, t int, bytes []byte) *pem.Block {
+	headers := map[string]string{"N": strconv.Itoa(n), "T": strconv.Itoa(t)}
+	if index >= 0 {
+		headers["Index"] = strconv.Itoa(index)
+	}
+	return &pem.Block{Type: typ, Headers: headers, Bytes: bytes}
+}
+
+func encoderFor(format string) (func(io.Writer, *pem.Block) error, error) {
+	label := func(b *pem.Block) string {
+		if index, ok := b.Headers["Index"]; ok {
+			return strings.ToLower(b.Type) + " " + index
+		}
+		return strings.ToLower(b.Type)
+	}
+
+	switch format {
+	case "hex":
+		return func(w io.Writer, b *pem.Block) error {
+			_, err := fmt.Fprintf(w, "%s: %s\n", label(b), hex.EncodeToString(b.Bytes))
+			return err
+		}, nil
+	case "base64":
+		return func(w io.Writer, b *pem.Block) error {
+			_, err := fmt.Fprintf(w, "%s: %s\n", label(b), base64.StdEncoding.EncodeToString(b.Bytes))
+			return err
+		}, nil
+	case "pem":
+		return pem.Encode, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrParams, format)
+	}
+}
+
+func writeJSON(path string, v interface{}, perm os.FileMode) error {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, perm)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/stuck/crypto/tbls/keyset"
+)
+
+const usage = `keytool manages tbls key sets
+
+Usage:
+	keytool <command> [flags]
+
+Commands:
+	generate  generate a key set for (n, t)
+	inspect   print group public key and public key of each share
+	check     check that private shares match their public shares
+	verify    check that t shares reconstruct a valid signature
+	export    export keys in hex, base64 or pem
+
+Run "keytool <command> -h" for the flags of a command.
+`
+
+// Flags shared by all commands
+type keySetFlags struct {
+	n    *int
+	t    *int
+	root *string
+	dir  *string
+}
+
+func newKeySetFlags(fs *flag.FlagSet) *keySetFlags {
+	return &keySetFlags{
+		n:    fs.Int("n", 4, "total node number"),
+		t:    fs.Int("t", 2, "threshold of tbls"),
+		root: fs.String("keys", "../keys", "root directory of key sets"),
+		dir:  fs.String("dir", "", "directory of the key set, default is <keys>/<f+1|2f+1>/<n>"),
+	}
+}
+
+func (kf *keySetFlags) keySet() (keyset.KeySet, error) {
+	return keyset.New(*kf.root, *kf.dir, *kf.n, *kf.t)
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	cmd, args := os.Args[1], os.Args[2:]
+	switch cmd {
+	case "generate":
+		err = runGenerate(args)
+	case "inspect":
+		err = runInspect(args)
+	case "check":
+		err = runCheck(args)
+	case "verify":
+		err = runVerify(args)
+	case "export":
+		err = runExport(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("%s: %v", cmd, err)
+	}
+}
+
+// Generate a key set and write it to disk
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	kf := newKeySetFlags(fs)
+	force := fs.Bool("force", false, "overwrite an existing key set")
+	fs.Parse(args)
+	ks, err := kf.keySet()
+	if err != nil {
+		return err
+	}
+	return ks.Generate(os.Stdout, *force)
+}
+
+// Print group public key and the public key of each share
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	kf := newKeySetFlags(fs)
+	fs.Parse(args)
+	ks, err := kf.keySet()
+	if err != nil {
+		return err
+	}
+	return ks.Inspect(os.Stdout)
+}
+
+// Check that each private share matches its public share
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	kf := newKeySetFlags(fs)
+	index := fs.Int("i", -1, "index of the share to check, -1 checks all")
+	fs.Parse(args)
+	ks, err := kf.keySet()
+	if err != nil {
+		return err
+	}
+	return ks.Check(os.Stdout, *index)
+}
+
+// Check that t shares reconstruct a signature valid under the group key
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	kf := newKeySetFlags(fs)
+	msg := fs.String("msg", "keytool test message", "message to sign")
+	signers := fs.String("signers", "", "comma separated share indexes, default is t random shares")
+	fs.Parse(args)
+	ks, err := kf.keySet()
+	if err != nil {
+		return err
+	}
+	indexes, err := keyset.ParseSigners(*signers)
+	if err != nil {
+		return err
+	}
+	return ks.Verify(os.Stdout, []byte(*msg), indexes)
+}
+
+// Export keys in hex, base64 or pem
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	kf := newKeySetFlags(fs)
+	format := fs.String("format", "hex", "output format: hex, base64 or pem")
+	part := fs.String("part", "public", "keys to export: public, private or all")
+	index := fs.Int("i", -1, "index of the share to export, -1 exports all")
+	out := fs.String("o", "", "output file, default is stdout")
+	fs.Parse(args)
+	ks, err := kf.keySet()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.OpenFile(*out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	return ks.Export(w, *format, *part, *index)
+}
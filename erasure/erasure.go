@@ -0,0 +1,139 @@
+package erasure
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTooFewShards    = errors.New("too few shards to reconstruct")
+	ErrShardSize       = errors.New("shards have different sizes")
+	ErrCorruptedLength = errors.New("decoded length is corrupted")
+)
+
+// Systematic Reed-Solomon code over GF(2^8): k data shards are extended to
+// n shards and any k of them reconstruct the data
+type Encoder struct {
+	k      int
+	n      int
+	matrix [][]byte // n x k, identity on top of a cauchy matrix
+}
+
+// Create (k, n) encoder, 0 < k <= n <= 256
+func NewEncoder(k, n int) (*Encoder, error) {
+	if k <= 0 || n < k || n > 256 {
+		return nil, fmt.Errorf("invalid erasure code parameters k=%d n=%d", k, n)
+	}
+
+	matrix := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		matrix[i] = make([]byte, k)
+		if i < k {
+			matrix[i][i] = 1
+			continue
+		}
+		// 1/(x_i + y_j) with x_i = i and y_j = j, never zero as i != j
+		for j := 0; j < k; j++ {
+			matrix[i][j] = gfInv(byte(i) ^ byte(j))
+		}
+	}
+	return &Encoder{k: k, n: n, matrix: matrix}, nil
+}
+
+// Number of shards needed to reconstruct
+func (e *Encoder) DataShards() int {
+	return e.k
+}
+
+// Number of shards produced
+func (e *Encoder) TotalShards() int {
+	return e.n
+}
+
+// Split data into n shards of equal size, the length of data is encoded too
+func (e *Encoder) Encode(data []byte) [][]byte {
+	padded := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(padded, uint64(len(data)))
+	copy(padded[8:], data)
+
+	size := (len(padded) + e.k - 1) / e.k
+	shards := make([][]byte, e.n)
+	for i := 0; i < e.k; i++ {
+		shards[i] = make([]byte, size)
+		if i*size < len(padded) {
+			copy(shards[i], padded[i*size:])
+		}
+	}
+
+	for i := e.k; i < e.n; i++ {
+		shards[i] = make([]byte, size)
+		for j := 0; j < e.k; j++ {
+			mulAdd(shards[i], shards[j], e.matrix[i][j])
+		}
+	}
+	return shards
+}
+
+// Reconstruct data from shards, missing shards are nil
+func (e *Encoder) Decode(shards [][]byte) ([]byte, error) {
+	if len(shards) != e.n {
+		return nil, fmt.Errorf("expect %d shards, got %d", e.n, len(shards))
+	}
+
+	rows := make([]int, 0, e.k)
+	size := -1
+	for i, shard := range shards {
+		if shard == nil {
+			continue
+		}
+		if size < 0 {
+			size = len(shard)
+		} else if len(shard) != size {
+			return nil, ErrShardSize
+		}
+		if len(rows) < e.k {
+			rows = append(rows, i)
+		}
+	}
+	if len(rows) < e.k {
+		return nil, ErrTooFewShards
+	}
+
+	sub := make([][]byte, e.k)
+	for i, row := range rows {
+		sub[i] = e.matrix[row]
+	}
+	inverse, ok := invert(sub)
+	if !ok {
+		return nil, errors.New("singular decoding matrix")
+	}
+
+	padded := make([]byte, 0, size*e.k)
+	for i := 0; i < e.k; i++ {
+		data := make([]byte, size)
+		for j, row := range rows {
+			mulAdd(data, shards[row], inverse[i][j])
+		}
+		padded = append(padded, data...)
+	}
+
+	if len(padded) < 8 {
+		return nil, ErrCorruptedLength
+	}
+	length := binary.BigEndian.Uint64(padded)
+	if length > uint64(len(padded)-8) {
+		return nil, ErrCorruptedLength
+	}
+	return padded[8 : 8+length], nil
+}
+
+// dst += c * src
+func mulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	for i := range src {
+		dst[i] ^= gfMul(c, src[i])
+	}
+}
@@ -1,12 +1,21 @@
 module github.com/stuck
 
-go 1.14
+go 1.18
 
 require (
 	github.com/fortytw2/leaktest v1.3.0
-	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/stretchr/testify v1.8.0
 	go.dedis.ch/kyber/v3 v3.1.0
-	go.uber.org/goleak v1.2.0
 	google.golang.org/protobuf v1.28.1
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/kr/pretty v0.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.dedis.ch/fixbuf v1.0.3 // indirect
+	golang.org/x/crypto v0.0.0-20190123085648-057139ce5d2b // indirect
+	golang.org/x/sys v0.0.0-20190124100055-b90733256f2e // indirect
+	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,48 @@
+package mempool
+
+import "crypto/sha256"
+
+// Committed remembers the transactions of the last blocks committed, so a
+// transaction proposed again by another node or resubmitted after its
+// commit is not committed twice. It is bounded by blocks rather than by
+// transactions, so replicas with the same last blocks filter the same
+// transactions. Like the replica owning it, it is not safe for concurrent
+// use
+type Committed struct {
+	blocks  int
+	counts  map[digest]int // digest -> remembered blocks holding it
+	history [][]digest     // of the remembered blocks, oldest first
+}
+
+// Create set remembering the transactions of the last blocks blocks
+func NewCommitted(blocks int) *Committed {
+	if blocks < 1 {
+		blocks = 1
+	}
+	return &Committed{blocks: blocks, counts: make(map[digest]int)}
+}
+
+// Whether tx is in one of the remembered blocks
+func (c *Committed) Has(tx []byte) bool {
+	return c.counts[sha256.Sum256(tx)] > 0
+}
+
+// Remember the transactions of the next committed block, forgetting the
+// oldest block beyond the bound
+func (c *Committed) Add(txs [][]byte) {
+	digests := make([]digest, 0, len(txs))
+	for _, tx := range txs {
+		d := sha256.Sum256(tx)
+		c.counts[d]++
+		digests = append(digests, d)
+	}
+	c.history = append(c.history, digests)
+	for len(c.history) > c.blocks {
+		for _, d := range c.history[0] {
+			if c.counts[d]--; c.counts[d] == 0 {
+				delete(c.counts, d)
+			}
+		}
+		c.history = c.history[1:]
+	}
+}
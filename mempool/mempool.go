@@ -0,0 +1,203 @@
+package mempool
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDuplicate = errors.New("transaction already in the mempool")
+	ErrTooLarge  = errors.New("transaction exceeds the size limit")
+	ErrFull      = errors.New("mempool is full")
+)
+
+// Limits of a mempool, zero values mean no limit
+type Config struct {
+	MaxTxs     int                   // maximum number of transactions
+	MaxBytes   int                   // maximum total size of transactions
+	MaxTxBytes int                   // maximum size of one transaction
+	TTL        time.Duration         // transactions older than this are evicted
+	Check      func(tx []byte) error // admission check such as CheckTx of the application
+}
+
+type digest = [sha256.Size]byte
+
+type entry struct {
+	tx     []byte
+	digest digest
+	added  time.Time
+}
+
+// Mempool holds client transactions until they are committed, in arrival
+// order and at most once each. It is safe for concurrent use, so clients
+// can add transactions while a replica takes batches out of it
+type Mempool struct {
+	mu      sync.Mutex
+	cfg     Config
+	entries *list.List // of *entry, oldest first
+	index   map[digest]*list.Element
+	bytes   int
+	rand    *rand.Rand
+}
+
+// Create empty mempool
+func NewMempool(cfg Config) *Mempool {
+	return &Mempool{
+		cfg:     cfg,
+		entries: list.New(),
+		index:   make(map[digest]*list.Element),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Add a transaction that passes the check and fits the limits, expired
+// transactions are evicted first to make room
+func (mp *Mempool) Add(tx []byte) error {
+	if mp.cfg.MaxTxBytes > 0 && len(tx) > mp.cfg.MaxTxBytes {
+		return ErrTooLarge
+	}
+	if mp.cfg.Check != nil {
+		if err := mp.cfg.Check(tx); err != nil {
+			return err
+		}
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	d := sha256.Sum256(tx)
+	if _, ok := mp.index[d]; ok {
+		return ErrDuplicate
+	}
+	mp.evict(time.Now())
+	if mp.cfg.MaxTxs > 0 && mp.entries.Len() >= mp.cfg.MaxTxs {
+		return ErrFull
+	}
+	if mp.cfg.MaxBytes > 0 && mp.bytes+len(tx) > mp.cfg.MaxBytes {
+		return ErrFull
+	}
+	mp.index[d] = mp.entries.PushBack(&entry{tx: tx, digest: d, added: time.Now()})
+	mp.bytes += len(tx)
+	return nil
+}
+
+// ErrTooLarge or ErrFull if tx would not be added now, without adding it.
+// Transactions added meanwhile may still fill the mempool before tx
+func (mp *Mempool) Fits(tx []byte) error {
+	if mp.cfg.MaxTxBytes > 0 && len(tx) > mp.cfg.MaxTxBytes {
+		return ErrTooLarge
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.evict(time.Now())
+	if mp.cfg.MaxTxs > 0 && mp.entries.Len() >= mp.cfg.MaxTxs {
+		return ErrFull
+	}
+	if mp.cfg.MaxBytes > 0 && mp.bytes+len(tx) > mp.cfg.MaxBytes {
+		return ErrFull
+	}
+	return nil
+}
+
+// Whether tx is in the mempool
+func (mp *Mempool) Has(tx []byte) bool {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	_, ok := mp.index[sha256.Sum256(tx)]
+	return ok
+}
+
+// Number of transactions
+func (mp *Mempool) Len() int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.entries.Len()
+}
+
+// Total size of transactions
+func (mp *Mempool) Bytes() int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.bytes
+}
+
+// Up to max transactions, oldest first, they stay in the mempool
+func (mp *Mempool) Oldest(max int) [][]byte {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.oldest(max)
+}
+
+// Random sample of size transactions among the oldest candidates, the
+// selection of HoneyBadgerBFT which keeps nodes from proposing the same
+// transactions. They stay in the mempool
+func (mp *Mempool) Sample(candidates, size int) [][]byte {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	txs := mp.oldest(candidates)
+	if size > len(txs) {
+		size = len(txs)
+	}
+	sample := make([][]byte, 0, size)
+	for _, i := range mp.rand.Perm(len(txs))[:size] {
+		sample = append(sample, txs[i])
+	}
+	return sample
+}
+
+// Remove committed transactions, return how many were in the mempool
+func (mp *Mempool) Remove(txs [][]byte) int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	removed := 0
+	for _, tx := range txs {
+		if e, ok := mp.index[sha256.Sum256(tx)]; ok {
+			mp.remove(e)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Evict transactions older than the TTL, return how many
+func (mp *Mempool) Evict() int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.evict(time.Now())
+}
+
+func (mp *Mempool) oldest(max int) [][]byte {
+	if max > mp.entries.Len() {
+		max = mp.entries.Len()
+	}
+	txs := make([][]byte, 0, max)
+	for e := mp.entries.Front(); e != nil && len(txs) < max; e = e.Next() {
+		txs = append(txs, e.Value.(*entry).tx)
+	}
+	return txs
+}
+
+func (mp *Mempool) evict(now time.Time) int {
+	if mp.cfg.TTL <= 0 {
+		return 0
+	}
+	evicted := 0
+	for e := mp.entries.Front(); e != nil; e = mp.entries.Front() {
+		if now.Sub(e.Value.(*entry).added) < mp.cfg.TTL {
+			break
+		}
+		mp.remove(e)
+		evicted++
+	}
+	return evicted
+}
+
+func (mp *Mempool) remove(e *list.Element) {
+	en := mp.entries.Remove(e).(*entry)
+	delete(mp.index, en.digest)
+	mp.bytes -= len(en.tx)
+}
@@ -0,0 +1,56 @@
+package message
+
+import (
+	"errors"
+
+	pb "github.com/stuck/message/messagepb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var ErrNotConsensus = errors.New("operation is not a consensus message")
+
+// Wrap a consensus message into an Operation
+func NewConsensusOperation(m proto.Message) (*pb.Operation, error) {
+	payloads, err := anypb.New(m)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Operation{Op: pb.OpType_HandleConsensus, Payloads: payloads}, nil
+}
+
+// Extract the consensus message from an Operation
+func ConsensusPayload(op *pb.Operation) (proto.Message, error) {
+	if op.GetOp() != pb.OpType_HandleConsensus || op.GetPayloads() == nil {
+		return nil, ErrNotConsensus
+	}
+	return op.GetPayloads().UnmarshalNew()
+}
+
+// Extract the consensus message from what a transport delivers, either an
+// Operation or its wire encoding
+func Decode(v interface{}) (proto.Message, error) {
+	switch m := v.(type) {
+	case *pb.Operation:
+		return ConsensusPayload(m)
+	case []byte:
+		op := &pb.Operation{}
+		if err := proto.Unmarshal(m, op); err != nil {
+			return nil, err
+		}
+		return ConsensusPayload(op)
+	default:
+		return nil, ErrNotConsensus
+	}
+}
+
+// Node a consensus message claims to come from, false if it names none
+func Sender(m proto.Message) (int, bool) {
+	r := m.ProtoReflect()
+	field := r.Descriptor().Fields().ByName("sender")
+	if field == nil || field.Kind() != protoreflect.Int64Kind {
+		return 0, false
+	}
+	return int(r.Get(field).Int()), true
+}
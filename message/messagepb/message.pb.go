@@ -69,33 +69,2335 @@ func (OpType) EnumDescriptor() ([]byte, []int) {
 	return file_message_proto_rawDescGZIP(), []int{0}
 }
 
+// Bracha reliable broadcast
+type RBCType int32
+
+const (
+	RBCType_RBCSend  RBCType = 0
+	RBCType_RBCEcho  RBCType = 1
+	RBCType_RBCReady RBCType = 2
+)
+
+// Enum value maps for RBCType.
+var (
+	RBCType_name = map[int32]string{
+		0: "RBCSend",
+		1: "RBCEcho",
+		2: "RBCReady",
+	}
+	RBCType_value = map[string]int32{
+		"RBCSend":  0,
+		"RBCEcho":  1,
+		"RBCReady": 2,
+	}
+)
+
+func (x RBCType) Enum() *RBCType {
+	p := new(RBCType)
+	*p = x
+	return p
+}
+
+func (x RBCType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RBCType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[1].Descriptor()
+}
+
+func (RBCType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[1]
+}
+
+func (x RBCType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RBCType.Descriptor instead.
+func (RBCType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{1}
+}
+
+// Erasure-coded reliable broadcast
+type AVIDType int32
+
+const (
+	AVIDType_AVIDVal   AVIDType = 0
+	AVIDType_AVIDEcho  AVIDType = 1
+	AVIDType_AVIDReady AVIDType = 2
+)
+
+// Enum value maps for AVIDType.
+var (
+	AVIDType_name = map[int32]string{
+		0: "AVIDVal",
+		1: "AVIDEcho",
+		2: "AVIDReady",
+	}
+	AVIDType_value = map[string]int32{
+		"AVIDVal":   0,
+		"AVIDEcho":  1,
+		"AVIDReady": 2,
+	}
+)
+
+func (x AVIDType) Enum() *AVIDType {
+	p := new(AVIDType)
+	*p = x
+	return p
+}
+
+func (x AVIDType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AVIDType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[2].Descriptor()
+}
+
+func (AVIDType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[2]
+}
+
+func (x AVIDType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AVIDType.Descriptor instead.
+func (AVIDType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{2}
+}
+
+// Binary agreement
+type ABAType int32
+
+const (
+	ABAType_ABABval ABAType = 0
+	ABAType_ABAAux  ABAType = 1
+	ABAType_ABAConf ABAType = 2
+	ABAType_ABACoin ABAType = 3
+	ABAType_ABATerm ABAType = 4
+)
+
+// Enum value maps for ABAType.
+var (
+	ABAType_name = map[int32]string{
+		0: "ABABval",
+		1: "ABAAux",
+		2: "ABAConf",
+		3: "ABACoin",
+		4: "ABATerm",
+	}
+	ABAType_value = map[string]int32{
+		"ABABval": 0,
+		"ABAAux":  1,
+		"ABAConf": 2,
+		"ABACoin": 3,
+		"ABATerm": 4,
+	}
+)
+
+func (x ABAType) Enum() *ABAType {
+	p := new(ABAType)
+	*p = x
+	return p
+}
+
+func (x ABAType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ABAType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[3].Descriptor()
+}
+
+func (ABAType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[3]
+}
+
+func (x ABAType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ABAType.Descriptor instead.
+func (ABAType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{3}
+}
+
+// Consistent broadcast with threshold certificates
+type CBCType int32
+
+const (
+	CBCType_CBCSend  CBCType = 0
+	CBCType_CBCShare CBCType = 1
+	CBCType_CBCFinal CBCType = 2
+)
+
+// Enum value maps for CBCType.
+var (
+	CBCType_name = map[int32]string{
+		0: "CBCSend",
+		1: "CBCShare",
+		2: "CBCFinal",
+	}
+	CBCType_value = map[string]int32{
+		"CBCSend":  0,
+		"CBCShare": 1,
+		"CBCFinal": 2,
+	}
+)
+
+func (x CBCType) Enum() *CBCType {
+	p := new(CBCType)
+	*p = x
+	return p
+}
+
+func (x CBCType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (CBCType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[4].Descriptor()
+}
+
+func (CBCType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[4]
+}
+
+func (x CBCType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use CBCType.Descriptor instead.
+func (CBCType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{4}
+}
+
+// Provable broadcast
+type PBType int32
+
+const (
+	PBType_PBSend  PBType = 0
+	PBType_PBShare PBType = 1
+)
+
+// Enum value maps for PBType.
+var (
+	PBType_name = map[int32]string{
+		0: "PBSend",
+		1: "PBShare",
+	}
+	PBType_value = map[string]int32{
+		"PBSend":  0,
+		"PBShare": 1,
+	}
+)
+
+func (x PBType) Enum() *PBType {
+	p := new(PBType)
+	*p = x
+	return p
+}
+
+func (x PBType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PBType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[5].Descriptor()
+}
+
+func (PBType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[5]
+}
+
+func (x PBType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PBType.Descriptor instead.
+func (PBType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{5}
+}
+
+// Multi-valued Byzantine agreement, values are provably broadcast with
+// PBMsg in round view
+type MVBAType int32
+
+const (
+	MVBAType_MVBAFinish MVBAType = 0
+	MVBAType_MVBACoin   MVBAType = 1
+	MVBAType_MVBAVote   MVBAType = 2
+	MVBAType_MVBAValue  MVBAType = 3
+)
+
+// Enum value maps for MVBAType.
+var (
+	MVBAType_name = map[int32]string{
+		0: "MVBAFinish",
+		1: "MVBACoin",
+		2: "MVBAVote",
+		3: "MVBAValue",
+	}
+	MVBAType_value = map[string]int32{
+		"MVBAFinish": 0,
+		"MVBACoin":   1,
+		"MVBAVote":   2,
+		"MVBAValue":  3,
+	}
+)
+
+func (x MVBAType) Enum() *MVBAType {
+	p := new(MVBAType)
+	*p = x
+	return p
+}
+
+func (x MVBAType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MVBAType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[6].Descriptor()
+}
+
+func (MVBAType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[6]
+}
+
+func (x MVBAType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MVBAType.Descriptor instead.
+func (MVBAType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{6}
+}
+
+// Chained HotStuff
+type HSType int32
+
+const (
+	HSType_HSProposal  HSType = 0
+	HSType_HSVote      HSType = 1
+	HSType_HSTimeout   HSType = 2
+	HSType_HSFetch     HSType = 3
+	HSType_HSBlockResp HSType = 4
+)
+
+// Enum value maps for HSType.
+var (
+	HSType_name = map[int32]string{
+		0: "HSProposal",
+		1: "HSVote",
+		2: "HSTimeout",
+		3: "HSFetch",
+		4: "HSBlockResp",
+	}
+	HSType_value = map[string]int32{
+		"HSProposal":  0,
+		"HSVote":      1,
+		"HSTimeout":   2,
+		"HSFetch":     3,
+		"HSBlockResp": 4,
+	}
+)
+
+func (x HSType) Enum() *HSType {
+	p := new(HSType)
+	*p = x
+	return p
+}
+
+func (x HSType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (HSType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[7].Descriptor()
+}
+
+func (HSType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[7]
+}
+
+func (x HSType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use HSType.Descriptor instead.
+func (HSType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{7}
+}
+
+// PBFT
+type PBFTType int32
+
+const (
+	PBFTType_PBFTPrePrepare PBFTType = 0
+	PBFTType_PBFTPrepare    PBFTType = 1
+	PBFTType_PBFTCommit     PBFTType = 2
+	PBFTType_PBFTCheckpoint PBFTType = 3
+	PBFTType_PBFTViewChange PBFTType = 4
+	PBFTType_PBFTNewView    PBFTType = 5
+)
+
+// Enum value maps for PBFTType.
+var (
+	PBFTType_name = map[int32]string{
+		0: "PBFTPrePrepare",
+		1: "PBFTPrepare",
+		2: "PBFTCommit",
+		3: "PBFTCheckpoint",
+		4: "PBFTViewChange",
+		5: "PBFTNewView",
+	}
+	PBFTType_value = map[string]int32{
+		"PBFTPrePrepare": 0,
+		"PBFTPrepare":    1,
+		"PBFTCommit":     2,
+		"PBFTCheckpoint": 3,
+		"PBFTViewChange": 4,
+		"PBFTNewView":    5,
+	}
+)
+
+func (x PBFTType) Enum() *PBFTType {
+	p := new(PBFTType)
+	*p = x
+	return p
+}
+
+func (x PBFTType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PBFTType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[8].Descriptor()
+}
+
+func (PBFTType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[8]
+}
+
+func (x PBFTType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PBFTType.Descriptor instead.
+func (PBFTType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{8}
+}
+
+// Write-ahead log
+type WALType int32
+
+const (
+	WALType_WALVote       WALType = 0 // vote sent for digest at (view, seq)
+	WALType_WALViewChange WALType = 1 // left view - 1 for view
+	WALType_WALNewView    WALType = 2 // installed view
+	WALType_WALCheckpoint WALType = 3 // stable checkpoint at seq, state in digest
+	WALType_WALDecide     WALType = 4 // batch decided at seq, about to be executed
+	WALType_WALPrepared   WALType = 5 // prepared certificate of digest at (view, seq)
+	WALType_WALMessage    WALType = 6 // message accepted in epoch seq, its operation in digest
+	WALType_WALInput      WALType = 7 // value input to instance view of epoch seq, in digest
+)
+
+// Enum value maps for WALType.
+var (
+	WALType_name = map[int32]string{
+		0: "WALVote",
+		1: "WALViewChange",
+		2: "WALNewView",
+		3: "WALCheckpoint",
+		4: "WALDecide",
+		5: "WALPrepared",
+		6: "WALMessage",
+		7: "WALInput",
+	}
+	WALType_value = map[string]int32{
+		"WALVote":       0,
+		"WALViewChange": 1,
+		"WALNewView":    2,
+		"WALCheckpoint": 3,
+		"WALDecide":     4,
+		"WALPrepared":   5,
+		"WALMessage":    6,
+		"WALInput":      7,
+	}
+)
+
+func (x WALType) Enum() *WALType {
+	p := new(WALType)
+	*p = x
+	return p
+}
+
+func (x WALType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WALType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[9].Descriptor()
+}
+
+func (WALType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[9]
+}
+
+func (x WALType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WALType.Descriptor instead.
+func (WALType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{9}
+}
+
+type BlockType int32
+
+const (
+	BlockType_BlockShare   BlockType = 0 // signature share on the height and digest of a committed block
+	BlockType_BlockRequest BlockType = 1 // node asks for the certified blocks after height
+	BlockType_BlockRange   BlockType = 2 // certified blocks after height
+)
+
+// Enum value maps for BlockType.
+var (
+	BlockType_name = map[int32]string{
+		0: "BlockShare",
+		1: "BlockRequest",
+		2: "BlockRange",
+	}
+	BlockType_value = map[string]int32{
+		"BlockShare":   0,
+		"BlockRequest": 1,
+		"BlockRange":   2,
+	}
+)
+
+func (x BlockType) Enum() *BlockType {
+	p := new(BlockType)
+	*p = x
+	return p
+}
+
+func (x BlockType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BlockType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[10].Descriptor()
+}
+
+func (BlockType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[10]
+}
+
+func (x BlockType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BlockType.Descriptor instead.
+func (BlockType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{10}
+}
+
+// Checkpoints
+type CheckpointType int32
+
+const (
+	CheckpointType_CheckpointShare CheckpointType = 0 // signature share on the state hash at height
+	CheckpointType_CheckpointCert  CheckpointType = 1 // threshold signature on the state hash at height
+)
+
+// Enum value maps for CheckpointType.
+var (
+	CheckpointType_name = map[int32]string{
+		0: "CheckpointShare",
+		1: "CheckpointCert",
+	}
+	CheckpointType_value = map[string]int32{
+		"CheckpointShare": 0,
+		"CheckpointCert":  1,
+	}
+)
+
+func (x CheckpointType) Enum() *CheckpointType {
+	p := new(CheckpointType)
+	*p = x
+	return p
+}
+
+func (x CheckpointType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (CheckpointType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[11].Descriptor()
+}
+
+func (CheckpointType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[11]
+}
+
+func (x CheckpointType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use CheckpointType.Descriptor instead.
+func (CheckpointType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{11}
+}
+
+// State transfer
+type SyncType int32
+
+const (
+	SyncType_SyncRequest  SyncType = 0 // lagging node asks for everything after height
+	SyncType_SyncResponse SyncType = 1 // certified snapshot if any and the blocks after it
+)
+
+// Enum value maps for SyncType.
+var (
+	SyncType_name = map[int32]string{
+		0: "SyncRequest",
+		1: "SyncResponse",
+	}
+	SyncType_value = map[string]int32{
+		"SyncRequest":  0,
+		"SyncResponse": 1,
+	}
+)
+
+func (x SyncType) Enum() *SyncType {
+	p := new(SyncType)
+	*p = x
+	return p
+}
+
+func (x SyncType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SyncType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[12].Descriptor()
+}
+
+func (SyncType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[12]
+}
+
+func (x SyncType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SyncType.Descriptor instead.
+func (SyncType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{12}
+}
+
 // The message format that the application layer reads from the network layer
 type Operation struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Op       OpType     `protobuf:"varint,1,opt,name=op,proto3,enum=message.OpType" json:"op,omitempty"`
-	Payloads *anypb.Any `protobuf:"bytes,2,opt,name=payloads,proto3" json:"payloads,omitempty"`
+	Op       OpType     `protobuf:"varint,1,opt,name=op,proto3,enum=message.OpType" json:"op,omitempty"`
+	Payloads *anypb.Any `protobuf:"bytes,2,opt,name=payloads,proto3" json:"payloads,omitempty"`
+}
+
+func (x *Operation) Reset() {
+	*x = Operation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Operation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Operation) ProtoMessage() {}
+
+func (x *Operation) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Operation.ProtoReflect.Descriptor instead.
+func (*Operation) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Operation) GetOp() OpType {
+	if x != nil {
+		return x.Op
+	}
+	return OpType_HandleRelationship
+}
+
+func (x *Operation) GetPayloads() *anypb.Any {
+	if x != nil {
+		return x.Payloads
+	}
+	return nil
+}
+
+// Test whether `any` of protobuf is available
+type AnyTest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Message string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *AnyTest) Reset() {
+	*x = AnyTest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AnyTest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnyTest) ProtoMessage() {}
+
+func (x *AnyTest) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnyTest.ProtoReflect.Descriptor instead.
+func (*AnyTest) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AnyTest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// An RBC instance is identified by (epoch, proposer)
+type RBCMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type     RBCType `protobuf:"varint,1,opt,name=type,proto3,enum=message.RBCType" json:"type,omitempty"`
+	Sender   int64   `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Epoch    uint64  `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Proposer int64   `protobuf:"varint,4,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	Value    []byte  `protobuf:"bytes,5,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *RBCMsg) Reset() {
+	*x = RBCMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RBCMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RBCMsg) ProtoMessage() {}
+
+func (x *RBCMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RBCMsg.ProtoReflect.Descriptor instead.
+func (*RBCMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RBCMsg) GetType() RBCType {
+	if x != nil {
+		return x.Type
+	}
+	return RBCType_RBCSend
+}
+
+func (x *RBCMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *RBCMsg) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *RBCMsg) GetProposer() int64 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *RBCMsg) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+// An AVID instance is identified by (epoch, proposer), the fragment of
+// VAL belongs to the receiver and the fragment of ECHO to the sender
+type AVIDMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type     AVIDType `protobuf:"varint,1,opt,name=type,proto3,enum=message.AVIDType" json:"type,omitempty"`
+	Sender   int64    `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Epoch    uint64   `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Proposer int64    `protobuf:"varint,4,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	Root     []byte   `protobuf:"bytes,5,opt,name=root,proto3" json:"root,omitempty"`
+	Fragment []byte   `protobuf:"bytes,6,opt,name=fragment,proto3" json:"fragment,omitempty"`
+	Proof    [][]byte `protobuf:"bytes,7,rep,name=proof,proto3" json:"proof,omitempty"`
+}
+
+func (x *AVIDMsg) Reset() {
+	*x = AVIDMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AVIDMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AVIDMsg) ProtoMessage() {}
+
+func (x *AVIDMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AVIDMsg.ProtoReflect.Descriptor instead.
+func (*AVIDMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AVIDMsg) GetType() AVIDType {
+	if x != nil {
+		return x.Type
+	}
+	return AVIDType_AVIDVal
+}
+
+func (x *AVIDMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *AVIDMsg) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *AVIDMsg) GetProposer() int64 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *AVIDMsg) GetRoot() []byte {
+	if x != nil {
+		return x.Root
+	}
+	return nil
+}
+
+func (x *AVIDMsg) GetFragment() []byte {
+	if x != nil {
+		return x.Fragment
+	}
+	return nil
+}
+
+func (x *AVIDMsg) GetProof() [][]byte {
+	if x != nil {
+		return x.Proof
+	}
+	return nil
+}
+
+// An ABA instance is identified by (epoch, instance). BVAL and AUX carry
+// a bit in value, CONF carries a set of bits as a mask (1 for 0, 2 for 1),
+// COIN carries a threshold signature share and TERM the decided bit
+type ABAMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type     ABAType `protobuf:"varint,1,opt,name=type,proto3,enum=message.ABAType" json:"type,omitempty"`
+	Sender   int64   `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Epoch    uint64  `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Instance int64   `protobuf:"varint,4,opt,name=instance,proto3" json:"instance,omitempty"`
+	Round    uint32  `protobuf:"varint,5,opt,name=round,proto3" json:"round,omitempty"`
+	Value    uint32  `protobuf:"varint,6,opt,name=value,proto3" json:"value,omitempty"`
+	Share    []byte  `protobuf:"bytes,7,opt,name=share,proto3" json:"share,omitempty"`
+}
+
+func (x *ABAMsg) Reset() {
+	*x = ABAMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ABAMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ABAMsg) ProtoMessage() {}
+
+func (x *ABAMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ABAMsg.ProtoReflect.Descriptor instead.
+func (*ABAMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ABAMsg) GetType() ABAType {
+	if x != nil {
+		return x.Type
+	}
+	return ABAType_ABABval
+}
+
+func (x *ABAMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *ABAMsg) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *ABAMsg) GetInstance() int64 {
+	if x != nil {
+		return x.Instance
+	}
+	return 0
+}
+
+func (x *ABAMsg) GetRound() uint32 {
+	if x != nil {
+		return x.Round
+	}
+	return 0
+}
+
+func (x *ABAMsg) GetValue() uint32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *ABAMsg) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+// Transactions proposed by one node in one epoch
+type TxBatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Txs [][]byte `protobuf:"bytes,1,rep,name=txs,proto3" json:"txs,omitempty"`
+}
+
+func (x *TxBatch) Reset() {
+	*x = TxBatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TxBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TxBatch) ProtoMessage() {}
+
+func (x *TxBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TxBatch.ProtoReflect.Descriptor instead.
+func (*TxBatch) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TxBatch) GetTxs() [][]byte {
+	if x != nil {
+		return x.Txs
+	}
+	return nil
+}
+
+// HoneyBadgerBFT decryption share of the proposal of proposer in epoch
+type HBDecShare struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Sender   int64  `protobuf:"varint,1,opt,name=sender,proto3" json:"sender,omitempty"`
+	Epoch    uint64 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Proposer int64  `protobuf:"varint,3,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	Share    []byte `protobuf:"bytes,4,opt,name=share,proto3" json:"share,omitempty"`
+}
+
+func (x *HBDecShare) Reset() {
+	*x = HBDecShare{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HBDecShare) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HBDecShare) ProtoMessage() {}
+
+func (x *HBDecShare) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HBDecShare.ProtoReflect.Descriptor instead.
+func (*HBDecShare) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HBDecShare) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *HBDecShare) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *HBDecShare) GetProposer() int64 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *HBDecShare) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+// FINAL carries the value and its certificate, n-f combined shares
+type CBCMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type        CBCType `protobuf:"varint,1,opt,name=type,proto3,enum=message.CBCType" json:"type,omitempty"`
+	Sender      int64   `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Epoch       uint64  `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Proposer    int64   `protobuf:"varint,4,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	Value       []byte  `protobuf:"bytes,5,opt,name=value,proto3" json:"value,omitempty"`
+	Share       []byte  `protobuf:"bytes,6,opt,name=share,proto3" json:"share,omitempty"`
+	Certificate []byte  `protobuf:"bytes,7,opt,name=certificate,proto3" json:"certificate,omitempty"`
+}
+
+func (x *CBCMsg) Reset() {
+	*x = CBCMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CBCMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CBCMsg) ProtoMessage() {}
+
+func (x *CBCMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CBCMsg.ProtoReflect.Descriptor instead.
+func (*CBCMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CBCMsg) GetType() CBCType {
+	if x != nil {
+		return x.Type
+	}
+	return CBCType_CBCSend
+}
+
+func (x *CBCMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *CBCMsg) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *CBCMsg) GetProposer() int64 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *CBCMsg) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *CBCMsg) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+func (x *CBCMsg) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+// A provable broadcast is identified by (epoch, round, proposer), protocols
+// running several of them per epoch number them with round
+type PBMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type     PBType `protobuf:"varint,1,opt,name=type,proto3,enum=message.PBType" json:"type,omitempty"`
+	Sender   int64  `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Epoch    uint64 `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Round    uint32 `protobuf:"varint,4,opt,name=round,proto3" json:"round,omitempty"`
+	Proposer int64  `protobuf:"varint,5,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	Value    []byte `protobuf:"bytes,6,opt,name=value,proto3" json:"value,omitempty"`
+	Share    []byte `protobuf:"bytes,7,opt,name=share,proto3" json:"share,omitempty"`
+}
+
+func (x *PBMsg) Reset() {
+	*x = PBMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PBMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PBMsg) ProtoMessage() {}
+
+func (x *PBMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PBMsg.ProtoReflect.Descriptor instead.
+func (*PBMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *PBMsg) GetType() PBType {
+	if x != nil {
+		return x.Type
+	}
+	return PBType_PBSend
+}
+
+func (x *PBMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *PBMsg) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *PBMsg) GetRound() uint32 {
+	if x != nil {
+		return x.Round
+	}
+	return 0
+}
+
+func (x *PBMsg) GetProposer() int64 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *PBMsg) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *PBMsg) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+// An MVBA instance is identified by epoch and runs in views. Proposer is the
+// owner of a provable broadcast, or the leader in VOTE and VALUE, and proof
+// is the certificate of its provable broadcast
+type MVBAMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type     MVBAType `protobuf:"varint,1,opt,name=type,proto3,enum=message.MVBAType" json:"type,omitempty"`
+	Sender   int64    `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Epoch    uint64   `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	View     uint32   `protobuf:"varint,4,opt,name=view,proto3" json:"view,omitempty"`
+	Proposer int64    `protobuf:"varint,5,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	Value    []byte   `protobuf:"bytes,6,opt,name=value,proto3" json:"value,omitempty"`
+	Share    []byte   `protobuf:"bytes,7,opt,name=share,proto3" json:"share,omitempty"`
+	Proof    []byte   `protobuf:"bytes,8,opt,name=proof,proto3" json:"proof,omitempty"`
+}
+
+func (x *MVBAMsg) Reset() {
+	*x = MVBAMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MVBAMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MVBAMsg) ProtoMessage() {}
+
+func (x *MVBAMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MVBAMsg.ProtoReflect.Descriptor instead.
+func (*MVBAMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *MVBAMsg) GetType() MVBAType {
+	if x != nil {
+		return x.Type
+	}
+	return MVBAType_MVBAFinish
+}
+
+func (x *MVBAMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *MVBAMsg) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *MVBAMsg) GetView() uint32 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *MVBAMsg) GetProposer() int64 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *MVBAMsg) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *MVBAMsg) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+func (x *MVBAMsg) GetProof() []byte {
+	if x != nil {
+		return x.Proof
+	}
+	return nil
+}
+
+// Dumbo2 common subset, share on the delivery of proposer's broadcast
+type DumboDone struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Sender   int64  `protobuf:"varint,1,opt,name=sender,proto3" json:"sender,omitempty"`
+	Epoch    uint64 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Proposer int64  `protobuf:"varint,3,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	Share    []byte `protobuf:"bytes,4,opt,name=share,proto3" json:"share,omitempty"`
+}
+
+func (x *DumboDone) Reset() {
+	*x = DumboDone{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DumboDone) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DumboDone) ProtoMessage() {}
+
+func (x *DumboDone) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DumboDone.ProtoReflect.Descriptor instead.
+func (*DumboDone) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DumboDone) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *DumboDone) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *DumboDone) GetProposer() int64 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *DumboDone) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+// Proof that the broadcast of proposer was delivered by an honest node
+type DumboProof struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Proposer  int64  `protobuf:"varint,1,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *DumboProof) Reset() {
+	*x = DumboProof{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DumboProof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DumboProof) ProtoMessage() {}
+
+func (x *DumboProof) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DumboProof.ProtoReflect.Descriptor instead.
+func (*DumboProof) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DumboProof) GetProposer() int64 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *DumboProof) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// MVBA value of Dumbo2, n-f proofs for distinct proposers
+type DumboProofs struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Proofs []*DumboProof `protobuf:"bytes,1,rep,name=proofs,proto3" json:"proofs,omitempty"`
+}
+
+func (x *DumboProofs) Reset() {
+	*x = DumboProofs{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DumboProofs) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DumboProofs) ProtoMessage() {}
+
+func (x *DumboProofs) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DumboProofs.ProtoReflect.Descriptor instead.
+func (*DumboProofs) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DumboProofs) GetProofs() []*DumboProof {
+	if x != nil {
+		return x.Proofs
+	}
+	return nil
+}
+
+// Quorum certificate on block in view, or timeout certificate of view when
+// block is empty. The genesis QC has view 0 and no signature
+type HSQC struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	View      uint64 `protobuf:"varint,1,opt,name=view,proto3" json:"view,omitempty"`
+	Block     []byte `protobuf:"bytes,2,opt,name=block,proto3" json:"block,omitempty"`
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *HSQC) Reset() {
+	*x = HSQC{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HSQC) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HSQC) ProtoMessage() {}
+
+func (x *HSQC) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HSQC.ProtoReflect.Descriptor instead.
+func (*HSQC) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *HSQC) GetView() uint64 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *HSQC) GetBlock() []byte {
+	if x != nil {
+		return x.Block
+	}
+	return nil
+}
+
+func (x *HSQC) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// A block extends the block certified by justify
+type HSBlock struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	View     uint64   `protobuf:"varint,1,opt,name=view,proto3" json:"view,omitempty"`
+	Justify  *HSQC    `protobuf:"bytes,2,opt,name=justify,proto3" json:"justify,omitempty"`
+	Proposer int64    `protobuf:"varint,3,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	Txs      [][]byte `protobuf:"bytes,4,rep,name=txs,proto3" json:"txs,omitempty"`
+}
+
+func (x *HSBlock) Reset() {
+	*x = HSBlock{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HSBlock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HSBlock) ProtoMessage() {}
+
+func (x *HSBlock) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HSBlock.ProtoReflect.Descriptor instead.
+func (*HSBlock) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *HSBlock) GetView() uint64 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *HSBlock) GetJustify() *HSQC {
+	if x != nil {
+		return x.Justify
+	}
+	return nil
+}
+
+func (x *HSBlock) GetProposer() int64 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *HSBlock) GetTxs() [][]byte {
+	if x != nil {
+		return x.Txs
+	}
+	return nil
+}
+
+type HSMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type   HSType   `protobuf:"varint,1,opt,name=type,proto3,enum=message.HSType" json:"type,omitempty"`
+	Sender int64    `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	View   uint64   `protobuf:"varint,3,opt,name=view,proto3" json:"view,omitempty"`
+	Block  *HSBlock `protobuf:"bytes,4,opt,name=block,proto3" json:"block,omitempty"`   // PROPOSAL and BLOCKRESP
+	Qc     *HSQC    `protobuf:"bytes,5,opt,name=qc,proto3" json:"qc,omitempty"`         // highest QC of the sender in TIMEOUT
+	Tc     *HSQC    `protobuf:"bytes,6,opt,name=tc,proto3" json:"tc,omitempty"`         // PROPOSAL made after a timeout
+	Digest []byte   `protobuf:"bytes,7,opt,name=digest,proto3" json:"digest,omitempty"` // VOTE and FETCH
+	Share  []byte   `protobuf:"bytes,8,opt,name=share,proto3" json:"share,omitempty"`   // VOTE and TIMEOUT
+	Vote   *HSMsg   `protobuf:"bytes,9,opt,name=vote,proto3" json:"vote,omitempty"`     // last VOTE of the sender in TIMEOUT, its leader may have crashed
+}
+
+func (x *HSMsg) Reset() {
+	*x = HSMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HSMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HSMsg) ProtoMessage() {}
+
+func (x *HSMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HSMsg.ProtoReflect.Descriptor instead.
+func (*HSMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *HSMsg) GetType() HSType {
+	if x != nil {
+		return x.Type
+	}
+	return HSType_HSProposal
+}
+
+func (x *HSMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *HSMsg) GetView() uint64 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *HSMsg) GetBlock() *HSBlock {
+	if x != nil {
+		return x.Block
+	}
+	return nil
+}
+
+func (x *HSMsg) GetQc() *HSQC {
+	if x != nil {
+		return x.Qc
+	}
+	return nil
+}
+
+func (x *HSMsg) GetTc() *HSQC {
+	if x != nil {
+		return x.Tc
+	}
+	return nil
+}
+
+func (x *HSMsg) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+func (x *HSMsg) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+func (x *HSMsg) GetVote() *HSMsg {
+	if x != nil {
+		return x.Vote
+	}
+	return nil
+}
+
+// Batch prepared in view at seq, the certificate combines n-f PREPARE shares
+type PBFTPrepared struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	View        uint64   `protobuf:"varint,1,opt,name=view,proto3" json:"view,omitempty"`
+	Seq         uint64   `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Digest      []byte   `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`
+	Txs         [][]byte `protobuf:"bytes,4,rep,name=txs,proto3" json:"txs,omitempty"`
+	Certificate []byte   `protobuf:"bytes,5,opt,name=certificate,proto3" json:"certificate,omitempty"`
+}
+
+func (x *PBFTPrepared) Reset() {
+	*x = PBFTPrepared{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PBFTPrepared) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PBFTPrepared) ProtoMessage() {}
+
+func (x *PBFTPrepared) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PBFTPrepared.ProtoReflect.Descriptor instead.
+func (*PBFTPrepared) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PBFTPrepared) GetView() uint64 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *PBFTPrepared) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *PBFTPrepared) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+func (x *PBFTPrepared) GetTxs() [][]byte {
+	if x != nil {
+		return x.Txs
+	}
+	return nil
+}
+
+func (x *PBFTPrepared) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+// Stable checkpoint, the certificate combines n-f CHECKPOINT shares on the
+// state after executing seq. Seq 0 is the initial state and has none
+type PBFTCheckpointCert struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seq         uint64 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	State       []byte `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Certificate []byte `protobuf:"bytes,3,opt,name=certificate,proto3" json:"certificate,omitempty"`
+}
+
+func (x *PBFTCheckpointCert) Reset() {
+	*x = PBFTCheckpointCert{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PBFTCheckpointCert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PBFTCheckpointCert) ProtoMessage() {}
+
+func (x *PBFTCheckpointCert) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PBFTCheckpointCert.ProtoReflect.Descriptor instead.
+func (*PBFTCheckpointCert) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *PBFTCheckpointCert) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *PBFTCheckpointCert) GetState() []byte {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *PBFTCheckpointCert) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+// Signature is a share of the sender on the other fields
+type PBFTViewChangeInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	View       uint64              `protobuf:"varint,1,opt,name=view,proto3" json:"view,omitempty"`
+	Sender     int64               `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Checkpoint *PBFTCheckpointCert `protobuf:"bytes,3,opt,name=checkpoint,proto3" json:"checkpoint,omitempty"`
+	Prepared   []*PBFTPrepared     `protobuf:"bytes,4,rep,name=prepared,proto3" json:"prepared,omitempty"`
+	Signature  []byte              `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *PBFTViewChangeInfo) Reset() {
+	*x = PBFTViewChangeInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PBFTViewChangeInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PBFTViewChangeInfo) ProtoMessage() {}
+
+func (x *PBFTViewChangeInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PBFTViewChangeInfo.ProtoReflect.Descriptor instead.
+func (*PBFTViewChangeInfo) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *PBFTViewChangeInfo) GetView() uint64 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *PBFTViewChangeInfo) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *PBFTViewChangeInfo) GetCheckpoint() *PBFTCheckpointCert {
+	if x != nil {
+		return x.Checkpoint
+	}
+	return nil
+}
+
+func (x *PBFTViewChangeInfo) GetPrepared() []*PBFTPrepared {
+	if x != nil {
+		return x.Prepared
+	}
+	return nil
+}
+
+func (x *PBFTViewChangeInfo) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type PBFTMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type        PBFTType              `protobuf:"varint,1,opt,name=type,proto3,enum=message.PBFTType" json:"type,omitempty"`
+	Sender      int64                 `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	View        uint64                `protobuf:"varint,3,opt,name=view,proto3" json:"view,omitempty"`
+	Seq         uint64                `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"`
+	Digest      []byte                `protobuf:"bytes,5,opt,name=digest,proto3" json:"digest,omitempty"`                               // batch digest, or state in CHECKPOINT
+	Txs         [][]byte              `protobuf:"bytes,6,rep,name=txs,proto3" json:"txs,omitempty"`                                     // PRE-PREPARE
+	Share       []byte                `protobuf:"bytes,7,opt,name=share,proto3" json:"share,omitempty"`                                 // PREPARE and CHECKPOINT
+	ViewChange  *PBFTViewChangeInfo   `protobuf:"bytes,8,opt,name=view_change,json=viewChange,proto3" json:"view_change,omitempty"`     // VIEW-CHANGE
+	ViewChanges []*PBFTViewChangeInfo `protobuf:"bytes,9,rep,name=view_changes,json=viewChanges,proto3" json:"view_changes,omitempty"`  // NEW-VIEW proof
+	PrePrepares []*PBFTMsg            `protobuf:"bytes,10,rep,name=pre_prepares,json=prePrepares,proto3" json:"pre_prepares,omitempty"` // NEW-VIEW
+}
+
+func (x *PBFTMsg) Reset() {
+	*x = PBFTMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PBFTMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PBFTMsg) ProtoMessage() {}
+
+func (x *PBFTMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PBFTMsg.ProtoReflect.Descriptor instead.
+func (*PBFTMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PBFTMsg) GetType() PBFTType {
+	if x != nil {
+		return x.Type
+	}
+	return PBFTType_PBFTPrePrepare
+}
+
+func (x *PBFTMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *PBFTMsg) GetView() uint64 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *PBFTMsg) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *PBFTMsg) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+func (x *PBFTMsg) GetTxs() [][]byte {
+	if x != nil {
+		return x.Txs
+	}
+	return nil
+}
+
+func (x *PBFTMsg) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+func (x *PBFTMsg) GetViewChange() *PBFTViewChangeInfo {
+	if x != nil {
+		return x.ViewChange
+	}
+	return nil
+}
+
+func (x *PBFTMsg) GetViewChanges() []*PBFTViewChangeInfo {
+	if x != nil {
+		return x.ViewChanges
+	}
+	return nil
+}
+
+func (x *PBFTMsg) GetPrePrepares() []*PBFTMsg {
+	if x != nil {
+		return x.PrePrepares
+	}
+	return nil
+}
+
+// Protocol state recorded before acting on it, asynchronous protocols use
+// view and seq for epoch and round
+type WALRecord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type        WALType  `protobuf:"varint,1,opt,name=type,proto3,enum=message.WALType" json:"type,omitempty"`
+	View        uint64   `protobuf:"varint,2,opt,name=view,proto3" json:"view,omitempty"`
+	Seq         uint64   `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	Digest      []byte   `protobuf:"bytes,4,opt,name=digest,proto3" json:"digest,omitempty"`
+	Txs         [][]byte `protobuf:"bytes,5,rep,name=txs,proto3" json:"txs,omitempty"`
+	Certificate []byte   `protobuf:"bytes,6,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	Locked      uint64   `protobuf:"varint,7,opt,name=locked,proto3" json:"locked,omitempty"`              // view HotStuff was locked on when it voted
+	Proposers   []int64  `protobuf:"varint,8,rep,packed,name=proposers,proto3" json:"proposers,omitempty"` // proposer of each of txs, agreed subsets
+}
+
+func (x *WALRecord) Reset() {
+	*x = WALRecord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WALRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WALRecord) ProtoMessage() {}
+
+func (x *WALRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WALRecord.ProtoReflect.Descriptor instead.
+func (*WALRecord) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *WALRecord) GetType() WALType {
+	if x != nil {
+		return x.Type
+	}
+	return WALType_WALVote
+}
+
+func (x *WALRecord) GetView() uint64 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *WALRecord) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *WALRecord) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+func (x *WALRecord) GetTxs() [][]byte {
+	if x != nil {
+		return x.Txs
+	}
+	return nil
+}
+
+func (x *WALRecord) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+func (x *WALRecord) GetLocked() uint64 {
+	if x != nil {
+		return x.Locked
+	}
+	return 0
+}
+
+func (x *WALRecord) GetProposers() []int64 {
+	if x != nil {
+		return x.Proposers
+	}
+	return nil
+}
+
+// Block store
+type StoredBlock struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Height      uint64   `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	Txs         [][]byte `protobuf:"bytes,2,rep,name=txs,proto3" json:"txs,omitempty"`
+	Certificate []byte   `protobuf:"bytes,3,opt,name=certificate,proto3" json:"certificate,omitempty"` // threshold signature on the height and digest of txs
 }
 
-func (x *Operation) Reset() {
-	*x = Operation{}
+func (x *StoredBlock) Reset() {
+	*x = StoredBlock{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_message_proto_msgTypes[0]
+		mi := &file_message_proto_msgTypes[21]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Operation) String() string {
+func (x *StoredBlock) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Operation) ProtoMessage() {}
+func (*StoredBlock) ProtoMessage() {}
 
-func (x *Operation) ProtoReflect() protoreflect.Message {
-	mi := &file_message_proto_msgTypes[0]
+func (x *StoredBlock) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[21]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -106,51 +2408,306 @@ func (x *Operation) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Operation.ProtoReflect.Descriptor instead.
-func (*Operation) Descriptor() ([]byte, []int) {
-	return file_message_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use StoredBlock.ProtoReflect.Descriptor instead.
+func (*StoredBlock) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{21}
 }
 
-func (x *Operation) GetOp() OpType {
+func (x *StoredBlock) GetHeight() uint64 {
 	if x != nil {
-		return x.Op
+		return x.Height
 	}
-	return OpType_HandleRelationship
+	return 0
 }
 
-func (x *Operation) GetPayloads() *anypb.Any {
+func (x *StoredBlock) GetTxs() [][]byte {
 	if x != nil {
-		return x.Payloads
+		return x.Txs
 	}
 	return nil
 }
 
-// Test whether `any` of protobuf is available
-type AnyTest struct {
+func (x *StoredBlock) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+type BlockMsg struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Message string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	Type   BlockType      `protobuf:"varint,1,opt,name=type,proto3,enum=message.BlockType" json:"type,omitempty"`
+	Sender int64          `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Height uint64         `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"` // height of the first block minus one in REQUEST and RANGE
+	Digest []byte         `protobuf:"bytes,4,opt,name=digest,proto3" json:"digest,omitempty"`  // SHARE
+	Share  []byte         `protobuf:"bytes,5,opt,name=share,proto3" json:"share,omitempty"`    // SHARE
+	Blocks []*StoredBlock `protobuf:"bytes,6,rep,name=blocks,proto3" json:"blocks,omitempty"`  // RANGE
 }
 
-func (x *AnyTest) Reset() {
-	*x = AnyTest{}
+func (x *BlockMsg) Reset() {
+	*x = BlockMsg{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_message_proto_msgTypes[1]
+		mi := &file_message_proto_msgTypes[22]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *AnyTest) String() string {
+func (x *BlockMsg) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*AnyTest) ProtoMessage() {}
+func (*BlockMsg) ProtoMessage() {}
 
-func (x *AnyTest) ProtoReflect() protoreflect.Message {
-	mi := &file_message_proto_msgTypes[1]
+func (x *BlockMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockMsg.ProtoReflect.Descriptor instead.
+func (*BlockMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *BlockMsg) GetType() BlockType {
+	if x != nil {
+		return x.Type
+	}
+	return BlockType_BlockShare
+}
+
+func (x *BlockMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *BlockMsg) GetHeight() uint64 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *BlockMsg) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+func (x *BlockMsg) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+func (x *BlockMsg) GetBlocks() []*StoredBlock {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
+}
+
+type CheckpointMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type        CheckpointType `protobuf:"varint,1,opt,name=type,proto3,enum=message.CheckpointType" json:"type,omitempty"`
+	Sender      int64          `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Height      uint64         `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`
+	StateHash   []byte         `protobuf:"bytes,4,opt,name=state_hash,json=stateHash,proto3" json:"state_hash,omitempty"`
+	Share       []byte         `protobuf:"bytes,5,opt,name=share,proto3" json:"share,omitempty"`             // SHARE
+	Certificate []byte         `protobuf:"bytes,6,opt,name=certificate,proto3" json:"certificate,omitempty"` // CERT
+}
+
+func (x *CheckpointMsg) Reset() {
+	*x = CheckpointMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CheckpointMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckpointMsg) ProtoMessage() {}
+
+func (x *CheckpointMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckpointMsg.ProtoReflect.Descriptor instead.
+func (*CheckpointMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *CheckpointMsg) GetType() CheckpointType {
+	if x != nil {
+		return x.Type
+	}
+	return CheckpointType_CheckpointShare
+}
+
+func (x *CheckpointMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *CheckpointMsg) GetHeight() uint64 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *CheckpointMsg) GetStateHash() []byte {
+	if x != nil {
+		return x.StateHash
+	}
+	return nil
+}
+
+func (x *CheckpointMsg) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+func (x *CheckpointMsg) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+type SyncSnapshot struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Height      uint64 `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	AppHash     []byte `protobuf:"bytes,2,opt,name=app_hash,json=appHash,proto3" json:"app_hash,omitempty"`
+	State       []byte `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	Certificate []byte `protobuf:"bytes,4,opt,name=certificate,proto3" json:"certificate,omitempty"` // checkpoint certificate on height and app hash
+}
+
+func (x *SyncSnapshot) Reset() {
+	*x = SyncSnapshot{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SyncSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncSnapshot) ProtoMessage() {}
+
+func (x *SyncSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncSnapshot.ProtoReflect.Descriptor instead.
+func (*SyncSnapshot) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *SyncSnapshot) GetHeight() uint64 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *SyncSnapshot) GetAppHash() []byte {
+	if x != nil {
+		return x.AppHash
+	}
+	return nil
+}
+
+func (x *SyncSnapshot) GetState() []byte {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *SyncSnapshot) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+type SyncMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type     SyncType      `protobuf:"varint,1,opt,name=type,proto3,enum=message.SyncType" json:"type,omitempty"`
+	Sender   int64         `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Height   uint64        `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`    // REQUEST, height of the first block minus one in RESPONSE
+	Snapshot *SyncSnapshot `protobuf:"bytes,4,opt,name=snapshot,proto3" json:"snapshot,omitempty"` // RESPONSE
+	Blocks   []*TxBatch    `protobuf:"bytes,5,rep,name=blocks,proto3" json:"blocks,omitempty"`     // RESPONSE
+}
+
+func (x *SyncMsg) Reset() {
+	*x = SyncMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_message_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SyncMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncMsg) ProtoMessage() {}
+
+func (x *SyncMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[25]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -158,19 +2715,47 @@ func (x *AnyTest) ProtoReflect() protoreflect.Message {
 		}
 		return ms
 	}
-	return mi.MessageOf(x)
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncMsg.ProtoReflect.Descriptor instead.
+func (*SyncMsg) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *SyncMsg) GetType() SyncType {
+	if x != nil {
+		return x.Type
+	}
+	return SyncType_SyncRequest
+}
+
+func (x *SyncMsg) GetSender() int64 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
 }
 
-// Deprecated: Use AnyTest.ProtoReflect.Descriptor instead.
-func (*AnyTest) Descriptor() ([]byte, []int) {
-	return file_message_proto_rawDescGZIP(), []int{1}
+func (x *SyncMsg) GetHeight() uint64 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
 }
 
-func (x *AnyTest) GetMessage() string {
+func (x *SyncMsg) GetSnapshot() *SyncSnapshot {
 	if x != nil {
-		return x.Message
+		return x.Snapshot
 	}
-	return ""
+	return nil
+}
+
+func (x *SyncMsg) GetBlocks() []*TxBatch {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
 }
 
 var File_message_proto protoreflect.FileDescriptor
@@ -187,12 +2772,302 @@ var file_message_proto_rawDesc = []byte{
 	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x41, 0x6e, 0x79, 0x52, 0x08, 0x70, 0x61, 0x79, 0x6c, 0x6f,
 	0x61, 0x64, 0x73, 0x22, 0x23, 0x0a, 0x07, 0x41, 0x6e, 0x79, 0x54, 0x65, 0x73, 0x74, 0x12, 0x18,
 	0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2a, 0x35, 0x0a, 0x06, 0x4f, 0x70, 0x54, 0x79,
-	0x70, 0x65, 0x12, 0x16, 0x0a, 0x12, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x52, 0x65, 0x6c, 0x61,
-	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x68, 0x69, 0x70, 0x10, 0x00, 0x12, 0x13, 0x0a, 0x0f, 0x48, 0x61,
-	0x6e, 0x64, 0x6c, 0x65, 0x43, 0x6f, 0x6e, 0x73, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x10, 0x01, 0x42,
-	0x0d, 0x5a, 0x0b, 0x2e, 0x2f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x70, 0x62, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x8e, 0x01, 0x0a, 0x06, 0x52, 0x42, 0x43,
+	0x4d, 0x73, 0x67, 0x12, 0x24, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x10, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x52, 0x42, 0x43, 0x54,
+	0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e,
+	0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65,
+	0x72, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f,
+	0x73, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f,
+	0x73, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0xc0, 0x01, 0x0a, 0x07, 0x41, 0x56,
+	0x49, 0x44, 0x4d, 0x73, 0x67, 0x12, 0x25, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x11, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x41, 0x56,
+	0x49, 0x44, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65,
+	0x6e, 0x64, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72,
+	0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x70, 0x72,
+	0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x72,
+	0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x66, 0x72,
+	0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x18,
+	0x07, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x05, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x22, 0xba, 0x01, 0x0a,
+	0x06, 0x41, 0x42, 0x41, 0x4d, 0x73, 0x67, 0x12, 0x24, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x41, 0x42, 0x41, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73,
+	0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x69,
+	0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x69,
+	0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x6f, 0x75, 0x6e, 0x64,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x72, 0x6f, 0x75, 0x6e, 0x64, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x22, 0x1b, 0x0a, 0x07, 0x54, 0x78, 0x42,
+	0x61, 0x74, 0x63, 0x68, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0c, 0x52, 0x03, 0x74, 0x78, 0x73, 0x22, 0x6c, 0x0a, 0x0a, 0x48, 0x42, 0x44, 0x65, 0x63, 0x53,
+	0x68, 0x61, 0x72, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05,
+	0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f,
+	0x63, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x12, 0x14,
+	0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x73,
+	0x68, 0x61, 0x72, 0x65, 0x22, 0xc6, 0x01, 0x0a, 0x06, 0x43, 0x42, 0x43, 0x4d, 0x73, 0x67, 0x12,
+	0x24, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x43, 0x42, 0x43, 0x54, 0x79, 0x70, 0x65, 0x52,
+	0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x14, 0x0a,
+	0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70,
+	0x6f, 0x63, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x63,
+	0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x22, 0xb8, 0x01,
+	0x0a, 0x05, 0x50, 0x42, 0x4d, 0x73, 0x67, 0x12, 0x23, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0f, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x50, 0x42, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65,
+	0x6e, 0x64, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x6f,
+	0x75, 0x6e, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x72, 0x6f, 0x75, 0x6e, 0x64,
+	0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x22, 0xd0, 0x01, 0x0a, 0x07, 0x4d, 0x56, 0x42,
+	0x41, 0x4d, 0x73, 0x67, 0x12, 0x25, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x11, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x4d, 0x56, 0x42,
+	0x41, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73,
+	0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x6e,
+	0x64, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65,
+	0x77, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x76, 0x69, 0x65, 0x77, 0x12, 0x1a, 0x0a,
+	0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12,
+	0x14, 0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05,
+	0x73, 0x68, 0x61, 0x72, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x22, 0x6b, 0x0a, 0x09, 0x44,
+	0x75, 0x6d, 0x62, 0x6f, 0x44, 0x6f, 0x6e, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x64,
+	0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73,
+	0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73,
+	0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x22, 0x46, 0x0a, 0x0a, 0x44, 0x75, 0x6d, 0x62,
+	0x6f, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73,
+	0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73,
+	0x65, 0x72, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65,
+	0x22, 0x3a, 0x0a, 0x0b, 0x44, 0x75, 0x6d, 0x62, 0x6f, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x73, 0x12,
+	0x2b, 0x0a, 0x06, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x13, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x44, 0x75, 0x6d, 0x62, 0x6f, 0x50,
+	0x72, 0x6f, 0x6f, 0x66, 0x52, 0x06, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x73, 0x22, 0x4e, 0x0a, 0x04,
+	0x48, 0x53, 0x51, 0x43, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65, 0x77, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x04, 0x76, 0x69, 0x65, 0x77, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x6c, 0x6f, 0x63,
+	0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x1c,
+	0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22, 0x74, 0x0a, 0x07,
+	0x48, 0x53, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65, 0x77, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x76, 0x69, 0x65, 0x77, 0x12, 0x27, 0x0a, 0x07, 0x6a,
+	0x75, 0x73, 0x74, 0x69, 0x66, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x48, 0x53, 0x51, 0x43, 0x52, 0x07, 0x6a, 0x75, 0x73,
+	0x74, 0x69, 0x66, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72,
+	0x12, 0x10, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x03, 0x74,
+	0x78, 0x73, 0x22, 0x90, 0x02, 0x0a, 0x05, 0x48, 0x53, 0x4d, 0x73, 0x67, 0x12, 0x23, 0x0a, 0x04,
+	0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0f, 0x2e, 0x6d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x2e, 0x48, 0x53, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65,
+	0x77, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x76, 0x69, 0x65, 0x77, 0x12, 0x26, 0x0a,
+	0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x48, 0x53, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x05,
+	0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x1d, 0x0a, 0x02, 0x71, 0x63, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0d, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x48, 0x53, 0x51, 0x43,
+	0x52, 0x02, 0x71, 0x63, 0x12, 0x1d, 0x0a, 0x02, 0x74, 0x63, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0d, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x48, 0x53, 0x51, 0x43, 0x52,
+	0x02, 0x74, 0x63, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x73,
+	0x68, 0x61, 0x72, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72,
+	0x65, 0x12, 0x22, 0x0a, 0x04, 0x76, 0x6f, 0x74, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0e, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x48, 0x53, 0x4d, 0x73, 0x67, 0x52,
+	0x04, 0x76, 0x6f, 0x74, 0x65, 0x22, 0x80, 0x01, 0x0a, 0x0c, 0x50, 0x42, 0x46, 0x54, 0x50, 0x72,
+	0x65, 0x70, 0x61, 0x72, 0x65, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65, 0x77, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x76, 0x69, 0x65, 0x77, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65,
+	0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x16, 0x0a, 0x06,
+	0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69,
+	0x67, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28,
+	0x0c, 0x52, 0x03, 0x74, 0x78, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66,
+	0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72,
+	0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x22, 0x5e, 0x0a, 0x12, 0x50, 0x42, 0x46, 0x54,
+	0x43, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x12, 0x10,
+	0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71,
+	0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66,
+	0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72,
+	0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x22, 0xce, 0x01, 0x0a, 0x12, 0x50, 0x42, 0x46,
+	0x54, 0x56, 0x69, 0x65, 0x77, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12,
+	0x12, 0x0a, 0x04, 0x76, 0x69, 0x65, 0x77, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x76,
+	0x69, 0x65, 0x77, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x3b, 0x0a, 0x0a, 0x63,
+	0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1b, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x42, 0x46, 0x54, 0x43, 0x68,
+	0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x52, 0x0a, 0x63, 0x68,
+	0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x31, 0x0a, 0x08, 0x70, 0x72, 0x65, 0x70,
+	0x61, 0x72, 0x65, 0x64, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x42, 0x46, 0x54, 0x50, 0x72, 0x65, 0x70, 0x61, 0x72, 0x65,
+	0x64, 0x52, 0x08, 0x70, 0x72, 0x65, 0x70, 0x61, 0x72, 0x65, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x73,
+	0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09,
+	0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22, 0xe1, 0x02, 0x0a, 0x07, 0x50, 0x42,
+	0x46, 0x54, 0x4d, 0x73, 0x67, 0x12, 0x25, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x11, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x42,
+	0x46, 0x54, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65,
+	0x6e, 0x64, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65, 0x77, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x04, 0x76, 0x69, 0x65, 0x77, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69,
+	0x67, 0x65, 0x73, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65,
+	0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0c, 0x52,
+	0x03, 0x74, 0x78, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x12, 0x3c, 0x0a, 0x0b, 0x76, 0x69,
+	0x65, 0x77, 0x5f, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1b, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x42, 0x46, 0x54, 0x56, 0x69,
+	0x65, 0x77, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0a, 0x76, 0x69,
+	0x65, 0x77, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x3e, 0x0a, 0x0c, 0x76, 0x69, 0x65, 0x77,
+	0x5f, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b,
+	0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x42, 0x46, 0x54, 0x56, 0x69, 0x65,
+	0x77, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0b, 0x76, 0x69, 0x65,
+	0x77, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x33, 0x0a, 0x0c, 0x70, 0x72, 0x65, 0x5f,
+	0x70, 0x72, 0x65, 0x70, 0x61, 0x72, 0x65, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10,
+	0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x50, 0x42, 0x46, 0x54, 0x4d, 0x73, 0x67,
+	0x52, 0x0b, 0x70, 0x72, 0x65, 0x50, 0x72, 0x65, 0x70, 0x61, 0x72, 0x65, 0x73, 0x22, 0xd9, 0x01,
+	0x0a, 0x09, 0x57, 0x41, 0x4c, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x24, 0x0a, 0x04, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x2e, 0x57, 0x41, 0x4c, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65, 0x77, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x04, 0x76, 0x69, 0x65, 0x77, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73,
+	0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12,
+	0x10, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x03, 0x74, 0x78,
+	0x73, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x6f, 0x63, 0x6b, 0x65, 0x64, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x06, 0x6c, 0x6f, 0x63, 0x6b, 0x65, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x70,
+	0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x03, 0x52, 0x09,
+	0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65, 0x72, 0x73, 0x22, 0x59, 0x0a, 0x0b, 0x53, 0x74, 0x6f,
+	0x72, 0x65, 0x64, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67,
+	0x68, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74,
+	0x12, 0x10, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x03, 0x74,
+	0x78, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x22, 0xbe, 0x01, 0x0a, 0x08, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x4d, 0x73,
+	0x67, 0x12, 0x26, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x12, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x54,
+	0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e,
+	0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65,
+	0x72, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67,
+	0x65, 0x73, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73,
+	0x74, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x12, 0x2c, 0x0a, 0x06, 0x62, 0x6c, 0x6f, 0x63, 0x6b,
+	0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x64, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x06, 0x62,
+	0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x22, 0xc3, 0x01, 0x0a, 0x0d, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x70,
+	0x6f, 0x69, 0x6e, 0x74, 0x4d, 0x73, 0x67, 0x12, 0x2b, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x43, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04,
+	0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06,
+	0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x68, 0x65,
+	0x69, 0x67, 0x68, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x68, 0x61,
+	0x73, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x74, 0x61, 0x74, 0x65, 0x48,
+	0x61, 0x73, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x65, 0x72,
+	0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b,
+	0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x22, 0x79, 0x0a, 0x0c, 0x53,
+	0x79, 0x6e, 0x63, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x68,
+	0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x68, 0x65, 0x69,
+	0x67, 0x68, 0x74, 0x12, 0x19, 0x0a, 0x08, 0x61, 0x70, 0x70, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x61, 0x70, 0x70, 0x48, 0x61, 0x73, 0x68, 0x12, 0x14,
+	0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x22, 0xbd, 0x01, 0x0a, 0x07, 0x53, 0x79, 0x6e, 0x63, 0x4d,
+	0x73, 0x67, 0x12, 0x25, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x11, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x53, 0x79, 0x6e, 0x63, 0x54,
+	0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e,
+	0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65,
+	0x72, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x31, 0x0a, 0x08, 0x73, 0x6e, 0x61,
+	0x70, 0x73, 0x68, 0x6f, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x53, 0x79, 0x6e, 0x63, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68,
+	0x6f, 0x74, 0x52, 0x08, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x28, 0x0a, 0x06,
+	0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x54, 0x78, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x06,
+	0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x2a, 0x35, 0x0a, 0x06, 0x4f, 0x70, 0x54, 0x79, 0x70, 0x65,
+	0x12, 0x16, 0x0a, 0x12, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x68, 0x69, 0x70, 0x10, 0x00, 0x12, 0x13, 0x0a, 0x0f, 0x48, 0x61, 0x6e, 0x64,
+	0x6c, 0x65, 0x43, 0x6f, 0x6e, 0x73, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x10, 0x01, 0x2a, 0x31, 0x0a,
+	0x07, 0x52, 0x42, 0x43, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x42, 0x43, 0x53,
+	0x65, 0x6e, 0x64, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x42, 0x43, 0x45, 0x63, 0x68, 0x6f,
+	0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x42, 0x43, 0x52, 0x65, 0x61, 0x64, 0x79, 0x10, 0x02,
+	0x2a, 0x34, 0x0a, 0x08, 0x41, 0x56, 0x49, 0x44, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0b, 0x0a, 0x07,
+	0x41, 0x56, 0x49, 0x44, 0x56, 0x61, 0x6c, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x41, 0x56, 0x49,
+	0x44, 0x45, 0x63, 0x68, 0x6f, 0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09, 0x41, 0x56, 0x49, 0x44, 0x52,
+	0x65, 0x61, 0x64, 0x79, 0x10, 0x02, 0x2a, 0x49, 0x0a, 0x07, 0x41, 0x42, 0x41, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x42, 0x41, 0x42, 0x76, 0x61, 0x6c, 0x10, 0x00, 0x12, 0x0a,
+	0x0a, 0x06, 0x41, 0x42, 0x41, 0x41, 0x75, 0x78, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x42,
+	0x41, 0x43, 0x6f, 0x6e, 0x66, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x42, 0x41, 0x43, 0x6f,
+	0x69, 0x6e, 0x10, 0x03, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x42, 0x41, 0x54, 0x65, 0x72, 0x6d, 0x10,
+	0x04, 0x2a, 0x32, 0x0a, 0x07, 0x43, 0x42, 0x43, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0b, 0x0a, 0x07,
+	0x43, 0x42, 0x43, 0x53, 0x65, 0x6e, 0x64, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x43, 0x42, 0x43,
+	0x53, 0x68, 0x61, 0x72, 0x65, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x43, 0x42, 0x43, 0x46, 0x69,
+	0x6e, 0x61, 0x6c, 0x10, 0x02, 0x2a, 0x21, 0x0a, 0x06, 0x50, 0x42, 0x54, 0x79, 0x70, 0x65, 0x12,
+	0x0a, 0x0a, 0x06, 0x50, 0x42, 0x53, 0x65, 0x6e, 0x64, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x50,
+	0x42, 0x53, 0x68, 0x61, 0x72, 0x65, 0x10, 0x01, 0x2a, 0x45, 0x0a, 0x08, 0x4d, 0x56, 0x42, 0x41,
+	0x54, 0x79, 0x70, 0x65, 0x12, 0x0e, 0x0a, 0x0a, 0x4d, 0x56, 0x42, 0x41, 0x46, 0x69, 0x6e, 0x69,
+	0x73, 0x68, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x4d, 0x56, 0x42, 0x41, 0x43, 0x6f, 0x69, 0x6e,
+	0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x4d, 0x56, 0x42, 0x41, 0x56, 0x6f, 0x74, 0x65, 0x10, 0x02,
+	0x12, 0x0d, 0x0a, 0x09, 0x4d, 0x56, 0x42, 0x41, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x10, 0x03, 0x2a,
+	0x51, 0x0a, 0x06, 0x48, 0x53, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0e, 0x0a, 0x0a, 0x48, 0x53, 0x50,
+	0x72, 0x6f, 0x70, 0x6f, 0x73, 0x61, 0x6c, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x48, 0x53, 0x56,
+	0x6f, 0x74, 0x65, 0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09, 0x48, 0x53, 0x54, 0x69, 0x6d, 0x65, 0x6f,
+	0x75, 0x74, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x48, 0x53, 0x46, 0x65, 0x74, 0x63, 0x68, 0x10,
+	0x03, 0x12, 0x0f, 0x0a, 0x0b, 0x48, 0x53, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x65, 0x73, 0x70,
+	0x10, 0x04, 0x2a, 0x78, 0x0a, 0x08, 0x50, 0x42, 0x46, 0x54, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12,
+	0x0a, 0x0e, 0x50, 0x42, 0x46, 0x54, 0x50, 0x72, 0x65, 0x50, 0x72, 0x65, 0x70, 0x61, 0x72, 0x65,
+	0x10, 0x00, 0x12, 0x0f, 0x0a, 0x0b, 0x50, 0x42, 0x46, 0x54, 0x50, 0x72, 0x65, 0x70, 0x61, 0x72,
+	0x65, 0x10, 0x01, 0x12, 0x0e, 0x0a, 0x0a, 0x50, 0x42, 0x46, 0x54, 0x43, 0x6f, 0x6d, 0x6d, 0x69,
+	0x74, 0x10, 0x02, 0x12, 0x12, 0x0a, 0x0e, 0x50, 0x42, 0x46, 0x54, 0x43, 0x68, 0x65, 0x63, 0x6b,
+	0x70, 0x6f, 0x69, 0x6e, 0x74, 0x10, 0x03, 0x12, 0x12, 0x0a, 0x0e, 0x50, 0x42, 0x46, 0x54, 0x56,
+	0x69, 0x65, 0x77, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x10, 0x04, 0x12, 0x0f, 0x0a, 0x0b, 0x50,
+	0x42, 0x46, 0x54, 0x4e, 0x65, 0x77, 0x56, 0x69, 0x65, 0x77, 0x10, 0x05, 0x2a, 0x8a, 0x01, 0x0a,
+	0x07, 0x57, 0x41, 0x4c, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x57, 0x41, 0x4c, 0x56,
+	0x6f, 0x74, 0x65, 0x10, 0x00, 0x12, 0x11, 0x0a, 0x0d, 0x57, 0x41, 0x4c, 0x56, 0x69, 0x65, 0x77,
+	0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x10, 0x01, 0x12, 0x0e, 0x0a, 0x0a, 0x57, 0x41, 0x4c, 0x4e,
+	0x65, 0x77, 0x56, 0x69, 0x65, 0x77, 0x10, 0x02, 0x12, 0x11, 0x0a, 0x0d, 0x57, 0x41, 0x4c, 0x43,
+	0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x10, 0x03, 0x12, 0x0d, 0x0a, 0x09, 0x57,
+	0x41, 0x4c, 0x44, 0x65, 0x63, 0x69, 0x64, 0x65, 0x10, 0x04, 0x12, 0x0f, 0x0a, 0x0b, 0x57, 0x41,
+	0x4c, 0x50, 0x72, 0x65, 0x70, 0x61, 0x72, 0x65, 0x64, 0x10, 0x05, 0x12, 0x0e, 0x0a, 0x0a, 0x57,
+	0x41, 0x4c, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x10, 0x06, 0x12, 0x0c, 0x0a, 0x08, 0x57,
+	0x41, 0x4c, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x10, 0x07, 0x2a, 0x3d, 0x0a, 0x09, 0x42, 0x6c, 0x6f,
+	0x63, 0x6b, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0e, 0x0a, 0x0a, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x53,
+	0x68, 0x61, 0x72, 0x65, 0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x10, 0x01, 0x12, 0x0e, 0x0a, 0x0a, 0x42, 0x6c, 0x6f, 0x63,
+	0x6b, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x10, 0x02, 0x2a, 0x39, 0x0a, 0x0e, 0x43, 0x68, 0x65, 0x63,
+	0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x68,
+	0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x53, 0x68, 0x61, 0x72, 0x65, 0x10, 0x00, 0x12,
+	0x12, 0x0a, 0x0e, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x43, 0x65, 0x72,
+	0x74, 0x10, 0x01, 0x2a, 0x2d, 0x0a, 0x08, 0x53, 0x79, 0x6e, 0x63, 0x54, 0x79, 0x70, 0x65, 0x12,
+	0x0f, 0x0a, 0x0b, 0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x10, 0x00,
+	0x12, 0x10, 0x0a, 0x0c, 0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x10, 0x01, 0x42, 0x0d, 0x5a, 0x0b, 0x2e, 0x2f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x70,
+	0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -207,22 +3082,84 @@ func file_message_proto_rawDescGZIP() []byte {
 	return file_message_proto_rawDescData
 }
 
-var file_message_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_message_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_message_proto_enumTypes = make([]protoimpl.EnumInfo, 13)
+var file_message_proto_msgTypes = make([]protoimpl.MessageInfo, 26)
 var file_message_proto_goTypes = []interface{}{
-	(OpType)(0),       // 0: message.OpType
-	(*Operation)(nil), // 1: message.Operation
-	(*AnyTest)(nil),   // 2: message.AnyTest
-	(*anypb.Any)(nil), // 3: google.protobuf.Any
+	(OpType)(0),                // 0: message.OpType
+	(RBCType)(0),               // 1: message.RBCType
+	(AVIDType)(0),              // 2: message.AVIDType
+	(ABAType)(0),               // 3: message.ABAType
+	(CBCType)(0),               // 4: message.CBCType
+	(PBType)(0),                // 5: message.PBType
+	(MVBAType)(0),              // 6: message.MVBAType
+	(HSType)(0),                // 7: message.HSType
+	(PBFTType)(0),              // 8: message.PBFTType
+	(WALType)(0),               // 9: message.WALType
+	(BlockType)(0),             // 10: message.BlockType
+	(CheckpointType)(0),        // 11: message.CheckpointType
+	(SyncType)(0),              // 12: message.SyncType
+	(*Operation)(nil),          // 13: message.Operation
+	(*AnyTest)(nil),            // 14: message.AnyTest
+	(*RBCMsg)(nil),             // 15: message.RBCMsg
+	(*AVIDMsg)(nil),            // 16: message.AVIDMsg
+	(*ABAMsg)(nil),             // 17: message.ABAMsg
+	(*TxBatch)(nil),            // 18: message.TxBatch
+	(*HBDecShare)(nil),         // 19: message.HBDecShare
+	(*CBCMsg)(nil),             // 20: message.CBCMsg
+	(*PBMsg)(nil),              // 21: message.PBMsg
+	(*MVBAMsg)(nil),            // 22: message.MVBAMsg
+	(*DumboDone)(nil),          // 23: message.DumboDone
+	(*DumboProof)(nil),         // 24: message.DumboProof
+	(*DumboProofs)(nil),        // 25: message.DumboProofs
+	(*HSQC)(nil),               // 26: message.HSQC
+	(*HSBlock)(nil),            // 27: message.HSBlock
+	(*HSMsg)(nil),              // 28: message.HSMsg
+	(*PBFTPrepared)(nil),       // 29: message.PBFTPrepared
+	(*PBFTCheckpointCert)(nil), // 30: message.PBFTCheckpointCert
+	(*PBFTViewChangeInfo)(nil), // 31: message.PBFTViewChangeInfo
+	(*PBFTMsg)(nil),            // 32: message.PBFTMsg
+	(*WALRecord)(nil),          // 33: message.WALRecord
+	(*StoredBlock)(nil),        // 34: message.StoredBlock
+	(*BlockMsg)(nil),           // 35: message.BlockMsg
+	(*CheckpointMsg)(nil),      // 36: message.CheckpointMsg
+	(*SyncSnapshot)(nil),       // 37: message.SyncSnapshot
+	(*SyncMsg)(nil),            // 38: message.SyncMsg
+	(*anypb.Any)(nil),          // 39: google.protobuf.Any
 }
 var file_message_proto_depIdxs = []int32{
-	0, // 0: message.Operation.op:type_name -> message.OpType
-	3, // 1: message.Operation.payloads:type_name -> google.protobuf.Any
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: message.Operation.op:type_name -> message.OpType
+	39, // 1: message.Operation.payloads:type_name -> google.protobuf.Any
+	1,  // 2: message.RBCMsg.type:type_name -> message.RBCType
+	2,  // 3: message.AVIDMsg.type:type_name -> message.AVIDType
+	3,  // 4: message.ABAMsg.type:type_name -> message.ABAType
+	4,  // 5: message.CBCMsg.type:type_name -> message.CBCType
+	5,  // 6: message.PBMsg.type:type_name -> message.PBType
+	6,  // 7: message.MVBAMsg.type:type_name -> message.MVBAType
+	24, // 8: message.DumboProofs.proofs:type_name -> message.DumboProof
+	26, // 9: message.HSBlock.justify:type_name -> message.HSQC
+	7,  // 10: message.HSMsg.type:type_name -> message.HSType
+	27, // 11: message.HSMsg.block:type_name -> message.HSBlock
+	26, // 12: message.HSMsg.qc:type_name -> message.HSQC
+	26, // 13: message.HSMsg.tc:type_name -> message.HSQC
+	28, // 14: message.HSMsg.vote:type_name -> message.HSMsg
+	30, // 15: message.PBFTViewChangeInfo.checkpoint:type_name -> message.PBFTCheckpointCert
+	29, // 16: message.PBFTViewChangeInfo.prepared:type_name -> message.PBFTPrepared
+	8,  // 17: message.PBFTMsg.type:type_name -> message.PBFTType
+	31, // 18: message.PBFTMsg.view_change:type_name -> message.PBFTViewChangeInfo
+	31, // 19: message.PBFTMsg.view_changes:type_name -> message.PBFTViewChangeInfo
+	32, // 20: message.PBFTMsg.pre_prepares:type_name -> message.PBFTMsg
+	9,  // 21: message.WALRecord.type:type_name -> message.WALType
+	10, // 22: message.BlockMsg.type:type_name -> message.BlockType
+	34, // 23: message.BlockMsg.blocks:type_name -> message.StoredBlock
+	11, // 24: message.CheckpointMsg.type:type_name -> message.CheckpointType
+	12, // 25: message.SyncMsg.type:type_name -> message.SyncType
+	37, // 26: message.SyncMsg.snapshot:type_name -> message.SyncSnapshot
+	18, // 27: message.SyncMsg.blocks:type_name -> message.TxBatch
+	28, // [28:28] is the sub-list for method output_type
+	28, // [28:28] is the sub-list for method input_type
+	28, // [28:28] is the sub-list for extension type_name
+	28, // [28:28] is the sub-list for extension extendee
+	0,  // [0:28] is the sub-list for field type_name
 }
 
 func init() { file_message_proto_init() }
@@ -255,14 +3192,302 @@ func file_message_proto_init() {
 				return nil
 			}
 		}
+		file_message_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RBCMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AVIDMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ABAMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TxBatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HBDecShare); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CBCMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PBMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MVBAMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DumboDone); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DumboProof); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DumboProofs); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HSQC); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HSBlock); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HSMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PBFTPrepared); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PBFTCheckpointCert); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PBFTViewChangeInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PBFTMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WALRecord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StoredBlock); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BlockMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CheckpointMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SyncSnapshot); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_message_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SyncMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_message_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   2,
+			NumEnums:      13,
+			NumMessages:   26,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,225 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stuck/consensus"
+)
+
+// Node of a cluster, paths left empty fall back to the cluster ones
+type ClusterNode struct {
+	ID             int    `json:"id"`
+	Addr           string `json:"addr"`                       // address peers connect to
+	Listen         string `json:"listen,omitempty"`           // address to listen on, default is addr
+	API            string `json:"api,omitempty"`              // client endpoint, empty disables it
+	Cert           string `json:"cert"`                       // pem certificate
+	Key            string `json:"key,omitempty"`              // pem private key, only on the node itself
+	TBLSPrivateKey string `json:"tbls_private_key,omitempty"` // file holding the private share of the node
+	DataDir        string `json:"data_dir,omitempty"`
+}
+
+// Cluster describes every node and the protocol they run, the same file
+// can be handed to every node. Relative paths are relative to the file
+type Cluster struct {
+	Protocol       string        `json:"protocol"`                   // hbbft, pbft or hotstuff
+	N              int           `json:"n"`                          // total nodes
+	F              int           `json:"f,omitempty"`                // faults tolerated, must be (n-1)/3 if set
+	T              int           `json:"t"`                          // threshold of the tbls key set
+	BatchSize      int           `json:"batch_size,omitempty"`       // zero takes the protocol default
+	ViewTimeout    string        `json:"view_timeout,omitempty"`     // pbft and hotstuff, e.g. "2s"
+	TBLSPublicKey  string        `json:"tbls_public_key"`            // public shares of every node
+	TBLSPrivateKey string        `json:"tbls_private_key,omitempty"` // private shares, when nodes share one file
+	Nodes          []ClusterNode `json:"nodes"`
+}
+
+// Every problem of a cluster config, so they can be fixed at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s:\n  %s", ErrConfig, strings.Join(e.Problems, "\n  "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrConfig
+}
+
+// Read a cluster from a json file and validate it
+func LoadCluster(path string) (*Cluster, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cluster{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c.resolve(filepath.Dir(path))
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Make the paths of the cluster relative to dir absolute
+func (c *Cluster) resolve(dir string) {
+	join := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+	join(&c.TBLSPublicKey)
+	join(&c.TBLSPrivateKey)
+	for i := range c.Nodes {
+		join(&c.Nodes[i].Cert)
+		join(&c.Nodes[i].Key)
+		join(&c.Nodes[i].TBLSPrivateKey)
+		join(&c.Nodes[i].DataDir)
+	}
+}
+
+// Check the whole cluster, files included, and report every problem
+func (c *Cluster) Validate() error {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	threshold := Threshold(c.Protocol, c.N)
+	if threshold == 0 {
+		report("unknown protocol %q, want %s, %s or %s", c.Protocol, ProtocolHoneyBadger, ProtocolPBFT, ProtocolHotStuff)
+	}
+	if c.N < 1 {
+		report("n must be positive, got %d", c.N)
+	}
+	// every protocol tolerates the most faults n allows, f only restates it
+	if c.F != 0 && c.F != consensus.MaxFaulty(c.N) {
+		report("n=%d nodes tolerate f=%d faults, got f=%d", c.N, consensus.MaxFaulty(c.N), c.F)
+	}
+	if c.T < 1 || c.T > c.N {
+		report("t=%d out of range [1, %d]", c.T, c.N)
+	} else if threshold != 0 && c.T != threshold {
+		report("%s with n=%d needs t=%d, got %d", c.Protocol, c.N, threshold, c.T)
+	}
+	if c.BatchSize < 0 {
+		report("batch_size must not be negative, got %d", c.BatchSize)
+	}
+	if c.ViewTimeout != "" {
+		if _, err := time.ParseDuration(c.ViewTimeout); err != nil {
+			report("view_timeout: %s", err)
+		}
+	}
+	checkFile := func(field, path string) {
+		if path == "" {
+			report("%s is missing", field)
+			return
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			report("%s: %s", field, err)
+		} else if info.IsDir() {
+			report("%s: %s is a directory", field, path)
+		}
+	}
+	checkFile("tbls_public_key", c.TBLSPublicKey)
+	if c.TBLSPrivateKey != "" {
+		checkFile("tbls_private_key", c.TBLSPrivateKey)
+	}
+
+	if len(c.Nodes) != c.N {
+		report("%d nodes listed for n=%d", len(c.Nodes), c.N)
+	}
+	ids := make(map[int]bool, len(c.Nodes))
+	addrs := make(map[string]int, len(c.Nodes))
+	for i, node := range c.Nodes {
+		name := fmt.Sprintf("nodes[%d]", i)
+		if node.ID < 1 || node.ID > c.N {
+			report("%s: id %d out of range [1, %d]", name, node.ID, c.N)
+		} else if ids[node.ID] {
+			report("%s: id %d is used twice", name, node.ID)
+		}
+		ids[node.ID] = true
+		if _, _, err := net.SplitHostPort(node.Addr); err != nil {
+			report("%s: addr: %s", name, err)
+		} else if other, ok := addrs[node.Addr]; ok {
+			report("%s: addr %s is also used by node %d", name, node.Addr, other)
+		} else {
+			addrs[node.Addr] = node.ID
+		}
+		if node.Listen != "" {
+			if _, _, err := net.SplitHostPort(node.Listen); err != nil {
+				report("%s: listen: %s", name, err)
+			}
+		}
+		if node.API != "" {
+			if _, _, err := net.SplitHostPort(node.API); err != nil {
+				report("%s: api: %s", name, err)
+			}
+		}
+		checkFile(name+".cert", node.Cert)
+		// keys are only on the machine of the node, a file lists them all
+		if node.Key != "" {
+			checkFile(name+".key", node.Key)
+		}
+		if node.TBLSPrivateKey != "" {
+			checkFile(name+".tbls_private_key", node.TBLSPrivateKey)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Config of node id, which needs its key and private share
+func (c *Cluster) Config(id int) (Config, error) {
+	var self *ClusterNode
+	peers := make([]PeerConfig, 0, len(c.Nodes))
+	for i := range c.Nodes {
+		if c.Nodes[i].ID == id {
+			self = &c.Nodes[i]
+		}
+		peers = append(peers, PeerConfig{ID: c.Nodes[i].ID, Addr: c.Nodes[i].Addr, Cert: c.Nodes[i].Cert})
+	}
+	if self == nil {
+		return Config{}, fmt.Errorf("%w: node %d is not in the cluster", ErrConfig, id)
+	}
+	cfg := Config{
+		ID:             id,
+		N:              c.N,
+		T:              c.T,
+		Listen:         self.Listen,
+		API:            self.API,
+		Cert:           self.Cert,
+		Key:            self.Key,
+		TBLSPrivateKey: self.TBLSPrivateKey,
+		TBLSPublicKey:  c.TBLSPublicKey,
+		Peers:          peers,
+		Protocol:       c.Protocol,
+		BatchSize:      c.BatchSize,
+		ViewTimeout:    c.ViewTimeout,
+		DataDir:        self.DataDir,
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = self.Addr
+	}
+	if cfg.TBLSPrivateKey == "" {
+		cfg.TBLSPrivateKey = c.TBLSPrivateKey
+	}
+	if cfg.Key == "" || cfg.TBLSPrivateKey == "" {
+		return cfg, fmt.Errorf("%w: node %d has no key or private share", ErrConfig, id)
+	}
+	return cfg, cfg.Validate()
+}
@@ -0,0 +1,131 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/stuck/consensus"
+)
+
+// Ordering protocols a node can run
+const (
+	ProtocolHoneyBadger = "hbbft"
+	ProtocolPBFT        = "pbft"
+	ProtocolHotStuff    = "hotstuff"
+)
+
+var ErrConfig = errors.New("invalid node config")
+
+// Where a peer listens and the certificate it presents
+type PeerConfig struct {
+	ID   int    `json:"id"`
+	Addr string `json:"addr"`
+	Cert string `json:"cert"` // pem certificate, pinned by the transport
+}
+
+// Config of one node, relative paths are relative to the config file
+type Config struct {
+	ID               int          `json:"id"`                // current node id, 1..N
+	N                int          `json:"n"`                 // total nodes
+	T                int          `json:"t"`                 // threshold of the tbls key set
+	Listen           string       `json:"listen"`            // address peers connect to
+	API              string       `json:"api"`               // client endpoint, empty disables it
+	Cert             string       `json:"cert"`              // pem certificate of this node
+	Key              string       `json:"key"`               // pem private key of the certificate
+	TBLSPrivateKey   string       `json:"tbls_private_key"`  // private shares, the one of this node is used
+	TBLSPublicKey    string       `json:"tbls_public_key"`   // public shares of every node
+	Peers            []PeerConfig `json:"peers"`             // every node, this one included
+	Protocol         string       `json:"protocol"`          // hbbft, pbft or hotstuff
+	BatchSize        int          `json:"batch_size"`        // zero takes the protocol default
+	ViewTimeout      string       `json:"view_timeout"`      // pbft and hotstuff, e.g. "2s", empty takes the default
+	SnapshotInterval uint64       `json:"snapshot_interval"` // blocks between certified snapshots, zero takes the default
+	MempoolSize      int          `json:"mempool_size"`      // hbbft, transactions waiting to be proposed, zero takes the default
+	MempoolBytes     int          `json:"mempool_bytes"`     // hbbft, their total size, zero takes the default
+	DataDir          string       `json:"data_dir"`          // write-ahead log, checkpoints and blocks, empty keeps nothing
+}
+
+// Read config from a json file
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.resolve(filepath.Dir(path))
+	return cfg, nil
+}
+
+// Make the paths of the config relative to dir absolute
+func (cfg *Config) resolve(dir string) {
+	join := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+	join(&cfg.Cert)
+	join(&cfg.Key)
+	join(&cfg.TBLSPrivateKey)
+	join(&cfg.TBLSPublicKey)
+	join(&cfg.DataDir)
+	for i := range cfg.Peers {
+		join(&cfg.Peers[i].Cert)
+	}
+}
+
+// Check the fields a node cannot start without
+func (cfg Config) Validate() error {
+	if cfg.N <= 0 || cfg.ID < 1 || cfg.ID > cfg.N {
+		return fmt.Errorf("%w: id %d out of range [1, %d]", ErrConfig, cfg.ID, cfg.N)
+	}
+	threshold := Threshold(cfg.Protocol, cfg.N)
+	if threshold == 0 {
+		return fmt.Errorf("%w: unknown protocol %q", ErrConfig, cfg.Protocol)
+	}
+	if cfg.T != threshold {
+		return fmt.Errorf("%w: %s needs threshold %d, got %d", ErrConfig, cfg.Protocol, threshold, cfg.T)
+	}
+	if _, err := cfg.viewTimeout(); err != nil {
+		return fmt.Errorf("%w: view timeout: %s", ErrConfig, err)
+	}
+	if cfg.MempoolSize < 0 || cfg.MempoolBytes < 0 {
+		return fmt.Errorf("%w: negative mempool limit", ErrConfig)
+	}
+	if len(cfg.Peers) != cfg.N {
+		return fmt.Errorf("%w: %d peers for %d nodes", ErrConfig, len(cfg.Peers), cfg.N)
+	}
+	seen := make(map[int]bool, len(cfg.Peers))
+	for _, peer := range cfg.Peers {
+		if peer.ID < 1 || peer.ID > cfg.N || seen[peer.ID] {
+			return fmt.Errorf("%w: peer id %d out of range or repeated", ErrConfig, peer.ID)
+		}
+		seen[peer.ID] = true
+	}
+	return nil
+}
+
+// Threshold of the key set a protocol needs with n nodes, 0 if the
+// protocol is unknown. Coins and decryption of HoneyBadgerBFT need f+1
+// shares, certificates of PBFT and HotStuff must be unique per view
+func Threshold(protocol string, n int) int {
+	switch protocol {
+	case ProtocolHoneyBadger:
+		return consensus.MaxFaulty(n) + 1
+	case ProtocolPBFT, ProtocolHotStuff:
+		return n - consensus.MaxFaulty(n)
+	}
+	return 0
+}
+
+func (cfg Config) viewTimeout() (time.Duration, error) {
+	if cfg.ViewTimeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(cfg.ViewTimeout)
+}
@@ -0,0 +1,486 @@
+package node
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/stuck/api"
+	"github.com/stuck/app"
+	"github.com/stuck/app/kvstore"
+	"github.com/stuck/blockstore"
+	"github.com/stuck/checkpoint"
+	"github.com/stuck/consensus"
+	"github.com/stuck/consensus/hbbft"
+	"github.com/stuck/consensus/hotstuff"
+	"github.com/stuck/consensus/pbft"
+	"github.com/stuck/crypto/cache"
+	"github.com/stuck/crypto/executor"
+	"github.com/stuck/crypto/tbls/decode"
+	"github.com/stuck/crypto/tbls/identity"
+	"github.com/stuck/mempool"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/statesync"
+	"github.com/stuck/transport"
+	"github.com/stuck/transport/mux"
+	"github.com/stuck/transport/remote"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrNoShare = errors.New("key file holds no private share of this node")
+	ErrNoStore = errors.New("node keeps no blocks without a data dir")
+)
+
+const (
+	// Verification results kept, certificates are verified again every
+	// time they are forwarded
+	verifyCacheSize = 4096
+	// Blocks committed past the next height kept until the gap is filled
+	maxPending = 1024
+	// Limits of the hbbft mempool, a full one rejects transactions
+	defaultMempoolSize  = 1 << 16
+	defaultMempoolBytes = 64 << 20
+)
+
+// Ordering protocol as the node drives it, commits are read by run
+type protocol interface {
+	Stop()
+}
+
+// Submit of a protocol that buffers every transaction it is handed
+func buffered(submit func(tx []byte)) func(tx []byte) error {
+	return func(tx []byte) error {
+		submit(tx)
+		return nil
+	}
+}
+
+// Node is one replica of a cluster: the remote transport to its peers,
+// the tbls provider of its key share, the configured ordering protocol,
+// the kvstore the committed transactions are applied to and the client
+// endpoint. Next to the protocol the node runs a syncer, which certifies
+// snapshots of the kvstore with a checkpointer and transfers state to a
+// lagging node, and with a data dir a certifier appending the committed
+// blocks to a block store. Blocks the syncer transferred already are
+// skipped when the protocol commits them, blocks past the next height
+// wait for the missing ones while the syncer asks the peers for them
+type Node struct {
+	cfg       Config
+	transport *remote.RemoteTransport
+	mux       *mux.Mux
+	nodeT     transport.Transport // sync, checkpoint and block messages
+	net       *consensus.TransportNetwork
+	protocol  protocol
+	submit    func(tx []byte) error // hands a transaction to the protocol
+	kv        *kvstore.KVStore
+	sm        *app.StateMachine
+	cps       *checkpoint.Checkpointer
+	syncer    *statesync.Syncer
+	certifier *blockstore.Certifier // nil without a data dir
+	store     *blockstore.Store
+	api       *api.Server
+	wal       *wal.WAL
+	cpWAL     *wal.WAL
+	verifier  *executor.Executor
+	stable    uint64              // height of the snapshot the protocol was told about
+	pending   map[uint64][][]byte // committed blocks past the next height
+	mu        sync.RWMutex
+	height    uint64
+	appHash   []byte
+	latest    *checkpoint.Checkpoint
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+
+	// only the channel of the configured protocol is set
+	hb         *hbbft.HoneyBadger
+	hbCommit   <-chan hbbft.Block
+	pbftCommit <-chan pbft.Block
+	hsCommit   <-chan hotstuff.Block
+}
+
+// Build the node from cfg and start it
+func New(cfg Config) (*Node, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	timeout, _ := cfg.viewTimeout()
+	cp, err := loadProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	t, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Connect(); err != nil {
+		t.Stop()
+		return nil, err
+	}
+
+	n := &Node{cfg: cfg, transport: t, kv: kvstore.NewKVStore(), pending: make(map[uint64][][]byte)}
+	if err := n.open(cp); err != nil {
+		n.close()
+		t.Stop()
+		return nil, err
+	}
+	n.mux = mux.NewMux(t)
+	n.nodeT = n.mux.Route(func(msg proto.Message) bool {
+		switch msg.(type) {
+		case *pb.SyncMsg, *pb.CheckpointMsg, *pb.BlockMsg:
+			return true
+		}
+		return false
+	})
+	protocolT := n.mux.Route(func(proto.Message) bool { return true })
+	n.net = consensus.NewTransportNetwork(cfg.ID, n.nodeT)
+	n.sm = app.NewStateMachine(n.kv)
+	n.cps = checkpoint.NewCheckpointer(cfg.N, cfg.ID, cfg.SnapshotInterval, n.net, cp, n.cpWAL)
+	n.latest = n.cps.Latest()
+	n.syncer = statesync.NewSyncer(cfg.N, cfg.ID, n.net, cp, n.cps, n.sm, n.kv)
+	if n.store != nil {
+		n.certifier = blockstore.NewCertifier(cfg.N, cfg.ID, n.net, cp, n.store)
+	}
+	n.stopCh = make(chan struct{})
+	n.doneCh = make(chan struct{})
+	switch cfg.Protocol {
+	case ProtocolHoneyBadger:
+		pool := mempool.Config{MaxTxs: cfg.MempoolSize, MaxBytes: cfg.MempoolBytes}
+		if pool.MaxTxs == 0 {
+			pool.MaxTxs = defaultMempoolSize
+		}
+		if pool.MaxBytes == 0 {
+			pool.MaxBytes = defaultMempoolBytes
+		}
+		n.hb = hbbft.NewHoneyBadger(hbbft.Config{N: cfg.N, ID: cfg.ID, BatchSize: cfg.BatchSize, Mempool: pool, WAL: n.wal}, protocolT, cp)
+		n.protocol, n.submit, n.hbCommit = n.hb, n.hb.Submit, n.hb.Commit()
+	case ProtocolPBFT:
+		n.verifier = executor.NewExecutor(cache.NewCachedCryptoProvider(cp, verifyCacheSize), runtime.NumCPU(), 0)
+		p := pbft.NewPBFT(pbft.Config{N: cfg.N, ID: cfg.ID, BatchSize: cfg.BatchSize, Timeout: timeout, WAL: n.wal, Executor: n.verifier}, protocolT, cp)
+		n.protocol, n.submit, n.pbftCommit = p, buffered(p.Submit), p.Commit()
+	case ProtocolHotStuff:
+		cached := cache.NewCachedCryptoProvider(cp, verifyCacheSize)
+		hs := hotstuff.NewHotStuff(hotstuff.Config{N: cfg.N, ID: cfg.ID, BatchSize: cfg.BatchSize, Timeout: timeout, WAL: n.wal}, protocolT, cached)
+		n.protocol, n.submit, n.hsCommit = hs, buffered(hs.Submit), hs.Commit()
+	}
+	n.mux.Start()
+	if cfg.API != "" {
+		n.api = api.NewServer(n.Submit)
+		if err := n.api.Start(cfg.API); err != nil {
+			n.api = nil
+			n.shutdown()
+			return nil, err
+		}
+	}
+	go n.run()
+	return n, nil
+}
+
+// Check a transaction and hand it to the protocol, mempool.ErrFull once
+// the hbbft mempool is full
+func (n *Node) Submit(tx []byte) error {
+	if err := n.kv.CheckTx(tx); err != nil {
+		return err
+	}
+	return n.submit(tx)
+}
+
+// Number of batches applied
+func (n *Node) Height() uint64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.height
+}
+
+// App hash after the last batch
+func (n *Node) AppHash() []byte {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.appHash
+}
+
+// Latest certified checkpoint, nil if there is none yet
+func (n *Node) Checkpoint() *checkpoint.Checkpoint {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.latest
+}
+
+// Certified block at height, blocks are stored once f+1 nodes signed them
+func (n *Node) Block(height uint64) (blockstore.Block, error) {
+	if n.store == nil {
+		return blockstore.Block{}, ErrNoStore
+	}
+	return n.store.Block(height)
+}
+
+// Read committed state of the kvstore
+func (n *Node) Query(path string, data []byte) ([]byte, error) {
+	return n.kv.Query(path, data)
+}
+
+// Address peers connect to
+func (n *Node) Addr() string {
+	return n.transport.Addr()
+}
+
+// Address of the client endpoint, empty if it is disabled
+func (n *Node) APIAddr() string {
+	if n.api == nil {
+		return ""
+	}
+	return n.api.Addr()
+}
+
+// Stop taking transactions, then stop the protocol and the transport
+func (n *Node) Stop() {
+	if n.api != nil {
+		n.api.Stop()
+	}
+	close(n.stopCh)
+	<-n.doneCh
+	n.shutdown()
+}
+
+func (n *Node) shutdown() {
+	n.protocol.Stop()
+	n.net.Stop()
+	n.mux.Stop()
+	if n.verifier != nil {
+		n.verifier.Stop()
+	}
+	n.transport.Stop()
+	n.close()
+}
+
+// Open the logs and the block store of the data dir, each in its own
+// directory. Without a data dir nothing is opened
+func (n *Node) open(cp *identity.TBLSCryproProvider) error {
+	dir := n.cfg.DataDir
+	if dir == "" {
+		return nil
+	}
+	var err error
+	if n.wal, err = wal.Open(filepath.Join(dir, "consensus"), wal.Options{}); err != nil {
+		return err
+	}
+	if n.cpWAL, err = wal.Open(filepath.Join(dir, "checkpoints"), wal.Options{}); err != nil {
+		return err
+	}
+	n.store, err = blockstore.Open(filepath.Join(dir, "blocks"), cp, wal.Options{})
+	return err
+}
+
+// Close what open opened
+func (n *Node) close() {
+	for _, w := range []*wal.WAL{n.wal, n.cpWAL} {
+		if w == nil {
+			continue
+		}
+		if err := w.Close(); err != nil {
+			fmt.Printf("[Node:%d] close wal error due to : %s.\n", n.cfg.ID, err)
+		}
+	}
+	if n.store != nil {
+		if err := n.store.Close(); err != nil {
+			fmt.Printf("[Node:%d] close block store error due to : %s.\n", n.cfg.ID, err)
+		}
+	}
+}
+
+func (n *Node) run() {
+	defer close(n.doneCh)
+	for {
+		select {
+		case <-n.stopCh:
+			return
+		// epoch e of HoneyBadgerBFT is block e+1
+		case b := <-n.hbCommit:
+			n.apply(b.Epoch+1, b.Txs)
+		case b := <-n.pbftCommit:
+			n.apply(b.Seq, b.Txs)
+		case b := <-n.hsCommit:
+			n.apply(b.Height, b.Txs)
+		case v := <-n.nodeT.Consume():
+			msg, err := message.Decode(v)
+			if err != nil {
+				continue
+			}
+			n.handleMessage(msg)
+		}
+
+		if latest := n.syncer.Latest(); latest != nil && latest.Height > n.stable {
+			n.stable = latest.Height
+			if n.hb != nil {
+				n.hb.Stable(latest.Height)
+			}
+		}
+		n.mu.Lock()
+		n.height = n.sm.Height()
+		n.appHash = n.sm.AppHash()
+		n.latest = n.cps.Latest()
+		n.mu.Unlock()
+	}
+}
+
+// Apply a committed block, have it certified and acknowledge its
+// transactions to clients. Blocks the syncer transferred already are
+// skipped, a block past the next height waits for the missing ones and
+// the syncer asks the peers for them
+func (n *Node) apply(height uint64, txs [][]byte) {
+	next := n.sm.Height() + 1
+	if height < next {
+		return
+	}
+	if height > next {
+		if _, ok := n.pending[height]; !ok && len(n.pending) < maxPending {
+			fmt.Printf("[Node:%d] block %d committed before block %d, kept until the gap is filled.\n", n.cfg.ID, height, next)
+			n.pending[height] = txs
+		}
+		n.syncer.Catchup()
+		return
+	}
+	n.commit(height, txs)
+	n.release()
+}
+
+// Apply the kept blocks that follow our height, drop those we passed
+func (n *Node) release() {
+	for height := range n.pending {
+		if height <= n.sm.Height() {
+			delete(n.pending, height)
+		}
+	}
+	for {
+		height := n.sm.Height() + 1
+		txs, ok := n.pending[height]
+		if !ok {
+			return
+		}
+		delete(n.pending, height)
+		n.commit(height, txs)
+	}
+}
+
+// Hand the next block to the syncer, the certifier and the clients
+func (n *Node) commit(height uint64, txs [][]byte) {
+	n.syncer.Apply(txs)
+	if n.certifier != nil {
+		if err := n.certifier.Commit(height, txs); err != nil {
+			fmt.Printf("[Node:%d] certify block error due to : %s.\n", n.cfg.ID, err)
+		}
+	}
+	if n.api != nil {
+		n.api.Committed(height, txs)
+	}
+}
+
+// Handle a sync, checkpoint or block message. After a state transfer
+// HoneyBadgerBFT continues from the next epoch it misses, PBFT and
+// HotStuff commit the transferred blocks again and they are skipped. The
+// kept blocks that follow are applied, the certifier fetches the blocks
+// we skipped on the next commit. Clients are told about each transferred
+// block at its own height, the transactions a restored snapshot covers
+// are never acknowledged by this node, the client asks another replica
+func (n *Node) handleMessage(msg proto.Message) {
+	if blockMsg, ok := msg.(*pb.BlockMsg); ok {
+		if n.certifier == nil {
+			return
+		}
+		if err := n.certifier.HandleMessage(blockMsg); err != nil {
+			fmt.Printf("[Node:%d] block handle msg error due to : %s.\n", n.cfg.ID, err)
+		}
+		return
+	}
+	height := n.sm.Height()
+	if err := n.syncer.HandleMessage(msg); err != nil {
+		fmt.Printf("[Node:%d] sync handle msg error due to : %s.\n", n.cfg.ID, err)
+	}
+	if n.sm.Height() <= height {
+		return
+	}
+	var txs [][]byte
+	for _, b := range n.syncer.Transferred() {
+		txs = append(txs, b.Txs...)
+		if n.api != nil {
+			n.api.Committed(b.Height, b.Txs)
+		}
+	}
+	if n.hb != nil {
+		n.hb.Jump(n.sm.Height(), txs)
+	}
+	n.release()
+}
+
+// Tbls provider of the share of this node
+func loadProvider(cfg Config) (*identity.TBLSCryproProvider, error) {
+	priShares, err := decode.ReadPriShares(cfg.TBLSPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	pubShares, err := decode.ReadPubShares(cfg.TBLSPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	pubPoly, err := decode.ToPubPoly(pubShares, cfg.T, cfg.N)
+	if err != nil {
+		return nil, err
+	}
+	// providers are indexed from 0, nodes from 1
+	for _, ps := range priShares {
+		if ps.Index != cfg.ID-1 {
+			continue
+		}
+		priShare, err := decode.ToPriShare(ps)
+		if err != nil {
+			return nil, err
+		}
+		return identity.NewTBLSCryproProviderFromShares(cfg.N, cfg.T, priShare, pubPoly), nil
+	}
+	return nil, fmt.Errorf("%w, %s", ErrNoShare, cfg.TBLSPrivateKey)
+}
+
+// Transport with the certificate of this node pinning the ones of the peers
+func newTransport(cfg Config) (*remote.RemoteTransport, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	peers := make(map[int]remote.Peer, len(cfg.Peers))
+	for _, peer := range cfg.Peers {
+		peerCert, err := LoadCertificate(peer.Cert)
+		if err != nil {
+			return nil, fmt.Errorf("peer %d: %w", peer.ID, err)
+		}
+		peers[peer.ID] = remote.Peer{Addr: peer.Addr, Cert: peerCert}
+	}
+	t := remote.NewRemoteTransport(cfg.N, cfg.ID, cfg.Listen, cert)
+	t.AssignPeers(peers)
+	return t, nil
+}
+
+// Read the first certificate of a pem file
+func LoadCertificate(path string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s holds no certificate", path)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/stuck/crypto/tbls/decode"
+	"github.com/stuck/crypto/tbls/key"
+	"github.com/stuck/crypto/tlscert"
+)
+
+// Files of a node directory written by GenerateTestnet
+const (
+	TestnetConfigFile  = "node.json"
+	TestnetClusterFile = "cluster.json"
+	testnetCertFile    = "cert.pem"
+	testnetKeyFile     = "key.pem"
+	testnetPeerDir     = "peers"
+	testnetDataDir     = "data"
+)
+
+// Parameters of a generated testnet, zero fields take defaults
+type TestnetOptions struct {
+	N           int    // total nodes
+	T           int    // threshold, default is the one the protocol needs
+	Protocol    string // default is hbbft
+	Host        string // host of every node, default is 127.0.0.1
+	BasePort    int    // node i listens on BasePort+i-1, default is 7000
+	APIBasePort int    // client endpoint of node i on APIBasePort+i-1, 0 disables it
+	BatchSize   int
+	ViewTimeout string
+	ValidFor    time.Duration // lifetime of the certificates, default is a year
+}
+
+// Write a directory per node into dir holding its tbls share and the
+// public shares, its certificate and key, the certificates of every peer
+// and a node config ready to run, keeping its logs and blocks in a data
+// dir beside them. A cluster file at the root describes the whole
+// testnet. An existing testnet is only replaced with force
+func GenerateTestnet(dir string, opts TestnetOptions, force bool) error {
+	if opts.Protocol == "" {
+		opts.Protocol = ProtocolHoneyBadger
+	}
+	if opts.T == 0 {
+		opts.T = Threshold(opts.Protocol, opts.N)
+	}
+	if opts.Host == "" {
+		opts.Host = "127.0.0.1"
+	}
+	if opts.BasePort == 0 {
+		opts.BasePort = 7000
+	}
+	if opts.ValidFor == 0 {
+		opts.ValidFor = 24 * 30 * 12 * time.Hour
+	}
+	if opts.N < 1 {
+		return fmt.Errorf("%w: n must be positive, got %d", ErrConfig, opts.N)
+	}
+	if _, err := os.Stat(filepath.Join(dir, TestnetClusterFile)); err == nil && !force {
+		return fmt.Errorf("%s already holds a testnet, use force to overwrite", dir)
+	}
+
+	cluster := Cluster{
+		Protocol:      opts.Protocol,
+		N:             opts.N,
+		T:             opts.T,
+		BatchSize:     opts.BatchSize,
+		ViewTimeout:   opts.ViewTimeout,
+		TBLSPublicKey: filepath.Join(nodeDirName(1), decode.PubKeyFile),
+	}
+	certs := make([][]byte, opts.N)
+	keys := make([][]byte, opts.N)
+	for id := 1; id <= opts.N; id++ {
+		var err error
+		certs[id-1], keys[id-1], err = tlscert.Generate("order"+strconv.Itoa(id)+".com", opts.ValidFor)
+		if err != nil {
+			return err
+		}
+		node := ClusterNode{
+			ID:             id,
+			Addr:           fmt.Sprintf("%s:%d", opts.Host, opts.BasePort+id-1),
+			Cert:           filepath.Join(nodeDirName(id), testnetCertFile),
+			Key:            filepath.Join(nodeDirName(id), testnetKeyFile),
+			TBLSPrivateKey: filepath.Join(nodeDirName(id), decode.PriKeyFile),
+		}
+		if opts.APIBasePort != 0 {
+			node.API = fmt.Sprintf("%s:%d", opts.Host, opts.APIBasePort+id-1)
+		}
+		node.DataDir = filepath.Join(nodeDirName(id), testnetDataDir)
+		cluster.Nodes = append(cluster.Nodes, node)
+	}
+	// only a consistent testnet is written
+	for id := 1; id <= opts.N; id++ {
+		if err := testnetConfig(cluster, id).Validate(); err != nil {
+			return err
+		}
+	}
+
+	priShares, pubShares, err := key.Generate(opts.N, opts.T)
+	if err != nil {
+		return err
+	}
+	for id := 1; id <= opts.N; id++ {
+		nodeDir := filepath.Join(dir, nodeDirName(id))
+		if err := os.MkdirAll(filepath.Join(nodeDir, testnetPeerDir), 0755); err != nil {
+			return err
+		}
+		// the private key file of a node holds its share only
+		if err := writeJSON(filepath.Join(nodeDir, decode.PriKeyFile), []key.PriShare{priShares[id-1]}, 0600); err != nil {
+			return err
+		}
+		if err := writeJSON(filepath.Join(nodeDir, decode.PubKeyFile), pubShares, 0644); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(nodeDir, testnetCertFile), certs[id-1], 0644); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(nodeDir, testnetKeyFile), keys[id-1], 0600); err != nil {
+			return err
+		}
+		for peer := 1; peer <= opts.N; peer++ {
+			if err := ioutil.WriteFile(filepath.Join(nodeDir, testnetPeerDir, peerCertName(peer)), certs[peer-1], 0644); err != nil {
+				return err
+			}
+		}
+		if err := writeJSON(filepath.Join(nodeDir, TestnetConfigFile), testnetConfig(cluster, id), 0644); err != nil {
+			return err
+		}
+	}
+	return writeJSON(filepath.Join(dir, TestnetClusterFile), cluster, 0644)
+}
+
+// Config of node id with paths relative to its own directory
+func testnetConfig(cluster Cluster, id int) Config {
+	self := cluster.Nodes[id-1]
+	cfg := Config{
+		ID:             id,
+		N:              cluster.N,
+		T:              cluster.T,
+		Listen:         self.Addr,
+		API:            self.API,
+		Cert:           testnetCertFile,
+		Key:            testnetKeyFile,
+		TBLSPrivateKey: decode.PriKeyFile,
+		TBLSPublicKey:  decode.PubKeyFile,
+		Protocol:       cluster.Protocol,
+		BatchSize:      cluster.BatchSize,
+		ViewTimeout:    cluster.ViewTimeout,
+	}
+	if self.DataDir != "" {
+		cfg.DataDir = testnetDataDir
+	}
+	for _, peer := range cluster.Nodes {
+		cfg.Peers = append(cfg.Peers, PeerConfig{
+			ID:   peer.ID,
+			Addr: peer.Addr,
+			Cert: filepath.Join(testnetPeerDir, peerCertName(peer.ID)),
+		})
+	}
+	return cfg
+}
+
+func nodeDirName(id int) string {
+	return "node" + strconv.Itoa(id)
+}
+
+func peerCertName(id int) string {
+	return "cert" + strconv.Itoa(id) + ".pem"
+}
+
+func writeJSON(path string, v interface{}, perm os.FileMode) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, perm)
+}
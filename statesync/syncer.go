@@ -0,0 +1,298 @@
+package statesync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/stuck/app"
+	"github.com/stuck/checkpoint"
+	"github.com/stuck/consensus"
+	"github.com/stuck/crypto"
+	pb "github.com/stuck/message/messagepb"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrInvalidSender   = errors.New("invalid sender")
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+const maxBlocks = 256 // blocks in one response
+
+// Block the syncer applied for its peers
+type Block struct {
+	Height uint64
+	Txs    [][]byte
+}
+
+// Block some peers sent for a height
+type transfer struct {
+	txs     [][]byte
+	senders map[int]bool
+}
+
+// Syncer keeps certified snapshots of the application and brings a
+// lagging replica up to date. It applies every committed block to the
+// state machine and has the checkpointer certify the app hash every
+// interval blocks, a snapshot is served once the checkpoint of its
+// height certifies the app hash. Blocks after the latest certified
+// snapshot are kept to be served.
+//
+// A replica that learns a checkpoint at least one interval above its
+// height asks its peers for their state. It restores a snapshot whose
+// certificate verifies and whose state hashes to the certified app hash,
+// then applies the following blocks once f+1 peers sent the same block
+// for a height. A request stays open until every peer answered, or the
+// correct ones did and no block they sent still waits for f+1 of them,
+// a newer checkpoint asks again if it is still open. The owner asks the
+// same way with Catchup when it commits a block past the next height.
+// Like the protocol instances it runs on the goroutine of its owner
+type Syncer struct {
+	n         int
+	f         int
+	id        int
+	interval  uint64
+	net       consensus.Network
+	cp        crypto.CryptoProvider
+	cps       *checkpoint.Checkpointer
+	sm        *app.StateMachine
+	snap      app.Snapshotter
+	latest    *pb.SyncSnapshot            // latest certified snapshot, nil before the first
+	own       map[uint64]*pb.SyncSnapshot // our snapshots waiting for a certificate
+	blocks    map[uint64][][]byte         // applied blocks after the latest snapshot
+	requested bool
+	askedFor  uint64 // checkpoint height the open request was sent for
+	responded map[int]bool
+	transfers map[uint64]map[string]*transfer // height -> digest -> block
+	moved     []Block                         // transferred blocks, in height order
+}
+
+// Create syncer applying blocks to sm, snap takes the snapshots of the
+// same application and cps certifies them
+func NewSyncer(n, id int, net consensus.Network, cp crypto.CryptoProvider, cps *checkpoint.Checkpointer, sm *app.StateMachine, snap app.Snapshotter) *Syncer {
+	return &Syncer{
+		n:         n,
+		f:         consensus.MaxFaulty(n),
+		id:        id,
+		interval:  cps.Interval(),
+		net:       net,
+		cp:        cp,
+		cps:       cps,
+		sm:        sm,
+		snap:      snap,
+		own:       make(map[uint64]*pb.SyncSnapshot),
+		blocks:    make(map[uint64][][]byte),
+		transfers: make(map[uint64]map[string]*transfer),
+	}
+}
+
+// Apply the next committed block, sign the snapshot at interval heights
+func (s *Syncer) Apply(txs [][]byte) {
+	_, appHash := s.sm.Apply(txs)
+	height := s.sm.Height()
+	s.blocks[height] = txs
+	if height%s.interval != 0 {
+		return
+	}
+	s.own[height] = &pb.SyncSnapshot{Height: height, AppHash: appHash, State: s.snap.Snapshot()}
+	s.cps.Record(height, appHash)
+	s.certify()
+}
+
+// Latest certified snapshot, nil if there is none yet
+func (s *Syncer) Latest() *pb.SyncSnapshot {
+	return s.latest
+}
+
+// Blocks transferred since the last call, the transactions of blocks a
+// restored snapshot covers are not included, there is no block for them
+func (s *Syncer) Transferred() []Block {
+	moved := s.moved
+	s.moved = nil
+	return moved
+}
+
+// Handle a sync or checkpoint message
+func (s *Syncer) HandleMessage(msg proto.Message) error {
+	if cpMsg, ok := msg.(*pb.CheckpointMsg); ok {
+		err := s.cps.HandleMessage(cpMsg)
+		s.certify()
+		return err
+	}
+	syncMsg, ok := msg.(*pb.SyncMsg)
+	if !ok {
+		return fmt.Errorf("unknown sync message %T", msg)
+	}
+	sender := int(syncMsg.Sender)
+	if sender < 1 || sender > s.n {
+		return fmt.Errorf("%w %d", ErrInvalidSender, sender)
+	}
+	switch syncMsg.Type {
+	case pb.SyncType_SyncRequest:
+		s.handleRequest(sender, syncMsg)
+		return nil
+	case pb.SyncType_SyncResponse:
+		return s.handleResponse(sender, syncMsg)
+	}
+	return fmt.Errorf("unknown sync message type %d", syncMsg.Type)
+}
+
+// Serve our snapshot once the latest checkpoint certifies it, ask for
+// the state if the checkpoint is an interval or more ahead of us
+func (s *Syncer) certify() {
+	latest := s.cps.Latest()
+	if latest == nil || (s.latest != nil && latest.Height <= s.latest.Height) {
+		return
+	}
+	if own, ok := s.own[latest.Height]; ok && bytes.Equal(own.AppHash, latest.StateHash) {
+		own.Certificate = latest.Certificate
+		s.adopt(own)
+	}
+	if latest.Height >= s.sm.Height()+s.interval {
+		s.request()
+	}
+}
+
+// Serve a snapshot from now on and forget what comes before it
+func (s *Syncer) adopt(snapshot *pb.SyncSnapshot) {
+	s.latest = snapshot
+	for height := range s.own {
+		if height <= snapshot.Height {
+			delete(s.own, height)
+		}
+	}
+	for height := range s.blocks {
+		if height <= snapshot.Height {
+			delete(s.blocks, height)
+		}
+	}
+}
+
+// Ask every peer for the blocks after our height unless a request is
+// open, the owner missed some
+func (s *Syncer) Catchup() {
+	if !s.requested {
+		s.ask()
+	}
+}
+
+// Ask every peer for what comes after our height, one request at a time
+// unless a newer checkpoint is certified meanwhile
+func (s *Syncer) request() {
+	latest := s.cps.Latest()
+	if s.requested && s.askedFor >= latest.Height {
+		return
+	}
+	s.askedFor = latest.Height
+	s.ask()
+}
+
+func (s *Syncer) ask() {
+	s.requested = true
+	s.responded = make(map[int]bool)
+	s.transfers = make(map[uint64]map[string]*transfer)
+	s.net.Broadcast(&pb.SyncMsg{Type: pb.SyncType_SyncRequest, Sender: int64(s.id), Height: s.sm.Height()})
+}
+
+// Send our latest snapshot if the requester is behind it, and the blocks
+// we have after what the requester or the snapshot covers
+func (s *Syncer) handleRequest(sender int, msg *pb.SyncMsg) {
+	if sender == s.id {
+		return
+	}
+	resp := &pb.SyncMsg{Type: pb.SyncType_SyncResponse, Sender: int64(s.id), Height: msg.Height}
+	if s.latest != nil && s.latest.Height > msg.Height {
+		resp.Snapshot = s.latest
+		resp.Height = s.latest.Height
+	}
+	for height := resp.Height + 1; height <= s.sm.Height() && len(resp.Blocks) < maxBlocks; height++ {
+		txs, ok := s.blocks[height]
+		if !ok {
+			break
+		}
+		resp.Blocks = append(resp.Blocks, &pb.TxBatch{Txs: txs})
+	}
+	s.net.SendToPeer(sender, resp)
+}
+
+// Restore a certified snapshot above our height, then apply the blocks
+// f+1 peers agree on
+func (s *Syncer) handleResponse(sender int, msg *pb.SyncMsg) error {
+	if sender == s.id || !s.requested || s.responded[sender] {
+		return nil
+	}
+	s.responded[sender] = true
+	defer s.settle()
+
+	if snapshot := msg.Snapshot; snapshot != nil && snapshot.Height > s.sm.Height() {
+		certified := checkpoint.Checkpoint{Height: snapshot.Height, StateHash: snapshot.AppHash, Certificate: snapshot.Certificate}
+		if snapshot.Height%s.interval != 0 || !checkpoint.Verify(s.cp, certified) {
+			return fmt.Errorf("%w from %d", ErrInvalidSnapshot, sender)
+		}
+		if err := s.sm.Restore(snapshot.Height, snapshot.State, snapshot.AppHash); err != nil {
+			return fmt.Errorf("%w from %d, %s", ErrInvalidSnapshot, sender, err)
+		}
+		if err := s.cps.Adopt(certified); err != nil {
+			return err
+		}
+		s.adopt(snapshot)
+	}
+
+	for i, b := range msg.Blocks {
+		height := msg.Height + 1 + uint64(i)
+		if height <= s.sm.Height() {
+			continue
+		}
+		if s.transfers[height] == nil {
+			s.transfers[height] = make(map[string]*transfer)
+		}
+		d := string(blockDigest(b.Txs))
+		if s.transfers[height][d] == nil {
+			s.transfers[height][d] = &transfer{txs: b.Txs, senders: make(map[int]bool)}
+		}
+		s.transfers[height][d].senders[sender] = true
+	}
+	for s.applyTransfer() {
+	}
+	for height := range s.transfers {
+		if height <= s.sm.Height() {
+			delete(s.transfers, height)
+		}
+	}
+	return nil
+}
+
+// Close the request once every peer answered, or the correct ones did and
+// no block is short of f+1 senders, the next checkpoint may ask again
+func (s *Syncer) settle() {
+	if len(s.responded) >= s.n-1 || (len(s.responded) >= s.n-s.f-1 && len(s.transfers) == 0) {
+		s.requested = false
+	}
+}
+
+// Apply the next block if f+1 peers sent it, one of them is correct
+func (s *Syncer) applyTransfer() bool {
+	for _, t := range s.transfers[s.sm.Height()+1] {
+		if len(t.senders) >= s.f+1 {
+			s.Apply(t.txs)
+			s.moved = append(s.moved, Block{Height: s.sm.Height(), Txs: t.txs})
+			return true
+		}
+	}
+	return false
+}
+
+// Digest of a block, transactions are length prefixed
+func blockDigest(txs [][]byte) []byte {
+	h := sha256.New()
+	var size [8]byte
+	for _, tx := range txs {
+		binary.BigEndian.PutUint64(size[:], uint64(len(tx)))
+		h.Write(size[:])
+		h.Write(tx)
+	}
+	return h.Sum(nil)
+}
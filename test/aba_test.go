@@ -0,0 +1,423 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus/aba"
+	"github.com/stuck/crypto"
+	"github.com/stuck/crypto/tbls/identity"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+)
+
+func startAgreements(n int, transports map[int]transport.Transport, byzantine map[int]bool) map[int]*aba.Agreement {
+	agreements := make(map[int]*aba.Agreement, n)
+	for id, tp := range transports {
+		if byzantine[id] {
+			continue
+		}
+		agreements[id] = aba.NewAgreement(n, id, tp, identity.NewTBLSCryproProvider(n, 2, id-1))
+	}
+	return agreements
+}
+
+func stopAgreements(agreements map[int]*aba.Agreement, transports map[int]transport.Transport) {
+	for _, ag := range agreements {
+		ag.Stop()
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Wait for the decisions of count instances on every node
+func waitDecisions(t *testing.T, agreements map[int]*aba.Agreement, count int) map[int]map[int]bool {
+	decisions := make(map[int]map[int]bool, count)
+	for id, ag := range agreements {
+		for i := 0; i < count; i++ {
+			select {
+			case d := <-ag.Decide():
+				if decisions[d.Instance] == nil {
+					decisions[d.Instance] = make(map[int]bool)
+				}
+				decisions[d.Instance][id] = d.Value
+			case <-time.After(10 * time.Second):
+				t.Fatalf("node %d does not decide", id)
+			}
+		}
+	}
+	return decisions
+}
+
+// Do nodes decide the bit that every honest node proposed?
+func TestABAValidity(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	agreements := startAgreements(n, transports, nil)
+
+	// instance 1 proposes true everywhere, instance 2 false everywhere
+	for _, ag := range agreements {
+		ag.Propose(0, 1, true)
+		ag.Propose(0, 2, false)
+	}
+	decisions := waitDecisions(t, agreements, 2)
+	for id := 1; id <= n; id++ {
+		assert.Equal(t, true, decisions[1][id])
+		assert.Equal(t, false, decisions[2][id])
+	}
+
+	stopAgreements(agreements, transports)
+}
+
+// Do nodes agree on split proposals?
+func TestABAAgreement(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	agreements := startAgreements(n, transports, nil)
+
+	// instance i: node j proposes (i+j) % 2 == 0
+	for id, ag := range agreements {
+		for instance := 1; instance <= n; instance++ {
+			ag.Propose(0, instance, (instance+id)%2 == 0)
+		}
+	}
+	decisions := waitDecisions(t, agreements, n)
+	for instance := 1; instance <= n; instance++ {
+		for id := 2; id <= n; id++ {
+			assert.Equal(t, decisions[instance][1], decisions[instance][id], "instance %d", instance)
+		}
+	}
+
+	stopAgreements(agreements, transports)
+}
+
+// Do honest nodes agree and keep validity despite a byzantine voter?
+func TestABAByzantineVotes(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	agreements := startAgreements(n, transports, map[int]bool{1: true})
+	stopDrain := drain(transports[1])
+
+	// byzantine node 1 votes for both bits, pushes 0 and sends bogus coin shares
+	byzantine := transports[1]
+	for round := uint32(0); round < 8; round++ {
+		for instance := int64(1); instance <= 2; instance++ {
+			for peer := 2; peer <= n; peer++ {
+				sendRaw(t, byzantine, peer, &pb.ABAMsg{Type: pb.ABAType_ABABval, Sender: 1, Instance: instance, Round: round, Value: 0})
+				sendRaw(t, byzantine, peer, &pb.ABAMsg{Type: pb.ABAType_ABABval, Sender: 1, Instance: instance, Round: round, Value: 1})
+				sendRaw(t, byzantine, peer, &pb.ABAMsg{Type: pb.ABAType_ABAAux, Sender: 1, Instance: instance, Round: round, Value: 0})
+				sendRaw(t, byzantine, peer, &pb.ABAMsg{Type: pb.ABAType_ABAConf, Sender: 1, Instance: instance, Round: round, Value: 3})
+				sendRaw(t, byzantine, peer, &pb.ABAMsg{Type: pb.ABAType_ABACoin, Sender: 1, Instance: instance, Round: round, Share: []byte{0, 0, 1}})
+			}
+		}
+	}
+
+	// instance 1: every honest node proposes true, instance 2: split
+	for id, ag := range agreements {
+		ag.Propose(0, 1, true)
+		ag.Propose(0, 2, id%2 == 0)
+	}
+	decisions := waitDecisions(t, agreements, 2)
+	for id := 2; id <= n; id++ {
+		assert.Equal(t, true, decisions[1][id])
+		assert.Equal(t, decisions[2][2], decisions[2][id])
+	}
+
+	close(stopDrain)
+	stopAgreements(agreements, transports)
+}
+
+// Does a restarted node deliver its decisions again, ignore proposals in
+// the instances it decided and decide in the next epoch?
+func TestABARestartFromWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *aba.Agreement {
+		return aba.NewLoggedAgreement(n, id, transports[id], identity.NewTBLSCryproProvider(n, 2, id-1), w)
+	}
+	wals := make(map[int]*wal.WAL, n)
+	agreements := make(map[int]*aba.Agreement, n)
+	for id := range transports {
+		wals[id] = open(id)
+		agreements[id] = start(id, wals[id])
+	}
+
+	for _, ag := range agreements {
+		ag.Propose(0, 1, true)
+		ag.Propose(0, 2, false)
+	}
+	before := waitDecisions(t, agreements, 2)
+
+	agreements[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	agreements[4] = start(4, wals[4])
+	replayed := waitDecisions(t, map[int]*aba.Agreement{4: agreements[4]}, 2)
+	assert.Equal(t, before[1][4], replayed[1][4])
+	assert.Equal(t, before[2][4], replayed[2][4])
+
+	// a proposal in an instance it decided before is ignored
+	agreements[4].Propose(0, 1, false)
+	for _, ag := range agreements {
+		ag.Propose(1, 1, false)
+	}
+	after := waitDecisions(t, agreements, 1)
+	for id := 1; id <= n; id++ {
+		assert.Equal(t, false, after[1][id])
+	}
+
+	stopAgreements(agreements, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
+
+// Is the log truncated below a stable epoch, and does a restarted node
+// only deliver the decisions from there?
+func TestABATruncatedWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{SegmentSize: 256, NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *aba.Agreement {
+		return aba.NewLoggedAgreement(n, id, transports[id], identity.NewTBLSCryproProvider(n, 2, id-1), w)
+	}
+	wals := make(map[int]*wal.WAL, n)
+	agreements := make(map[int]*aba.Agreement, n)
+	for id := range transports {
+		wals[id] = open(id)
+		agreements[id] = start(id, wals[id])
+	}
+
+	for epoch := uint64(0); epoch < 6; epoch++ {
+		for _, ag := range agreements {
+			ag.Propose(epoch, 1, epoch%2 == 0)
+		}
+		waitDecisions(t, agreements, 1)
+	}
+	agreements[4].Stable(4)
+	waitTruncated(t, filepath.Join(dir, "4"))
+
+	agreements[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	agreements[4] = start(4, wals[4])
+	for epoch := uint64(4); epoch < 6; epoch++ {
+		select {
+		case d := <-agreements[4].Decide():
+			assert.Equal(t, epoch, d.Epoch)
+			assert.Equal(t, epoch%2 == 0, d.Value)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("node 4 does not replay epoch %d", epoch)
+		}
+	}
+	select {
+	case d := <-agreements[4].Decide():
+		t.Fatalf("stable epoch %d delivered again", d.Epoch)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for _, ag := range agreements {
+		ag.Propose(6, 1, true)
+	}
+	after := waitDecisions(t, agreements, 1)
+	for id := 1; id <= n; id++ {
+		assert.Equal(t, true, after[1][id])
+	}
+
+	stopAgreements(agreements, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
+
+// Are coin shares of one protocol rejected by the ABA of another?
+func TestABACoinDomain(t *testing.T) {
+	n := 4
+	providers := generateProviders(t, n, 2)
+	share := providers[1].ComputeShare([]byte("aba-coin|0|1|0"))
+	coin := &pb.ABAMsg{Type: pb.ABAType_ABACoin, Sender: 2, Instance: 1, Share: share}
+
+	a := aba.NewABA(n, 1, 0, 1, &recordingNetwork{}, providers[0])
+	assert.Nil(t, a.HandleMessage(coin))
+	tagged := aba.NewTaggedABA("mvba-aba", n, 1, 0, 1, &recordingNetwork{}, providers[0])
+	assert.ErrorIs(t, tagged.HandleMessage(coin), aba.ErrInvalidShare)
+}
+
+// Does a node behind the others decide and halt on their TERM?
+func TestABATermination(t *testing.T) {
+	n := 4
+	providers := generateProviders(t, n, 2)
+	net := &recordingNetwork{}
+	a := aba.NewABA(n, 1, 0, 1, net, providers[0])
+	assert.Nil(t, a.Propose(false))
+	term := func(sender int64) *pb.ABAMsg {
+		return &pb.ABAMsg{Type: pb.ABAType_ABATerm, Sender: sender, Instance: 1, Round: 5, Value: 1}
+	}
+
+	// one TERM may come from a liar
+	assert.Nil(t, a.HandleMessage(term(2)))
+	assert.Nil(t, a.HandleMessage(term(2)))
+	_, decided := a.Output()
+	assert.False(t, decided)
+
+	// f+1 do not, the decision is announced in turn
+	assert.Nil(t, a.HandleMessage(term(3)))
+	value, decided := a.Output()
+	assert.True(t, decided)
+	assert.True(t, value)
+	assert.False(t, a.Halted())
+	last := net.broadcasts[len(net.broadcasts)-1].(*pb.ABAMsg)
+	assert.Equal(t, pb.ABAType_ABATerm, last.Type)
+	assert.Equal(t, uint32(1), last.Value)
+
+	// n-f let it halt
+	assert.Nil(t, a.HandleMessage(term(1)))
+	assert.True(t, a.Halted())
+	sent := len(net.broadcasts)
+	assert.Nil(t, a.HandleMessage(&pb.ABAMsg{Type: pb.ABAType_ABABval, Sender: 4, Instance: 1, Value: 1}))
+	assert.Equal(t, sent, len(net.broadcasts))
+}
+
+// Are rounds far ahead dropped, and does a sender flooding future rounds
+// only use up its own quota?
+func TestABARoundWindow(t *testing.T) {
+	n := 4
+	providers := generateProviders(t, n, 2)
+	net := &recordingNetwork{}
+	a := aba.NewABA(n, 1, 0, 1, net, providers[0])
+	bval := func(sender int64, round uint32, value uint32) *pb.ABAMsg {
+		return &pb.ABAMsg{Type: pb.ABAType_ABABval, Sender: sender, Instance: 1, Round: round, Value: value}
+	}
+
+	// f+1 BVAL are relayed within the window only
+	assert.Nil(t, a.HandleMessage(bval(2, 9, 0)))
+	assert.Nil(t, a.HandleMessage(bval(3, 9, 0)))
+	assert.Empty(t, net.broadcasts)
+	assert.Nil(t, a.HandleMessage(bval(2, 8, 0)))
+	assert.Nil(t, a.HandleMessage(bval(3, 8, 0)))
+	assert.Len(t, net.broadcasts, 1)
+
+	// node 2 used up its quota, its BVAL no longer count
+	for i := 0; i < 64; i++ {
+		assert.Nil(t, a.HandleMessage(bval(2, 1, 0)))
+	}
+	assert.Nil(t, a.HandleMessage(bval(2, 2, 1)))
+	assert.Nil(t, a.HandleMessage(bval(3, 2, 1)))
+	assert.Len(t, net.broadcasts, 1)
+	assert.Nil(t, a.HandleMessage(bval(4, 2, 1)))
+	assert.Len(t, net.broadcasts, 2)
+}
+
+// Provider counting its combines
+type combineCounter struct {
+	crypto.CryptoProvider
+	combines int
+}
+
+func (c *combineCounter) Combine(data []byte, shares [][]byte) []byte {
+	c.combines++
+	return c.CryptoProvider.Combine(data, shares)
+}
+
+// Is a coin that cannot be combined yet only tried again with a new share?
+func TestABACoinRetry(t *testing.T) {
+	n := 4
+	// the coin needs three shares, f+1 are not enough
+	providers := generateProviders(t, n, 3)
+	cp := &combineCounter{CryptoProvider: providers[0]}
+	a := aba.NewABA(n, 1, 0, 1, &recordingNetwork{}, cp)
+	msg := func(typ pb.ABAType, sender int64, value uint32) *pb.ABAMsg {
+		return &pb.ABAMsg{Type: typ, Sender: sender, Instance: 1, Value: value}
+	}
+	coin := func(sender int) *pb.ABAMsg {
+		m := msg(pb.ABAType_ABACoin, int64(sender), 0)
+		m.Share = providers[sender-1].ComputeShare([]byte("aba-coin|0|1|0"))
+		return m
+	}
+
+	// round 0 gets to the coin with 0 the only candidate
+	assert.Nil(t, a.Propose(false))
+	for sender := int64(2); sender <= 4; sender++ {
+		assert.Nil(t, a.HandleMessage(msg(pb.ABAType_ABABval, sender, 0)))
+		assert.Nil(t, a.HandleMessage(msg(pb.ABAType_ABAAux, sender, 0)))
+		assert.Nil(t, a.HandleMessage(msg(pb.ABAType_ABAConf, sender, 1)))
+	}
+	assert.Nil(t, a.HandleMessage(coin(2)))
+	assert.Equal(t, 0, cp.combines)
+	assert.Nil(t, a.HandleMessage(coin(3)))
+	assert.Equal(t, 1, cp.combines)
+
+	// messages without a new share do not combine again
+	for sender := int64(2); sender <= 4; sender++ {
+		assert.Nil(t, a.HandleMessage(msg(pb.ABAType_ABABval, sender, 1)))
+	}
+	assert.Nil(t, a.HandleMessage(coin(3)))
+	assert.Equal(t, 1, cp.combines)
+	assert.Equal(t, uint32(0), a.Round())
+
+	assert.Nil(t, a.HandleMessage(coin(4)))
+	assert.Equal(t, 2, cp.combines)
+	assert.Equal(t, uint32(1), a.Round())
+}
+
+// Are instances far ahead never created, and dropped once stable?
+func TestABAAgreementWindow(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	ag := aba.NewAgreement(n, 2, transports[2], identity.NewTBLSCryproProvider(n, 2, 1))
+	stopDrains := []chan struct{}{drain(transports[1]), drain(transports[3]), drain(transports[4])}
+
+	byzantine := transports[1]
+	sendRaw(t, byzantine, 2, &pb.ABAMsg{Type: pb.ABAType_ABABval, Sender: 1, Epoch: 100, Instance: 1, Value: 1})
+	sendRaw(t, byzantine, 2, &pb.ABAMsg{Type: pb.ABAType_ABABval, Sender: 1, Epoch: 3, Instance: 1, Value: 1})
+	waitUsage(t, ag.Usage, 3)
+	ag.Stable(4)
+	waitUsage(t, ag.Usage)
+
+	// messages below the stable epoch are garbage
+	sendRaw(t, byzantine, 2, &pb.ABAMsg{Type: pb.ABAType_ABABval, Sender: 1, Epoch: 3, Instance: 1, Value: 1})
+	sendRaw(t, byzantine, 2, &pb.ABAMsg{Type: pb.ABAType_ABABval, Sender: 1, Epoch: 5, Instance: 1, Value: 1})
+	waitUsage(t, ag.Usage, 5)
+
+	ag.Stop()
+	for _, stopDrain := range stopDrains {
+		close(stopDrain)
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
@@ -0,0 +1,304 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus"
+	"github.com/stuck/consensus/acs"
+	"github.com/stuck/crypto/tbls/identity"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+)
+
+func startSubsets(n int, transports map[int]transport.Transport, crashed map[int]bool) map[int]*acs.Subset {
+	subsets := make(map[int]*acs.Subset, n)
+	for id, tp := range transports {
+		if crashed[id] {
+			continue
+		}
+		subsets[id] = acs.NewSubset(n, id, tp, identity.NewTBLSCryproProvider(n, 2, id-1))
+	}
+	return subsets
+}
+
+func stopSubsets(subsets map[int]*acs.Subset, transports map[int]transport.Transport) {
+	for _, s := range subsets {
+		s.Stop()
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+func waitSubsets(t *testing.T, subsets map[int]*acs.Subset) map[int]acs.Result {
+	results := make(map[int]acs.Result, len(subsets))
+	for id, s := range subsets {
+		select {
+		case r := <-s.Output():
+			results[id] = r
+		case <-time.After(10 * time.Second):
+			t.Fatalf("node %d does not output", id)
+		}
+	}
+	return results
+}
+
+// Run epochs one after the other, nobody tells the subsets what is stable
+func runSubsetEpochs(t *testing.T, subsets map[int]*acs.Subset, epochs uint64) {
+	for epoch := uint64(0); epoch < epochs; epoch++ {
+		for id, s := range subsets {
+			s.Propose(epoch, []byte{byte(epoch), byte(id)})
+		}
+		results := make(map[int]acs.Result, len(subsets))
+		for id, s := range subsets {
+			select {
+			case results[id] = <-s.Output():
+			case <-time.After(10 * time.Second):
+				t.Fatalf("node %d never outputs epoch %d", id, epoch)
+			}
+		}
+		for id := range subsets {
+			assert.Equal(t, epoch, results[id].Epoch)
+			assert.Equal(t, results[1].Values, results[id].Values)
+		}
+	}
+}
+
+// Do all nodes output the same subset of at least n-f proposals?
+func TestACSAllHonest(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	subsets := startSubsets(n, transports, nil)
+
+	for epoch := uint64(0); epoch < 2; epoch++ {
+		for id, s := range subsets {
+			s.Propose(epoch, []byte{byte(epoch), byte(id)})
+		}
+		results := waitSubsets(t, subsets)
+		for id := 1; id <= n; id++ {
+			assert.Equal(t, epoch, results[id].Epoch)
+			assert.Equal(t, results[1].Values, results[id].Values)
+		}
+		assert.GreaterOrEqual(t, len(results[1].Values), n-1)
+		for proposer, value := range results[1].Values {
+			assert.Equal(t, []byte{byte(epoch), byte(proposer)}, value)
+		}
+	}
+
+	stopSubsets(subsets, transports)
+}
+
+// Do nodes keep agreeing for more epochs than the window kept ahead?
+func TestACSBeyondWindow(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	subsets := startSubsets(n, transports, nil)
+	runSubsetEpochs(t, subsets, 20)
+	stopSubsets(subsets, transports)
+}
+
+// Does a restarted node deliver its subsets again and agree in the next
+// epoch?
+func TestACSRestartFromWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *acs.Subset {
+		cp := identity.NewTBLSCryproProvider(n, 2, id-1)
+		return acs.NewLoggedSubset(n, id, transports[id], func(epoch uint64, net consensus.Network) acs.Instance {
+			return acs.NewACS(n, id, epoch, net, cp)
+		}, w)
+	}
+	wals := make(map[int]*wal.WAL, n)
+	subsets := make(map[int]*acs.Subset, n)
+	for id := range transports {
+		wals[id] = open(id)
+		subsets[id] = start(id, wals[id])
+	}
+
+	for id, s := range subsets {
+		s.Propose(0, []byte{0, byte(id)})
+	}
+	before := waitSubsets(t, subsets)
+
+	subsets[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	subsets[4] = start(4, wals[4])
+	replayed := waitSubsets(t, map[int]*acs.Subset{4: subsets[4]})
+	assert.Equal(t, before[4], replayed[4])
+
+	for id, s := range subsets {
+		s.Propose(1, []byte{1, byte(id)})
+	}
+	after := waitSubsets(t, subsets)
+	for id := 1; id <= n; id++ {
+		assert.Equal(t, uint64(1), after[id].Epoch)
+		assert.Equal(t, after[1].Values, after[id].Values)
+	}
+
+	stopSubsets(subsets, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
+
+// Does a node crashing in the middle of an epoch deliver the subset of
+// that epoch after its restart?
+func TestACSRestartMidEpoch(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	gate := newGatedTransport(transports[4], 20)
+	transports[4] = gate
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *acs.Subset {
+		cp := identity.NewTBLSCryproProvider(n, 2, id-1)
+		return acs.NewLoggedSubset(n, id, transports[id], func(epoch uint64, net consensus.Network) acs.Instance {
+			return acs.NewACS(n, id, epoch, net, cp)
+		}, w)
+	}
+	wals := make(map[int]*wal.WAL, n)
+	subsets := make(map[int]*acs.Subset, n)
+	for id := range transports {
+		wals[id] = open(id)
+		subsets[id] = start(id, wals[id])
+	}
+
+	for id, s := range subsets {
+		s.Propose(0, []byte{0, byte(id)})
+	}
+	select {
+	case <-gate.passedCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("node 4 does not take part in epoch 0")
+	}
+	before := waitSubsets(t, map[int]*acs.Subset{1: subsets[1], 2: subsets[2], 3: subsets[3]})
+	subsets[4].Stop()
+	assert.Nil(t, wals[4].Close())
+
+	// the recorded proposal stands
+	wals[4] = open(4)
+	subsets[4] = start(4, wals[4])
+	subsets[4].Propose(0, []byte{9})
+	gate.Open()
+	after := waitSubsets(t, map[int]*acs.Subset{4: subsets[4]})
+	assert.Equal(t, uint64(0), after[4].Epoch)
+	assert.Equal(t, before[1].Values, after[4].Values)
+
+	stopSubsets(subsets, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
+
+// Do nodes still agree when a node never proposes?
+func TestACSWithCrashedNode(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	subsets := startSubsets(n, transports, map[int]bool{3: true})
+	stopDrain := drain(transports[3])
+
+	for id, s := range subsets {
+		s.Propose(5, []byte{byte(id)})
+	}
+	results := waitSubsets(t, subsets)
+	for id := range subsets {
+		assert.Equal(t, results[1].Values, results[id].Values)
+	}
+	assert.Equal(t, n-1, len(results[1].Values))
+	assert.NotContains(t, results[1].Values, 3)
+
+	close(stopDrain)
+	stopSubsets(subsets, transports)
+}
+
+// Is the log truncated below a stable epoch, and does a restarted node
+// only deliver the epochs from there?
+func TestACSTruncatedWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{SegmentSize: 256, NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *acs.Subset {
+		cp := identity.NewTBLSCryproProvider(n, 2, id-1)
+		return acs.NewLoggedSubset(n, id, transports[id], func(epoch uint64, net consensus.Network) acs.Instance {
+			return acs.NewACS(n, id, epoch, net, cp)
+		}, w)
+	}
+	wals := make(map[int]*wal.WAL, n)
+	subsets := make(map[int]*acs.Subset, n)
+	for id := range transports {
+		wals[id] = open(id)
+		subsets[id] = start(id, wals[id])
+	}
+
+	runSubsetEpochs(t, subsets, 6)
+	subsets[4].Stable(4)
+	waitTruncated(t, filepath.Join(dir, "4"))
+
+	subsets[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	subsets[4] = start(4, wals[4])
+	for epoch := uint64(4); epoch < 6; epoch++ {
+		replayed := waitSubsets(t, map[int]*acs.Subset{4: subsets[4]})
+		assert.Equal(t, epoch, replayed[4].Epoch)
+	}
+	select {
+	case r := <-subsets[4].Output():
+		t.Fatalf("stable epoch %d delivered again", r.Epoch)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for id, s := range subsets {
+		s.Propose(6, []byte{6, byte(id)})
+	}
+	after := waitSubsets(t, subsets)
+	for id := 1; id <= n; id++ {
+		assert.Equal(t, uint64(6), after[id].Epoch)
+		assert.Equal(t, after[1].Values, after[id].Values)
+	}
+
+	stopSubsets(subsets, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
@@ -0,0 +1,193 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/api"
+	"github.com/stuck/mempool"
+)
+
+func startServer(t *testing.T, submit func(tx []byte) error) *api.Server {
+	s := api.NewServer(submit)
+	assert.NoError(t, s.Start("127.0.0.1:0"))
+	return s
+}
+
+func txHash(tx []byte) string {
+	d := sha256.Sum256(tx)
+	return hex.EncodeToString(d[:])
+}
+
+// Does the server accept transactions and report their commit?
+func TestServerSubmitAndSubscribe(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	submitted := make(chan []byte, 1)
+	s := startServer(t, func(tx []byte) error {
+		if bytes.Equal(tx, []byte("full")) {
+			return mempool.ErrFull
+		}
+		if bytes.Equal(tx, []byte("down")) {
+			return errors.New("replica is stopping")
+		}
+		submitted <- tx
+		return nil
+	})
+	url := "http://" + s.Addr()
+	client := &http.Client{}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Post(url+"/tx", "application/octet-stream", bytes.NewReader([]byte("a=1")))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, []byte("a=1"), <-submitted)
+
+	resp, err = client.Post(url+"/tx", "application/octet-stream", bytes.NewReader([]byte("full")))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+	resp.Body.Close()
+	resp, err = client.Post(url+"/tx", "application/octet-stream", bytes.NewReader([]byte("down")))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp.Body.Close()
+	resp, err = client.Post(url+"/tx", "application/octet-stream", bytes.NewReader(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+	resp, err = client.Get(url + "/tx/nothex")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	hash := txHash([]byte("a=1"))
+	resp, err = client.Get(url + "/tx/" + hash)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	// a subscriber waiting for the commit is notified
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.Committed(7, [][]byte{[]byte("b=2"), []byte("a=1")})
+	}()
+	resp, err = client.Get(url + "/tx/" + hash + "?wait=5s")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var commit api.Commit
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&commit))
+	resp.Body.Close()
+	assert.Equal(t, api.Commit{Hash: hash, Height: 7, Position: 1}, commit)
+
+	s.Stop()
+}
+
+// Does the client wait for f+1 matching acknowledgments?
+func TestClientIgnoresLyingReplica(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	tx := []byte("a=1")
+	submitted := make(chan struct{}, n)
+	servers := make([]*api.Server, n)
+	urls := make([]string, n)
+	for i := range servers {
+		servers[i] = startServer(t, func([]byte) error {
+			submitted <- struct{}{}
+			return nil
+		})
+		urls[i] = "http://" + servers[i].Addr()
+	}
+
+	go func() {
+		<-submitted
+		// the liar answers first, the others agree later
+		servers[0].Committed(99, [][]byte{tx})
+		time.Sleep(200 * time.Millisecond)
+		servers[1].Committed(3, [][]byte{[]byte("b=2"), tx})
+		time.Sleep(200 * time.Millisecond)
+		servers[2].Committed(3, [][]byte{[]byte("b=2"), tx})
+	}()
+
+	_, err := api.NewClient(nil)
+	assert.ErrorIs(t, err, api.ErrNoReplicas)
+	client, err := api.NewClient(urls)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	commit, err := client.Submit(ctx, tx)
+	cancel()
+	assert.NoError(t, err)
+	assert.Equal(t, api.Commit{Hash: txHash(tx), Height: 3, Position: 1}, commit)
+
+	client.Close()
+	for _, s := range servers {
+		s.Stop()
+	}
+}
+
+// Are transactions submitted through the client committed by HoneyBadger?
+func TestClientWithHoneyBadger(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	replicas := startHoneyBadgers(n, transports, nil)
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{}, n)
+	urls := make([]string, 0, n)
+	servers := make([]*api.Server, 0, n)
+	for id := 1; id <= n; id++ {
+		hb := replicas[id]
+		s := startServer(t, func(tx []byte) error {
+			hb.Submit(tx)
+			return nil
+		})
+		go func() {
+			defer func() { doneCh <- struct{}{} }()
+			for {
+				select {
+				case b := <-hb.Commit():
+					s.Committed(b.Epoch, b.Txs)
+				case <-stopCh:
+					return
+				}
+			}
+		}()
+		servers = append(servers, s)
+		urls = append(urls, "http://"+s.Addr())
+	}
+
+	client, err := api.NewClient(urls)
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		tx := []byte(fmt.Sprintf("key-%d=%d", i, i))
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		commit, err := client.Submit(ctx, tx)
+		cancel()
+		assert.NoError(t, err)
+		assert.Equal(t, txHash(tx), commit.Hash)
+	}
+
+	client.Close()
+	for _, s := range servers {
+		s.Stop()
+	}
+	close(stopCh)
+	for i := 0; i < n; i++ {
+		<-doneCh
+	}
+	stopHoneyBadgers(replicas, transports)
+}
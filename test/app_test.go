@@ -0,0 +1,80 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/app"
+	"github.com/stuck/app/kvstore"
+)
+
+// Are pairs visible only once committed?
+func TestKVStoreExecuteAndQuery(t *testing.T) {
+	kv := kvstore.NewKVStore()
+	assert.NoError(t, kv.CheckTx([]byte("a=1")))
+	assert.NoError(t, kv.CheckTx([]byte("a=")))
+	assert.ErrorIs(t, kv.CheckTx([]byte("=1")), kvstore.ErrMalformedTx)
+	assert.ErrorIs(t, kv.CheckTx([]byte("a")), kvstore.ErrMalformedTx)
+
+	results := kv.ExecuteBlock(app.Block{Height: 1, Txs: [][]byte{[]byte("a=1"), []byte("bad"), []byte("b=2")}})
+	assert.Equal(t, []uint32{app.CodeOK, app.CodeInvalid, app.CodeOK}, []uint32{results[0].Code, results[1].Code, results[2].Code})
+	_, err := kv.Query(kvstore.PathKey, []byte("a"))
+	assert.ErrorIs(t, err, kvstore.ErrNotFound)
+
+	first := kv.Commit()
+	value, err := kv.Query(kvstore.PathKey, []byte("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+	_, err = kv.Query("/other", []byte("a"))
+	assert.ErrorIs(t, err, app.ErrUnknownPath)
+
+	// the hash covers the state, not how it was reached
+	kv.ExecuteBlock(app.Block{Height: 2, Txs: [][]byte{[]byte("a=3")}})
+	assert.NotEqual(t, first, kv.Commit())
+	kv.ExecuteBlock(app.Block{Height: 3, Txs: [][]byte{[]byte("a=1")}})
+	assert.Equal(t, first, kv.Commit())
+}
+
+// Do replicas applying the PBFT log reach the same state?
+func TestKVStoreReplicasSameState(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	replicas := startPBFTs(t, n, transports, nil)
+
+	txs := 12
+	wait := collectAll(t, replicas, pbftLayout, txs)
+	for i := 0; i < txs; i++ {
+		for _, p := range replicas {
+			// keys are written several times, only the order decides the state
+			p.Submit([]byte(fmt.Sprintf("key-%d=%d", i%3, i)))
+		}
+	}
+	batches := wait()
+
+	stores := make(map[int]*kvstore.KVStore, n)
+	hashes := make(map[int][]byte, n)
+	for id, blocks := range batches {
+		stores[id] = kvstore.NewKVStore()
+		sm := app.NewStateMachine(stores[id])
+		for _, b := range blocks {
+			sm.Apply(b.Txs)
+		}
+		hashes[id] = sm.AppHash()
+	}
+	for id := range batches {
+		assert.Equal(t, hashes[1], hashes[id])
+		for k := 0; k < 3; k++ {
+			expected, _ := stores[1].Query(kvstore.PathKey, []byte(fmt.Sprintf("key-%d", k)))
+			value, err := stores[id].Query(kvstore.PathKey, []byte(fmt.Sprintf("key-%d", k)))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, value)
+		}
+	}
+
+	stopPBFTs(replicas, transports)
+}
@@ -0,0 +1,188 @@
+package test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus/avid"
+	"github.com/stuck/crypto/merkle"
+	"github.com/stuck/erasure"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+)
+
+// Can any k shards reconstruct the data?
+func TestErasureAnyKShards(t *testing.T) {
+	enc, err := erasure.NewEncoder(3, 7)
+	assert.Nil(t, err)
+	for _, size := range []int{0, 1, 100, 1000} {
+		data := make([]byte, size)
+		rand.Read(data)
+		shards := enc.Encode(data)
+		assert.Equal(t, 7, len(shards))
+
+		// drop 4 random shards
+		for trial := 0; trial < 10; trial++ {
+			partial := make([][]byte, len(shards))
+			for _, i := range rand.Perm(7)[:3] {
+				partial[i] = shards[i]
+			}
+			decoded, err := enc.Decode(partial)
+			assert.Nil(t, err)
+			assert.Equal(t, true, bytes.Equal(data, decoded))
+		}
+	}
+
+	// k-1 shards are not enough
+	shards := enc.Encode([]byte("Hello world"))
+	shards[0], shards[1], shards[2], shards[3], shards[4] = nil, nil, nil, nil, nil
+	_, err = enc.Decode(shards)
+	assert.Equal(t, erasure.ErrTooFewShards, err)
+}
+
+// Do proofs bind a leaf to its position?
+func TestMerkleProof(t *testing.T) {
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+	tree := merkle.NewTree(leaves)
+	for i, leaf := range leaves {
+		assert.Equal(t, true, merkle.Verify(tree.Root(), leaf, i, tree.Proof(i)))
+	}
+	assert.Equal(t, false, merkle.Verify(tree.Root(), []byte("x"), 0, tree.Proof(0)))
+	assert.Equal(t, false, merkle.Verify(tree.Root(), leaves[0], 1, tree.Proof(0)))
+	assert.Equal(t, false, merkle.Verify(tree.Root(), leaves[0], 8, tree.Proof(0)))
+	assert.Nil(t, tree.Proof(5))
+}
+
+func stopAVIDBroadcasters(broadcasters map[int]*avid.Broadcaster, transports map[int]transport.Transport) {
+	for _, b := range broadcasters {
+		b.Stop()
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+func waitAVIDDeliveries(t *testing.T, broadcasters map[int]*avid.Broadcaster) map[int]avid.Delivery {
+	deliveries := make(map[int]avid.Delivery)
+	for id, b := range broadcasters {
+		select {
+		case d := <-b.Deliver():
+			deliveries[id] = d
+		case <-time.After(5 * time.Second):
+			t.Fatalf("node %d does not deliver", id)
+		}
+	}
+	return deliveries
+}
+
+// Do all nodes reconstruct a large value, even with a silent node?
+func TestAVIDWithSilentNode(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	broadcasters := make(map[int]*avid.Broadcaster, n)
+	for id := 1; id < n; id++ {
+		broadcasters[id] = avid.NewBroadcaster(n, id, transports[id])
+	}
+	// node 4 is crashed
+	stopDrain := drain(transports[n])
+
+	value := make([]byte, 64*1024)
+	rand.Read(value)
+	broadcasters[1].Broadcast(0, value)
+	for _, d := range waitAVIDDeliveries(t, broadcasters) {
+		assert.Equal(t, 1, d.Proposer)
+		assert.Equal(t, true, bytes.Equal(value, d.Value))
+	}
+
+	close(stopDrain)
+	stopAVIDBroadcasters(broadcasters, transports)
+}
+
+// Do honest nodes agree on nil when fragments are not a valid encoding?
+func TestAVIDInconsistentFragments(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	broadcasters := make(map[int]*avid.Broadcaster, n)
+	for id := 2; id <= n; id++ {
+		broadcasters[id] = avid.NewBroadcaster(n, id, transports[id])
+	}
+	stopDrain := drain(transports[1])
+
+	// byzantine node 1 commits to random fragments of equal size
+	fragments := make([][]byte, n)
+	for i := range fragments {
+		fragments[i] = make([]byte, 16)
+		rand.Read(fragments[i])
+	}
+	tree := merkle.NewTree(fragments)
+	byzantine := transports[1]
+	for id := 1; id <= n; id++ {
+		msg := &pb.AVIDMsg{Type: pb.AVIDType_AVIDVal, Sender: 1, Proposer: 1, Root: tree.Root(), Fragment: fragments[id-1], Proof: tree.Proof(id - 1)}
+		if id == 1 {
+			msg.Type = pb.AVIDType_AVIDEcho
+			for peer := 2; peer <= n; peer++ {
+				sendRaw(t, byzantine, peer, msg)
+			}
+			continue
+		}
+		sendRaw(t, byzantine, id, msg)
+	}
+
+	for _, d := range waitAVIDDeliveries(t, broadcasters) {
+		assert.Nil(t, d.Value)
+	}
+
+	close(stopDrain)
+	stopAVIDBroadcasters(broadcasters, transports)
+}
+
+// Are fragments with wrong proofs rejected?
+func TestAVIDRejectsBadProof(t *testing.T) {
+	var net recordingNetwork
+	a := avid.NewAVID(4, 2, 0, 1, &net)
+	fragments := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	tree := merkle.NewTree(fragments)
+
+	// fragment of node 3 sent to node 2
+	err := a.HandleMessage(&pb.AVIDMsg{Type: pb.AVIDType_AVIDVal, Sender: 1, Proposer: 1, Root: tree.Root(), Fragment: fragments[2], Proof: tree.Proof(2)})
+	assert.Equal(t, avid.ErrInvalidProof, err)
+	assert.Empty(t, net.broadcasts)
+
+	err = a.HandleMessage(&pb.AVIDMsg{Type: pb.AVIDType_AVIDVal, Sender: 1, Proposer: 1, Root: tree.Root(), Fragment: fragments[1], Proof: tree.Proof(1)})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(net.broadcasts))
+}
+
+// Are instances far ahead never created, and dropped once stable?
+func TestAVIDBroadcasterWindow(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	b := avid.NewBroadcaster(n, 2, transports[2])
+	stopDrains := []chan struct{}{drain(transports[1]), drain(transports[3]), drain(transports[4])}
+
+	// invalid messages are counted too, the guard runs first
+	byzantine := transports[1]
+	sendRaw(t, byzantine, 2, &pb.AVIDMsg{Type: pb.AVIDType_AVIDVal, Sender: 1, Epoch: 100, Proposer: 1})
+	sendRaw(t, byzantine, 2, &pb.AVIDMsg{Type: pb.AVIDType_AVIDVal, Sender: 1, Epoch: 3, Proposer: 1})
+	waitUsage(t, b.Usage, 3)
+	b.Stable(4)
+	waitUsage(t, b.Usage)
+
+	b.Stop()
+	for _, stopDrain := range stopDrains {
+		close(stopDrain)
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
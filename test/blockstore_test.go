@@ -0,0 +1,163 @@
+package test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/blockstore"
+	"github.com/stuck/crypto/tbls/identity"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/wal"
+)
+
+// Block at height certified by the first threshold providers
+func certifiedBlock(players []*identity.TBLSCryproProvider, threshold int, height uint64, txs [][]byte) blockstore.Block {
+	data := blockstore.CertificateData(height, txs)
+	shares := make([][]byte, 0, threshold)
+	for _, p := range players[:threshold] {
+		shares = append(shares, p.ComputeShare(data))
+	}
+	return blockstore.Block{Height: height, Txs: txs, Certificate: players[0].Combine(data, shares)}
+}
+
+// Are blocks found by height, range and transaction after a reopen?
+func TestBlockStoreIndexes(t *testing.T) {
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+	n, threshold := 4, 3
+	players := generateProviders(t, n, threshold)
+	opts := wal.Options{SegmentSize: 256, NoSync: true}
+
+	s, err := blockstore.Open(dir, players[0], opts)
+	assert.Nil(t, err)
+	blocks := make([]blockstore.Block, 0)
+	for h := uint64(1); h <= 5; h++ {
+		txs := [][]byte{[]byte(fmt.Sprintf("tx-%d-a", h)), []byte(fmt.Sprintf("tx-%d-b", h))}
+		b := certifiedBlock(players, threshold, h, txs)
+		assert.Nil(t, s.Append(b))
+		blocks = append(blocks, b)
+	}
+
+	// heights follow each other and certificates must match the block
+	assert.ErrorIs(t, s.Append(certifiedBlock(players, threshold, 7, nil)), blockstore.ErrHeight)
+	forged := certifiedBlock(players, threshold, 6, [][]byte{[]byte("tx")})
+	forged.Txs = [][]byte{[]byte("forged")}
+	assert.ErrorIs(t, s.Append(forged), blockstore.ErrInvalidCertificate)
+	assert.Nil(t, s.Close())
+
+	s, err = blockstore.Open(dir, players[1], opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), s.Height())
+	b, err := s.Block(3)
+	assert.Nil(t, err)
+	assert.Equal(t, blocks[2], b)
+	_, err = s.Block(6)
+	assert.ErrorIs(t, err, blockstore.ErrNotFound)
+
+	r, err := s.Range(2, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, blocks[1:], r)
+	_, err = s.Range(6, 10)
+	assert.ErrorIs(t, err, blockstore.ErrNotFound)
+
+	hash := sha256.Sum256([]byte("tx-4-b"))
+	loc, err := s.Tx(hash[:])
+	assert.Nil(t, err)
+	assert.Equal(t, blockstore.Location{Height: 4, Position: 1}, loc)
+	hash = sha256.Sum256([]byte("forged"))
+	_, err = s.Tx(hash[:])
+	assert.ErrorIs(t, err, blockstore.ErrNotFound)
+
+	// blocks keep appending after a reopen
+	assert.Nil(t, s.Append(certifiedBlock(players, threshold, 6, nil)))
+	assert.Equal(t, uint64(6), s.Height())
+	assert.Nil(t, s.Close())
+}
+
+// Is a store whose certificates do not match the group key rejected?
+func TestBlockStoreVerifyOnLoad(t *testing.T) {
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+	n, threshold := 4, 3
+	players := generateProviders(t, n, threshold)
+	opts := wal.Options{NoSync: true}
+
+	s, err := blockstore.Open(dir, players[0], opts)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Append(certifiedBlock(players, threshold, 1, [][]byte{[]byte("tx")})))
+	assert.Nil(t, s.Close())
+
+	others := generateProviders(t, n, threshold)
+	_, err = blockstore.Open(dir, others[0], opts)
+	assert.ErrorIs(t, err, blockstore.ErrInvalidCertificate)
+}
+
+// Do committed blocks get certified and stored, and does a node that
+// skipped heights fetch them from its peers?
+func TestBlockCertifier(t *testing.T) {
+	n, threshold := 4, 3
+	players := generateProviders(t, n, threshold)
+	var queue []queued
+	stores := make([]*blockstore.Store, n)
+	certifiers := make([]*blockstore.Certifier, n)
+	for i := range certifiers {
+		dir := tempWALDir(t)
+		defer os.RemoveAll(dir)
+		s, err := blockstore.Open(dir, players[i], wal.Options{NoSync: true})
+		assert.Nil(t, err)
+		defer s.Close()
+		stores[i] = s
+		certifiers[i] = blockstore.NewCertifier(n, i+1, &queueNetwork{id: i + 1, n: n, queue: &queue}, players[i], s)
+	}
+	deliver := func() {
+		for len(queue) > 0 {
+			q := queue[0]
+			queue = queue[1:]
+			assert.Nil(t, certifiers[q.to-1].HandleMessage(q.msg.(*pb.BlockMsg)))
+		}
+	}
+	block := func(height uint64) [][]byte {
+		return [][]byte{[]byte(fmt.Sprintf("tx-%d", height))}
+	}
+
+	// node 4 lags, the others certify without it
+	for h := uint64(1); h <= 3; h++ {
+		for _, c := range certifiers[:3] {
+			assert.Nil(t, c.Commit(h, block(h)))
+		}
+	}
+	deliver()
+	for _, s := range stores[:3] {
+		assert.Equal(t, uint64(3), s.Height())
+	}
+	assert.Equal(t, uint64(0), stores[3].Height())
+
+	// shares of another block or forged ones do not certify anything
+	forged := []byte("forged")
+	share := players[3].ComputeShare(blockstore.CertificateData(4, [][]byte{forged}))
+	for _, c := range certifiers[:3] {
+		msg := &pb.BlockMsg{Type: pb.BlockType_BlockShare, Sender: 4, Height: 4, Digest: blockstore.Digest([][]byte{forged}), Share: share}
+		assert.Nil(t, c.HandleMessage(msg))
+		msg = &pb.BlockMsg{Type: pb.BlockType_BlockShare, Sender: 4, Height: 5, Digest: blockstore.Digest(block(5)), Share: share}
+		assert.ErrorIs(t, c.HandleMessage(msg), blockstore.ErrInvalidShare)
+	}
+
+	// node 4 restored a snapshot at 3 and commits 4, it fetches 1 to 3
+	for _, c := range certifiers {
+		assert.Nil(t, c.Commit(4, block(4)))
+	}
+	deliver()
+	for i, s := range stores {
+		assert.Equal(t, uint64(4), s.Height())
+		b, err := s.Block(4)
+		assert.Nil(t, err)
+		assert.Equal(t, block(4), b.Txs)
+		assert.True(t, players[(i+1)%n].VerifySignature(blockstore.CertificateData(4, b.Txs), b.Certificate))
+	}
+	b, err := stores[3].Block(2)
+	assert.Nil(t, err)
+	assert.Equal(t, block(2), b.Txs)
+}
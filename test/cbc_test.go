@@ -0,0 +1,196 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus/cbc"
+	"github.com/stuck/consensus/provable"
+	"github.com/stuck/crypto/tbls/identity"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"google.golang.org/protobuf/proto"
+)
+
+func startCBCBroadcasters(n int, transports map[int]transport.Transport, providers []*identity.TBLSCryproProvider, crashed map[int]bool) map[int]*cbc.Broadcaster {
+	broadcasters := make(map[int]*cbc.Broadcaster, n)
+	for id, tp := range transports {
+		if crashed[id] {
+			continue
+		}
+		broadcasters[id] = cbc.NewBroadcaster(n, id, tp, providers[id-1])
+	}
+	return broadcasters
+}
+
+func stopCBCBroadcasters(broadcasters map[int]*cbc.Broadcaster, transports map[int]transport.Transport) {
+	for _, b := range broadcasters {
+		b.Stop()
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Does every node deliver every broadcast with a certificate anyone can verify?
+func TestCBCAllHonest(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	// certificates are made of n-f shares
+	providers := generateProviders(t, n, 3)
+	broadcasters := startCBCBroadcasters(n, transports, providers, nil)
+
+	for id, b := range broadcasters {
+		b.Broadcast(0, []byte{byte(id)})
+	}
+	for id, b := range broadcasters {
+		for i := 0; i < n; i++ {
+			select {
+			case d := <-b.Deliver():
+				assert.Equal(t, []byte{byte(d.Proposer)}, d.Value)
+				assert.True(t, cbc.Verify(providers[0], d.Epoch, d.Proposer, d.Value, d.Certificate))
+				assert.False(t, cbc.Verify(providers[0], d.Epoch, d.Proposer, []byte("other"), d.Certificate))
+				assert.False(t, cbc.Verify(providers[0], d.Epoch+1, d.Proposer, d.Value, d.Certificate))
+			case <-time.After(5 * time.Second):
+				t.Fatalf("node %d does not deliver", id)
+			}
+		}
+	}
+
+	stopCBCBroadcasters(broadcasters, transports)
+}
+
+// Does the proposer still get a certificate with a crashed node?
+func TestCBCWithCrashedNode(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	providers := generateProviders(t, n, 3)
+	broadcasters := startCBCBroadcasters(n, transports, providers, map[int]bool{4: true})
+	stopDrain := drain(transports[4])
+
+	broadcasters[1].Broadcast(0, []byte("hello"))
+	for id, b := range broadcasters {
+		select {
+		case d := <-b.Deliver():
+			assert.Equal(t, 1, d.Proposer)
+			assert.Equal(t, []byte("hello"), d.Value)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("node %d does not deliver", id)
+		}
+	}
+
+	close(stopDrain)
+	stopCBCBroadcasters(broadcasters, transports)
+}
+
+// Are forged certificates and second values rejected?
+func TestCBCRejectsForgery(t *testing.T) {
+	n := 4
+	providers := generateProviders(t, n, 3)
+	var net recordingNetwork
+	c := cbc.NewCBC(n, 2, 0, 1, &net, providers[1])
+
+	// only the proposer can send, and we sign its first value only
+	err := c.HandleMessage(&pb.CBCMsg{Type: pb.CBCType_CBCSend, Sender: 3, Proposer: 1, Value: []byte("forged")})
+	assert.True(t, errors.Is(err, cbc.ErrInvalidSender))
+	assert.Nil(t, c.HandleMessage(&pb.CBCMsg{Type: pb.CBCType_CBCSend, Sender: 1, Proposer: 1, Value: []byte("a")}))
+	assert.Nil(t, c.HandleMessage(&pb.CBCMsg{Type: pb.CBCType_CBCSend, Sender: 1, Proposer: 1, Value: []byte("b")}))
+	assert.Equal(t, 1, len(net.sends[1]))
+
+	// FINAL needs a valid certificate
+	err = c.HandleMessage(&pb.CBCMsg{Type: pb.CBCType_CBCFinal, Sender: 1, Proposer: 1, Value: []byte("a"), Certificate: []byte("forged")})
+	assert.Equal(t, cbc.ErrInvalidCertificate, err)
+	_, delivered := c.Output()
+	assert.False(t, delivered)
+}
+
+// Network that queues messages between in-memory instances
+type queueNetwork struct {
+	id    int
+	n     int
+	queue *[]queued
+}
+
+type queued struct {
+	to  int
+	msg proto.Message
+}
+
+func (qn *queueNetwork) SendToPeer(peerId int, msg proto.Message) {
+	*qn.queue = append(*qn.queue, queued{to: peerId, msg: msg})
+}
+
+func (qn *queueNetwork) Broadcast(msg proto.Message) {
+	for i := 1; i <= qn.n; i++ {
+		qn.SendToPeer(i, msg)
+	}
+}
+
+// Does the proposer of a valid value get a certificate for it?
+func TestProvableBroadcast(t *testing.T) {
+	n := 4
+	providers := generateProviders(t, n, 3)
+	valid := func(value []byte) bool { return len(value) > 0 }
+	var queue []queued
+	instances := make(map[int]*provable.PB, n)
+	for id := 1; id <= n; id++ {
+		net := &queueNetwork{id: id, n: n, queue: &queue}
+		instances[id] = provable.NewPB(n, id, 0, 1, 1, net, providers[id-1], valid)
+	}
+
+	assert.Equal(t, provable.ErrNotProposer, instances[2].Input([]byte("v")))
+	assert.Equal(t, provable.ErrInvalidValue, instances[1].Input(nil))
+	assert.Nil(t, instances[1].Input([]byte("v")))
+	for len(queue) > 0 {
+		q := queue[0]
+		queue = queue[1:]
+		assert.Nil(t, instances[q.to].HandleMessage(q.msg.(*pb.PBMsg)))
+	}
+
+	for id := 1; id <= n; id++ {
+		value, ok := instances[id].Output()
+		assert.True(t, ok)
+		assert.Equal(t, []byte("v"), value)
+	}
+	certificate, ok := instances[1].Certificate()
+	assert.True(t, ok)
+	assert.True(t, provable.Verify(providers[3], 0, 1, 1, []byte("v"), certificate))
+	assert.False(t, provable.Verify(providers[3], 0, 2, 1, []byte("v"), certificate))
+
+	// receivers never get a certificate
+	_, ok = instances[2].Certificate()
+	assert.False(t, ok)
+}
+
+// Are instances far ahead never created, and dropped once stable?
+func TestCBCBroadcasterWindow(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	b := cbc.NewBroadcaster(n, 2, transports[2], generateProviders(t, n, 3)[1])
+	stopDrains := []chan struct{}{drain(transports[1]), drain(transports[3]), drain(transports[4])}
+
+	// invalid messages are counted too, the guard runs first
+	byzantine := transports[1]
+	sendRaw(t, byzantine, 2, &pb.CBCMsg{Type: pb.CBCType_CBCSend, Sender: 1, Epoch: 100, Proposer: 1})
+	sendRaw(t, byzantine, 2, &pb.CBCMsg{Type: pb.CBCType_CBCSend, Sender: 1, Epoch: 3, Proposer: 1})
+	waitUsage(t, b.Usage, 3)
+	b.Stable(4)
+	waitUsage(t, b.Usage)
+
+	b.Stop()
+	for _, stopDrain := range stopDrains {
+		close(stopDrain)
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
@@ -0,0 +1,152 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/checkpoint"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+// Do f+1 shares make a certificate anyone verifies with one signature
+// check, and do lagging nodes get it back?
+func TestCheckpointCertificate(t *testing.T) {
+	n, threshold := 4, 2
+	players := generateProviders(t, n, threshold)
+	nets := make([]*recordingNetwork, n)
+	cps := make([]*checkpoint.Checkpointer, n)
+	for i := range cps {
+		nets[i] = &recordingNetwork{}
+		cps[i] = checkpoint.NewCheckpointer(n, i+1, 4, nets[i], players[i], nil)
+	}
+	stateHash := []byte("state at 4")
+
+	// heights between checkpoints are not signed
+	cps[0].Record(3, stateHash)
+	assert.Empty(t, nets[0].broadcasts)
+
+	cps[0].Record(4, stateHash)
+	cps[1].Record(4, stateHash)
+	cps[2].Record(4, []byte("diverged"))
+	assert.Nil(t, cps[3].HandleMessage(nets[0].broadcasts[0].(*pb.CheckpointMsg)))
+	assert.Nil(t, cps[3].HandleMessage(nets[2].broadcasts[0].(*pb.CheckpointMsg)))
+	assert.Nil(t, cps[3].Latest())
+	assert.Nil(t, cps[3].HandleMessage(nets[1].broadcasts[0].(*pb.CheckpointMsg)))
+
+	latest := cps[3].Latest()
+	assert.NotNil(t, latest)
+	assert.Equal(t, uint64(4), latest.Height)
+	assert.Equal(t, stateHash, latest.StateHash)
+	assert.True(t, checkpoint.Verify(players[0], *latest))
+	cert := nets[3].broadcasts[0].(*pb.CheckpointMsg)
+	assert.Equal(t, pb.CheckpointType_CheckpointCert, cert.Type)
+
+	// the certificate is gossiped, forged ones are not adopted
+	forged := proto.Clone(cert).(*pb.CheckpointMsg)
+	forged.StateHash = []byte("other")
+	assert.ErrorIs(t, cps[0].HandleMessage(forged), checkpoint.ErrInvalidCertificate)
+	assert.Nil(t, cps[0].Latest())
+	assert.Nil(t, cps[0].HandleMessage(cert))
+	assert.Equal(t, latest, cps[0].Latest())
+
+	// shares of other heights are rejected
+	share := func(sender int, height uint64, stateHash []byte) *pb.CheckpointMsg {
+		return &pb.CheckpointMsg{
+			Type:      pb.CheckpointType_CheckpointShare,
+			Sender:    int64(sender),
+			Height:    height,
+			StateHash: stateHash,
+			Share:     players[sender-1].ComputeShare(checkpoint.Data(height, stateHash)),
+		}
+	}
+	assert.ErrorIs(t, cps[0].HandleMessage(share(3, 0, stateHash)), checkpoint.ErrInvalidShare)
+	assert.ErrorIs(t, cps[0].HandleMessage(share(3, 6, stateHash)), checkpoint.ErrInvalidShare)
+
+	// a node still signing older heights gets the latest certificate back
+	assert.Nil(t, cps[3].HandleMessage(share(1, 8, stateHash)))
+	assert.Nil(t, cps[3].HandleMessage(share(3, 8, []byte("diverged"))))
+	assert.Equal(t, uint64(4), cps[3].Latest().Height)
+	assert.Nil(t, cps[3].HandleMessage(share(2, 8, stateHash)))
+	assert.Equal(t, uint64(8), cps[3].Latest().Height)
+	assert.Empty(t, nets[3].sends)
+	assert.Nil(t, cps[3].HandleMessage(share(3, 4, []byte("diverged"))))
+	assert.Equal(t, 1, len(nets[3].sends[3]))
+	assert.Equal(t, uint64(8), nets[3].sends[3][0].(*pb.CheckpointMsg).Height)
+
+	// shares far above the latest certificate are not collected
+	assert.Nil(t, cps[3].HandleMessage(share(1, 28, stateHash)))
+	assert.Nil(t, cps[3].HandleMessage(share(2, 28, stateHash)))
+	assert.Equal(t, uint64(8), cps[3].Latest().Height)
+	assert.Nil(t, cps[3].HandleMessage(share(1, 24, stateHash)))
+	assert.Nil(t, cps[3].HandleMessage(share(2, 24, stateHash)))
+	assert.Equal(t, uint64(24), cps[3].Latest().Height)
+}
+
+// Are certificates stored and the latest one loaded again on restart?
+func TestCheckpointStorage(t *testing.T) {
+	n, threshold := 4, 2
+	players := generateProviders(t, n, threshold)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	log, err := wal.Open(dir, wal.Options{})
+	assert.Nil(t, err)
+	var net recordingNetwork
+	cps := checkpoint.NewCheckpointer(n, 1, 2, &net, players[0], log)
+	assert.Nil(t, cps.Latest())
+	for _, height := range []uint64{2, 6, 4} {
+		snapshot := certifiedSnapshot(players, threshold, height, []byte{byte(height)}, nil)
+		assert.Nil(t, cps.Adopt(checkpoint.Checkpoint{Height: height, StateHash: snapshot.AppHash, Certificate: snapshot.Certificate}))
+	}
+	assert.Equal(t, uint64(6), cps.Latest().Height)
+
+	// certificates of other heights and odd heights are rejected
+	snapshot := certifiedSnapshot(players, threshold, 8, []byte{8}, nil)
+	assert.ErrorIs(t, cps.Adopt(checkpoint.Checkpoint{Height: 10, StateHash: snapshot.AppHash, Certificate: snapshot.Certificate}), checkpoint.ErrInvalidCertificate)
+	snapshot = certifiedSnapshot(players, threshold, 9, []byte{9}, nil)
+	assert.ErrorIs(t, cps.Adopt(checkpoint.Checkpoint{Height: 9, StateHash: snapshot.AppHash, Certificate: snapshot.Certificate}), checkpoint.ErrInvalidCertificate)
+	assert.Nil(t, log.Close())
+
+	log, err = wal.Open(dir, wal.Options{})
+	assert.Nil(t, err)
+	restarted := checkpoint.NewCheckpointer(n, 1, 2, &net, players[0], log)
+	assert.Equal(t, cps.Latest(), restarted.Latest())
+	assert.True(t, checkpoint.Verify(players[3], *restarted.Latest()))
+	assert.Equal(t, 2, len(replayWAL(t, log)))
+	assert.Nil(t, log.Close())
+}
+
+// Is a certificate the provider cannot combine yet only tried again with
+// a new share?
+func TestCheckpointCombineRetry(t *testing.T) {
+	n := 4
+	// the certificate needs three shares, f+1 are not enough
+	players := generateProviders(t, n, 3)
+	cp := &combineCounter{CryptoProvider: players[0]}
+	cps := checkpoint.NewCheckpointer(n, 1, 4, &recordingNetwork{}, cp, nil)
+	stateHash := []byte("state at 4")
+	share := func(sender int) *pb.CheckpointMsg {
+		return &pb.CheckpointMsg{
+			Type:      pb.CheckpointType_CheckpointShare,
+			Sender:    int64(sender),
+			Height:    4,
+			StateHash: stateHash,
+			Share:     players[sender-1].ComputeShare(checkpoint.Data(4, stateHash)),
+		}
+	}
+
+	assert.Nil(t, cps.HandleMessage(share(2)))
+	assert.Nil(t, cps.HandleMessage(share(3)))
+	assert.Equal(t, 1, cp.combines)
+	assert.Nil(t, cps.HandleMessage(share(2)))
+	assert.Nil(t, cps.HandleMessage(share(3)))
+	assert.Equal(t, 1, cp.combines)
+	assert.Nil(t, cps.Latest())
+
+	assert.Nil(t, cps.HandleMessage(share(4)))
+	assert.Equal(t, 2, cp.combines)
+	assert.Equal(t, uint64(4), cps.Latest().Height)
+}
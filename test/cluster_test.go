@@ -0,0 +1,113 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/node"
+)
+
+// Cluster of n nodes whose files exist in dir, paths are relative to it
+func writeCluster(t *testing.T, dir string, n int) node.Cluster {
+	touch := func(name string) string {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600))
+		return name
+	}
+	c := node.Cluster{
+		Protocol:       node.ProtocolHoneyBadger,
+		N:              n,
+		T:              node.Threshold(node.ProtocolHoneyBadger, n),
+		TBLSPublicKey:  touch("public_key.conf"),
+		TBLSPrivateKey: touch("private_key.conf"),
+	}
+	for id := 1; id <= n; id++ {
+		c.Nodes = append(c.Nodes, node.ClusterNode{
+			ID:   id,
+			Addr: fmt.Sprintf("10.0.0.%d:7000", id),
+			Cert: touch(fmt.Sprintf("cert%d.pem", id)),
+			Key:  touch(fmt.Sprintf("key%d.pem", id)),
+		})
+	}
+	return c
+}
+
+func saveCluster(t *testing.T, dir string, c interface{}) string {
+	data, err := json.Marshal(c)
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "cluster.json")
+	assert.Nil(t, ioutil.WriteFile(path, data, 0644))
+	return path
+}
+
+// Does a valid cluster give every node its config?
+func TestClusterConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cluster")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := node.LoadCluster(saveCluster(t, dir, writeCluster(t, dir, 4)))
+	assert.Nil(t, err)
+	cfg, err := c.Config(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, cfg.ID)
+	assert.Equal(t, 2, cfg.T)
+	assert.Equal(t, "10.0.0.2:7000", cfg.Listen)
+	assert.Equal(t, filepath.Join(dir, "key2.pem"), cfg.Key)
+	assert.Equal(t, filepath.Join(dir, "private_key.conf"), cfg.TBLSPrivateKey)
+	assert.Equal(t, 4, len(cfg.Peers))
+	assert.Equal(t, filepath.Join(dir, "cert4.pem"), cfg.Peers[3].Cert)
+
+	_, err = c.Config(5)
+	assert.ErrorIs(t, err, node.ErrConfig)
+}
+
+// Are all problems of a cluster reported at once?
+func TestClusterValidateReportsAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cluster")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c := writeCluster(t, dir, 4)
+	c.F = 2
+	c.T = 5
+	c.ViewTimeout = "soon"
+	c.Nodes[1].ID = 1
+	c.Nodes[2].Addr = c.Nodes[0].Addr
+	c.Nodes[3].Cert = "missing.pem"
+	_, err = node.LoadCluster(saveCluster(t, dir, c))
+
+	assert.ErrorIs(t, err, node.ErrConfig)
+	var invalid *node.ValidationError
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Equal(t, 6, len(invalid.Problems), err.Error())
+	}
+
+	// the threshold must fit the protocol
+	c = writeCluster(t, dir, 4)
+	c.Protocol = node.ProtocolPBFT
+	_, err = node.LoadCluster(saveCluster(t, dir, c))
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Equal(t, []string{"pbft with n=4 needs t=3, got 2"}, invalid.Problems)
+	}
+
+	// f cannot be lower than the protocols tolerate
+	c = writeCluster(t, dir, 7)
+	c.F = 1
+	_, err = node.LoadCluster(saveCluster(t, dir, c))
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Equal(t, []string{"n=7 nodes tolerate f=2 faults, got f=1"}, invalid.Problems)
+	}
+	c.F = 2
+	_, err = node.LoadCluster(saveCluster(t, dir, c))
+	assert.Nil(t, err)
+
+	// misspelt fields are not ignored
+	_, err = node.LoadCluster(saveCluster(t, dir, map[string]interface{}{"protocl": "pbft"}))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Replica delivering the blocks it commits in order
+type committer[B any] interface {
+	Commit() <-chan B
+}
+
+// How the helpers read a block of a protocol: its transactions and its
+// position in the log, the first block a log commits is at first
+type blockLayout[B any] struct {
+	txs   func(b B) [][]byte
+	pos   func(b B) uint64
+	first uint64
+}
+
+// Collect blocks of a replica until count distinct transactions are committed
+func collect[C committer[B], B any](t *testing.T, c C, layout blockLayout[B], count int) []B {
+	blocks := make([]B, 0)
+	committed := make(map[string]bool)
+	for len(committed) < count {
+		select {
+		case b := <-c.Commit():
+			blocks = append(blocks, b)
+			for _, tx := range layout.txs(b) {
+				committed[string(tx)] = true
+			}
+		case <-time.After(20 * time.Second):
+			t.Fatalf("only %d of %d transactions committed", len(committed), count)
+		}
+	}
+	return blocks
+}
+
+// Start collecting the blocks of every replica until count distinct
+// transactions are committed. Replicas wait for their blocks to be taken
+// before handling anything else, so collection starts before submission
+func collectAll[C committer[B], B any](t *testing.T, replicas map[int]C, layout blockLayout[B], count int) func() map[int][]B {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	logs := make(map[int][]B, len(replicas))
+	for id, c := range replicas {
+		wg.Add(1)
+		go func(id int, c C) {
+			defer wg.Done()
+			blocks := make([]B, 0)
+			committed := make(map[string]bool)
+			for len(committed) < count {
+				select {
+				case b := <-c.Commit():
+					blocks = append(blocks, b)
+					for _, tx := range layout.txs(b) {
+						committed[string(tx)] = true
+					}
+				case <-time.After(20 * time.Second):
+					t.Errorf("node %d committed only %d of %d transactions", id, len(committed), count)
+					return
+				}
+			}
+			mu.Lock()
+			logs[id] = blocks
+			mu.Unlock()
+		}(id, c)
+	}
+	return func() map[int][]B {
+		wg.Wait()
+		return logs
+	}
+}
+
+// Take the blocks committed after the collection until every replica is
+// idle
+func settle[C committer[B], B any](replicas map[int]C, logs map[int][]B) {
+	for id, c := range replicas {
+		for {
+			select {
+			case b := <-c.Commit():
+				logs[id] = append(logs[id], b)
+				continue
+			case <-time.After(500 * time.Millisecond):
+			}
+			break
+		}
+	}
+}
+
+// Blocks one replica commits until it is idle
+func settleOne[C committer[B], B any](c C) []B {
+	logs := map[int][]B{0: {}}
+	settle(map[int]C{0: c}, logs)
+	return logs[0]
+}
+
+// Compare the logs on their common prefix, which starts at the first block
+func assertSameLog[B any](t *testing.T, logs map[int][]B, layout blockLayout[B]) {
+	var reference []B
+	for _, blocks := range logs {
+		if reference == nil || len(blocks) < len(reference) {
+			reference = blocks
+		}
+	}
+	for _, blocks := range logs {
+		assert.Equal(t, reference, blocks[:len(reference)])
+	}
+	for i, b := range reference {
+		assert.Equal(t, layout.first+uint64(i), layout.pos(b))
+	}
+}
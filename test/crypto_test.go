@@ -1,11 +1,15 @@
 package test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stuck/crypto"
+	"github.com/stuck/crypto/cache"
+	"github.com/stuck/crypto/tbls/decode"
 	"github.com/stuck/crypto/tbls/identity"
+	"github.com/stuck/crypto/tbls/key"
 )
 
 // Test if TBLS is working
@@ -35,3 +39,223 @@ func TestTBLSWith4Nodes(t *testing.T) {
 	assert.NotNil(t, signature)
 	assert.Equal(t, true, players[3].VerifySignature(msg, signature))
 }
+
+// Can a freshly generated key set be decoded and used to sign?
+func TestTBLSWithGeneratedKeys(t *testing.T) {
+	n, threshold := 7, 3
+	priShares, pubShares, err := key.Generate(n, threshold)
+	assert.Nil(t, err)
+	pubPoly, err := decode.ToPubPoly(pubShares, threshold, n)
+	assert.Nil(t, err)
+
+	players := make([]crypto.CryptoProvider, n)
+	for i := range players {
+		priShare, err := decode.ToPriShare(priShares[i])
+		assert.Nil(t, err)
+		players[i] = identity.NewTBLSCryproProviderFromShares(n, threshold, priShare, pubPoly)
+	}
+
+	// Any t shares can generate valid signature
+	msg := []byte("Hello world")
+	shares := make([][]byte, 0, threshold)
+	for _, i := range []int{6, 2, 4} {
+		shares = append(shares, players[i].ComputeShare(msg))
+	}
+	signature := players[0].Combine(msg, shares)
+	assert.NotNil(t, signature)
+	assert.Equal(t, true, players[5].VerifySignature(msg, signature))
+
+	// Less than t shares can not
+	assert.Nil(t, players[0].Combine(msg, shares[:threshold-1]))
+}
+
+// Create n providers from a freshly generated key set
+func generateProviders(tb testing.TB, n, threshold int) []*identity.TBLSCryproProvider {
+	priShares, pubShares, err := key.Generate(n, threshold)
+	assert.Nil(tb, err)
+	pubPoly, err := decode.ToPubPoly(pubShares, threshold, n)
+	assert.Nil(tb, err)
+
+	players := make([]*identity.TBLSCryproProvider, n)
+	for i := range players {
+		priShare, err := decode.ToPriShare(priShares[i])
+		assert.Nil(tb, err)
+		players[i] = identity.NewTBLSCryproProviderFromShares(n, threshold, priShare, pubPoly)
+	}
+	return players
+}
+
+// Does batch verification find exactly the invalid shares?
+func TestTBLSBatchVerifyShares(t *testing.T) {
+	players := generateProviders(t, 7, 3)
+	verifier := players[0]
+	msg := []byte("Hello world")
+	shares := make([][]byte, len(players))
+	for i, p := range players {
+		shares[i] = p.ComputeShare(msg)
+	}
+
+	// all shares are valid
+	assert.Empty(t, verifier.BatchVerifyShares(msg, shares))
+
+	// share for another message, tampered share and truncated share
+	shares[1] = players[1].ComputeShare([]byte("Hello byzantine"))
+	shares[3] = append([]byte{}, shares[3]...)
+	shares[3][10] ^= 0x10
+	shares[5] = shares[5][:1]
+	assert.Equal(t, []int{1, 3, 5}, verifier.BatchVerifyShares(msg, shares))
+
+	// batch verification agrees with individual verification
+	for i, share := range shares {
+		assert.Equal(t, i != 1 && i != 3 && i != 5, verifier.VerifyShare(msg, share))
+	}
+}
+
+func BenchmarkVerifyShares(b *testing.B) {
+	for _, n := range []int{4, 16, 64} {
+		players := generateProviders(b, n, (n-1)/3+1)
+		msg := []byte("Hello world")
+		shares := make([][]byte, n)
+		for i, p := range players {
+			shares[i] = p.ComputeShare(msg)
+		}
+		verifier := players[0]
+
+		b.Run(fmt.Sprintf("Individual/n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, share := range shares {
+					verifier.VerifyShare(msg, share)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Batch/n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				verifier.BatchVerifyShares(msg, shares)
+			}
+		})
+	}
+}
+
+// Can combine succeed with invalid shares and report them?
+func TestTBLSOptimisticCombine(t *testing.T) {
+	players := generateProviders(t, 7, 3)
+	combiner := players[0]
+	msg := []byte("Hello world")
+	shares := make([][]byte, len(players))
+	for i, p := range players {
+		shares[i] = p.ComputeShare(msg)
+	}
+
+	// optimistic path, nothing is reported
+	signature, invalid := combiner.OptimisticCombine(msg, shares)
+	assert.NotNil(t, signature)
+	assert.Empty(t, invalid)
+
+	// two of the first t shares are invalid
+	shares[0] = players[0].ComputeShare([]byte("Hello byzantine"))
+	shares[2] = append([]byte{}, shares[2]...)
+	shares[2][10] ^= 0x10
+	signature, invalid = combiner.OptimisticCombine(msg, shares)
+	assert.Equal(t, []int{0, 2}, invalid)
+	assert.Equal(t, true, combiner.VerifySignature(msg, signature))
+	assert.Equal(t, signature, combiner.Combine(msg, shares))
+
+	// not enough valid shares left
+	signature, invalid = combiner.OptimisticCombine(msg, shares[:4])
+	assert.Nil(t, signature)
+	assert.Equal(t, []int{0, 2}, invalid)
+}
+
+// Are verification results memoized and bounded?
+func TestCachedCryptoProvider(t *testing.T) {
+	players := generateProviders(t, 4, 2)
+	cached := cache.NewCachedCryptoProvider(players[0], 2)
+	var _ crypto.CryptoProvider = cached
+
+	msg := []byte("Hello world")
+	share1 := players[1].ComputeShare(msg)
+	share2 := players[2].ComputeShare(msg)
+	tampered := append([]byte{}, share2...)
+	tampered[10] ^= 0x10
+
+	assert.Equal(t, true, cached.VerifyShare(msg, share1))
+	assert.Equal(t, true, cached.VerifyShare(msg, share1))
+	assert.Equal(t, uint64(1), cached.Hits())
+	assert.Equal(t, uint64(1), cached.Misses())
+
+	// invalid outcomes are cached too
+	assert.Equal(t, false, cached.VerifyShare(msg, tampered))
+	assert.Equal(t, false, cached.VerifyShare(msg, tampered))
+	assert.Equal(t, uint64(2), cached.Hits())
+	assert.Equal(t, uint64(2), cached.Misses())
+
+	// a share is not mistaken for a signature
+	assert.Equal(t, false, cached.VerifySignature(msg, share1))
+	assert.Equal(t, uint64(3), cached.Misses())
+
+	// capacity is 2, share1 is the least recently used and was evicted
+	assert.Equal(t, 2, cached.Len())
+	assert.Equal(t, true, cached.VerifyShare(msg, share1))
+	assert.Equal(t, uint64(4), cached.Misses())
+	assert.Equal(t, true, cached.VerifySignature(msg, cached.Combine(msg, [][]byte{share1, share2})))
+}
+
+// Can any t decryption shares open a ciphertext?
+func TestThresholdEncryption(t *testing.T) {
+	players := generateProviders(t, 7, 3)
+	var _ crypto.ThresholdEncrypter = players[0]
+	plaintext := []byte("Hello world")
+	label := []byte("label")
+	ciphertext, err := players[0].Encrypt(plaintext, label)
+	assert.Nil(t, err)
+
+	shares := make([][]byte, len(players))
+	for i, p := range players {
+		shares[i], err = p.DecryptShare(ciphertext, label)
+		assert.Nil(t, err)
+		assert.Equal(t, true, players[6].VerifyDecryptShare(ciphertext, label, shares[i]))
+	}
+
+	// a tampered share is rejected and skipped
+	tampered := append([]byte{}, shares[1]...)
+	tampered[10] ^= 0x10
+	assert.Equal(t, false, players[6].VerifyDecryptShare(ciphertext, label, tampered))
+	decrypted, err := players[6].CombineDecrypt(ciphertext, label, [][]byte{tampered, shares[4], shares[2], shares[5]})
+	assert.Nil(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	// less than t valid shares are not enough
+	_, err = players[6].CombineDecrypt(ciphertext, label, [][]byte{tampered, shares[4], shares[2]})
+	assert.Equal(t, identity.ErrNotEnoughShares, err)
+
+	// a tampered ciphertext is rejected
+	ciphertext[len(ciphertext)-1] ^= 0x01
+	_, err = players[6].CombineDecrypt(ciphertext, label, shares)
+	assert.NotNil(t, err)
+	ciphertext[0] ^= 0x01
+	_, err = players[0].DecryptShare(ciphertext, label)
+	assert.Equal(t, identity.ErrInvalidCiphertext, err)
+}
+
+// Is a ciphertext copied under another label never decrypted?
+func TestThresholdEncryptionLabel(t *testing.T) {
+	players := generateProviders(t, 4, 2)
+	ciphertext, err := players[0].Encrypt([]byte("secret"), []byte("epoch 1 proposer 1"))
+	assert.Nil(t, err)
+
+	// no share is released, and shares for the right label do not count
+	copied := []byte("epoch 1 proposer 4")
+	_, err = players[1].DecryptShare(ciphertext, copied)
+	assert.Equal(t, identity.ErrInvalidCiphertext, err)
+	shares := make([][]byte, 0, len(players))
+	for _, p := range players {
+		share, err := p.DecryptShare(ciphertext, []byte("epoch 1 proposer 1"))
+		assert.Nil(t, err)
+		assert.Equal(t, false, players[2].VerifyDecryptShare(ciphertext, copied, share))
+		shares = append(shares, share)
+	}
+	_, err = players[2].CombineDecrypt(ciphertext, copied, shares)
+	assert.Equal(t, identity.ErrInvalidCiphertext, err)
+
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus/acs"
+	"github.com/stuck/consensus/dumbo"
+	"github.com/stuck/transport"
+)
+
+// Dumbo2 needs threshold n-f for its MVBA
+func startDumboSubsets(t *testing.T, n int, transports map[int]transport.Transport, crashed map[int]bool) map[int]*acs.Subset {
+	providers := generateProviders(t, n, n-(n-1)/3)
+	subsets := make(map[int]*acs.Subset, n)
+	for id, tp := range transports {
+		if crashed[id] {
+			continue
+		}
+		subsets[id] = dumbo.NewSubset(n, id, tp, providers[id-1])
+	}
+	return subsets
+}
+
+// Do all nodes output the same subset of at least n-f proposals?
+func TestDumboAllHonest(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	subsets := startDumboSubsets(t, n, transports, nil)
+
+	for epoch := uint64(0); epoch < 2; epoch++ {
+		for id, s := range subsets {
+			s.Propose(epoch, []byte{byte(epoch), byte(id)})
+		}
+		results := waitSubsets(t, subsets)
+		for id := 1; id <= n; id++ {
+			assert.Equal(t, epoch, results[id].Epoch)
+			assert.Equal(t, results[1].Values, results[id].Values)
+		}
+		assert.True(t, len(results[1].Values) >= n-(n-1)/3)
+		for j, value := range results[1].Values {
+			assert.Equal(t, []byte{byte(epoch), byte(j)}, value)
+		}
+	}
+
+	stopSubsets(subsets, transports)
+}
+
+// Do nodes keep agreeing for more epochs than the window kept ahead?
+func TestDumboBeyondWindow(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	subsets := startDumboSubsets(t, n, transports, nil)
+	runSubsetEpochs(t, subsets, 20)
+	stopSubsets(subsets, transports)
+}
+
+// Do honest nodes output when one node crashed?
+func TestDumboWithCrashedNode(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	subsets := startDumboSubsets(t, n, transports, map[int]bool{4: true})
+	stopDrain := drain(transports[4])
+
+	for id, s := range subsets {
+		s.Propose(0, []byte{byte(id)})
+	}
+	results := waitSubsets(t, subsets)
+	for id := range subsets {
+		assert.Equal(t, results[1].Values, results[id].Values)
+	}
+	assert.Equal(t, 3, len(results[1].Values))
+	assert.NotContains(t, results[1].Values, 4)
+
+	close(stopDrain)
+	stopSubsets(subsets, transports)
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/crypto"
+	"github.com/stuck/crypto/executor"
+	"github.com/stuck/crypto/tbls/identity"
+)
+
+// Are shares verified correctly on the worker pool?
+func TestExecutorVerifyShare(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	players := make([]*identity.TBLSCryproProvider, 4)
+	for i := range players {
+		players[i] = identity.NewTBLSCryproProvider(4, 2, i)
+	}
+	e := executor.NewExecutor(players[0], 4, 0)
+
+	// byzantine node tampering share
+	msg := []byte("Hello world")
+	futures := make([]*executor.Future, len(players))
+	for i, p := range players {
+		share := p.ComputeShare(msg)
+		if i == 3 {
+			share[0] ^= 0x10
+		}
+		futures[i] = e.VerifyShare(msg, share)
+	}
+	for i, f := range futures {
+		valid, err := f.Result()
+		assert.Nil(t, err)
+		assert.Equal(t, i != 3, valid)
+	}
+
+	// callbacks run on workers
+	results := make(chan bool, 2)
+	shares := [][]byte{players[1].ComputeShare(msg), players[2].ComputeShare(msg)}
+	signature := players[0].Combine(msg, shares)
+	e.VerifySignatureAsync(msg, signature, func(valid bool) { results <- valid })
+	e.VerifySignatureAsync([]byte("Hello byzantine"), signature, func(valid bool) { results <- valid })
+	received := map[bool]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case valid := <-results:
+			received[valid]++
+		case <-time.After(5 * time.Second):
+			t.Fatal("callback is not invoked")
+		}
+	}
+	assert.Equal(t, map[bool]int{true: 1, false: 1}, received)
+
+	e.Stop()
+
+	// tasks submitted after stop fail immediately
+	valid, err := e.VerifyShare(msg, shares[0]).Result()
+	assert.Equal(t, false, valid)
+	assert.Equal(t, executor.ErrStopped, err)
+}
+
+// Provider whose verifications wait until released
+type blockingProvider struct {
+	crypto.CryptoProvider
+	release chan struct{}
+}
+
+func (b *blockingProvider) VerifyShare(data []byte, share []byte) bool {
+	<-b.release
+	return true
+}
+
+// Does a full queue block the caller until a worker takes a task?
+func TestExecutorBoundedQueue(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	cp := &blockingProvider{release: make(chan struct{})}
+	e := executor.NewExecutor(cp, 1, 1)
+
+	// one task runs, one waits and the third cannot be queued
+	first := e.VerifyShare(nil, nil)
+	for e.Pending() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	e.VerifyShare(nil, nil)
+	submitted := make(chan *executor.Future)
+	go func() {
+		submitted <- e.VerifyShare(nil, nil)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("submitted to a full queue")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, 1, e.Pending())
+
+	cp.release <- struct{}{}
+	valid, err := first.Result()
+	assert.True(t, valid)
+	assert.Nil(t, err)
+	var third *executor.Future
+	select {
+	case third = <-submitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("submit still blocked")
+	}
+
+	// stopping unblocks the running task, queued ones fail
+	close(cp.release)
+	e.Stop()
+	_, err = third.Result()
+	if err != nil {
+		assert.Equal(t, executor.ErrStopped, err)
+	}
+}
@@ -0,0 +1,455 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus"
+	"github.com/stuck/consensus/hbbft"
+	"github.com/stuck/crypto/tbls/identity"
+	"github.com/stuck/mempool"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+	"google.golang.org/protobuf/proto"
+)
+
+func startHoneyBadgers(n int, transports map[int]transport.Transport, crashed map[int]bool) map[int]*hbbft.HoneyBadger {
+	replicas := make(map[int]*hbbft.HoneyBadger, n)
+	for id, tp := range transports {
+		if crashed[id] {
+			continue
+		}
+		cfg := hbbft.Config{N: n, ID: id, BatchSize: 8}
+		replicas[id] = hbbft.NewHoneyBadger(cfg, tp, identity.NewTBLSCryproProvider(n, 2, id-1))
+	}
+	return replicas
+}
+
+// Blocks of HoneyBadgerBFT are numbered by epoch from 0
+var hbLayout = blockLayout[hbbft.Block]{
+	txs: func(b hbbft.Block) [][]byte { return b.Txs },
+	pos: func(b hbbft.Block) uint64 { return b.Epoch },
+}
+
+func stopHoneyBadgers(replicas map[int]*hbbft.HoneyBadger, transports map[int]transport.Transport) {
+	for _, hb := range replicas {
+		hb.Stop()
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Do all replicas commit every transaction in the same order?
+func TestHoneyBadgerTotalOrder(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	replicas := startHoneyBadgers(n, transports, nil)
+
+	// every replica receives all transactions, as clients submit to many replicas
+	txs := 12
+	for i := 0; i < txs; i++ {
+		for _, hb := range replicas {
+			hb.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+
+	logs := make(map[int][]hbbft.Block, n)
+	for id, hb := range replicas {
+		logs[id] = collect(t, hb, hbLayout, txs)
+	}
+	for id := 1; id <= n; id++ {
+		assert.Equal(t, logs[1], logs[id])
+	}
+	for i, b := range logs[1] {
+		assert.Equal(t, uint64(i), b.Epoch)
+	}
+
+	stopHoneyBadgers(replicas, transports)
+}
+
+// Is a transaction submitted to a single replica committed with a crashed node?
+func TestHoneyBadgerWithCrashedNode(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	replicas := startHoneyBadgers(n, transports, map[int]bool{4: true})
+	stopDrain := drain(transports[4])
+
+	replicas[2].Submit([]byte("only-on-2"))
+	logs := make(map[int][]hbbft.Block, n)
+	for id, hb := range replicas {
+		logs[id] = collect(t, hb, hbLayout, 1)
+	}
+	for id := range replicas {
+		assert.Equal(t, logs[1], logs[id])
+	}
+	assert.Equal(t, []byte("only-on-2"), logs[1][len(logs[1])-1].Txs[0])
+
+	close(stopDrain)
+	stopHoneyBadgers(replicas, transports)
+}
+
+// Is a transaction resubmitted after its commit, to replicas that never
+// held it, committed only once?
+func TestHoneyBadgerCommitOnce(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	replicas := startHoneyBadgers(n, transports, nil)
+
+	wait := collectAll(t, replicas, hbLayout, 1)
+	replicas[1].Submit([]byte("once"))
+	logs := wait()
+	settle(replicas, logs)
+
+	wait = collectAll(t, replicas, hbLayout, 1)
+	for id, hb := range replicas {
+		hb.Submit([]byte("once"))
+		hb.Submit([]byte(fmt.Sprintf("next-%d", id)))
+	}
+	after := wait()
+	settle(replicas, after)
+	for id := range replicas {
+		logs[id] = append(logs[id], after[id]...)
+	}
+	assertSameLog(t, logs, hbLayout)
+	count := 0
+	for _, b := range logs[1] {
+		for _, tx := range b.Txs {
+			if string(tx) == "once" {
+				count++
+			}
+		}
+	}
+	assert.Equal(t, 1, count)
+
+	stopHoneyBadgers(replicas, transports)
+}
+
+// Is a transaction the mempool has no room for rejected by Submit?
+func TestHoneyBadgerMempoolFull(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	stopDrains := make([]chan struct{}, 0, n-1)
+	for id := 2; id <= n; id++ {
+		stopDrains = append(stopDrains, drain(transports[id]))
+	}
+	// the others are down, nothing is committed
+	cfg := hbbft.Config{N: n, ID: 1, BatchSize: 8, Mempool: mempool.Config{MaxTxs: 2, MaxTxBytes: 8}}
+	hb := hbbft.NewHoneyBadger(cfg, transports[1], identity.NewTBLSCryproProvider(n, 2, 0))
+
+	assert.ErrorIs(t, hb.Submit([]byte("too large")), mempool.ErrTooLarge)
+	assert.NoError(t, hb.Submit([]byte("a")))
+	assert.NoError(t, hb.Submit([]byte("b")))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := hb.Submit([]byte("c"))
+		if errors.Is(err, mempool.ErrFull) {
+			break
+		}
+		assert.NoError(t, err)
+		if time.Now().After(deadline) {
+			t.Fatalf("mempool does not fill up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hb.Stop()
+	for _, stopCh := range stopDrains {
+		close(stopCh)
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Are epochs beyond the window, over the sender quota or below the stable
+// one dropped?
+func TestEpochGuardLimits(t *testing.T) {
+	guard := consensus.NewEpochGuard(consensus.EpochLimits{Window: 2, PerSender: 3})
+	msg := &pb.HBDecShare{Share: []byte("share")}
+
+	assert.Equal(t, true, guard.Admit(0, 1, msg))
+	assert.Equal(t, false, guard.Admit(3, 1, msg))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, true, guard.Admit(2, 1, msg))
+	}
+	assert.Equal(t, false, guard.Admit(2, 1, msg))
+	assert.Equal(t, true, guard.Admit(2, 2, msg))
+
+	// messages of the current epoch no longer count against the quota
+	guard.Advance(2)
+	assert.Equal(t, true, guard.Admit(4, 1, msg))
+	assert.Equal(t, true, guard.Admit(2, 1, msg))
+
+	assert.Equal(t, true, guard.Stabilize(2))
+	assert.Equal(t, false, guard.Stabilize(1))
+	assert.Equal(t, false, guard.Admit(1, 1, msg))
+	size := proto.Size(msg)
+	assert.Equal(t, []consensus.EpochUsage{
+		{Epoch: 2, Messages: 5, Bytes: 5 * size},
+		{Epoch: 4, Messages: 1, Bytes: size},
+	}, guard.Usage())
+}
+
+// Are stable epochs dropped and far future epochs never kept?
+func TestHoneyBadgerStableEpochs(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	replicas := startHoneyBadgers(n, transports, map[int]bool{4: true})
+	stopDrain := drain(transports[4])
+
+	// byzantine node 4 sends shares for an epoch nobody reached
+	for id := 1; id < n; id++ {
+		sendRaw(t, transports[4], id, &pb.HBDecShare{Sender: 4, Epoch: 1000, Proposer: 1, Share: []byte("share")})
+	}
+	txs := 6
+	for i := 0; i < txs; i++ {
+		for _, hb := range replicas {
+			hb.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+	logs := make(map[int][]hbbft.Block, n)
+	for id, hb := range replicas {
+		logs[id] = collect(t, hb, hbLayout, txs)
+	}
+
+	// the next commit drops every epoch before it
+	stable := logs[1][len(logs[1])-1].Epoch + 1
+	for _, hb := range replicas {
+		hb.Stable(stable)
+	}
+	for _, hb := range replicas {
+		hb.Submit([]byte("after"))
+	}
+	for _, hb := range replicas {
+		collect(t, hb, hbLayout, 1)
+	}
+	for _, hb := range replicas {
+		usage := hb.Usage()
+		assert.NotEmpty(t, usage)
+		for _, u := range usage {
+			assert.GreaterOrEqual(t, u.Epoch, stable)
+			assert.Less(t, u.Epoch, uint64(1000))
+		}
+	}
+
+	close(stopDrain)
+	stopHoneyBadgers(replicas, transports)
+}
+
+// Does a restarted replica commit its blocks again and keep committing
+// after them?
+func TestHoneyBadgerRestartFromWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *hbbft.HoneyBadger {
+		cfg := hbbft.Config{N: n, ID: id, BatchSize: 8, WAL: w}
+		return hbbft.NewHoneyBadger(cfg, transports[id], identity.NewTBLSCryproProvider(n, 2, id-1))
+	}
+	wals := make(map[int]*wal.WAL, n)
+	replicas := make(map[int]*hbbft.HoneyBadger, n)
+	for id := range transports {
+		wals[id] = open(id)
+		replicas[id] = start(id, wals[id])
+	}
+	submit := func(from, to int) {
+		for i := from; i < to; i++ {
+			for _, hb := range replicas {
+				hb.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+			}
+		}
+	}
+
+	txs := 12
+	wait := collectAll(t, replicas, hbLayout, txs)
+	submit(0, txs)
+	logs := wait()
+	settle(replicas, logs)
+	assertSameLog(t, logs, hbLayout)
+
+	// node 4 crashes once idle and restarts on the same transport
+	replicas[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	replicas[4] = start(4, wals[4])
+	for _, b := range logs[4] {
+		select {
+		case replayed := <-replicas[4].Commit():
+			assert.Equal(t, b, replayed)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("node 4 does not replay epoch %d", b.Epoch)
+		}
+	}
+
+	wait = collectAll(t, replicas, hbLayout, txs)
+	submit(txs, 2*txs)
+	after := wait()
+	for id := range replicas {
+		logs[id] = append(logs[id], after[id]...)
+	}
+	assertSameLog(t, logs, hbLayout)
+
+	stopHoneyBadgers(replicas, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
+
+// Is the log truncated below a stable epoch, and does a restarted replica
+// only commit the blocks left in it again?
+func TestHoneyBadgerTruncatedWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{SegmentSize: 256, NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *hbbft.HoneyBadger {
+		cfg := hbbft.Config{N: n, ID: id, BatchSize: 4, Committed: 1, WAL: w}
+		return hbbft.NewHoneyBadger(cfg, transports[id], identity.NewTBLSCryproProvider(n, 2, id-1))
+	}
+	wals := make(map[int]*wal.WAL, n)
+	replicas := make(map[int]*hbbft.HoneyBadger, n)
+	for id := range transports {
+		wals[id] = open(id)
+		replicas[id] = start(id, wals[id])
+	}
+	submit := func(from, to int) {
+		for i := from; i < to; i++ {
+			for _, hb := range replicas {
+				hb.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+			}
+		}
+	}
+
+	txs := 16
+	wait := collectAll(t, replicas, hbLayout, txs)
+	submit(0, txs)
+	logs := wait()
+	settle(replicas, logs)
+	assertSameLog(t, logs, hbLayout)
+	replicas[4].Stable(logs[4][len(logs[4])-1].Epoch + 1)
+	waitTruncated(t, filepath.Join(dir, "4"))
+
+	replicas[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	replicas[4] = start(4, wals[4])
+	replayed := map[int][]hbbft.Block{4: nil}
+	settle(map[int]*hbbft.HoneyBadger{4: replicas[4]}, replayed)
+	assert.NotEmpty(t, replayed[4])
+	assert.True(t, len(replayed[4]) < len(logs[4]))
+	assert.Equal(t, logs[4][len(logs[4])-len(replayed[4]):], replayed[4])
+
+	wait = collectAll(t, replicas, hbLayout, txs)
+	submit(txs, 2*txs)
+	after := wait()
+	for id := range replicas {
+		logs[id] = append(logs[id], after[id]...)
+	}
+	assertSameLog(t, logs, hbLayout)
+
+	stopHoneyBadgers(replicas, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
+
+// Does a replica crashing in the middle of an epoch commit that epoch
+// after its restart, with the same blocks as the others?
+func TestHoneyBadgerRestartMidEpoch(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	gate := newGatedTransport(transports[4], 30)
+	transports[4] = gate
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *hbbft.HoneyBadger {
+		cfg := hbbft.Config{N: n, ID: id, BatchSize: 8, WAL: w}
+		return hbbft.NewHoneyBadger(cfg, transports[id], identity.NewTBLSCryproProvider(n, 2, id-1))
+	}
+	wals := make(map[int]*wal.WAL, n)
+	replicas := make(map[int]*hbbft.HoneyBadger, n)
+	for id := range transports {
+		wals[id] = open(id)
+		replicas[id] = start(id, wals[id])
+	}
+
+	// node 4 proposes and votes in epoch 0, then is cut off and crashes
+	txs := 12
+	others := map[int]*hbbft.HoneyBadger{1: replicas[1], 2: replicas[2], 3: replicas[3]}
+	wait := collectAll(t, others, hbLayout, txs)
+	for i := 0; i < txs; i++ {
+		for _, hb := range replicas {
+			hb.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+	select {
+	case <-gate.passedCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("node 4 does not take part in epoch 0")
+	}
+	logs := wait()
+	settle(others, logs)
+	select {
+	case b := <-replicas[4].Commit():
+		t.Fatalf("node 4 committed epoch %d while cut off", b.Epoch)
+	default:
+	}
+	replicas[4].Stop()
+	assert.Nil(t, wals[4].Close())
+
+	// it re-enters epoch 0 and catches up on the held messages
+	wals[4] = open(4)
+	replicas[4] = start(4, wals[4])
+	gate.Open()
+	logs[4] = collect(t, replicas[4], hbLayout, txs)
+	assert.Equal(t, uint64(0), logs[4][0].Epoch)
+	assertSameLog(t, logs, hbLayout)
+
+	stopHoneyBadgers(replicas, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
@@ -0,0 +1,234 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus/hotstuff"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+)
+
+// QCs are made of n-f votes
+func startHotStuffs(t *testing.T, n int, transports map[int]transport.Transport, crashed map[int]bool) map[int]*hotstuff.HotStuff {
+	providers := generateProviders(t, n, n-(n-1)/3)
+	replicas := make(map[int]*hotstuff.HotStuff, n)
+	for id, tp := range transports {
+		if crashed[id] {
+			continue
+		}
+		cfg := hotstuff.Config{N: n, ID: id, BatchSize: 4, Timeout: 300 * time.Millisecond}
+		replicas[id] = hotstuff.NewHotStuff(cfg, tp, providers[id-1])
+	}
+	return replicas
+}
+
+// Blocks of HotStuff are numbered by height from 1
+var hsLayout = blockLayout[hotstuff.Block]{
+	txs:   func(b hotstuff.Block) [][]byte { return b.Txs },
+	pos:   func(b hotstuff.Block) uint64 { return b.Height },
+	first: 1,
+}
+
+func stopHotStuffs(replicas map[int]*hotstuff.HotStuff, transports map[int]transport.Transport) {
+	for _, hs := range replicas {
+		hs.Stop()
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Do all replicas commit every transaction in the same order?
+func TestHotStuffTotalOrder(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	replicas := startHotStuffs(t, n, transports, nil)
+
+	txs := 12
+	for i := 0; i < txs; i++ {
+		for _, hs := range replicas {
+			hs.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+
+	chains := make(map[int][]hotstuff.Block, n)
+	for id, hs := range replicas {
+		chains[id] = collect(t, hs, hsLayout, txs)
+	}
+	assertSameLog(t, chains, hsLayout)
+
+	stopHotStuffs(replicas, transports)
+}
+
+// Do views led by a crashed node time out while the others keep committing?
+func TestHotStuffWithCrashedLeader(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	replicas := startHotStuffs(t, n, transports, map[int]bool{1: true})
+	stopDrain := drain(transports[1])
+
+	txs := 20
+	for i := 0; i < txs; i++ {
+		for _, hs := range replicas {
+			hs.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+
+	chains := make(map[int][]hotstuff.Block, n)
+	for id, hs := range replicas {
+		chains[id] = collect(t, hs, hsLayout, txs)
+	}
+	assertSameLog(t, chains, hsLayout)
+
+	close(stopDrain)
+	stopHotStuffs(replicas, transports)
+}
+
+// Does a restarted replica replay its chain and keep committing after it?
+func TestHotStuffRestartFromWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	providers := generateProviders(t, n, n-(n-1)/3)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *hotstuff.HotStuff {
+		cfg := hotstuff.Config{N: n, ID: id, BatchSize: 4, Timeout: 300 * time.Millisecond, WAL: w}
+		return hotstuff.NewHotStuff(cfg, transports[id], providers[id-1])
+	}
+	wals := make(map[int]*wal.WAL, n)
+	replicas := make(map[int]*hotstuff.HotStuff, n)
+	for id := range transports {
+		wals[id] = open(id)
+		replicas[id] = start(id, wals[id])
+	}
+	submit := func(from, to int) {
+		for i := from; i < to; i++ {
+			for _, hs := range replicas {
+				hs.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+			}
+		}
+	}
+
+	txs := 12
+	wait := collectAll(t, replicas, hsLayout, txs)
+	submit(0, txs)
+	chains := wait()
+	assertSameLog(t, chains, hsLayout)
+
+	// node 4 restarts on the same transport and delivers its chain again
+	replicas[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	replicas[4] = start(4, wals[4])
+	for _, b := range chains[4] {
+		select {
+		case replayed := <-replicas[4].Commit():
+			assert.Equal(t, b, replayed)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("node 4 does not replay block %d", b.Height)
+		}
+	}
+
+	wait = collectAll(t, replicas, hsLayout, txs)
+	submit(txs, 2*txs)
+	after := wait()
+	for id := range replicas {
+		chains[id] = append(chains[id], after[id]...)
+	}
+	assertSameLog(t, chains, hsLayout)
+
+	stopHotStuffs(replicas, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
+
+// Is the log compacted, and does a restarted replica only deliver the
+// blocks left in it and keep committing after them?
+func TestHotStuffCompactedWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	providers := generateProviders(t, n, n-(n-1)/3)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{SegmentSize: 256, NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *hotstuff.HotStuff {
+		cfg := hotstuff.Config{N: n, ID: id, BatchSize: 2, Timeout: 300 * time.Millisecond, CompactInterval: 2, WAL: w}
+		return hotstuff.NewHotStuff(cfg, transports[id], providers[id-1])
+	}
+	wals := make(map[int]*wal.WAL, n)
+	replicas := make(map[int]*hotstuff.HotStuff, n)
+	for id := range transports {
+		wals[id] = open(id)
+		replicas[id] = start(id, wals[id])
+	}
+	submit := func(from, to int) {
+		for i := from; i < to; i++ {
+			for _, hs := range replicas {
+				hs.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+			}
+		}
+	}
+
+	txs := 16
+	wait := collectAll(t, replicas, hsLayout, txs)
+	submit(0, txs)
+	chains := wait()
+	assertSameLog(t, chains, hsLayout)
+	waitTruncated(t, filepath.Join(dir, "4"))
+
+	// the replay starts after the first block and follows the chain
+	replicas[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	replicas[4] = start(4, wals[4])
+	last := chains[4][len(chains[4])-1].Height
+	for height := uint64(0); height < last; {
+		select {
+		case b := <-replicas[4].Commit():
+			assert.True(t, b.Height > 1)
+			assert.Equal(t, chains[4][b.Height-1], b)
+			height = b.Height
+		case <-time.After(5 * time.Second):
+			t.Fatalf("node 4 does not replay block %d", last)
+		}
+	}
+
+	wait = collectAll(t, replicas, hsLayout, txs)
+	submit(txs, 2*txs)
+	after := wait()
+	for id := range replicas {
+		chains[id] = append(chains[id], after[id]...)
+	}
+	assertSameLog(t, chains, hsLayout)
+
+	stopHotStuffs(replicas, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
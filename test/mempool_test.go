@@ -0,0 +1,118 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/mempool"
+)
+
+// Are transactions kept once each and in arrival order?
+func TestMempoolDeduplication(t *testing.T) {
+	mp := mempool.NewMempool(mempool.Config{})
+	assert.NoError(t, mp.Add([]byte("tx-1")))
+	assert.NoError(t, mp.Add([]byte("tx-2")))
+	assert.ErrorIs(t, mp.Add([]byte("tx-1")), mempool.ErrDuplicate)
+	assert.Equal(t, 2, mp.Len())
+	assert.Equal(t, 8, mp.Bytes())
+	assert.True(t, mp.Has([]byte("tx-2")))
+	assert.Equal(t, [][]byte{[]byte("tx-1"), []byte("tx-2")}, mp.Oldest(10))
+
+	// committed transactions leave, unknown ones are ignored
+	assert.Equal(t, 1, mp.Remove([][]byte{[]byte("tx-1"), []byte("tx-3")}))
+	assert.False(t, mp.Has([]byte("tx-1")))
+	assert.Equal(t, [][]byte{[]byte("tx-2")}, mp.Oldest(10))
+	assert.NoError(t, mp.Add([]byte("tx-1")))
+}
+
+// Are the count, size and admission limits enforced?
+func TestMempoolLimits(t *testing.T) {
+	mp := mempool.NewMempool(mempool.Config{MaxTxs: 2})
+	assert.NoError(t, mp.Add([]byte("a")))
+	assert.NoError(t, mp.Fits([]byte("b")))
+	assert.NoError(t, mp.Add([]byte("b")))
+	assert.ErrorIs(t, mp.Fits([]byte("c")), mempool.ErrFull)
+	assert.ErrorIs(t, mp.Add([]byte("c")), mempool.ErrFull)
+	assert.Equal(t, 2, mp.Len())
+
+	mp = mempool.NewMempool(mempool.Config{MaxBytes: 5, MaxTxBytes: 3})
+	assert.ErrorIs(t, mp.Fits([]byte("abcd")), mempool.ErrTooLarge)
+	assert.ErrorIs(t, mp.Add([]byte("abcd")), mempool.ErrTooLarge)
+	assert.NoError(t, mp.Add([]byte("abc")))
+	assert.ErrorIs(t, mp.Add([]byte("def")), mempool.ErrFull)
+	assert.NoError(t, mp.Add([]byte("de")))
+
+	errOdd := errors.New("odd length")
+	check := func(tx []byte) error {
+		if len(tx)%2 == 1 {
+			return errOdd
+		}
+		return nil
+	}
+	mp = mempool.NewMempool(mempool.Config{Check: check})
+	assert.ErrorIs(t, mp.Add([]byte("a")), errOdd)
+	assert.NoError(t, mp.Add([]byte("ab")))
+	assert.Equal(t, 1, mp.Len())
+}
+
+// Are old transactions evicted, also to make room for new ones?
+func TestMempoolEviction(t *testing.T) {
+	mp := mempool.NewMempool(mempool.Config{MaxTxs: 2, TTL: 50 * time.Millisecond})
+	assert.NoError(t, mp.Add([]byte("a")))
+	assert.NoError(t, mp.Add([]byte("b")))
+	assert.Equal(t, 0, mp.Evict())
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, mp.Add([]byte("c")))
+	assert.Equal(t, [][]byte{[]byte("c")}, mp.Oldest(10))
+	assert.Equal(t, 1, mp.Bytes())
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, mp.Evict())
+	assert.Equal(t, 0, mp.Len())
+}
+
+// Are samples distinct transactions among the oldest candidates?
+func TestMempoolSample(t *testing.T) {
+	mp := mempool.NewMempool(mempool.Config{})
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, mp.Add([]byte(fmt.Sprintf("tx-%02d", i))))
+	}
+	candidates := make(map[string]bool)
+	for _, tx := range mp.Oldest(8) {
+		candidates[string(tx)] = true
+	}
+
+	for i := 0; i < 10; i++ {
+		sample := mp.Sample(8, 3)
+		assert.Len(t, sample, 3)
+		seen := make(map[string]bool)
+		for _, tx := range sample {
+			assert.True(t, candidates[string(tx)])
+			assert.False(t, seen[string(tx)])
+			seen[string(tx)] = true
+		}
+	}
+	assert.Len(t, mp.Sample(30, 25), 20)
+	assert.Equal(t, 20, mp.Len())
+}
+
+// Are the transactions of the last blocks remembered and older ones
+// forgotten?
+func TestMempoolCommitted(t *testing.T) {
+	c := mempool.NewCommitted(2)
+	c.Add([][]byte{[]byte("a"), []byte("b")})
+	c.Add([][]byte{[]byte("b")})
+	assert.True(t, c.Has([]byte("a")))
+	assert.False(t, c.Has([]byte("c")))
+
+	// the first block is forgotten, b is still in the second
+	c.Add([][]byte{[]byte("c")})
+	assert.False(t, c.Has([]byte("a")))
+	assert.True(t, c.Has([]byte("b")))
+	c.Add(nil)
+	assert.False(t, c.Has([]byte("b")))
+	assert.True(t, c.Has([]byte("c")))
+}
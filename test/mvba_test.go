@@ -0,0 +1,158 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus/mvba"
+	"github.com/stuck/consensus/provable"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+)
+
+// Values are valid when they start with "ok"
+func validMVBAValue(value []byte) bool {
+	return bytes.HasPrefix(value, []byte("ok"))
+}
+
+// MVBA proofs need threshold n-f
+func startMVBAs(t *testing.T, n int, transports map[int]transport.Transport, crashed map[int]bool) map[int]*mvba.Agreement {
+	providers := generateProviders(t, n, n-(n-1)/3)
+	agreements := make(map[int]*mvba.Agreement, n)
+	for id, tp := range transports {
+		if crashed[id] {
+			continue
+		}
+		agreements[id] = mvba.NewAgreement(n, id, tp, providers[id-1], validMVBAValue)
+	}
+	return agreements
+}
+
+func stopMVBAs(agreements map[int]*mvba.Agreement, transports map[int]transport.Transport) {
+	for _, ag := range agreements {
+		ag.Stop()
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+func waitMVBAs(t *testing.T, agreements map[int]*mvba.Agreement) map[int]mvba.Decision {
+	decisions := make(map[int]mvba.Decision, len(agreements))
+	for id, ag := range agreements {
+		select {
+		case d := <-ag.Decide():
+			decisions[id] = d
+		case <-time.After(10 * time.Second):
+			t.Fatalf("node %d does not decide", id)
+		}
+	}
+	return decisions
+}
+
+// Do all nodes decide the same proposed value?
+func TestMVBAAgreement(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	agreements := startMVBAs(t, n, transports, nil)
+
+	for epoch := uint64(0); epoch < 2; epoch++ {
+		proposals := make(map[string]bool, n)
+		for id, ag := range agreements {
+			value := []byte{'o', 'k', byte(epoch), byte(id)}
+			proposals[string(value)] = true
+			ag.Propose(epoch, value)
+		}
+		decisions := waitMVBAs(t, agreements)
+		for id := 1; id <= n; id++ {
+			assert.Equal(t, epoch, decisions[id].Epoch)
+			assert.Equal(t, decisions[1].Value, decisions[id].Value)
+		}
+		assert.True(t, proposals[string(decisions[1].Value)])
+	}
+
+	stopMVBAs(agreements, transports)
+}
+
+// Do honest nodes decide when one node crashed?
+func TestMVBAWithCrashedNode(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	agreements := startMVBAs(t, n, transports, map[int]bool{4: true})
+	stopDrain := drain(transports[4])
+
+	for id, ag := range agreements {
+		ag.Propose(0, []byte{'o', 'k', byte(id)})
+	}
+	decisions := waitMVBAs(t, agreements)
+	for id := range agreements {
+		assert.Equal(t, decisions[1].Value, decisions[id].Value)
+	}
+	assert.True(t, validMVBAValue(decisions[1].Value))
+
+	close(stopDrain)
+	stopMVBAs(agreements, transports)
+}
+
+// Are invalid values never signed nor decided?
+func TestMVBAExternalValidity(t *testing.T) {
+	n := 4
+	providers := generateProviders(t, n, 3)
+	net := &recordingNetwork{}
+
+	m := mvba.NewMVBA(n, 1, 0, net, providers[0], validMVBAValue)
+	assert.Equal(t, mvba.ErrInvalidValue, m.Input([]byte("bad")))
+	assert.False(t, m.Proposed())
+
+	// an invalid proposal of node 2 gets no signature share
+	err := m.HandleMessage(&pb.PBMsg{Type: pb.PBType_PBSend, Sender: 2, Proposer: 2, Value: []byte("bad")})
+	assert.True(t, errors.Is(err, provable.ErrInvalidValue))
+	assert.Empty(t, net.sends[2])
+
+	// a valid one does
+	err = m.HandleMessage(&pb.PBMsg{Type: pb.PBType_PBSend, Sender: 3, Proposer: 3, Value: []byte("ok")})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(net.sends[3]))
+
+	// and a forged proof of delivery is rejected
+	err = m.HandleMessage(&pb.MVBAMsg{Type: pb.MVBAType_MVBAFinish, Sender: 3, Proposer: 3, Value: []byte("ok"), Proof: []byte("forged")})
+	assert.True(t, errors.Is(err, mvba.ErrInvalidProof))
+}
+
+// Are instances far ahead never created, and dropped once stable?
+func TestMVBAAgreementWindow(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	ag := mvba.NewAgreement(n, 2, transports[2], generateProviders(t, n, 3)[1], validMVBAValue)
+	stopDrains := []chan struct{}{drain(transports[1]), drain(transports[3]), drain(transports[4])}
+
+	byzantine := transports[1]
+	sendRaw(t, byzantine, 2, &pb.MVBAMsg{Type: pb.MVBAType_MVBAVote, Sender: 1, Epoch: 100, Proposer: 3})
+	sendRaw(t, byzantine, 2, &pb.MVBAMsg{Type: pb.MVBAType_MVBAVote, Sender: 1, Epoch: 3, Proposer: 3})
+	waitUsage(t, ag.Usage, 3)
+	ag.Stable(4)
+	waitUsage(t, ag.Usage)
+
+	// messages below the stable epoch are garbage
+	sendRaw(t, byzantine, 2, &pb.MVBAMsg{Type: pb.MVBAType_MVBAVote, Sender: 1, Epoch: 3, Proposer: 3})
+	sendRaw(t, byzantine, 2, &pb.MVBAMsg{Type: pb.MVBAType_MVBAVote, Sender: 1, Epoch: 5, Proposer: 3})
+	waitUsage(t, ag.Usage, 5)
+
+	ag.Stop()
+	for _, stopDrain := range stopDrains {
+		close(stopDrain)
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
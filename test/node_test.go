@@ -0,0 +1,249 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/app/kvstore"
+	"github.com/stuck/blockstore"
+	"github.com/stuck/crypto/tbls/decode"
+	"github.com/stuck/crypto/tbls/key"
+	"github.com/stuck/node"
+)
+
+// Addresses on local ports nobody listens on right now
+func freeAddrs(t *testing.T, n int) []string {
+	addrs := make([]string, n)
+	for i := range addrs {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		addrs[i] = listener.Addr().String()
+		listener.Close()
+	}
+	return addrs
+}
+
+// Write a key set of threshold t into dir
+func writeKeySet(t *testing.T, dir string, n, threshold int) {
+	priShares, pubShares, err := key.Generate(n, threshold)
+	assert.Nil(t, err)
+	priBytes, err := json.Marshal(priShares)
+	assert.Nil(t, err)
+	pubBytes, err := json.Marshal(pubShares)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, decode.PriKeyFile), priBytes, 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, decode.PubKeyFile), pubBytes, 0644))
+}
+
+// Write a key set, a config file and certificate per node into dir, the
+// paths in the configs are relative to it
+func writeNodeConfigs(t *testing.T, dir string, n int, protocol string) []string {
+	threshold := node.Threshold(protocol, n)
+	writeKeySet(t, dir, n, threshold)
+	addrs := freeAddrs(t, n)
+	peers := make([]node.PeerConfig, n)
+	for i := range peers {
+		certPEM, keyPEM := selfSignedCert(t)
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("cert%d.pem", i+1)), certPEM, 0644))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("key%d.pem", i+1)), keyPEM, 0600))
+		peers[i] = node.PeerConfig{ID: i + 1, Addr: addrs[i], Cert: fmt.Sprintf("cert%d.pem", i+1)}
+	}
+	paths := make([]string, n)
+	for i := range paths {
+		cfg := node.Config{
+			ID:             i + 1,
+			N:              n,
+			T:              threshold,
+			Listen:         addrs[i],
+			Cert:           fmt.Sprintf("cert%d.pem", i+1),
+			Key:            fmt.Sprintf("key%d.pem", i+1),
+			TBLSPrivateKey: decode.PriKeyFile,
+			TBLSPublicKey:  decode.PubKeyFile,
+			Peers:          peers,
+			Protocol:       protocol,
+			BatchSize:      8,
+		}
+		data, err := json.Marshal(cfg)
+		assert.Nil(t, err)
+		paths[i] = filepath.Join(dir, fmt.Sprintf("node%d.json", i+1))
+		assert.Nil(t, ioutil.WriteFile(paths[i], data, 0644))
+	}
+	return paths
+}
+
+// Do nodes built from config files order transactions over TLS?
+func TestNodeFromConfig(t *testing.T) {
+	for _, protocol := range []string{node.ProtocolHoneyBadger, node.ProtocolPBFT, node.ProtocolHotStuff} {
+		t.Run(protocol, func(t *testing.T) {
+			// is there a goroutine leak?
+			defer leaktest.CheckTimeout(t, 10*time.Second)()
+			dir, err := ioutil.TempDir("", "node")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+
+			nodes := make([]*node.Node, 0, 4)
+			defer func() {
+				for _, n := range nodes {
+					n.Stop()
+				}
+			}()
+			for _, path := range writeNodeConfigs(t, dir, 4, protocol) {
+				cfg, err := node.LoadConfig(path)
+				assert.Nil(t, err)
+				n, err := node.New(cfg)
+				if !assert.Nil(t, err) {
+					return
+				}
+				nodes = append(nodes, n)
+			}
+			for i := 0; i < 8; i++ {
+				for _, n := range nodes {
+					assert.Nil(t, n.Submit([]byte(fmt.Sprintf("key-%d=%d", i, i))))
+				}
+			}
+			assert.ErrorIs(t, nodes[0].Submit([]byte("malformed")), kvstore.ErrMalformedTx)
+
+			deadline := time.Now().Add(30 * time.Second)
+			for _, n := range nodes {
+				for {
+					if _, err := n.Query(kvstore.PathKey, []byte("key-7")); err == nil {
+						break
+					}
+					if time.Now().After(deadline) {
+						t.Fatalf("transactions not applied, height %d", n.Height())
+					}
+					time.Sleep(20 * time.Millisecond)
+				}
+			}
+		})
+	}
+}
+
+// Do nodes with a data dir certify checkpoints and store the same
+// certified blocks, and does a restarted node keep them?
+func TestNodeStoresBlocks(t *testing.T) {
+	for _, protocol := range []string{node.ProtocolHoneyBadger, node.ProtocolPBFT, node.ProtocolHotStuff} {
+		t.Run(protocol, func(t *testing.T) {
+			// is there a goroutine leak?
+			defer leaktest.CheckTimeout(t, 10*time.Second)()
+			dir, err := ioutil.TempDir("", "node")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+
+			cfgs := make([]node.Config, 0, 4)
+			nodes := make([]*node.Node, 0, 4)
+			defer func() {
+				for _, n := range nodes {
+					n.Stop()
+				}
+			}()
+			for _, path := range writeNodeConfigs(t, dir, 4, protocol) {
+				cfg, err := node.LoadConfig(path)
+				assert.Nil(t, err)
+				cfg.DataDir = filepath.Join(dir, fmt.Sprintf("data%d", cfg.ID))
+				n, err := node.New(cfg)
+				if !assert.Nil(t, err) {
+					return
+				}
+				cfgs = append(cfgs, cfg)
+				nodes = append(nodes, n)
+			}
+
+			// blocks keep coming until every node certified a checkpoint
+			deadline := time.Now().Add(60 * time.Second)
+			for i := 0; ; i++ {
+				certified := true
+				for _, n := range nodes {
+					certified = certified && n.Checkpoint() != nil
+				}
+				if certified {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("no checkpoint certified, height %d", nodes[0].Height())
+				}
+				for _, n := range nodes {
+					assert.Nil(t, n.Submit([]byte(fmt.Sprintf("key-%d=%d", i, i))))
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			// a block may be certified after the checkpoint above it
+			height := nodes[0].Checkpoint().Height
+			blocks := make([]blockstore.Block, 0, height)
+			for h := uint64(1); h <= height; h++ {
+				b, err := nodes[0].Block(h)
+				for err != nil {
+					if time.Now().After(deadline) {
+						t.Fatalf("block %d not stored, %s", h, err)
+					}
+					time.Sleep(20 * time.Millisecond)
+					b, err = nodes[0].Block(h)
+				}
+				assert.NotEmpty(t, b.Certificate)
+				blocks = append(blocks, b)
+			}
+			waitBlocks := func(n *node.Node) {
+				for _, b := range blocks {
+					for {
+						stored, err := n.Block(b.Height)
+						if err == nil {
+							assert.Equal(t, b, stored)
+							break
+						}
+						if time.Now().After(deadline) {
+							t.Fatalf("block %d not stored", b.Height)
+						}
+						time.Sleep(20 * time.Millisecond)
+					}
+				}
+			}
+			for _, n := range nodes[1:] {
+				waitBlocks(n)
+			}
+
+			// node 4 restarts from its data dir
+			nodes[3].Stop()
+			nodes = nodes[:3]
+			restarted, err := node.New(cfgs[3])
+			if !assert.Nil(t, err) {
+				return
+			}
+			nodes = append(nodes, restarted)
+			waitBlocks(restarted)
+		})
+	}
+}
+
+// Are configs that cannot start a node rejected?
+func TestNodeConfigValidate(t *testing.T) {
+	peers := []node.PeerConfig{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	cfg := node.Config{ID: 1, N: 4, T: 3, Peers: peers, Protocol: node.ProtocolPBFT, ViewTimeout: "2s"}
+	assert.Nil(t, cfg.Validate())
+
+	bad := cfg
+	bad.ID = 5
+	assert.ErrorIs(t, bad.Validate(), node.ErrConfig)
+	bad = cfg
+	bad.T = 2
+	assert.ErrorIs(t, bad.Validate(), node.ErrConfig)
+	bad.Protocol = node.ProtocolHoneyBadger
+	assert.Nil(t, bad.Validate())
+	bad = cfg
+	bad.Protocol = "raft"
+	assert.ErrorIs(t, bad.Validate(), node.ErrConfig)
+	bad = cfg
+	bad.ViewTimeout = "soon"
+	assert.ErrorIs(t, bad.Validate(), node.ErrConfig)
+	bad = cfg
+	bad.Peers = []node.PeerConfig{{ID: 1}, {ID: 2}, {ID: 2}, {ID: 4}}
+	assert.ErrorIs(t, bad.Validate(), node.ErrConfig)
+}
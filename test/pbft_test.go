@@ -0,0 +1,250 @@
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus/pbft"
+	"github.com/stuck/crypto/cache"
+	"github.com/stuck/crypto/executor"
+	"github.com/stuck/transport"
+	"github.com/stuck/wal"
+)
+
+// Certificates are made of n-f shares, checkpoints every two batches so
+// that the window moves during the tests
+func startPBFTs(t *testing.T, n int, transports map[int]transport.Transport, crashed map[int]bool) map[int]*pbft.PBFT {
+	providers := generateProviders(t, n, n-(n-1)/3)
+	replicas := make(map[int]*pbft.PBFT, n)
+	for id, tp := range transports {
+		if crashed[id] {
+			continue
+		}
+		cfg := pbft.Config{N: n, ID: id, BatchSize: 2, Timeout: time.Second, CheckpointInterval: 2}
+		replicas[id] = pbft.NewPBFT(cfg, tp, providers[id-1])
+	}
+	return replicas
+}
+
+// Batches of PBFT are numbered by sequence number from 1
+var pbftLayout = blockLayout[pbft.Block]{
+	txs:   func(b pbft.Block) [][]byte { return b.Txs },
+	pos:   func(b pbft.Block) uint64 { return b.Seq },
+	first: 1,
+}
+
+func stopPBFTs(replicas map[int]*pbft.PBFT, transports map[int]transport.Transport) {
+	for _, p := range replicas {
+		p.Stop()
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Do all replicas execute every transaction in the same order?
+func TestPBFTTotalOrder(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	replicas := startPBFTs(t, n, transports, nil)
+
+	txs := 16
+	wait := collectAll(t, replicas, pbftLayout, txs)
+	for i := 0; i < txs; i++ {
+		for _, p := range replicas {
+			p.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+
+	batches := wait()
+	assertSameLog(t, batches, pbftLayout)
+
+	stopPBFTs(replicas, transports)
+}
+
+// Does a crashed primary get replaced by a view change?
+func TestPBFTWithCrashedPrimary(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	stopDrain := drain(transports[1])
+
+	// view changes are verified on a worker pool behind a cache, every
+	// provider verifies the shares of all nodes
+	providers := generateProviders(t, n, n-(n-1)/3)
+	verifier := executor.NewExecutor(cache.NewCachedCryptoProvider(providers[1], 1024), 4, 0)
+	replicas := make(map[int]*pbft.PBFT, n)
+	for id := 2; id <= n; id++ {
+		cfg := pbft.Config{N: n, ID: id, BatchSize: 2, Timeout: time.Second, CheckpointInterval: 2, Executor: verifier}
+		replicas[id] = pbft.NewPBFT(cfg, transports[id], providers[id-1])
+	}
+
+	txs := 16
+	wait := collectAll(t, replicas, pbftLayout, txs)
+	for i := 0; i < txs; i++ {
+		for _, p := range replicas {
+			p.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+
+	batches := wait()
+	assertSameLog(t, batches, pbftLayout)
+	for _, b := range batches[2] {
+		assert.NotEqual(t, uint64(0), b.View)
+	}
+
+	close(stopDrain)
+	stopPBFTs(replicas, transports)
+	verifier.Stop()
+}
+
+// Does a restarted replica replay its log and keep executing in order?
+func TestPBFTRestartFromWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	providers := generateProviders(t, n, n-(n-1)/3)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *pbft.PBFT {
+		cfg := pbft.Config{N: n, ID: id, BatchSize: 2, Timeout: time.Second, CheckpointInterval: 2, WAL: w}
+		return pbft.NewPBFT(cfg, transports[id], providers[id-1])
+	}
+	wals := make(map[int]*wal.WAL, n)
+	replicas := make(map[int]*pbft.PBFT, n)
+	for id := range transports {
+		wals[id] = open(id)
+		replicas[id] = start(id, wals[id])
+	}
+
+	txs := 8
+	wait := collectAll(t, replicas, pbftLayout, txs)
+	for i := 0; i < txs; i++ {
+		for _, p := range replicas {
+			p.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+	before := wait()
+	settle(replicas, before)
+	assertSameLog(t, before, pbftLayout)
+
+	// node 4 crashes once idle and restarts on the same transport, in
+	// flight messages it consumed are lost without state transfer
+	replicas[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	replicas[4] = start(4, wals[4])
+	for _, b := range before[4] {
+		select {
+		case replayed := <-replicas[4].Commit():
+			assert.Equal(t, b, replayed)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("node 4 does not replay batch %d", b.Seq)
+		}
+	}
+
+	wait = collectAll(t, replicas, pbftLayout, txs)
+	for i := txs; i < 2*txs; i++ {
+		for _, p := range replicas {
+			p.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+	after := wait()
+	batches := make(map[int][]pbft.Block, n)
+	for id := range replicas {
+		batches[id] = append(before[id], after[id]...)
+	}
+	assertSameLog(t, batches, pbftLayout)
+
+	stopPBFTs(replicas, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
+
+// Is the log dropped before stable checkpoints, and does a replica
+// restarting from what is left resume after the checkpoint?
+func TestPBFTTruncatedWAL(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	n := 4
+	transports := initLocalTransports(n)
+	providers := generateProviders(t, n, n-(n-1)/3)
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	open := func(id int) *wal.WAL {
+		w, err := wal.Open(filepath.Join(dir, fmt.Sprint(id)), wal.Options{SegmentSize: 256, NoSync: true})
+		assert.Nil(t, err)
+		return w
+	}
+	start := func(id int, w *wal.WAL) *pbft.PBFT {
+		cfg := pbft.Config{N: n, ID: id, BatchSize: 2, Timeout: time.Second, CheckpointInterval: 2, WAL: w}
+		return pbft.NewPBFT(cfg, transports[id], providers[id-1])
+	}
+	wals := make(map[int]*wal.WAL, n)
+	replicas := make(map[int]*pbft.PBFT, n)
+	for id := range transports {
+		wals[id] = open(id)
+		replicas[id] = start(id, wals[id])
+	}
+
+	txs := 16
+	wait := collectAll(t, replicas, pbftLayout, txs)
+	for i := 0; i < txs; i++ {
+		for _, p := range replicas {
+			p.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+	before := wait()
+	settle(replicas, before)
+	assertSameLog(t, before, pbftLayout)
+
+	// the first segments are gone
+	files, err := ioutil.ReadDir(filepath.Join(dir, "4"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, fmt.Sprintf("%020d.wal", 1), files[0].Name())
+
+	// only the batches after the checkpoint are replayed
+	replicas[4].Stop()
+	assert.Nil(t, wals[4].Close())
+	wals[4] = open(4)
+	replicas[4] = start(4, wals[4])
+	replayed := settleOne(replicas[4])
+	assert.True(t, len(replayed) < len(before[4]))
+	assert.Equal(t, before[4][len(before[4])-len(replayed):], replayed)
+
+	wait = collectAll(t, replicas, pbftLayout, txs)
+	for i := txs; i < 2*txs; i++ {
+		for _, p := range replicas {
+			p.Submit([]byte(fmt.Sprintf("tx-%d", i)))
+		}
+	}
+	after := wait()
+	batches := make(map[int][]pbft.Block, n)
+	for id := range replicas {
+		batches[id] = append(before[id], after[id]...)
+	}
+	assertSameLog(t, batches, pbftLayout)
+
+	stopPBFTs(replicas, transports)
+	for _, w := range wals {
+		assert.Nil(t, w.Close())
+	}
+}
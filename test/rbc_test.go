@@ -0,0 +1,231 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/consensus"
+	"github.com/stuck/consensus/rbc"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"google.golang.org/protobuf/proto"
+)
+
+// Send a consensus message on behalf of a byzantine node
+func sendRaw(t *testing.T, tp transport.Transport, peerId int, msg proto.Message) {
+	op, err := message.NewConsensusOperation(msg)
+	assert.Nil(t, err)
+	assert.Nil(t, tp.SendToPeer(peerId, op))
+}
+
+// Keep consuming a byzantine node's transport so that its peers never block
+func drain(tp transport.Transport) chan struct{} {
+	stopCh := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-tp.Consume():
+			}
+		}
+	}()
+	return stopCh
+}
+
+// Wait until exactly the epochs are kept
+func waitUsage(t *testing.T, usage func() []consensus.EpochUsage, epochs ...uint64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		kept := make([]uint64, 0, len(epochs))
+		for _, u := range usage() {
+			kept = append(kept, u.Epoch)
+		}
+		if assert.ObjectsAreEqual(append([]uint64{}, epochs...), kept) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("epochs %v kept, want %v", kept, epochs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Wait for one delivery of each broadcaster
+func waitDeliveries(t *testing.T, broadcasters map[int]*rbc.Broadcaster) map[int]rbc.Delivery {
+	deliveries := make(map[int]rbc.Delivery)
+	for id, b := range broadcasters {
+		select {
+		case d := <-b.Deliver():
+			deliveries[id] = d
+		case <-time.After(5 * time.Second):
+			t.Fatalf("node %d does not deliver", id)
+		}
+	}
+	return deliveries
+}
+
+func stopBroadcasters(broadcasters map[int]*rbc.Broadcaster, transports map[int]transport.Transport) {
+	for _, b := range broadcasters {
+		b.Stop()
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Do all nodes deliver every honest broadcast?
+func TestRBCAllHonest(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	broadcasters := make(map[int]*rbc.Broadcaster, n)
+	for id, tp := range transports {
+		broadcasters[id] = rbc.NewBroadcaster(n, id, tp)
+	}
+
+	// every node broadcasts in epoch 7
+	for id, b := range broadcasters {
+		b.Broadcast(7, []byte{byte(id)})
+	}
+	for i := 0; i < n; i++ {
+		for id, d := range waitDeliveries(t, broadcasters) {
+			assert.Equal(t, uint64(7), d.Epoch)
+			assert.Equal(t, []byte{byte(d.Proposer)}, d.Value, "node %d", id)
+		}
+	}
+
+	stopBroadcasters(broadcasters, transports)
+}
+
+// Do honest nodes agree when the proposer equivocates?
+func TestRBCEquivocatingProposer(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	broadcasters := make(map[int]*rbc.Broadcaster, n)
+	for id := 2; id <= n; id++ {
+		broadcasters[id] = rbc.NewBroadcaster(n, id, transports[id])
+	}
+	stopDrain := drain(transports[1])
+
+	// byzantine node 1 sends v1 to node 2 and 3, v2 to node 4 and echoes v1
+	v1, v2 := []byte("v1"), []byte("v2")
+	byzantine := transports[1]
+	for id := 2; id <= n; id++ {
+		value := v1
+		if id == 4 {
+			value = v2
+		}
+		sendRaw(t, byzantine, id, &pb.RBCMsg{Type: pb.RBCType_RBCSend, Sender: 1, Epoch: 0, Proposer: 1, Value: value})
+		sendRaw(t, byzantine, id, &pb.RBCMsg{Type: pb.RBCType_RBCEcho, Sender: 1, Epoch: 0, Proposer: 1, Value: v1})
+	}
+
+	for _, d := range waitDeliveries(t, broadcasters) {
+		assert.Equal(t, v1, d.Value)
+	}
+
+	close(stopDrain)
+	stopBroadcasters(broadcasters, transports)
+}
+
+// Does a node that never got SEND still deliver?
+func TestRBCTotality(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	broadcasters := make(map[int]*rbc.Broadcaster, n)
+	for id := 2; id <= n; id++ {
+		broadcasters[id] = rbc.NewBroadcaster(n, id, transports[id])
+	}
+	stopDrain := drain(transports[1])
+
+	// byzantine node 1 ignores node 4, node 4 only sees ECHO from 2 and 3
+	value := []byte("value")
+	byzantine := transports[1]
+	for id := 2; id <= 3; id++ {
+		sendRaw(t, byzantine, id, &pb.RBCMsg{Type: pb.RBCType_RBCSend, Sender: 1, Epoch: 3, Proposer: 1, Value: value})
+		sendRaw(t, byzantine, id, &pb.RBCMsg{Type: pb.RBCType_RBCEcho, Sender: 1, Epoch: 3, Proposer: 1, Value: value})
+	}
+
+	for _, d := range waitDeliveries(t, broadcasters) {
+		assert.Equal(t, value, d.Value)
+		assert.Equal(t, uint64(3), d.Epoch)
+	}
+
+	close(stopDrain)
+	stopBroadcasters(broadcasters, transports)
+}
+
+// Are instances far ahead never created, and dropped once stable?
+func TestRBCBroadcasterWindow(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.Check(t)()
+	n := 4
+	transports := initLocalTransports(n)
+	b := rbc.NewBroadcaster(n, 2, transports[2])
+	stopDrains := []chan struct{}{drain(transports[1]), drain(transports[3]), drain(transports[4])}
+
+	byzantine := transports[1]
+	sendRaw(t, byzantine, 2, &pb.RBCMsg{Type: pb.RBCType_RBCSend, Sender: 1, Epoch: 100, Proposer: 1, Value: []byte("far")})
+	sendRaw(t, byzantine, 2, &pb.RBCMsg{Type: pb.RBCType_RBCSend, Sender: 1, Epoch: 3, Proposer: 1, Value: []byte("near")})
+	waitUsage(t, b.Usage, 3)
+	b.Stable(4)
+	waitUsage(t, b.Usage)
+
+	b.Stop()
+	for _, stopDrain := range stopDrains {
+		close(stopDrain)
+	}
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Are forged SEND messages ignored?
+func TestRBCForgedSend(t *testing.T) {
+	n := 4
+	var net recordingNetwork
+	r := rbc.NewRBC(n, 2, 0, 1, &net)
+
+	// only the proposer can send
+	err := r.HandleMessage(&pb.RBCMsg{Type: pb.RBCType_RBCSend, Sender: 3, Epoch: 0, Proposer: 1, Value: []byte("forged")})
+	assert.NotNil(t, err)
+	assert.Empty(t, net.broadcasts)
+
+	// READY from f nodes is not enough to deliver or amplify
+	err = r.HandleMessage(&pb.RBCMsg{Type: pb.RBCType_RBCReady, Sender: 3, Epoch: 0, Proposer: 1, Value: []byte("forged")})
+	assert.Nil(t, err)
+	err = r.HandleMessage(&pb.RBCMsg{Type: pb.RBCType_RBCReady, Sender: 3, Epoch: 0, Proposer: 1, Value: []byte("forged")})
+	assert.Nil(t, err)
+	_, delivered := r.Output()
+	assert.Equal(t, false, delivered)
+	assert.Empty(t, net.broadcasts)
+
+	// messages of other instances and unknown senders are rejected
+	assert.NotNil(t, r.HandleMessage(&pb.RBCMsg{Type: pb.RBCType_RBCEcho, Sender: 3, Epoch: 1, Proposer: 1}))
+	assert.NotNil(t, r.HandleMessage(&pb.RBCMsg{Type: pb.RBCType_RBCEcho, Sender: 5, Epoch: 0, Proposer: 1}))
+}
+
+// Network recording what a protocol instance sends
+type recordingNetwork struct {
+	broadcasts []proto.Message
+	sends      map[int][]proto.Message
+}
+
+func (rn *recordingNetwork) SendToPeer(peerId int, msg proto.Message) {
+	if rn.sends == nil {
+		rn.sends = make(map[int][]proto.Message)
+	}
+	rn.sends[peerId] = append(rn.sends[peerId], msg)
+}
+
+func (rn *recordingNetwork) Broadcast(msg proto.Message) {
+	rn.broadcasts = append(rn.broadcasts, msg)
+}
@@ -0,0 +1,201 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/message"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/transport"
+	"github.com/stuck/transport/remote"
+)
+
+var _ transport.Transport = (*remote.RemoteTransport)(nil)
+
+// Self-signed certificate and key, pem encoded
+func selfSignedCert(t *testing.T) ([]byte, []byte) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{Organization: []string{"order.org"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	assert.Nil(t, err)
+	privBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes})
+}
+
+// Remote transports on local ports knowing each other's certificates
+func initRemoteTransports(t *testing.T, n int) (map[int]*remote.RemoteTransport, map[int]remote.Peer) {
+	transports := make(map[int]*remote.RemoteTransport, n)
+	peers := make(map[int]remote.Peer, n)
+	for i := 1; i <= n; i++ {
+		certPEM, keyPEM := selfSignedCert(t)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		assert.Nil(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		assert.Nil(t, err)
+		transports[i] = remote.NewRemoteTransport(n, i, "127.0.0.1:0", cert)
+		assert.Nil(t, transports[i].Listen())
+		peers[i] = remote.Peer{Addr: transports[i].Addr(), Cert: leaf}
+	}
+	for _, tp := range transports {
+		tp.AssignPeers(peers)
+		assert.Nil(t, tp.Connect())
+	}
+	return transports, peers
+}
+
+func receiveRemote(t *testing.T, tp transport.Transport) *pb.RBCMsg {
+	select {
+	case v := <-tp.Consume():
+		msg, err := message.Decode(v)
+		assert.Nil(t, err)
+		return msg.(*pb.RBCMsg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+// Do messages reach one peer, every peer and ourselves over TLS?
+func TestRemoteTransport(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 5*time.Second)()
+	transports, _ := initRemoteTransports(t, 3)
+
+	op, err := message.NewConsensusOperation(&pb.RBCMsg{Sender: 1, Epoch: 7})
+	assert.Nil(t, err)
+	assert.Nil(t, transports[1].SendToPeer(2, op))
+	assert.Equal(t, uint64(7), receiveRemote(t, transports[2]).Epoch)
+
+	op, err = message.NewConsensusOperation(&pb.RBCMsg{Sender: 2, Epoch: 8})
+	assert.Nil(t, err)
+	assert.Nil(t, transports[2].Broadcast(op))
+	for id := 1; id <= 3; id++ {
+		assert.Equal(t, int64(2), receiveRemote(t, transports[id]).Sender)
+	}
+
+	// unknown peers and values that cannot be encoded are refused
+	assert.ErrorIs(t, transports[1].SendToPeer(4, op), remote.ErrUnknownPeer)
+	assert.ErrorIs(t, transports[1].SendToPeer(2, "Hello"), remote.ErrUnsupported)
+
+	// a stopped transport sends nothing
+	transports[3].Stop()
+	assert.ErrorIs(t, transports[3].SendToPeer(1, op), remote.ErrAsleep)
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Are nodes whose certificate is not pinned kept out?
+func TestRemoteTransportRejectsStranger(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 5*time.Second)()
+	transports, peers := initRemoteTransports(t, 2)
+
+	// the stranger knows node 2 but claims to be node 1 with a
+	// certificate of its own
+	certPEM, keyPEM := selfSignedCert(t)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.Nil(t, err)
+	stranger := remote.NewRemoteTransport(2, 1, "127.0.0.1:0", cert)
+	stranger.AssignPeers(map[int]remote.Peer{2: peers[2]})
+	assert.Nil(t, stranger.Connect())
+	op, err := message.NewConsensusOperation(&pb.RBCMsg{Sender: 1, Epoch: 9})
+	assert.Nil(t, err)
+	assert.Nil(t, stranger.SendToPeer(2, op))
+	select {
+	case <-transports[2].Consume():
+		t.Fatal("message of a stranger delivered")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	stranger.Stop()
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Are messages naming another sender than the peer dropped?
+func TestRemoteTransportAuthenticatesSender(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 5*time.Second)()
+	transports, _ := initRemoteTransports(t, 3)
+
+	// byzantine node 1 speaks for node 2, then for itself
+	forged, err := message.NewConsensusOperation(&pb.RBCMsg{Sender: 2, Epoch: 1})
+	assert.Nil(t, err)
+	assert.Nil(t, transports[1].SendToPeer(3, forged))
+	genuine, err := message.NewConsensusOperation(&pb.RBCMsg{Sender: 1, Epoch: 2})
+	assert.Nil(t, err)
+	assert.Nil(t, transports[1].SendToPeer(3, genuine))
+
+	msg := receiveRemote(t, transports[3])
+	assert.Equal(t, int64(1), msg.Sender)
+	assert.Equal(t, uint64(2), msg.Epoch)
+	select {
+	case <-transports[3].Consume():
+		t.Fatal("forged message delivered")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// node 2 itself is still heard
+	real, err := message.NewConsensusOperation(&pb.RBCMsg{Sender: 2, Epoch: 3})
+	assert.Nil(t, err)
+	assert.Nil(t, transports[2].SendToPeer(3, real))
+	assert.Equal(t, uint64(3), receiveRemote(t, transports[3]).Epoch)
+
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
+
+// Does a peer that never reads leave the others unaffected, and does it
+// lose only its oldest messages?
+func TestRemoteTransportSlowPeer(t *testing.T) {
+	defer leaktest.CheckTimeout(t, 15*time.Second)()
+	transports, _ := initRemoteTransports(t, 3)
+
+	// more than the queue and the socket buffers of node 2 hold, node 3
+	// reads along and gets every message in order meanwhile
+	total := 6000
+	value := make([]byte, 16<<10)
+	for i := 1; i <= total; i++ {
+		op, err := message.NewConsensusOperation(&pb.RBCMsg{Sender: 1, Epoch: uint64(i), Value: value})
+		assert.Nil(t, err)
+		transports[1].SendToPeer(2, op)
+		assert.Nil(t, transports[1].SendToPeer(3, op))
+		if !assert.Equal(t, uint64(i), receiveRemote(t, transports[3]).Epoch) {
+			break
+		}
+	}
+
+	// node 2 reads late, it gets the latest messages in order
+	last := uint64(0)
+	for last < uint64(total) {
+		epoch := receiveRemote(t, transports[2]).Epoch
+		assert.Greater(t, epoch, last)
+		last = epoch
+	}
+
+	for _, tp := range transports {
+		tp.Stop()
+	}
+}
@@ -0,0 +1,270 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/app"
+	"github.com/stuck/app/kvstore"
+	"github.com/stuck/checkpoint"
+	"github.com/stuck/crypto/tbls/decode"
+	"github.com/stuck/crypto/tbls/identity"
+	pb "github.com/stuck/message/messagepb"
+	"github.com/stuck/node"
+	"github.com/stuck/statesync"
+)
+
+// Snapshot at height certified by the first threshold providers
+func certifiedSnapshot(players []*identity.TBLSCryproProvider, threshold int, height uint64, appHash, state []byte) *pb.SyncSnapshot {
+	data := checkpoint.Data(height, appHash)
+	shares := make([][]byte, 0, threshold)
+	for _, p := range players[:threshold] {
+		shares = append(shares, p.ComputeShare(data))
+	}
+	return &pb.SyncSnapshot{Height: height, AppHash: appHash, State: state, Certificate: players[0].Combine(data, shares)}
+}
+
+// Wait until every node applied the same blocks and holds key-0 to key-(count-1)
+func waitSameState(t *testing.T, nodes map[int]*node.Node, count int) {
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		same := true
+		var reference *node.Node
+		for _, n := range nodes {
+			if reference == nil {
+				reference = n
+			}
+			if n.Height() != reference.Height() || !bytes.Equal(n.AppHash(), reference.AppHash()) {
+				same = false
+			}
+			for i := 0; i < count && same; i++ {
+				if _, err := n.Query(kvstore.PathKey, []byte(fmt.Sprintf("key-%d", i))); err != nil {
+					same = false
+				}
+			}
+		}
+		if same {
+			return
+		}
+		if time.Now().After(deadline) {
+			for id, n := range nodes {
+				t.Errorf("node %d at height %d", id, n.Height())
+			}
+			t.Fatalf("nodes do not reach the same state")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Does a node that missed many epochs rejoin from a snapshot?
+func TestStateSyncRejoin(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	dir, err := ioutil.TempDir("", "node")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	nodes := make(map[int]*node.Node, 4)
+	defer func() {
+		for _, n := range nodes {
+			n.Stop()
+		}
+	}()
+	cfgs := make(map[int]node.Config, 4)
+	for _, path := range writeNodeConfigs(t, dir, 4, node.ProtocolHoneyBadger) {
+		cfg, err := node.LoadConfig(path)
+		assert.Nil(t, err)
+		cfg.SnapshotInterval = 2
+		cfgs[cfg.ID] = cfg
+	}
+	start := func(id int) bool {
+		n, err := node.New(cfgs[id])
+		if !assert.Nil(t, err) {
+			return false
+		}
+		nodes[id] = n
+		return true
+	}
+	submit := func(from, to int) {
+		for i := from; i < to; i++ {
+			for _, n := range nodes {
+				assert.Nil(t, n.Submit([]byte(fmt.Sprintf("key-%d=%d", i, i))))
+			}
+		}
+	}
+
+	// node 4 is offline while the others commit more epochs than it keeps
+	// messages for
+	for id := 1; id < 4; id++ {
+		if !start(id) {
+			return
+		}
+	}
+	for i := 0; i < 24; i++ {
+		submit(i, i+1)
+		waitSameState(t, nodes, i+1)
+	}
+
+	// node 4 comes back with empty state and catches up
+	if !start(4) {
+		return
+	}
+	submit(24, 32)
+	waitSameState(t, nodes, 32)
+
+	// every node holds a checkpoint a client checks with one signature
+	pubShares, err := decode.ReadPubShares(cfgs[1].TBLSPublicKey)
+	assert.Nil(t, err)
+	pubPoly, err := decode.ToPubPoly(pubShares, cfgs[1].T, 4)
+	assert.Nil(t, err)
+	verifier := identity.NewTBLSCryproProviderFromShares(4, cfgs[1].T, nil, pubPoly)
+	for _, n := range nodes {
+		latest := n.Checkpoint()
+		if assert.NotNil(t, latest) {
+			assert.True(t, checkpoint.Verify(verifier, *latest))
+		}
+	}
+}
+
+// Are snapshots without a valid certificate or state, and blocks only
+// one peer sent, rejected?
+func TestSyncerRejectsForgedState(t *testing.T) {
+	n, threshold := 4, 2
+	players := generateProviders(t, n, threshold)
+	var net recordingNetwork
+	kv := kvstore.NewKVStore()
+	sm := app.NewStateMachine(kv)
+	cps := checkpoint.NewCheckpointer(n, 4, 2, &net, players[3], nil)
+	s := statesync.NewSyncer(n, 4, &net, players[3], cps, sm, kv)
+
+	// the state the others reached at height 2
+	other := kvstore.NewKVStore()
+	otherSM := app.NewStateMachine(other)
+	otherSM.Apply([][]byte{[]byte("a=1")})
+	_, appHash := otherSM.Apply([][]byte{[]byte("b=2")})
+	snapshot := certifiedSnapshot(players, threshold, 2, appHash, other.Snapshot())
+	share := func(sender int) *pb.CheckpointMsg {
+		return &pb.CheckpointMsg{
+			Type:      pb.CheckpointType_CheckpointShare,
+			Sender:    int64(sender),
+			Height:    2,
+			StateHash: appHash,
+			Share:     players[sender-1].ComputeShare(checkpoint.Data(2, appHash)),
+		}
+	}
+	response := func(sender int, snapshot *pb.SyncSnapshot, height uint64, blocks ...string) *pb.SyncMsg {
+		msg := &pb.SyncMsg{Type: pb.SyncType_SyncResponse, Sender: int64(sender), Height: height, Snapshot: snapshot}
+		for _, b := range blocks {
+			msg.Blocks = append(msg.Blocks, &pb.TxBatch{Txs: [][]byte{[]byte(b)}})
+		}
+		return msg
+	}
+
+	// a checkpoint one interval ahead asks for the state, forged shares
+	// do not count towards it
+	forgedShare := share(1)
+	forgedShare.Share = players[0].ComputeShare([]byte("other"))
+	assert.ErrorIs(t, s.HandleMessage(forgedShare), checkpoint.ErrInvalidShare)
+	assert.Nil(t, s.HandleMessage(share(1)))
+	assert.Empty(t, net.broadcasts)
+	assert.Nil(t, s.HandleMessage(share(2)))
+	assert.Equal(t, 2, len(net.broadcasts))
+	cert := net.broadcasts[0].(*pb.CheckpointMsg)
+	assert.Equal(t, pb.CheckpointType_CheckpointCert, cert.Type)
+	assert.Equal(t, pb.SyncType_SyncRequest, net.broadcasts[1].(*pb.SyncMsg).Type)
+
+	forgedCert := certifiedSnapshot(players, threshold, 2, []byte("other"), other.Snapshot())
+	forgedCert.AppHash = appHash
+	assert.ErrorIs(t, s.HandleMessage(response(1, forgedCert, 2)), statesync.ErrInvalidSnapshot)
+	forgedState := certifiedSnapshot(players, threshold, 2, appHash, kvstore.NewKVStore().Snapshot())
+	assert.ErrorIs(t, s.HandleMessage(response(2, forgedState, 2)), statesync.ErrInvalidSnapshot)
+	assert.Equal(t, uint64(0), sm.Height())
+	_, err := kv.Query(kvstore.PathKey, []byte("a"))
+	assert.ErrorIs(t, err, kvstore.ErrNotFound)
+
+	// every correct peer answered, the next checkpoint message asks again
+	assert.Nil(t, s.HandleMessage(share(3)))
+	assert.Equal(t, 3, len(net.broadcasts))
+	assert.Nil(t, s.HandleMessage(response(3, snapshot, 2, "c=3")))
+	assert.Equal(t, uint64(2), sm.Height())
+	assert.Equal(t, appHash, sm.AppHash())
+	value, err := kv.Query(kvstore.PathKey, []byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), value)
+
+	// the block after the snapshot needs f+1 peers
+	assert.Equal(t, uint64(2), sm.Height())
+	assert.Nil(t, s.HandleMessage(response(1, nil, 2, "c=3")))
+	assert.Equal(t, uint64(3), sm.Height())
+	assert.Equal(t, []statesync.Block{{Height: 3, Txs: [][]byte{[]byte("c=3")}}}, s.Transferred())
+	assert.Equal(t, snapshot, s.Latest())
+
+	// peers disagreeing on a block keep the request open for the others
+	ahead := []byte("state at 6")
+	for sender := 1; sender <= 2; sender++ {
+		msg := &pb.CheckpointMsg{
+			Type:      pb.CheckpointType_CheckpointShare,
+			Sender:    int64(sender),
+			Height:    6,
+			StateHash: ahead,
+			Share:     players[sender-1].ComputeShare(checkpoint.Data(6, ahead)),
+		}
+		assert.Nil(t, s.HandleMessage(msg))
+	}
+	assert.Equal(t, pb.SyncType_SyncRequest, net.broadcasts[len(net.broadcasts)-1].(*pb.SyncMsg).Type)
+	assert.Nil(t, s.HandleMessage(response(1, nil, 3, "d=4")))
+	assert.Nil(t, s.HandleMessage(response(2, nil, 3, "x=9")))
+	assert.Equal(t, uint64(3), sm.Height())
+	assert.Nil(t, s.HandleMessage(response(3, nil, 3, "d=4")))
+	assert.Equal(t, uint64(4), sm.Height())
+	assert.Equal(t, []statesync.Block{{Height: 4, Txs: [][]byte{[]byte("d=4")}}}, s.Transferred())
+}
+
+// Does a syncer asked to catch up request the blocks after its height
+// once at a time and apply those f+1 peers sent, each at its height?
+func TestSyncerCatchup(t *testing.T) {
+	n, threshold := 4, 2
+	players := generateProviders(t, n, threshold)
+	var net recordingNetwork
+	kv := kvstore.NewKVStore()
+	sm := app.NewStateMachine(kv)
+	cps := checkpoint.NewCheckpointer(n, 4, 8, &net, players[3], nil)
+	s := statesync.NewSyncer(n, 4, &net, players[3], cps, sm, kv)
+	response := func(sender int, height uint64, blocks ...string) *pb.SyncMsg {
+		msg := &pb.SyncMsg{Type: pb.SyncType_SyncResponse, Sender: int64(sender), Height: height}
+		for _, b := range blocks {
+			msg.Blocks = append(msg.Blocks, &pb.TxBatch{Txs: [][]byte{[]byte(b)}})
+		}
+		return msg
+	}
+
+	s.Catchup()
+	s.Catchup()
+	if assert.Equal(t, 1, len(net.broadcasts)) {
+		request := net.broadcasts[0].(*pb.SyncMsg)
+		assert.Equal(t, pb.SyncType_SyncRequest, request.Type)
+		assert.Equal(t, uint64(0), request.Height)
+	}
+	assert.Nil(t, s.HandleMessage(response(1, 0, "a=1", "b=2")))
+	assert.Equal(t, uint64(0), sm.Height())
+	assert.Nil(t, s.HandleMessage(response(2, 0, "a=1", "b=2")))
+	assert.Equal(t, uint64(2), sm.Height())
+	transferred := []statesync.Block{
+		{Height: 1, Txs: [][]byte{[]byte("a=1")}},
+		{Height: 2, Txs: [][]byte{[]byte("b=2")}},
+	}
+	assert.Equal(t, transferred, s.Transferred())
+
+	// every peer answered, the next gap asks again from the new height
+	assert.Nil(t, s.HandleMessage(response(3, 0)))
+	s.Catchup()
+	if assert.Equal(t, 2, len(net.broadcasts)) {
+		assert.Equal(t, uint64(2), net.broadcasts[1].(*pb.SyncMsg).Height)
+	}
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/app/kvstore"
+	"github.com/stuck/crypto/tbls/decode"
+	"github.com/stuck/node"
+)
+
+// Does a generated testnet hold valid configs and run?
+func TestGenerateTestnet(t *testing.T) {
+	// is there a goroutine leak?
+	defer leaktest.CheckTimeout(t, 10*time.Second)()
+	dir, err := ioutil.TempDir("", "testnet")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	opts := node.TestnetOptions{N: 4, Protocol: node.ProtocolHoneyBadger}
+	assert.Nil(t, node.GenerateTestnet(dir, opts, false))
+	assert.NotNil(t, node.GenerateTestnet(dir, opts, false))
+	assert.Nil(t, node.GenerateTestnet(dir, opts, true))
+
+	cluster, err := node.LoadCluster(filepath.Join(dir, node.TestnetClusterFile))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, cluster.T)
+
+	// ports of the generated configs may be taken, run on free ones
+	addrs := freeAddrs(t, 4)
+	nodes := make([]*node.Node, 0, 4)
+	defer func() {
+		for _, n := range nodes {
+			n.Stop()
+		}
+	}()
+	for id := 1; id <= 4; id++ {
+		nodeDir := filepath.Join(dir, fmt.Sprintf("node%d", id))
+		data, err := ioutil.ReadFile(filepath.Join(nodeDir, decode.PriKeyFile))
+		assert.Nil(t, err)
+		var shares []json.RawMessage
+		assert.Nil(t, json.Unmarshal(data, &shares))
+		assert.Equal(t, 1, len(shares), "private key file of node %d holds other shares", id)
+
+		cfg, err := node.LoadConfig(filepath.Join(nodeDir, node.TestnetConfigFile))
+		assert.Nil(t, err)
+		assert.Nil(t, cfg.Validate())
+		assert.Equal(t, filepath.Join(nodeDir, "data"), cfg.DataDir)
+		cfg.Listen, cfg.API = addrs[id-1], ""
+		for i := range cfg.Peers {
+			cfg.Peers[i].Addr = addrs[i]
+		}
+		n, err := node.New(cfg)
+		if !assert.Nil(t, err) {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+	for _, n := range nodes {
+		assert.Nil(t, n.Submit([]byte("key=value")))
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for _, n := range nodes {
+		for {
+			if _, err := n.Query(kvstore.PathKey, []byte("key")); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("transactions not applied, height %d", n.Height())
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// the committed blocks are certified and kept in the data dir
+	for _, n := range nodes {
+		for {
+			if _, err := n.Block(1); err == nil {
+				break
+			}
+			assert.NotErrorIs(t, err, node.ErrNoStore)
+			if time.Now().After(deadline) {
+				t.Fatalf("block 1 not stored, height %d", n.Height())
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// every protocol keeps a data dir
+	for _, protocol := range []string{node.ProtocolPBFT, node.ProtocolHotStuff} {
+		other := filepath.Join(dir, protocol)
+		assert.Nil(t, node.GenerateTestnet(other, node.TestnetOptions{N: 4, Protocol: protocol}, false))
+		cfg, err := node.LoadConfig(filepath.Join(other, "node1", node.TestnetConfigFile))
+		assert.Nil(t, err)
+		assert.Equal(t, filepath.Join(other, "node1", "data"), cfg.DataDir)
+	}
+
+	// a threshold the protocol cannot run with writes nothing
+	other := filepath.Join(dir, "other")
+	opts = node.TestnetOptions{N: 4, T: 2, Protocol: node.ProtocolPBFT}
+	assert.ErrorIs(t, node.GenerateTestnet(other, opts, false), node.ErrConfig)
+	_, err = os.Stat(other)
+	assert.True(t, os.IsNotExist(err))
+}
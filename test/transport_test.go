@@ -30,6 +30,73 @@ func initLocalTransports(n int) map[int]transport.Transport {
 	return peersReturn
 }
 
+// Transport of a node that consumes only the first pass messages, the
+// later ones are held back until Open, as if the node were cut off
+type gatedTransport struct {
+	transport.Transport
+	pass      int
+	passedCh  chan struct{} // closed once pass messages are consumed
+	openCh    chan struct{}
+	consumeCh chan interface{}
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+}
+
+func newGatedTransport(tp transport.Transport, pass int) *gatedTransport {
+	g := &gatedTransport{
+		Transport: tp,
+		pass:      pass,
+		passedCh:  make(chan struct{}),
+		openCh:    make(chan struct{}),
+		consumeCh: make(chan interface{}),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+	go g.run()
+	return g
+}
+
+func (g *gatedTransport) Consume() <-chan interface{} {
+	return g.consumeCh
+}
+
+// Deliver the held messages and every later one
+func (g *gatedTransport) Open() {
+	close(g.openCh)
+}
+
+func (g *gatedTransport) Stop() {
+	close(g.stopCh)
+	<-g.doneCh
+	g.Transport.Stop()
+}
+
+func (g *gatedTransport) run() {
+	defer close(g.doneCh)
+	var held []interface{}
+	passed, open := 0, g.openCh
+	for {
+		var out chan interface{}
+		var next interface{}
+		if len(held) > 0 && (passed < g.pass || open == nil) {
+			out, next = g.consumeCh, held[0]
+		}
+		select {
+		case v := <-g.Transport.Consume():
+			held = append(held, v)
+		case out <- next:
+			held = held[1:]
+			if passed++; passed == g.pass {
+				close(g.passedCh)
+			}
+		case <-open:
+			open = nil
+		case <-g.stopCh:
+			return
+		}
+	}
+}
+
 // Is it possible to send a message to a single node?
 func TestTransportWithSendOne(t *testing.T) {
 	// is there a goroutine leak?
@@ -0,0 +1,42 @@
+package test
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/wal"
+)
+
+// Is a record cut short by the file size limit dropped again, so the next
+// one follows the last complete record?
+func TestWALFailedWrite(t *testing.T) {
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+	w, err := wal.Open(dir, wal.Options{NoSync: true})
+	assert.Nil(t, err)
+	_, err = w.Append([]byte("a"))
+	assert.Nil(t, err)
+
+	// writes beyond the limit fail with EFBIG instead of the signal
+	signal.Ignore(syscall.SIGXFSZ)
+	defer signal.Reset(syscall.SIGXFSZ)
+	var limit syscall.Rlimit
+	assert.Nil(t, syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit))
+	assert.Nil(t, syscall.Setrlimit(syscall.RLIMIT_FSIZE, &syscall.Rlimit{Cur: 16, Max: limit.Max}))
+	_, err = w.Append(make([]byte, 64))
+	assert.Nil(t, syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit))
+	assert.NotNil(t, err)
+
+	index, err := w.Append([]byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), index)
+	assert.Nil(t, w.Close())
+
+	w, err = wal.Open(dir, wal.Options{NoSync: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, replayWAL(t, w))
+	assert.Nil(t, w.Close())
+}
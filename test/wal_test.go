@@ -0,0 +1,200 @@
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stuck/wal"
+)
+
+func tempWALDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "wal")
+	assert.Nil(t, err)
+	return dir
+}
+
+// Records of a log in order
+func replayWAL(t *testing.T, w *wal.WAL) []string {
+	records := make([]string, 0)
+	err := w.Replay(func(index uint64, data []byte) error {
+		assert.Equal(t, uint64(len(records)+1), index)
+		records = append(records, string(data))
+		return nil
+	})
+	assert.Nil(t, err)
+	return records
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	assert.Nil(t, err)
+	return files
+}
+
+// Wait until the first segment of the log in dir is deleted
+func waitTruncated(t *testing.T, dir string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		files := segmentFiles(t, dir)
+		if len(files) > 0 && filepath.Base(files[0]) != fmt.Sprintf("%020d.wal", 1) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("log in %s is not truncated", dir)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Do records survive reopening and keep their indexes across segments?
+func TestWALReplayAfterReopen(t *testing.T) {
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	w, err := wal.Open(dir, wal.Options{SegmentSize: 64, NoSync: true})
+	assert.Nil(t, err)
+	expected := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		record := fmt.Sprintf("record-%d", i)
+		index, err := w.Append([]byte(record))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(i+1), index)
+		expected = append(expected, record)
+	}
+	assert.Nil(t, w.Close())
+	_, err = w.Append([]byte("closed"))
+	assert.ErrorIs(t, err, wal.ErrClosed)
+	assert.Greater(t, len(segmentFiles(t, dir)), 1)
+
+	w, err = wal.Open(dir, wal.Options{SegmentSize: 64, NoSync: true})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, replayWAL(t, w))
+	assert.Equal(t, uint64(21), w.NextIndex())
+	data, err := w.Read(5)
+	assert.Nil(t, err)
+	assert.Equal(t, "record-4", string(data))
+	_, err = w.Read(21)
+	assert.ErrorIs(t, err, wal.ErrNotFound)
+	index, err := w.Append([]byte("record-20"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(21), index)
+	assert.Nil(t, w.Close())
+}
+
+// Are written records kept across segments once synced, and is syncing a
+// closed log refused?
+func TestWALWriteSync(t *testing.T) {
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	w, err := wal.Open(dir, wal.Options{SegmentSize: 64})
+	assert.Nil(t, err)
+	expected := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		record := fmt.Sprintf("written-%d", i)
+		index, err := w.Write([]byte(record))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(i+1), index)
+		expected = append(expected, record)
+	}
+	assert.Nil(t, w.Sync())
+	assert.Nil(t, w.Sync())
+	assert.Nil(t, w.Close())
+	assert.ErrorIs(t, w.Sync(), wal.ErrClosed)
+
+	w, err = wal.Open(dir, wal.Options{SegmentSize: 64})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, replayWAL(t, w))
+	assert.Nil(t, w.Close())
+}
+
+// Is a record torn by a crash dropped while the log stays usable?
+func TestWALTornTail(t *testing.T) {
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	w, err := wal.Open(dir, wal.Options{NoSync: true})
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := w.Append([]byte(fmt.Sprintf("record-%d", i)))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+
+	// cut the last record in half
+	files := segmentFiles(t, dir)
+	assert.Equal(t, 1, len(files))
+	info, err := os.Stat(files[0])
+	assert.Nil(t, err)
+	assert.Nil(t, os.Truncate(files[0], info.Size()-4))
+
+	w, err = wal.Open(dir, wal.Options{NoSync: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"record-0", "record-1"}, replayWAL(t, w))
+	_, err = w.Append([]byte("record-2"))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+
+	w, err = wal.Open(dir, wal.Options{NoSync: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"record-0", "record-1", "record-2"}, replayWAL(t, w))
+	assert.Nil(t, w.Close())
+}
+
+// Is a damaged record before the end reported instead of skipped?
+func TestWALCorruption(t *testing.T) {
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	w, err := wal.Open(dir, wal.Options{NoSync: true})
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := w.Append([]byte(fmt.Sprintf("record-%d", i)))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+
+	// flip a byte of the first record
+	files := segmentFiles(t, dir)
+	content, err := ioutil.ReadFile(files[0])
+	assert.Nil(t, err)
+	content[10] ^= 0xff
+	assert.Nil(t, ioutil.WriteFile(files[0], content, 0600))
+
+	_, err = wal.Open(dir, wal.Options{NoSync: true})
+	assert.ErrorIs(t, err, wal.ErrCorrupt)
+}
+
+// Are whole segments before an index deleted and later records kept?
+func TestWALTruncateBefore(t *testing.T) {
+	dir := tempWALDir(t)
+	defer os.RemoveAll(dir)
+
+	// every record fills a segment
+	w, err := wal.Open(dir, wal.Options{SegmentSize: 1, NoSync: true})
+	assert.Nil(t, err)
+	for i := 0; i < 5; i++ {
+		_, err := w.Append([]byte(fmt.Sprintf("record-%d", i)))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 5, len(segmentFiles(t, dir)))
+	assert.Nil(t, w.TruncateBefore(4))
+	assert.Equal(t, 2, len(segmentFiles(t, dir)))
+	assert.Nil(t, w.Close())
+
+	w, err = wal.Open(dir, wal.Options{SegmentSize: 1, NoSync: true})
+	assert.Nil(t, err)
+	records := make(map[uint64]string)
+	assert.Nil(t, w.Replay(func(index uint64, data []byte) error {
+		records[index] = string(data)
+		return nil
+	}))
+	assert.Equal(t, map[uint64]string{4: "record-3", 5: "record-4"}, records)
+	assert.Equal(t, uint64(6), w.NextIndex())
+	assert.Nil(t, w.Close())
+}
@@ -114,8 +114,13 @@ func (lp *LocalTransport) Connect() error {
 func (lp *LocalTransport) SendToPeer(peerId int, msg interface{}) error {
 	// create channel with buffer to avoid deadlock
 	outToOne := sendWithDest{dest: peerId, msg: msg, err: make(chan error, 1)}
-	lp.sendOneCh <- outToOne
-	err := <-outToOne.err
+	var err error
+	select {
+	case lp.sendOneCh <- outToOne:
+		err = lp.waitErr(outToOne.err)
+	case <-lp.stopCh:
+		err = ErrAsleep
+	}
 	if err != nil {
 		fmt.Printf("[Sender:%d] send msg to [Receiver:%d] error due to : %s.\n", lp.id, peerId, err)
 	}
@@ -126,14 +131,29 @@ func (lp *LocalTransport) SendToPeer(peerId int, msg interface{}) error {
 func (lp *LocalTransport) Broadcast(msg interface{}) error {
 	// create channel with buffer to avoid deadlock
 	outToAll := broadcastWithErr{msg: msg, err: make(chan error, 1)}
-	lp.sendBroadcast <- outToAll
-	err := <-outToAll.err
+	var err error
+	select {
+	case lp.sendBroadcast <- outToAll:
+		err = lp.waitErr(outToAll.err)
+	case <-lp.stopCh:
+		err = ErrAsleep
+	}
 	if err != nil {
 		fmt.Printf("[Sender:%d] broadcast msg error due to : %s.\n", lp.id, err)
 	}
 	return err
 }
 
+// Wait for the result of a send, the run loop may have stopped in between
+func (lp *LocalTransport) waitErr(errCh chan error) error {
+	select {
+	case err := <-errCh:
+		return err
+	case <-lp.stopCh:
+		return ErrAsleep
+	}
+}
+
 // Assign peers to current LocalTransport
 func (lp *LocalTransport) AssignPeers(peers interface{}) {
 	lp.peers = make(map[int]*LocalTransport)
@@ -149,11 +169,15 @@ func (lp *LocalTransport) Stop() {
 
 // Message entrance
 func (lp *LocalTransport) recvFromOtherTransport(msg interface{}) {
+	// a full recvCh must not block the sender forever once we stop
 	select {
 	case <-lp.stopCh:
 		return
 	default:
-		lp.recvCh <- msg
+	}
+	select {
+	case <-lp.stopCh:
+	case lp.recvCh <- msg:
 	}
 }
 
@@ -0,0 +1,82 @@
+package mux
+
+import (
+	"github.com/stuck/message"
+	"github.com/stuck/transport"
+	"google.golang.org/protobuf/proto"
+)
+
+const routeBuffer = 1024 // messages waiting on one route
+
+// Mux shares a transport between protocols that each consume their own
+// messages. One goroutine decodes what the transport delivers and hands it
+// to the first route whose match accepts it, messages no route accepts are
+// dropped. Routes send straight through the shared transport
+type Mux struct {
+	t      transport.Transport
+	routes []*route
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+type route struct {
+	transport.Transport
+	match  func(msg proto.Message) bool
+	recvCh chan interface{}
+}
+
+// Create mux over t, add routes before starting it
+func NewMux(t transport.Transport) *Mux {
+	return &Mux{t: t, stopCh: make(chan struct{}), doneCh: make(chan struct{})}
+}
+
+// Transport consuming the messages match accepts. Its Stop is a no-op, the
+// shared transport is left to the caller
+func (m *Mux) Route(match func(msg proto.Message) bool) transport.Transport {
+	r := &route{Transport: m.t, match: match, recvCh: make(chan interface{}, routeBuffer)}
+	m.routes = append(m.routes, r)
+	return r
+}
+
+// Start consuming the shared transport
+func (m *Mux) Start() {
+	go m.run()
+}
+
+// Stop consuming, the shared transport is left to the caller
+func (m *Mux) Stop() {
+	close(m.stopCh)
+	<-m.doneCh
+}
+
+func (m *Mux) run() {
+	defer close(m.doneCh)
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case v := <-m.t.Consume():
+			msg, err := message.Decode(v)
+			if err != nil {
+				continue
+			}
+			for _, r := range m.routes {
+				if !r.match(msg) {
+					continue
+				}
+				select {
+				case r.recvCh <- v:
+				case <-m.stopCh:
+					return
+				}
+				break
+			}
+		}
+	}
+}
+
+func (r *route) Stop() {}
+
+func (r *route) Consume() <-chan interface{} {
+	return r.recvCh
+}
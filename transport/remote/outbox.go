@@ -0,0 +1,73 @@
+package remote
+
+import "sync"
+
+// Frames waiting for one peer. Pushing never blocks, a full outbox drops
+// its oldest frame instead, so a slow or faulty peer only loses its own
+// messages and gets the latest ones once it reads again
+type outbox struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // signaled when frames are pushed
+	frames  [][]byte
+	first   uint64 // sequence number of frames[0]
+	stopped bool
+}
+
+func newOutbox() *outbox {
+	o := &outbox{}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+// Queue frame, ErrQueueFull if an older frame was dropped for it
+func (o *outbox) push(frame []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.stopped {
+		return ErrAsleep
+	}
+	var err error
+	if len(o.frames) >= queueSize {
+		o.drop()
+		err = ErrQueueFull
+	}
+	o.frames = append(o.frames, frame)
+	o.cond.Broadcast()
+	return err
+}
+
+// Oldest frame and its sequence number, waiting for one, false once stopped
+func (o *outbox) peek() ([]byte, uint64, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for len(o.frames) == 0 && !o.stopped {
+		o.cond.Wait()
+	}
+	if o.stopped {
+		return nil, 0, false
+	}
+	return o.frames[0], o.first, true
+}
+
+// Remove frame seq once written, it may have been dropped meanwhile
+func (o *outbox) pop(seq uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.frames) > 0 && o.first == seq {
+		o.drop()
+	}
+}
+
+func (o *outbox) drop() {
+	o.frames[0] = nil // release the frame held by the backing array
+	o.frames = o.frames[1:]
+	o.first++
+}
+
+func (o *outbox) stop() {
+	o.mu.Lock()
+	o.stopped = true
+	o.frames = nil
+	o.cond.Broadcast()
+	o.mu.Unlock()
+}
@@ -1,4 +1,476 @@
 package remote
 
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/stuck/message"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrAsleep        = errors.New("RemoteTransport is dead")
+	ErrUnknownPeer   = errors.New("unknown peer")
+	ErrQueueFull     = errors.New("send queue is full")
+	ErrUnsupported   = errors.New("message is neither a proto.Message nor []byte")
+	ErrFrameTooLarge = errors.New("frame too large")
+	ErrCertificate   = errors.New("peer presented an unexpected certificate")
+	ErrForgedSender  = errors.New("message claims another sender")
+)
+
+const (
+	maxFrame         = 64 << 20 // bytes in one message
+	queueSize        = 4096     // messages waiting for one peer
+	handshakeTimeout = 10 * time.Second
+	writeTimeout     = 10 * time.Second
+	minRetry         = 50 * time.Millisecond
+	maxRetry         = 5 * time.Second
+)
+
+// Where a peer listens and the certificate it has to present
+type Peer struct {
+	Addr string
+	Cert *x509.Certificate
+}
+
+// RemoteTransport sends messages over TCP with mutual TLS. Both ends of
+// a connection must present the certificate configured for the peer,
+// there is no certificate authority, so only cluster members can connect.
+// Messages are proto encoded and length prefixed, the consumer receives
+// them as []byte. The certificate tells which peer an inbound connection
+// belongs to, consensus messages on it that name another sender are
+// dropped, so protocols can trust their sender field. Each peer has its
+// own queue and connection, sending never waits for a peer, a slow or
+// faulty peer only loses its oldest messages once its queue is full, we
+// dial again with backoff until the transport stops
 type RemoteTransport struct {
+	n        int // total nodes
+	id       int // current node id
+	listen   string
+	cert     tls.Certificate
+	mu       sync.Mutex
+	peers    map[int]Peer
+	senders  map[int]*outbox
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	started  bool
+	closed   bool
+	recvCh   chan interface{}
+	ctx      context.Context // cancels dials in progress
+	cancel   context.CancelFunc
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Create transport of node id listening on listen with cert
+func NewRemoteTransport(n, id int, listen string, cert tls.Certificate) *RemoteTransport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RemoteTransport{
+		n:       n,
+		id:      id,
+		listen:  listen,
+		cert:    cert,
+		peers:   make(map[int]Peer),
+		senders: make(map[int]*outbox),
+		conns:   make(map[net.Conn]struct{}),
+		recvCh:  make(chan interface{}, n*n),
+		ctx:     ctx,
+		cancel:  cancel,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Assign peers, a map[int]Peer, the entry of the node itself is ignored
+func (rt *RemoteTransport) AssignPeers(peers interface{}) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.peers = make(map[int]Peer)
+	for peerId, peer := range peers.(map[int]Peer) {
+		if peerId != rt.id {
+			rt.peers[peerId] = peer
+		}
+	}
+}
+
+// Listen for peers, Connect listens too, calling it first tells the
+// address when listening on port 0
+func (rt *RemoteTransport) Listen() error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.closed {
+		return ErrAsleep
+	}
+	if rt.listener != nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", rt.listen)
+	if err != nil {
+		return err
+	}
+	rt.listener = listener
+	return nil
+}
+
+// Address the transport listens on, empty before Listen
+func (rt *RemoteTransport) Addr() string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.listener == nil {
+		return ""
+	}
+	return rt.listener.Addr().String()
+}
+
+// Accept peers and start a sender for each of them, peers are dialed
+// when the first message for them is sent
+func (rt *RemoteTransport) Connect() error {
+	if err := rt.Listen(); err != nil {
+		return err
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.closed {
+		return ErrAsleep
+	}
+	if rt.started {
+		return nil
+	}
+	rt.started = true
+	rt.wg.Add(1)
+	go rt.accept(rt.listener)
+	for peerId, peer := range rt.peers {
+		queue := newOutbox()
+		rt.senders[peerId] = queue
+		rt.wg.Add(1)
+		go rt.send(peer, queue)
+	}
+	return nil
+}
+
+// Stop accepting and close every connection, messages are dropped from now on
+func (rt *RemoteTransport) Disconnect() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.closed {
+		return
+	}
+	rt.closed = true
+	close(rt.stopCh)
+	rt.cancel()
+	if rt.listener != nil {
+		rt.listener.Close()
+	}
+	for conn := range rt.conns {
+		conn.Close()
+	}
+	for _, queue := range rt.senders {
+		queue.stop()
+	}
+}
+
+// Disconnect and wait for every goroutine of the transport
+func (rt *RemoteTransport) Stop() {
+	rt.Disconnect()
+	rt.wg.Wait()
+}
+
+// Send message to one peer, or to ourselves
+func (rt *RemoteTransport) SendToPeer(peerId int, msg interface{}) error {
+	frame, err := encode(msg)
+	if err == nil {
+		err = rt.enqueue(peerId, frame)
+	}
+	if err != nil {
+		fmt.Printf("[Sender:%d] send msg to [Receiver:%d] error due to : %s.\n", rt.id, peerId, err)
+	}
+	return err
+}
+
+// Broadcast message to every peer and ourselves
+func (rt *RemoteTransport) Broadcast(msg interface{}) error {
+	frame, err := encode(msg)
+	if err != nil {
+		fmt.Printf("[Sender:%d] broadcast msg error due to : %s.\n", rt.id, err)
+		return err
+	}
+	failed := make([]int, 0)
+	for peerId := 1; peerId <= rt.n; peerId++ {
+		if rt.enqueue(peerId, frame) != nil {
+			failed = append(failed, peerId)
+		}
+	}
+	if len(failed) > 0 {
+		err = fmt.Errorf("%v peers are unreachable", failed)
+		fmt.Printf("[Sender:%d] broadcast msg error due to : %s.\n", rt.id, err)
+	}
+	return err
+}
+
+// Return a "read-only" channel of the []byte received
+func (rt *RemoteTransport) Consume() <-chan interface{} {
+	return rt.recvCh
+}
+
+func (rt *RemoteTransport) enqueue(peerId int, frame []byte) error {
+	select {
+	case <-rt.stopCh:
+		return ErrAsleep
+	default:
+	}
+	if peerId == rt.id {
+		rt.deliver(append([]byte(nil), frame...))
+		return nil
+	}
+	rt.mu.Lock()
+	queue, ok := rt.senders[peerId]
+	rt.mu.Unlock()
+	if !ok {
+		return ErrUnknownPeer
+	}
+	return queue.push(frame)
+}
+
+// A full recvCh must not block a reader forever once we stop
+func (rt *RemoteTransport) deliver(msg []byte) bool {
+	select {
+	case rt.recvCh <- msg:
+		return true
+	case <-rt.stopCh:
+		return false
+	}
+}
+
+// Keep a connection open until the transport closes, false if it did
+func (rt *RemoteTransport) track(conn net.Conn) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.closed {
+		conn.Close()
+		return false
+	}
+	rt.conns[conn] = struct{}{}
+	return true
+}
+
+func (rt *RemoteTransport) untrack(conn net.Conn) {
+	rt.mu.Lock()
+	delete(rt.conns, conn)
+	rt.mu.Unlock()
+	conn.Close()
+}
+
+func (rt *RemoteTransport) accept(listener net.Listener) {
+	defer rt.wg.Done()
+	config := &tls.Config{
+		Certificates:          []tls.Certificate{rt.cert},
+		ClientAuth:            tls.RequireAnyClientCert,
+		MinVersion:            tls.VersionTLS12,
+		VerifyPeerCertificate: rt.verifyClient,
+	}
+	for {
+		raw, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		if !rt.track(raw) {
+			return
+		}
+		rt.wg.Add(1)
+		go rt.receive(raw, tls.Server(raw, config))
+	}
+}
+
+// Read frames of one inbound connection until it breaks
+func (rt *RemoteTransport) receive(raw net.Conn, conn *tls.Conn) {
+	defer rt.wg.Done()
+	defer rt.untrack(raw)
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	peerId, ok := rt.peerOf(conn.ConnectionState().PeerCertificates)
+	if !ok {
+		return
+	}
+	for {
+		frame, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		if err := authenticate(frame, peerId); err != nil {
+			fmt.Printf("[Receiver:%d] drop msg from [Sender:%d] due to : %s.\n", rt.id, peerId, err)
+			continue
+		}
+		if !rt.deliver(frame) {
+			return
+		}
+	}
+}
+
+// Frames that are not consensus messages are left to the consumer, they
+// carry no sender to forge
+func authenticate(frame []byte, peerId int) error {
+	msg, err := message.Decode(frame)
+	if err != nil {
+		return nil
+	}
+	if sender, ok := message.Sender(msg); ok && sender != peerId {
+		return fmt.Errorf("%w %d", ErrForgedSender, sender)
+	}
+	return nil
+}
+
+// Write the queue of one peer, dialing again whenever the connection
+// breaks, a frame leaves the queue only once it is written
+func (rt *RemoteTransport) send(peer Peer, queue *outbox) {
+	defer rt.wg.Done()
+	var raw net.Conn
+	var conn *tls.Conn
+	defer func() {
+		if raw != nil {
+			rt.untrack(raw)
+		}
+	}()
+	retry := minRetry
+	for {
+		frame, seq, ok := queue.peek()
+		if !ok {
+			return
+		}
+		for {
+			if conn == nil {
+				raw, conn = rt.dial(peer)
+			}
+			if conn != nil {
+				conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+				if err := writeFrame(conn, frame); err == nil {
+					queue.pop(seq)
+					retry = minRetry
+					break
+				}
+				rt.untrack(raw)
+				raw, conn = nil, nil
+			}
+			select {
+			case <-rt.stopCh:
+				return
+			case <-time.After(retry):
+			}
+			if retry *= 2; retry > maxRetry {
+				retry = maxRetry
+			}
+		}
+	}
+}
+
+// Open a connection to peer, nil if it cannot be reached or is not who
+// it should be
+func (rt *RemoteTransport) dial(peer Peer) (net.Conn, *tls.Conn) {
+	dialer := &net.Dialer{Timeout: handshakeTimeout}
+	raw, err := dialer.DialContext(rt.ctx, "tcp", peer.Addr)
+	if err != nil {
+		return nil, nil
+	}
+	if !rt.track(raw) {
+		return nil, nil
+	}
+	conn := tls.Client(raw, &tls.Config{
+		Certificates: []tls.Certificate{rt.cert},
+		MinVersion:   tls.VersionTLS12,
+		// the certificate is pinned, there is no name or chain to verify
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || peer.Cert == nil || !bytes.Equal(rawCerts[0], peer.Cert.Raw) {
+				return ErrCertificate
+			}
+			return nil
+		},
+	})
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		rt.untrack(raw)
+		return nil, nil
+	}
+	conn.SetDeadline(time.Time{})
+	return raw, conn
+}
+
+// Accept only the certificates of our peers
+func (rt *RemoteTransport) verifyClient(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return ErrCertificate
+	}
+	if _, ok := rt.peerOfRaw(rawCerts[0]); !ok {
+		return ErrCertificate
+	}
+	return nil
+}
+
+// Peer presenting certs, false if it is no peer
+func (rt *RemoteTransport) peerOf(certs []*x509.Certificate) (int, bool) {
+	if len(certs) == 0 {
+		return 0, false
+	}
+	return rt.peerOfRaw(certs[0].Raw)
+}
+
+// Peer pinning the certificate raw, false if none or several peers do
+func (rt *RemoteTransport) peerOfRaw(raw []byte) (int, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	found := 0
+	for peerId, peer := range rt.peers {
+		if peer.Cert != nil && bytes.Equal(raw, peer.Cert.Raw) {
+			if found != 0 {
+				return 0, false
+			}
+			found = peerId
+		}
+	}
+	return found, found != 0
+}
+
+func encode(msg interface{}) ([]byte, error) {
+	switch m := msg.(type) {
+	case []byte:
+		return m, nil
+	case proto.Message:
+		return proto.Marshal(m)
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+func writeFrame(w io.Writer, frame []byte) error {
+	buf := make([]byte, 4+len(frame))
+	binary.BigEndian.PutUint32(buf, uint32(len(frame)))
+	copy(buf[4:], frame)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length > maxFrame {
+		return nil, ErrFrameTooLarge
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
 }
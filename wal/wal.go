@@ -0,0 +1,404 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrCorrupt  = errors.New("write-ahead log is corrupt")
+	ErrClosed   = errors.New("write-ahead log is closed")
+	ErrNotFound = errors.New("no such record")
+	ErrBroken   = errors.New("write-ahead log is broken")
+)
+
+const (
+	headerSize         = 8 // length and checksum
+	segmentSuffix      = ".wal"
+	defaultSegmentSize = 64 << 20
+	maxRecordSize      = 1 << 30
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type Options struct {
+	SegmentSize int64 // a segment is closed once it grows beyond this
+	NoSync      bool  // skip fsync after each append, tests only
+}
+
+// WAL is a write-ahead log of opaque records. Records are numbered from 1
+// and stored as length, CRC-32C and data in segment files named after the
+// index of their first record. A record is durable once Append returns,
+// one written with Write once Sync or a later Append returns.
+// Opening a log drops a torn record at the end of the last segment, left
+// by a crash in the middle of an append, any other damage is ErrCorrupt.
+// A failed write is cut off the segment again, a failed sync leaves the
+// records on disk unknown, the log is then broken and refuses writes until
+// it is opened again
+type WAL struct {
+	dir      string
+	opts     Options
+	mu       sync.Mutex
+	segments []uint64  // first index of every segment, in order
+	offsets  [][]int64 // offset of every record, per segment
+	file     *os.File  // last segment, open for appending
+	size     int64     // of the last segment
+	next     uint64    // index of the next record
+	dirty    bool      // records written since the last sync
+	broken   error     // sticky, the last segment is in an unknown state
+	closed   bool
+}
+
+// Open the log in dir, creating it if needed
+func Open(dir string, opts Options) (*WAL, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = defaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	w := &WAL{dir: dir, opts: opts, next: 1}
+	if err := w.recover(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Append a record, return its index
+func (w *WAL) Append(data []byte) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.append(data, !w.opts.NoSync)
+}
+
+// Write a record without waiting for the disk, return its index
+func (w *WAL) Write(data []byte) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.append(data, false)
+}
+
+// Make the records written so far durable
+func (w *WAL) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	if w.broken != nil {
+		return w.broken
+	}
+	return w.sync()
+}
+
+func (w *WAL) append(data []byte, sync bool) (uint64, error) {
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.broken != nil {
+		return 0, w.broken
+	}
+	if w.file == nil || w.size >= w.opts.SegmentSize {
+		if err := w.roll(); err != nil {
+			return 0, err
+		}
+	}
+
+	record := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(data, crcTable))
+	copy(record[headerSize:], data)
+	if _, err := w.file.Write(record); err != nil {
+		w.cut(err)
+		return 0, err
+	}
+	w.dirty = true
+	if sync {
+		if err := w.sync(); err != nil {
+			return 0, err
+		}
+	}
+	last := len(w.offsets) - 1
+	w.offsets[last] = append(w.offsets[last], w.size)
+	w.size += int64(len(record))
+	index := w.next
+	w.next++
+	return index, nil
+}
+
+// Call fn on every record in order, stop at the first error of fn
+func (w *WAL) Replay(fn func(index uint64, data []byte) error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	for _, first := range w.segments {
+		records, _, err := readSegment(w.path(first))
+		if err != nil {
+			return err
+		}
+		for i, data := range records {
+			if err := fn(first+uint64(i), data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Record at index, ErrNotFound if it is truncated or not written yet
+func (w *WAL) Read(index uint64) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, ErrClosed
+	}
+	i := sort.Search(len(w.segments), func(i int) bool { return w.segments[i] > index }) - 1
+	if i < 0 || index >= w.next {
+		return nil, ErrNotFound
+	}
+	offset := w.offsets[i][index-w.segments[i]]
+
+	file := w.file
+	if i != len(w.segments)-1 {
+		f, err := os.Open(w.path(w.segments[i]))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		file = f
+	}
+	var header [headerSize]byte
+	if _, err := file.ReadAt(header[:], offset); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := file.ReadAt(data, offset+headerSize); err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, fmt.Errorf("%w: bad checksum of record %d", ErrCorrupt, index)
+	}
+	return data, nil
+}
+
+// Index the next record will get
+func (w *WAL) NextIndex() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.next
+}
+
+// Delete the segments whose records all come before index, the log is
+// garbage collected in whole segments
+func (w *WAL) TruncateBefore(index uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	for len(w.segments) > 1 && w.segments[1] <= index {
+		if err := os.Remove(w.path(w.segments[0])); err != nil {
+			return err
+		}
+		w.segments = w.segments[1:]
+		w.offsets = w.offsets[1:]
+	}
+	return nil
+}
+
+// Close the log after syncing it, appending afterwards fails
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	if w.broken != nil {
+		w.file.Close()
+		return w.broken
+	}
+	if err := w.sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Find the segments, check every record and cut a torn tail
+func (w *WAL) recover() error {
+	entries, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		w.segments = append(w.segments, first)
+	}
+	sort.Slice(w.segments, func(i, j int) bool { return w.segments[i] < w.segments[j] })
+
+	for i, first := range w.segments {
+		if first != w.next && i > 0 {
+			return fmt.Errorf("%w: segment %d does not follow record %d", ErrCorrupt, first, w.next-1)
+		}
+		w.next = first
+		records, valid, err := readSegment(w.path(first))
+		last := i == len(w.segments)-1
+		if err != nil && (!last || !errors.Is(err, io.ErrUnexpectedEOF)) {
+			return err
+		}
+		w.next += uint64(len(records))
+		offsets := make([]int64, 0, len(records))
+		var offset int64
+		for _, data := range records {
+			offsets = append(offsets, offset)
+			offset += headerSize + int64(len(data))
+		}
+		w.offsets = append(w.offsets, offsets)
+		if !last {
+			continue
+		}
+
+		// append to the last segment after its last complete record
+		file, err := os.OpenFile(w.path(first), os.O_RDWR, 0600)
+		if err != nil {
+			return err
+		}
+		if err := file.Truncate(valid); err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := file.Seek(valid, io.SeekStart); err != nil {
+			file.Close()
+			return err
+		}
+		w.file = file
+		w.size = valid
+	}
+	return nil
+}
+
+func (w *WAL) sync() error {
+	if !w.dirty || w.opts.NoSync {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		// the kernel may have dropped the pages it failed to write
+		w.broken = fmt.Errorf("%w: %s", ErrBroken, err)
+		return w.broken
+	}
+	w.dirty = false
+	return nil
+}
+
+// Drop what a failed write left after the last record, the log is broken
+// if that fails too
+func (w *WAL) cut(cause error) {
+	if err := w.file.Truncate(w.size); err != nil {
+		w.broken = fmt.Errorf("%w: %s after %s", ErrBroken, err, cause)
+		return
+	}
+	if _, err := w.file.Seek(w.size, io.SeekStart); err != nil {
+		w.broken = fmt.Errorf("%w: %s after %s", ErrBroken, err, cause)
+	}
+}
+
+// Start a new segment at the next index, the records written to the last
+// one are synced first
+func (w *WAL) roll() error {
+	if w.file != nil {
+		if err := w.sync(); err != nil {
+			return err
+		}
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+	file, err := os.OpenFile(w.path(w.next), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if !w.opts.NoSync {
+		// make the new file itself durable
+		if dir, err := os.Open(w.dir); err == nil {
+			dir.Sync()
+			dir.Close()
+		}
+	}
+	w.file = file
+	w.size = 0
+	w.segments = append(w.segments, w.next)
+	w.offsets = append(w.offsets, make([]int64, 0))
+	return nil
+}
+
+func (w *WAL) path(first uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", first, segmentSuffix))
+}
+
+// Records of a segment and the size of its valid prefix. A record cut
+// short by the end of the file is io.ErrUnexpectedEOF, a bad checksum is
+// ErrCorrupt unless nothing but zeros follows, which is a torn write too
+func readSegment(path string) ([][]byte, int64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	records := make([][]byte, 0)
+	var offset int64
+	for offset < int64(len(content)) {
+		rest := content[offset:]
+		if len(rest) < headerSize {
+			return records, offset, io.ErrUnexpectedEOF
+		}
+		size := binary.BigEndian.Uint32(rest[0:4])
+		if size > maxRecordSize {
+			return records, offset, fmt.Errorf("%w: record of %d bytes in %s", ErrCorrupt, size, path)
+		}
+		if int64(len(rest)) < headerSize+int64(size) {
+			return records, offset, io.ErrUnexpectedEOF
+		}
+		data := rest[headerSize : headerSize+int(size)]
+		if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(rest[4:8]) {
+			if allZero(rest) {
+				return records, offset, io.ErrUnexpectedEOF
+			}
+			return records, offset, fmt.Errorf("%w: bad checksum at offset %d of %s", ErrCorrupt, offset, path)
+		}
+		records = append(records, data)
+		offset += headerSize + int64(size)
+	}
+	return records, offset, nil
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}